@@ -0,0 +1,103 @@
+// Package aggregator holds the seamless wallet protocol spoken with game
+// aggregators. The aggregator calls the wallet back for every balance check,
+// stake, payout and refund of its players and signs each call with the
+// secret of the provider it calls for.
+package aggregator
+
+import (
+	"github.com/Oloruntobi1/qgdc/internal/models"
+)
+
+// paths of the callbacks, relative to the wallet base url
+const (
+	BalancePath = "/provider/balance"
+	DebitPath   = "/provider/debit"
+	CreditPath  = "/provider/credit"
+	RefundPath  = "/provider/refund"
+	JackpotPath = "/provider/jackpot"
+)
+
+const (
+	StatusOK    = "OK"
+	StatusError = "ERROR"
+)
+
+// error codes of a response whose status is StatusError
+const (
+	ErrorCodeInvalidSignature    = "INVALID_SIGNATURE"
+	ErrorCodeInvalidRequest      = "INVALID_REQUEST"
+	ErrorCodePlayerNotFound      = "PLAYER_NOT_FOUND"
+	ErrorCodeInsufficientFunds   = "INSUFFICIENT_FUNDS"
+	ErrorCodeLimitExceeded       = "LIMIT_EXCEEDED"
+	ErrorCodePlayerRestricted    = "PLAYER_RESTRICTED"
+	ErrorCodeOperationBlocked    = "OPERATION_BLOCKED"
+	ErrorCodeRoundNotFound       = "ROUND_NOT_FOUND"
+	ErrorCodeTransactionNotFound = "TRANSACTION_NOT_FOUND"
+	ErrorCodeRoundClosed         = "ROUND_CLOSED"
+	ErrorCodeTransactionConflict = "TRANSACTION_CONFLICT"
+	ErrorCodeJackpotNotFound     = "JACKPOT_NOT_FOUND"
+	ErrorCodeInternalError       = "INTERNAL_ERROR"
+)
+
+// BalanceRequest asks for the balance of the player wallet in Currency
+type BalanceRequest struct {
+	PlayerID int64  `json:"player_id" binding:"required,min=1"`
+	Currency string `json:"currency" binding:"required,len=3"`
+}
+
+// TransactionRequest is sent for debits, which take a stake, and credits,
+// which pay out a win. TransactionID is the id of the call on the
+// aggregator side, a call that is retried with it does not move funds
+// twice.
+type TransactionRequest struct {
+	PlayerID      int64        `json:"player_id" binding:"required,min=1"`
+	Currency      string       `json:"currency" binding:"required,len=3"`
+	RoundID       string       `json:"round_id" binding:"required,max=128"`
+	TransactionID string       `json:"transaction_id" binding:"required,max=128"`
+	Amount        models.Money `json:"amount"`
+	// Category is the game category of a debit, it decides the loyalty
+	// points the stake earns. It is ignored on credits.
+	Category string `json:"category,omitempty" binding:"max=32"`
+}
+
+// RefundRequest gives back the stake of the debit with RefTransactionID
+type RefundRequest struct {
+	PlayerID         int64  `json:"player_id" binding:"required,min=1"`
+	Currency         string `json:"currency" binding:"required,len=3"`
+	RoundID          string `json:"round_id" binding:"required,max=128"`
+	TransactionID    string `json:"transaction_id" binding:"required,max=128"`
+	RefTransactionID string `json:"ref_transaction_id" binding:"required,max=128"`
+}
+
+// JackpotHitRequest pays the jackpot pool JackpotID to the player, the
+// game server sends it when a round of the player hits the jackpot
+type JackpotHitRequest struct {
+	JackpotID     int64  `json:"jackpot_id" binding:"required,min=1"`
+	PlayerID      int64  `json:"player_id" binding:"required,min=1"`
+	Currency      string `json:"currency" binding:"required,len=3"`
+	RoundID       string `json:"round_id" binding:"required,max=128"`
+	TransactionID string `json:"transaction_id" binding:"required,max=128"`
+}
+
+// Response answers every callback. Balance is the balance the player can
+// spend after the call.
+type Response struct {
+	Status        string `json:"status"`
+	Balance       string `json:"balance,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	// Amount is what a jackpot hit paid out
+	Amount string `json:"amount,omitempty"`
+	// Duplicate is set when the call was a retry of one already processed
+	Duplicate bool   `json:"duplicate,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func ErrorResponse(code string, err error) Response {
+	return Response{
+		Status:    StatusError,
+		ErrorCode: code,
+		Message:   err.Error(),
+	}
+}
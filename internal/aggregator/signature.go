@@ -0,0 +1,59 @@
+package aggregator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// headers of a signed provider callback
+const (
+	ProviderHeader  = "X-Provider-Id"
+	TimestampHeader = "X-Timestamp"
+	SignatureHeader = "X-Signature"
+)
+
+var (
+	ErrInvalidSecrets = errors.New("provider secrets must be a comma separated list of provider:secret pairs")
+)
+
+// Secrets maps the id of a game provider to the secret it signs its
+// callbacks with
+type Secrets map[string]string
+
+// ParseSecrets reads secrets written as "acme:secret1,studio:secret2". An
+// empty spec means no provider is allowed to call the wallet.
+func ParseSecrets(spec string) (Secrets, error) {
+	secrets := Secrets{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, ErrInvalidSecrets
+		}
+		secrets[parts[0]] = parts[1]
+	}
+	return secrets, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and the raw
+// request body. The timestamp is part of the signature so a captured
+// callback cannot be replayed once it is too old.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature was made by Sign with secret
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	expected := Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
@@ -0,0 +1,32 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSecrets(t *testing.T) {
+	secrets, err := ParseSecrets(" acme:s3cret, studio:a:b ,")
+	require.NoError(t, err)
+	require.Equal(t, Secrets{"acme": "s3cret", "studio": "a:b"}, secrets)
+
+	secrets, err = ParseSecrets("")
+	require.NoError(t, err)
+	require.Empty(t, secrets)
+
+	_, err = ParseSecrets("acme")
+	require.ErrorIs(t, err, ErrInvalidSecrets)
+	_, err = ParseSecrets("acme:")
+	require.ErrorIs(t, err, ErrInvalidSecrets)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"player_id":1,"currency":"USD"}`)
+	signature := Sign("s3cret", "1700000000", body)
+
+	require.True(t, Verify("s3cret", "1700000000", body, signature))
+	require.False(t, Verify("other", "1700000000", body, signature))
+	require.False(t, Verify("s3cret", "1700000001", body, signature))
+	require.False(t, Verify("s3cret", "1700000000", []byte(`{"player_id":2,"currency":"USD"}`), signature))
+}
@@ -0,0 +1,89 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Simulator plays the aggregator side of the protocol against a wallet,
+// typically one served by httptest.NewServer. It signs its callbacks the
+// way a real aggregator does so the whole path from the signature check to
+// the ledger can be exercised in tests.
+type Simulator struct {
+	BaseURL  string
+	Provider string
+	Secret   string
+	Client   *http.Client
+	// Now stamps the callbacks, it can be moved to simulate stale or
+	// replayed calls
+	Now func() time.Time
+}
+
+func NewSimulator(baseURL, provider, secret string) *Simulator {
+	return &Simulator{
+		BaseURL:  baseURL,
+		Provider: provider,
+		Secret:   secret,
+		Client:   http.DefaultClient,
+		Now:      time.Now,
+	}
+}
+
+// Result is a callback response together with its http status
+type Result struct {
+	Response
+	StatusCode int
+}
+
+func (s *Simulator) Balance(ctx context.Context, req BalanceRequest) (*Result, error) {
+	return s.call(ctx, BalancePath, req)
+}
+
+func (s *Simulator) Debit(ctx context.Context, req TransactionRequest) (*Result, error) {
+	return s.call(ctx, DebitPath, req)
+}
+
+func (s *Simulator) Credit(ctx context.Context, req TransactionRequest) (*Result, error) {
+	return s.call(ctx, CreditPath, req)
+}
+
+func (s *Simulator) Refund(ctx context.Context, req RefundRequest) (*Result, error) {
+	return s.call(ctx, RefundPath, req)
+}
+
+func (s *Simulator) HitJackpot(ctx context.Context, req JackpotHitRequest) (*Result, error) {
+	return s.call(ctx, JackpotPath, req)
+}
+
+// call signs and posts body to path
+func (s *Simulator) call(ctx context.Context, path string, body interface{}) (*Result, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(s.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(ProviderHeader, s.Provider)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(s.Secret, timestamp, data))
+
+	response, err := s.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	result := &Result{StatusCode: response.StatusCode}
+	if err := json.NewDecoder(response.Body).Decode(&result.Response); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
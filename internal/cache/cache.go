@@ -14,6 +14,10 @@ type Cacher interface {
 	Get(context context.Context, key string) (string, error)
 	Set(context context.Context, key string, value string, duration time.Duration) error
 	Delete(context context.Context, key string) error
+	// CompareAndSwap sets key to value for duration if it still holds old,
+	// an old value that is empty stands for a key that is not set. It
+	// reports whether key was set.
+	CompareAndSwap(context context.Context, key string, old string, value string, duration time.Duration) (bool, error)
 }
 
 func GetCurrentCache(cacheSystem string) Cacher {
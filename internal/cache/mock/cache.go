@@ -35,6 +35,21 @@ func (m *MockCacher) EXPECT() *MockCacherMockRecorder {
 	return m.recorder
 }
 
+// CompareAndSwap mocks base method.
+func (m *MockCacher) CompareAndSwap(arg0 context.Context, arg1, arg2, arg3 string, arg4 time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSwap", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSwap indicates an expected call of CompareAndSwap.
+func (mr *MockCacherMockRecorder) CompareAndSwap(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSwap", reflect.TypeOf((*MockCacher)(nil).CompareAndSwap), arg0, arg1, arg2, arg3, arg4)
+}
+
 // Delete mocks base method.
 func (m *MockCacher) Delete(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -10,6 +10,17 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// compareAndSwapScript sets KEYS[1] to ARGV[2] for ARGV[3] milliseconds if
+// it holds ARGV[1], a key that is not set holds an empty value
+var compareAndSwapScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1]) or ""
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
 type RedisCache struct {
 	client *redis.Client
 }
@@ -63,3 +74,8 @@ func (c *RedisCache) Set(ctx context.Context, key string, value string, duration
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
+
+func (c *RedisCache) CompareAndSwap(ctx context.Context, key string, old string, value string, duration time.Duration) (bool, error) {
+	swapped, err := compareAndSwapScript.Run(ctx, c.client, []string{key}, old, value, duration.Milliseconds()).Int()
+	return swapped == 1, err
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+)
+
+// bonusExpiryActor is recorded on the entries of grants removed on expiry
+const bonusExpiryActor = "system"
+
+// bonusGrantPosting credits the grant to the bonus funds of its wallet
+func bonusGrantPosting(grant *models.BonusGrant, txn *models.Transaction) *Posting {
+	txn.BonusAmount = grant.Amount
+	return &Posting{
+		WalletID:    grant.WalletID,
+		Amount:      grant.Amount,
+		Bonus:       grant.Amount,
+		Transaction: txn,
+	}
+}
+
+// removeBonusGrant moves the grant to status and returns the posting that
+// takes what is left of it out of the wallet. The removal happens whatever
+// the balance of the wallet is, there is no journal entry when nothing was
+// left.
+func removeBonusGrant(grant *models.BonusGrant, status models.BonusStatus, txn *models.Transaction, now time.Time) (*Posting, error) {
+	if grant.Status != models.BonusStatusActive {
+		return nil, util.ErrBonusNotActive
+	}
+	grant.Status = status
+	grant.UpdatedAt = now
+	if grant.Remaining.IsZero() {
+		txn = nil
+	} else {
+		txn.BonusAmount = grant.Remaining
+	}
+	return &Posting{
+		WalletID:    grant.WalletID,
+		Amount:      grant.Remaining.Neg(),
+		Bonus:       grant.Remaining.Neg(),
+		Constraint:  AllowNegativeBalance,
+		Transaction: txn,
+	}, nil
+}
+
+// bonusExpiryTransaction is the journal entry removing what is left of an
+// expired grant, it is linked to the grant through its correlation id
+func bonusExpiryTransaction(grant *models.BonusGrant) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          models.TransactionTypeBonusExpiry,
+		Reference:     grant.Reference,
+		CorrelationID: grant.UUID.String(),
+		Actor:         bonusExpiryActor,
+	}
+}
+
+// allocateWager pays the stake of p out of the grants and the real money of
+// wallet in the debit order of each grant, and counts it toward their
+// wagering requirements. grants are the grants of the wallet that were
+// active at now, they are updated in place. A grant whose requirement is
+// met is completed and what is left of it becomes real money.
+func allocateWager(wallet *models.Wallet, grants []*models.BonusGrant, p *Posting, now time.Time) {
+	// grants closest to their expiry are used up first
+	sortGrantsByExpiry(grants)
+
+	stake := p.Amount.Neg()
+	unpaid := stake
+	fromBonus := models.ZeroMoney
+	spend := func(order models.BonusDebitOrder) {
+		for _, grant := range grants {
+			if grant.DebitOrder != order || grant.Status != models.BonusStatusActive {
+				continue
+			}
+			taken := minMoney(grant.Remaining, unpaid)
+			grant.Remaining = grant.Remaining.Sub(taken)
+			grant.UpdatedAt = now
+			fromBonus = fromBonus.Add(taken)
+			unpaid = unpaid.Sub(taken)
+		}
+	}
+	spend(models.BonusDebitOrderBonusFirst)
+	if real := wallet.RealBalance(); real.IsPositive() {
+		unpaid = unpaid.Sub(minMoney(real, unpaid))
+	}
+	// anything still unpaid after this has no funds behind it and fails the
+	// balance check of the posting
+	spend(models.BonusDebitOrderRealFirst)
+
+	converted := models.ZeroMoney
+	wager := stake
+	for _, grant := range grants {
+		if grant.Status != models.BonusStatusActive || !wager.IsPositive() {
+			continue
+		}
+		counted := minMoney(grant.WageringRequirement.Sub(grant.Wagered), wager)
+		grant.Wagered = grant.Wagered.Add(counted)
+		grant.UpdatedAt = now
+		wager = wager.Sub(counted)
+		if !grant.Wagered.LessThan(grant.WageringRequirement) {
+			grant.Status = models.BonusStatusCompleted
+			converted = converted.Add(grant.Remaining)
+		}
+	}
+
+	p.Bonus = p.Bonus.Sub(fromBonus).Sub(converted)
+	p.Transaction.BonusAmount = fromBonus
+}
+
+// refundWager gives the bonus funds of the stake p refunds back to the
+// grants of wallet they were paid out of and takes the stake off their
+// wagering progress. grants are the grants of the wallet that were active
+// at now, they are updated in place. Bonus funds whose grant is no longer
+// active are not given back, the posting it returns takes them out of the
+// wallet again. It returns nil when there are none.
+func refundWager(grants []*models.BonusGrant, p *Posting, now time.Time) *Posting {
+	sortGrantsByExpiry(grants)
+
+	unrestored := p.Bonus
+	for _, grant := range grants {
+		if !unrestored.IsPositive() {
+			break
+		}
+		restored := minMoney(grant.Amount.Sub(grant.Remaining), unrestored)
+		grant.Remaining = grant.Remaining.Add(restored)
+		grant.UpdatedAt = now
+		unrestored = unrestored.Sub(restored)
+	}
+	// the stake was counted toward the grants closest to their expiry
+	// first, it is taken off the others first
+	wager := p.Amount
+	for i := len(grants) - 1; i >= 0 && wager.IsPositive(); i-- {
+		grant := grants[i]
+		counted := minMoney(grant.Wagered, wager)
+		grant.Wagered = grant.Wagered.Sub(counted)
+		grant.UpdatedAt = now
+		wager = wager.Sub(counted)
+	}
+
+	if !unrestored.IsPositive() {
+		return nil
+	}
+	refund := p.Transaction
+	return &Posting{
+		WalletID:   p.WalletID,
+		Amount:     unrestored.Neg(),
+		Bonus:      unrestored.Neg(),
+		Constraint: AllowNegativeBalance,
+		Transaction: &models.Transaction{
+			UUID:          uuid.New(),
+			Type:          models.TransactionTypeBonusForfeit,
+			BonusAmount:   unrestored,
+			Reference:     refund.Reference,
+			CorrelationID: refund.CorrelationID,
+			Actor:         refund.Actor,
+		},
+	}
+}
+
+// sortGrantsByExpiry orders grants the way stakes are paid out of them,
+// the grants closest to their expiry first
+func sortGrantsByExpiry(grants []*models.BonusGrant) {
+	sort.SliceStable(grants, func(i, j int) bool {
+		if grants[i].ExpiresAt.Equal(grants[j].ExpiresAt) {
+			return grants[i].ID < grants[j].ID
+		}
+		return grants[i].ExpiresAt.Before(grants[j].ExpiresAt)
+	})
+}
+
+func minMoney(a, b models.Money) models.Money {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
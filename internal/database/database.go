@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -8,7 +9,6 @@ import (
 	"github.com/Oloruntobi1/qgdc/internal/models"
 
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
 )
 
 // merge models.User and models.Wallet
@@ -16,30 +16,296 @@ type UserWallet struct {
 	ID   int64     `json:"id"`
 	UUID uuid.UUID `json:"uuid"`
 	// Saving the password in clear text for YOUR testing purpose via Postman etc.
-	Password          string          `json:"password"`
-	HashedPassword    string          `json:"hashed_password"`
-	FullName          string          `json:"full_name"`
-	Email             string          `json:"email"`
-	PasswordChangedAt *time.Time      `json:"password_changed_at"`
-	CreatedAt         time.Time       `json:"created_at"`
-	UpdatedAt         time.Time       `json:"updated_at"`
-	WalletID          int64           `json:"wallet_id"`
-	WalletBalance     decimal.Decimal `json:"wallet_balance"`
+	Password          string       `json:"password"`
+	HashedPassword    string       `json:"hashed_password"`
+	FullName          string       `json:"full_name"`
+	Email             string       `json:"email"`
+	PasswordChangedAt *time.Time   `json:"password_changed_at"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	WalletID          int64        `json:"wallet_id"`
+	WalletCurrency    string       `json:"wallet_currency"`
+	WalletBalance     models.Money `json:"wallet_balance"`
+}
+
+// TransactionFilter narrows down the journal entries returned for a wallet.
+// Entries are returned newest first and BeforeID acts as the pagination
+// cursor, only entries with a smaller id are returned when it is set.
+type TransactionFilter struct {
+	WalletID  int64
+	Type      models.TransactionType
+	From      *time.Time
+	To        *time.Time
+	MinAmount *models.Money
+	MaxAmount *models.Money
+	BeforeID  int64
+	Limit     int
 }
 
 type Reader interface {
 	GetWallet(id int64) (*models.Wallet, error)
 	GetWalletByUserID(userID int64) (*models.Wallet, error)
+	GetWalletsByUserID(userID int64) ([]*models.Wallet, error)
 	GetAllWallets() ([]*models.Wallet, error)
+	GetUser(id int64) (*models.User, error)
 	GetUserByEmail(email string) (*models.User, error)
+	// GetRestrictionEvents returns the audit record of the restrictions of
+	// a user, oldest first
+	GetRestrictionEvents(userID int64) ([]*models.RestrictionEvent, error)
 	GetAllUsers() ([]*UserWallet, error)
+	GetTransaction(id int64) (*models.Transaction, error)
+	GetTransactionsByWallet(filter *TransactionFilter) ([]*models.Transaction, error)
+	// GetReversals returns the entries that reversed part or all of the
+	// transaction with originalID, oldest first
+	GetReversals(originalID int64) ([]*models.Transaction, error)
+	GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error)
+	GetHold(id int64) (*models.Hold, error)
+	GetHoldsByWallet(walletID int64, status models.HoldStatus) ([]*models.Hold, error)
+	GetFXQuote(id int64) (*models.FXQuote, error)
+	GetGameRound(provider, roundID string) (*models.GameRound, error)
+	GetBonusGrant(id int64) (*models.BonusGrant, error)
+	GetBonusGrantsByWallet(walletID int64, status models.BonusStatus) ([]*models.BonusGrant, error)
+	// GetGamingLimits returns the responsible gaming limits set on a wallet,
+	// including the changes to them that are still pending
+	GetGamingLimits(walletID int64) ([]*models.GamingLimit, error)
+	GetTournament(id int64) (*models.Tournament, error)
+	// GetTournamentEntries returns the entries of a tournament in the order
+	// they were made
+	GetTournamentEntries(tournamentID int64) ([]*models.TournamentEntry, error)
+	GetMatch(id int64) (*models.Match, error)
+	GetDeposit(id int64) (*models.Deposit, error)
+	// GetDeposits returns the deposits with status, every deposit when
+	// status is empty, oldest first
+	GetDeposits(status models.DepositStatus) ([]*models.Deposit, error)
+	GetWithdrawal(id int64) (*models.Withdrawal, error)
+	// GetFeeSchedules returns the fee schedules ordered by operation and
+	// currency
+	GetFeeSchedules() ([]*models.FeeSchedule, error)
+	// GetFeeSchedule fails with util.ErrFeeScheduleNotFound when no fee is
+	// charged on operation in currency
+	GetFeeSchedule(operation models.FeeOperation, currency string) (*models.FeeSchedule, error)
+	// GetVelocityRules returns the rules of wallets first, ordered by wallet,
+	// then the rules of tiers ordered by tier and currency
+	GetVelocityRules() ([]*models.VelocityRule, error)
+	// GetVelocityRule returns the rule of the wallet, or else the rule of
+	// tier in currency, it fails with util.ErrVelocityRuleNotFound when
+	// neither has a rule
+	GetVelocityRule(walletID int64, tier, currency string) (*models.VelocityRule, error)
+	// GetLoginEvents returns the attempts to log in as email since, newest
+	// first
+	GetLoginEvents(email string, since time.Time) ([]*models.LoginEvent, error)
+	GetFraudReview(id int64) (*models.FraudReview, error)
+	// GetFraudReviews returns the reviews with status, or all of them when
+	// status is empty, oldest first so they are reviewed in order
+	GetFraudReviews(status models.FraudReviewStatus) ([]*models.FraudReview, error)
+	// GetWithdrawals returns the withdrawals with status, or all of them
+	// when status is empty, oldest first so they are reviewed in order
+	GetWithdrawals(status models.WithdrawalStatus) ([]*models.Withdrawal, error)
+	// GetWithdrawalTransitions returns the history of a withdrawal, oldest
+	// first
+	GetWithdrawalTransitions(withdrawalID int64) ([]*models.WithdrawalTransition, error)
+	GetJackpotPool(id int64) (*models.JackpotPool, error)
+	// GetJackpotEvents returns the history of a pool, newest first, at most
+	// limit events
+	GetJackpotEvents(poolID int64, limit int) ([]*models.JackpotEvent, error)
+	// GetVoucherByCode matches code case insensitively
+	GetVoucherByCode(code string) (*models.Voucher, error)
+	GetVoucherRedemptions(voucherID int64) ([]*models.VoucherRedemption, error)
+	// GetLoyaltyRules returns the earning rules ordered by category and
+	// currency
+	GetLoyaltyRules() ([]*models.LoyaltyRule, error)
+	GetLoyaltyAccount(userID int64) (*models.LoyaltyAccount, error)
+	// GetLoyaltyEntries returns the loyalty ledger of a user, newest first,
+	// at most limit entries
+	GetLoyaltyEntries(userID int64, limit int) ([]*models.LoyaltyEntry, error)
+	// GetGameRoundActions returns the bets, wins and rollbacks of a round,
+	// oldest first
+	GetGameRoundActions(gameRoundID int64) ([]*models.GameRoundAction, error)
 }
 
 type Updater interface {
 	CreateUser(user *models.User) error
+	// PromoteAdmin makes the user with email an admin. It fails with
+	// util.ErrUserNotFound if there is none.
+	PromoteAdmin(email string) (*models.User, error)
+	// RestrictUser imposes the restriction event describes on the user and
+	// appends the event to the audit record in a single step. It fails with
+	// util.ErrRestrictionShortened if it would end a restriction in force
+	// earlier.
+	RestrictUser(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error)
+	// LiftRestriction ends the restriction in force on the user and appends
+	// event to the audit record in a single step. It fails with
+	// util.ErrUserNotRestricted if there is none.
+	LiftRestriction(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error)
+	// CreateWallet fails with util.ErrWalletExists if the user already has a
+	// wallet in the same currency
 	CreateWallet(wallet *models.Wallet) (int64, error)
 	UpdateWallet(wallet *models.Wallet) (*models.Wallet, error)
+	// AdjustBalance adds delta to the wallet balance and appends txn to the
+	// transaction journal in a single step. The read, check and write happen
+	// under the wallet's lock so concurrent adjustments can never overdraw
+	// the wallet or overwrite each other.
+	AdjustBalance(ctx context.Context, walletID int64, delta models.Money, constraint BalanceConstraint, txn *models.Transaction) (*models.Wallet, error)
+	// ApplyPostings applies every posting in a single step, either all of
+	// them succeed or none do. It fails with util.ErrInsufficientFunds if a
+	// posting breaks its balance constraint, and with
+	// util.ErrDepositLimitExceeded, util.ErrLossLimitExceeded or
+	// util.ErrWagerLimitExceeded if a credit or a stake would take its wallet
+	// over one of its gaming limits. Stakes are paid out of the bonus
+	// grants of their wallet in the debit order of each grant and count
+	// toward their wagering requirements. The wallets are returned in the
+	// order they were first posted to.
+	ApplyPostings(ctx context.Context, postings ...*Posting) ([]*models.Wallet, error)
+	// CreateHold reserves the hold amount on its wallet and saves the hold.
+	// It fails with util.ErrInsufficientFunds if the available balance of the
+	// wallet is lower than the amount.
+	CreateHold(ctx context.Context, hold *models.Hold) (*models.Wallet, error)
+	// CaptureHold debits amount, which may be less than the held amount, and
+	// gives the rest of the reservation back to the wallet
+	CaptureHold(ctx context.Context, holdID int64, amount models.Money, txn *models.Transaction) (*models.Hold, *models.Wallet, error)
+	ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, *models.Wallet, error)
+	// ExpireHolds releases every active hold that expired at or before now
+	// and returns how many were expired
+	ExpireHolds(ctx context.Context, now time.Time) (int64, error)
+	// ReverseTransaction appends txn to compensate amount of the transaction
+	// with originalID, or all of what is left to reverse of it when amount is
+	// nil. The total reversed can never exceed the original amount.
+	ReverseTransaction(ctx context.Context, originalID int64, amount *models.Money, txn *models.Transaction) (*models.Wallet, error)
+	CreateFXQuote(quote *models.FXQuote) error
+	// ExecuteFXQuote converts the quoted amount between the two wallets of
+	// the quote and marks it executed in a single step. It fails with
+	// util.ErrQuoteExpired or util.ErrQuoteExecuted if the quote cannot be
+	// used any more. The source wallet is returned first, then the target
+	// wallet and the wallet the fee was credited to, if any.
+	ExecuteFXQuote(ctx context.Context, quoteID int64, debit, credit *models.Transaction) (*models.FXQuote, []*models.Wallet, error)
+	// ApplyGameRoundAction places a bet, pays a win or rolls back a bet of
+	// the round roundID of the action provider on the wallet, and saves the
+	// action in a single step. The round is started by its first bet. An
+	// action whose provider transaction id was already processed changes
+	// nothing and returns the first outcome with Duplicate set, it fails with
+	// util.ErrGameRoundActionConflict if it is not the same action. A bet
+	// contributes to the jackpot pools of its provider and currency.
+	ApplyGameRoundAction(ctx context.Context, walletID int64, roundID string, action *models.GameRoundAction, txn *models.Transaction) (*RoundResult, error)
+	// CreateBonusGrant credits the grant to the bonus funds of its wallet
+	// and saves it
+	CreateBonusGrant(ctx context.Context, grant *models.BonusGrant, txn *models.Transaction) (*models.Wallet, error)
+	// ForfeitBonusGrant takes what is left of an active grant out of its
+	// wallet
+	ForfeitBonusGrant(ctx context.Context, grantID int64, txn *models.Transaction) (*models.BonusGrant, *models.Wallet, error)
+	// ExpireBonusGrants removes what is left of every active grant that
+	// expired at or before now and returns how many expired
+	ExpireBonusGrants(ctx context.Context, now time.Time) (int64, error)
+	// SetGamingLimit applies change to the limit of its kind and period on
+	// the wallet. It fails with util.ErrGamingLimitNotFound when asked to
+	// lift a limit that is not set.
+	SetGamingLimit(ctx context.Context, change *LimitChange) (*models.GamingLimit, error)
+	CreateTournament(t *models.Tournament) error
+	// EnterTournament moves the entry fee of the tournament from the wallet
+	// of entry into the escrow wallet of the tournament and saves the entry
+	// in a single step. It fails with util.ErrTournamentAlreadyEntered if the
+	// wallet entered before and util.ErrTournamentNotOpen once the tournament
+	// closed. The wallet of the entry is returned.
+	EnterTournament(ctx context.Context, entry *models.TournamentEntry, actor string) (*models.Tournament, *models.Wallet, error)
+	// CloseTournament pays the prize pool of the tournament out of escrow to
+	// placements, the wallets of the best placed entrants with the winner
+	// first, and the rake to the rake wallet in a single step
+	CloseTournament(ctx context.Context, tournamentID int64, placements []int64, actor string) (*models.Tournament, []*models.TournamentEntry, error)
+	// CancelTournament refunds every entry fee out of escrow in a single step
+	CancelTournament(ctx context.Context, tournamentID int64, actor string) (*models.Tournament, []*models.TournamentEntry, error)
+	// StartMatch moves the stake of both players of the match into escrow
+	// and saves the match in a single step. A match started again under its
+	// reference changes nothing and is returned with Duplicate set, it
+	// fails with util.ErrMatchConflict if it is not the same match.
+	StartMatch(ctx context.Context, m *models.Match) (*MatchResult, error)
+	// FinishMatch settles, draws or aborts a started match and pays the pot
+	// out of escrow in a single step. A match finished again the same way
+	// changes nothing and is returned with Duplicate set, it fails with
+	// util.ErrMatchFinished once the match ended another way.
+	FinishMatch(ctx context.Context, outcome *MatchOutcome) (*MatchResult, error)
+	CreateDeposit(d *models.Deposit) error
+	// SetDepositProviderReference records the id the payment provider gave
+	// the deposit
+	SetDepositProviderReference(id int64, providerReference string) error
+	// ConfirmDeposit ends the pending deposit with the reference of c and
+	// credits its wallet if the payment succeeded in a single step. A
+	// deposit confirmed again the same way changes nothing and is returned
+	// with Duplicate set, it fails with util.ErrDepositFinished once the
+	// deposit ended another way. A credit that would break a deposit limit
+	// is not made, the deposit is left with models.DepositStatusRefundDue
+	// for its payment to be refunded by hand.
+	ConfirmDeposit(ctx context.Context, c *DepositConfirmation) (*DepositResult, error)
+	// RefundDeposit records that actor refunded the payment of a deposit
+	// refused by a deposit limit, it fails with util.ErrDepositNoRefund
+	// unless the deposit is waiting for its refund
+	RefundDeposit(ctx context.Context, id int64, actor string) (*models.Deposit, error)
+	// CheckGamingLimits fails like ApplyPostings if postings would take a
+	// wallet over one of its gaming limits, without applying them. Nothing
+	// is locked, so it only tells an operation that is bound to be refused
+	// from one that may go through.
+	CheckGamingLimits(ctx context.Context, postings ...*Posting) error
+	// RequestWithdrawal holds the withdrawal amount on its wallet and saves
+	// the withdrawal requested, with its first transition, in a single step.
+	// Only real money that is not held yet can be withdrawn.
+	RequestWithdrawal(ctx context.Context, w *models.Withdrawal) (*models.Wallet, error)
+	// SetFeeSchedule saves the schedule, replacing the schedule of the same
+	// operation and currency
+	SetFeeSchedule(s *models.FeeSchedule) error
+	RemoveFeeSchedule(operation models.FeeOperation, currency string) error
+	// SetVelocityRule saves the rule, replacing the rule of the same wallet,
+	// or of the same tier and currency
+	SetVelocityRule(rule *models.VelocityRule) error
+	RemoveVelocityRule(id int64) error
+	RecordLogin(event *models.LoginEvent) error
+	CreateFraudReview(review *models.FraudReview) error
+	// MoveFraudReview moves the review to the status of c, the operation of
+	// an approving review is applied by the caller. It fails with
+	// util.ErrFraudReviewResolved when the review was approved or denied
+	// and util.ErrFraudReviewTransition when it cannot move to the status.
+	MoveFraudReview(ctx context.Context, c *FraudReviewChange) (*models.FraudReview, error)
+	// MoveWithdrawal moves the withdrawal to the status of c, records the
+	// transition and debits the held amount or gives it back in a single
+	// step. It fails with util.ErrWithdrawalTransition when the withdrawal
+	// cannot move to that status from its current one.
+	MoveWithdrawal(ctx context.Context, c *WithdrawalChange) (*WithdrawalResult, error)
+	// CreateJackpotPool saves the pool and moves its seed from the seed
+	// wallet into the pool wallet in a single step
+	CreateJackpotPool(ctx context.Context, pool *models.JackpotPool, actor string) error
+	// HitJackpot pays the pool to the winner and resets it to its seed in a
+	// single step. A hit sent again is answered with the outcome of the
+	// first one, or fails with util.ErrJackpotHitConflict if it differs.
+	HitJackpot(ctx context.Context, hit *JackpotHit) (*JackpotHitResult, error)
+	// CreateVoucher fails with util.ErrVoucherCodeExists if the code is
+	// taken
+	CreateVoucher(v *models.Voucher) error
+	// RedeemVoucher credits the voucher with code to the wallet of the
+	// redemption and saves the redemption in a single step. Redemptions of
+	// one voucher are serialized, so it is never redeemed twice by a player,
+	// more than once when it is single use or beyond its budget.
+	RedeemVoucher(ctx context.Context, code string, redemption *models.VoucherRedemption, txn *models.Transaction) (*models.Voucher, *models.Wallet, error)
+	// SetLoyaltyRule saves the rule, replacing the rule of the same category
+	// and currency
+	SetLoyaltyRule(rule *models.LoyaltyRule) error
+	// AccrueLoyaltyPoints credits the points earned by the wagers written to
+	// the journal since the last accrual to the accounts of their players,
+	// looking at wagers made before until and at most limit of them. It
+	// runs in the background so stakes never wait on it, and returns how
+	// many wagers it looked at.
+	AccrueLoyaltyPoints(ctx context.Context, until time.Time, limit int) (int, error)
+	// ExpireLoyaltyPoints removes the points of every account last active at
+	// or before inactiveSince and returns how many accounts expired
+	ExpireLoyaltyPoints(ctx context.Context, inactiveSince time.Time) (int64, error)
+	// RedeemLoyaltyPoints takes the points of the redemption off the account
+	// of its user and credits what they are worth to its wallet in a single
+	// step. It fails with util.ErrInsufficientLoyaltyPoints if the account
+	// holds fewer points.
+	RedeemLoyaltyPoints(ctx context.Context, redemption *LoyaltyRedemption, txn *models.Transaction) (*models.LoyaltyAccount, *models.Wallet, error)
 	DeleteWallet(id int64) error
+	// CreateIdempotencyKey returns util.ErrIdempotencyKeyExists if the
+	// scope already has a record for the key
+	CreateIdempotencyKey(key *models.IdempotencyKey) error
+	UpdateIdempotencyKey(key *models.IdempotencyKey) error
+	DeleteIdempotencyKey(id int64) error
+	DeleteExpiredIdempotencyKeys(now time.Time) (int64, error)
 }
 
 type Seeder interface {
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+)
+
+// DepositConfirmation is what the payment provider told us about the
+// deposit with Reference, Status is models.DepositStatusSucceeded or
+// models.DepositStatusFailed
+type DepositConfirmation struct {
+	Reference         string
+	ProviderReference string
+	Status            models.DepositStatus
+	Amount            models.Money
+	Currency          string
+	// Reason explains a failed payment
+	Reason string
+}
+
+// DepositResult is the outcome of confirming a deposit
+type DepositResult struct {
+	Deposit *models.Deposit
+	// Wallet is the credited wallet, it is only set when the deposit just
+	// succeeded
+	Wallet *models.Wallet
+	// Duplicate is set when the deposit had already been confirmed the same
+	// way, nothing was changed
+	Duplicate bool
+}
+
+// depositTransaction is the credit of a deposit, it carries the deposit
+// reference as its reference and the id of the payment at the provider as
+// its correlation id
+func depositTransaction(d *models.Deposit) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          models.TransactionTypeCredit,
+		Reference:     d.Reference,
+		CorrelationID: d.ProviderReference,
+		Actor:         "payments:" + d.Provider,
+	}
+}
+
+// depositConfirmedAs reports whether the deposit already ended the way c
+// says, a deposit refused by a deposit limit was paid
+func depositConfirmedAs(d *models.Deposit, c *DepositConfirmation) bool {
+	switch d.Status {
+	case models.DepositStatusRefundDue, models.DepositStatusRefunded:
+		return c.Status == models.DepositStatusSucceeded
+	default:
+		return d.Status == c.Status
+	}
+}
+
+// confirmDeposit ends the pending deposit the way c says and returns the
+// posting crediting the wallet when the payment succeeded, there is none
+// when it failed. A payment that is not for the amount and currency of the
+// deposit is not credited and leaves the deposit pending.
+func confirmDeposit(d *models.Deposit, c *DepositConfirmation, now time.Time) (*Posting, error) {
+	if d.Status != models.DepositStatusPending {
+		return nil, util.ErrDepositFinished
+	}
+	// the webhook can come in before the provider reference was saved
+	if d.ProviderReference == "" {
+		d.ProviderReference = c.ProviderReference
+	}
+	switch c.Status {
+	case models.DepositStatusSucceeded:
+		if c.Currency != d.Currency || !c.Amount.Equal(d.Amount) {
+			return nil, util.ErrDepositMismatch
+		}
+		d.Status = models.DepositStatusSucceeded
+		d.CompletedAt = &now
+		d.UpdatedAt = now
+		return &Posting{
+			WalletID:    d.WalletID,
+			Currency:    d.Currency,
+			Amount:      d.Amount,
+			Transaction: depositTransaction(d),
+		}, nil
+	case models.DepositStatusFailed:
+		failDeposit(d, c.Reason, now)
+		return nil, nil
+	default:
+		return nil, util.ErrDepositFinished
+	}
+}
+
+// failDeposit ends the deposit without crediting it
+func failDeposit(d *models.Deposit, reason string, now time.Time) {
+	d.Status = models.DepositStatusFailed
+	d.FailureReason = reason
+	d.CompletedAt = &now
+	d.UpdatedAt = now
+}
+
+// refuseDeposit ends the deposit without crediting it although the player
+// paid, the payment waits to be refunded by hand
+func refuseDeposit(d *models.Deposit, reason string, now time.Time) {
+	d.Status = models.DepositStatusRefundDue
+	d.FailureReason = reason
+	d.CompletedAt = &now
+	d.UpdatedAt = now
+}
+
+// refundDeposit records that the payment of the deposit was refunded by
+// actor
+func refundDeposit(d *models.Deposit, actor string, now time.Time) error {
+	if d.Status != models.DepositStatusRefundDue {
+		return util.ErrDepositNoRefund
+	}
+	d.Status = models.DepositStatusRefunded
+	d.RefundedBy = actor
+	d.RefundedAt = &now
+	d.UpdatedAt = now
+	return nil
+}
+
+// isDepositLimitError reports whether err is a credit refused by a deposit
+// limit. The player already paid and nothing takes the money back on its
+// own, so the deposit is refused and waits to be refunded by hand rather
+// than the webhook being retried forever.
+func isDepositLimitError(err error) bool {
+	return errors.Is(err, util.ErrDepositLimitExceeded)
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"github.com/Oloruntobi1/qgdc/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// FeePostings charge fee to the wallet walletID and credit it to the
+// revenue wallet, both in currency, there are none when fee is zero. They
+// are applied together with the operation the fee is charged on.
+func FeePostings(walletID, revenueWalletID int64, currency string, fee models.Money, charge, credit *models.Transaction) []*Posting {
+	if !fee.IsPositive() {
+		return nil
+	}
+	return []*Posting{
+		{
+			WalletID:    walletID,
+			Currency:    currency,
+			Amount:      fee.Neg(),
+			Transaction: charge,
+		},
+		{
+			WalletID:    revenueWalletID,
+			Currency:    currency,
+			Amount:      fee,
+			Transaction: credit,
+		},
+	}
+}
+
+// feeTransaction is a leg of the fee charged on the operation journaled by
+// txn, it carries the same reference and correlation id
+func feeTransaction(txn *models.Transaction) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          models.TransactionTypeFee,
+		Reference:     txn.Reference,
+		CorrelationID: txn.CorrelationID,
+		Actor:         txn.Actor,
+	}
+}
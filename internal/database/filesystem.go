@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/Oloruntobi1/qgdc/internal/models"
 )
@@ -43,6 +45,10 @@ func (fs *FileSystem) GetWalletByUserID(id int64) (*models.Wallet, error) {
 	return nil, nil
 }
 
+func (fs *FileSystem) GetWalletsByUserID(userID int64) ([]*models.Wallet, error) {
+	return nil, nil
+}
+
 func (fs *FileSystem) GetAllWallets() ([]*models.Wallet, error) {
 	return nil, nil
 }
@@ -55,11 +61,170 @@ func (fs *FileSystem) GetAllUsers() ([]*UserWallet, error) {
 	return nil, nil
 }
 
+func (fs *FileSystem) GetTransaction(id int64) (*models.Transaction, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetTransactionsByWallet(filter *TransactionFilter) ([]*models.Transaction, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetHold(id int64) (*models.Hold, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetHoldsByWallet(walletID int64, status models.HoldStatus) ([]*models.Hold, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetReversals(originalID int64) ([]*models.Transaction, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetFXQuote(id int64) (*models.FXQuote, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetGameRound(provider, roundID string) (*models.GameRound, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetGameRoundActions(gameRoundID int64) ([]*models.GameRoundAction, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetBonusGrant(id int64) (*models.BonusGrant, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetBonusGrantsByWallet(walletID int64, status models.BonusStatus) ([]*models.BonusGrant, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetGamingLimits(walletID int64) ([]*models.GamingLimit, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetUser(id int64) (*models.User, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetRestrictionEvents(userID int64) ([]*models.RestrictionEvent, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetTournament(id int64) (*models.Tournament, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetTournamentEntries(tournamentID int64) ([]*models.TournamentEntry, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetJackpotPool(id int64) (*models.JackpotPool, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetJackpotEvents(poolID int64, limit int) ([]*models.JackpotEvent, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetMatch(id int64) (*models.Match, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetDeposit(id int64) (*models.Deposit, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetWithdrawal(id int64) (*models.Withdrawal, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetFeeSchedules() ([]*models.FeeSchedule, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetFeeSchedule(operation models.FeeOperation, currency string) (*models.FeeSchedule, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetVelocityRule(walletID int64, tier, currency string) (*models.VelocityRule, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetVelocityRules() ([]*models.VelocityRule, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetLoginEvents(email string, since time.Time) ([]*models.LoginEvent, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetFraudReview(id int64) (*models.FraudReview, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetFraudReviews(status models.FraudReviewStatus) ([]*models.FraudReview, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetDeposits(status models.DepositStatus) ([]*models.Deposit, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetWithdrawals(status models.WithdrawalStatus) ([]*models.Withdrawal, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetWithdrawalTransitions(withdrawalID int64) ([]*models.WithdrawalTransition, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetVoucherByCode(code string) (*models.Voucher, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetVoucherRedemptions(voucherID int64) ([]*models.VoucherRedemption, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetLoyaltyRules() ([]*models.LoyaltyRule, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetLoyaltyAccount(userID int64) (*models.LoyaltyAccount, error) {
+	return nil, nil
+}
+
+func (fs *FileSystem) GetLoyaltyEntries(userID int64, limit int) ([]*models.LoyaltyEntry, error) {
+	return nil, nil
+}
+
 // implement Updater interface
 func (fs *FileSystem) CreateUser(user *models.User) error {
 	return nil
 }
 
+// promote admin
+func (fs *FileSystem) PromoteAdmin(email string) (*models.User, error) {
+	return nil, nil
+}
+
+// restrict user
+func (fs *FileSystem) RestrictUser(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error) {
+	return nil, nil
+}
+
+// lift restriction
+func (fs *FileSystem) LiftRestriction(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error) {
+	return nil, nil
+}
+
 // create wallet
 func (fs *FileSystem) CreateWallet(wallet *models.Wallet) (int64, error) {
 	return 0, nil
@@ -70,11 +235,289 @@ func (fs *FileSystem) UpdateWallet(wallet *models.Wallet) (*models.Wallet, error
 	return nil, nil
 }
 
+// adjust balance
+func (fs *FileSystem) AdjustBalance(
+	ctx context.Context,
+	walletID int64,
+	delta models.Money,
+	constraint BalanceConstraint,
+	txn *models.Transaction,
+) (*models.Wallet, error) {
+	return nil, nil
+}
+
+// apply postings
+func (fs *FileSystem) ApplyPostings(ctx context.Context, postings ...*Posting) ([]*models.Wallet, error) {
+	return nil, nil
+}
+
+// create hold
+func (fs *FileSystem) CreateHold(ctx context.Context, hold *models.Hold) (*models.Wallet, error) {
+	return nil, nil
+}
+
+// capture hold
+func (fs *FileSystem) CaptureHold(
+	ctx context.Context,
+	holdID int64,
+	amount models.Money,
+	txn *models.Transaction,
+) (*models.Hold, *models.Wallet, error) {
+	return nil, nil, nil
+}
+
+// release hold
+func (fs *FileSystem) ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, *models.Wallet, error) {
+	return nil, nil, nil
+}
+
+// expire holds
+func (fs *FileSystem) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+// create bonus grant
+func (fs *FileSystem) CreateBonusGrant(ctx context.Context, grant *models.BonusGrant, txn *models.Transaction) (*models.Wallet, error) {
+	return nil, nil
+}
+
+// forfeit bonus grant
+func (fs *FileSystem) ForfeitBonusGrant(ctx context.Context, grantID int64, txn *models.Transaction) (*models.BonusGrant, *models.Wallet, error) {
+	return nil, nil, nil
+}
+
+// expire bonus grants
+func (fs *FileSystem) ExpireBonusGrants(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+// set gaming limit
+func (fs *FileSystem) SetGamingLimit(ctx context.Context, change *LimitChange) (*models.GamingLimit, error) {
+	return nil, nil
+}
+
+// create tournament
+func (fs *FileSystem) CreateTournament(t *models.Tournament) error {
+	return nil
+}
+
+// enter tournament
+func (fs *FileSystem) EnterTournament(
+	ctx context.Context,
+	entry *models.TournamentEntry,
+	actor string,
+) (*models.Tournament, *models.Wallet, error) {
+	return nil, nil, nil
+}
+
+// close tournament
+func (fs *FileSystem) CloseTournament(
+	ctx context.Context,
+	tournamentID int64,
+	placements []int64,
+	actor string,
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	return nil, nil, nil
+}
+
+// cancel tournament
+func (fs *FileSystem) CancelTournament(
+	ctx context.Context,
+	tournamentID int64,
+	actor string,
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	return nil, nil, nil
+}
+
+// start match
+func (fs *FileSystem) StartMatch(ctx context.Context, m *models.Match) (*MatchResult, error) {
+	return nil, nil
+}
+
+// finish match
+func (fs *FileSystem) FinishMatch(ctx context.Context, outcome *MatchOutcome) (*MatchResult, error) {
+	return nil, nil
+}
+
+// create deposit
+func (fs *FileSystem) CreateDeposit(d *models.Deposit) error {
+	return nil
+}
+
+// set deposit provider reference
+func (fs *FileSystem) SetDepositProviderReference(id int64, providerReference string) error {
+	return nil
+}
+
+// confirm deposit
+func (fs *FileSystem) ConfirmDeposit(ctx context.Context, c *DepositConfirmation) (*DepositResult, error) {
+	return nil, nil
+}
+
+// refund deposit
+func (fs *FileSystem) RefundDeposit(ctx context.Context, id int64, actor string) (*models.Deposit, error) {
+	return nil, nil
+}
+
+// check gaming limits
+func (fs *FileSystem) CheckGamingLimits(ctx context.Context, postings ...*Posting) error {
+	return nil
+}
+
+// set fee schedule
+func (fs *FileSystem) SetFeeSchedule(s *models.FeeSchedule) error {
+	return nil
+}
+
+// remove fee schedule
+func (fs *FileSystem) RemoveFeeSchedule(operation models.FeeOperation, currency string) error {
+	return nil
+}
+
+// set velocity rule
+func (fs *FileSystem) SetVelocityRule(rule *models.VelocityRule) error {
+	return nil
+}
+
+// remove velocity rule
+func (fs *FileSystem) RemoveVelocityRule(id int64) error {
+	return nil
+}
+
+// record login
+func (fs *FileSystem) RecordLogin(event *models.LoginEvent) error {
+	return nil
+}
+
+// create fraud review
+func (fs *FileSystem) CreateFraudReview(review *models.FraudReview) error {
+	return nil
+}
+
+// move fraud review
+func (fs *FileSystem) MoveFraudReview(ctx context.Context, c *FraudReviewChange) (*models.FraudReview, error) {
+	return nil, nil
+}
+
+// request withdrawal
+func (fs *FileSystem) RequestWithdrawal(ctx context.Context, w *models.Withdrawal) (*models.Wallet, error) {
+	return nil, nil
+}
+
+// move withdrawal
+func (fs *FileSystem) MoveWithdrawal(ctx context.Context, c *WithdrawalChange) (*WithdrawalResult, error) {
+	return nil, nil
+}
+
+// create jackpot pool
+func (fs *FileSystem) CreateJackpotPool(ctx context.Context, pool *models.JackpotPool, actor string) error {
+	return nil
+}
+
+// hit jackpot
+func (fs *FileSystem) HitJackpot(ctx context.Context, hit *JackpotHit) (*JackpotHitResult, error) {
+	return nil, nil
+}
+
+// create voucher
+func (fs *FileSystem) CreateVoucher(v *models.Voucher) error {
+	return nil
+}
+
+// redeem voucher
+func (fs *FileSystem) RedeemVoucher(
+	ctx context.Context,
+	code string,
+	redemption *models.VoucherRedemption,
+	txn *models.Transaction,
+) (*models.Voucher, *models.Wallet, error) {
+	return nil, nil, nil
+}
+
+// set loyalty rule
+func (fs *FileSystem) SetLoyaltyRule(rule *models.LoyaltyRule) error {
+	return nil
+}
+
+// accrue loyalty points
+func (fs *FileSystem) AccrueLoyaltyPoints(ctx context.Context, until time.Time, limit int) (int, error) {
+	return 0, nil
+}
+
+// expire loyalty points
+func (fs *FileSystem) ExpireLoyaltyPoints(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	return 0, nil
+}
+
+// redeem loyalty points
+func (fs *FileSystem) RedeemLoyaltyPoints(
+	ctx context.Context,
+	redemption *LoyaltyRedemption,
+	txn *models.Transaction,
+) (*models.LoyaltyAccount, *models.Wallet, error) {
+	return nil, nil, nil
+}
+
+// apply game round action
+func (fs *FileSystem) ApplyGameRoundAction(
+	ctx context.Context,
+	walletID int64,
+	roundID string,
+	action *models.GameRoundAction,
+	txn *models.Transaction,
+) (*RoundResult, error) {
+	return nil, nil
+}
+
+// reverse transaction
+func (fs *FileSystem) ReverseTransaction(
+	ctx context.Context,
+	originalID int64,
+	amount *models.Money,
+	txn *models.Transaction,
+) (*models.Wallet, error) {
+	return nil, nil
+}
+
+// create fx quote
+func (fs *FileSystem) CreateFXQuote(quote *models.FXQuote) error {
+	return nil
+}
+
+// execute fx quote
+func (fs *FileSystem) ExecuteFXQuote(
+	ctx context.Context,
+	quoteID int64,
+	debit, credit *models.Transaction,
+) (*models.FXQuote, []*models.Wallet, error) {
+	return nil, nil, nil
+}
+
 // delete wallet
 func (fs *FileSystem) DeleteWallet(id int64) error {
 	return nil
 }
 
+// create idempotency key
+func (fs *FileSystem) CreateIdempotencyKey(key *models.IdempotencyKey) error {
+	return nil
+}
+
+// update idempotency key
+func (fs *FileSystem) UpdateIdempotencyKey(key *models.IdempotencyKey) error {
+	return nil
+}
+
+// delete idempotency key
+func (fs *FileSystem) DeleteIdempotencyKey(id int64) error {
+	return nil
+}
+
+// delete expired idempotency keys
+func (fs *FileSystem) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	return 0, nil
+}
+
 // implement Seeder interface
 func (fs *FileSystem) Seed() {
 
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// FraudReviewChange moves the review with ReviewID to Status on behalf of
+// Reviewer
+type FraudReviewChange struct {
+	ReviewID int64
+	Status   models.FraudReviewStatus
+	Reviewer string
+	Note     string
+}
+
+// moveFraudReview moves the review the way c says. A review that goes
+// back to pending is free to be reviewed again by anyone.
+func moveFraudReview(r *models.FraudReview, c *FraudReviewChange, now time.Time) error {
+	if !r.Status.CanMoveTo(c.Status) {
+		if r.Status.IsFinal() {
+			return util.ErrFraudReviewResolved
+		}
+		return util.ErrFraudReviewTransition
+	}
+	r.Status = c.Status
+	r.Reviewer = c.Reviewer
+	r.Note = c.Note
+	r.ReviewedAt = nil
+	if c.Status.IsFinal() {
+		r.ReviewedAt = &now
+	}
+	if c.Status == models.FraudReviewStatusPending {
+		r.Reviewer = ""
+		r.Note = ""
+	}
+	r.UpdatedAt = now
+	return nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// executeQuote marks the quote executed and returns the postings that
+// debit the source wallet and credit the target wallet at the quoted rate,
+// and charge the fee of the quote
+func executeQuote(quote *models.FXQuote, debit, credit *models.Transaction, now time.Time) ([]*Posting, error) {
+	if quote.ExecutedAt != nil {
+		return nil, util.ErrQuoteExecuted
+	}
+	if !quote.IsExecutable(now) {
+		return nil, util.ErrQuoteExpired
+	}
+	quote.ExecutedAt = &now
+	postings := []*Posting{
+		{
+			WalletID:    quote.FromWalletID,
+			Currency:    quote.FromCurrency,
+			Amount:      quote.Amount.Sub(quote.Fee).Neg(),
+			Transaction: debit,
+		},
+		{
+			WalletID:    quote.ToWalletID,
+			Currency:    quote.ToCurrency,
+			Amount:      quote.ConvertedAmount,
+			Transaction: credit,
+		},
+	}
+	fees := FeePostings(quote.FromWalletID, quote.FeeWalletID, quote.FromCurrency, quote.Fee, feeTransaction(debit), feeTransaction(debit))
+	return append(postings, fees...), nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+)
+
+// RoundResult is the outcome of a game round action
+type RoundResult struct {
+	Round  *models.GameRound
+	Action *models.GameRoundAction
+	Wallet *models.Wallet
+	// Duplicate is set when the provider transaction id had already been
+	// processed, nothing was changed and the outcome of the first attempt is
+	// returned
+	Duplicate bool
+}
+
+// newGameRound starts the round a first bet on wallet is placed on
+func newGameRound(wallet *models.Wallet, provider, roundID string, now time.Time) *models.GameRound {
+	return &models.GameRound{
+		UUID:      uuid.New(),
+		WalletID:  wallet.ID,
+		Provider:  provider,
+		RoundID:   roundID,
+		Currency:  wallet.CurrencyCode(),
+		Status:    models.GameRoundStatusOpen,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// checkRepeatedRoundAction makes sure an action sent again under the
+// provider transaction id of stored is the same action, a provider must not
+// reuse its ids for something else
+func checkRepeatedRoundAction(
+	stored *models.GameRoundAction,
+	round *models.GameRound,
+	walletID int64,
+	roundID string,
+	action *models.GameRoundAction,
+) error {
+	if stored.Type != action.Type || round.WalletID != walletID || round.RoundID != roundID {
+		return util.ErrGameRoundActionConflict
+	}
+	if action.Type == models.GameRoundActionRollback {
+		if stored.RollbackOf != action.RollbackOf {
+			return util.ErrGameRoundActionConflict
+		}
+		return nil
+	}
+	if !stored.Amount.Equal(action.Amount) {
+		return util.ErrGameRoundActionConflict
+	}
+	return nil
+}
+
+// roundActionPosting applies action to round and returns the posting that
+// moves its funds. bet is the bet a rollback cancels, it is ignored for the
+// other actions. The round, the bet and the action are only updated in
+// memory, the caller saves them once the posting went through.
+func roundActionPosting(
+	round *models.GameRound,
+	bet *models.GameRoundAction,
+	walletID int64,
+	action *models.GameRoundAction,
+	txn *models.Transaction,
+	now time.Time,
+) (*Posting, error) {
+	if round.WalletID != walletID {
+		return nil, util.ErrGameRoundWalletMismatch
+	}
+	posting := &Posting{
+		WalletID:    round.WalletID,
+		Currency:    round.Currency,
+		Transaction: txn,
+	}
+	switch action.Type {
+	case models.GameRoundActionBet:
+		if round.Status != models.GameRoundStatusOpen {
+			return nil, util.ErrGameRoundClosed
+		}
+		round.TotalBet = round.TotalBet.Add(action.Amount)
+		posting.Amount = action.Amount.Neg()
+	case models.GameRoundActionWin:
+		if round.Status == models.GameRoundStatusRolledBack {
+			return nil, util.ErrGameRoundClosed
+		}
+		round.TotalWin = round.TotalWin.Add(action.Amount)
+		round.Status = models.GameRoundStatusSettled
+		posting.Amount = action.Amount
+		// a losing round is settled with a win of nothing, there is no
+		// movement worth a journal entry
+		if action.Amount.IsZero() {
+			posting.Transaction = nil
+		}
+	case models.GameRoundActionRollback:
+		if bet == nil || bet.Type != models.GameRoundActionBet || bet.GameRoundID != round.ID {
+			return nil, util.ErrGameRoundActionNotFound
+		}
+		if bet.RolledBack {
+			return nil, util.ErrGameRoundBetAlreadyRolled
+		}
+		// only the stake of a round nothing was paid out on yet can be given back
+		if round.Status != models.GameRoundStatusOpen {
+			return nil, util.ErrGameRoundClosed
+		}
+		bet.RolledBack = true
+		action.Amount = bet.Amount
+		round.TotalBet = round.TotalBet.Sub(bet.Amount)
+		if round.TotalBet.IsZero() {
+			round.Status = models.GameRoundStatusRolledBack
+		}
+		posting.Amount = bet.Amount
+		posting.Bonus = bet.BonusAmount
+		action.BonusAmount = bet.BonusAmount
+		if posting.Transaction != nil {
+			posting.Transaction.BonusAmount = bet.BonusAmount
+		}
+	default:
+		return nil, util.ErrGameRoundActionConflict
+	}
+	round.UpdatedAt = now
+	action.CreatedAt = now
+	if posting.Transaction != nil {
+		posting.Transaction.CorrelationID = round.UUID.String()
+	}
+	return posting, nil
+}
+
+// completeRoundAction links the action to its round and journal entry once
+// both are saved
+func completeRoundAction(round *models.GameRound, action *models.GameRoundAction, posting *Posting) {
+	action.GameRoundID = round.ID
+	if posting.Transaction != nil {
+		action.TransactionID = posting.Transaction.ID
+		if action.Type == models.GameRoundActionBet {
+			action.BonusAmount = posting.Transaction.BonusAmount
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// holdPosting reserves the hold amount on its wallet
+func holdPosting(hold *models.Hold) *Posting {
+	return &Posting{
+		WalletID: hold.WalletID,
+		Held:     hold.Amount,
+	}
+}
+
+// captureHold marks the hold captured and returns the posting that debits
+// amount from the wallet and gives back the rest of the reservation
+func captureHold(hold *models.Hold, amount models.Money, txn *models.Transaction, now time.Time) (*Posting, error) {
+	if !hold.IsActive(now) {
+		return nil, util.ErrHoldNotActive
+	}
+	if amount.GreaterThan(hold.Amount) {
+		return nil, util.ErrCaptureExceedsHold
+	}
+	hold.Status = models.HoldStatusCaptured
+	hold.CapturedAmount = amount
+	hold.UpdatedAt = now
+	return &Posting{
+		WalletID:    hold.WalletID,
+		Amount:      amount.Neg(),
+		Held:        hold.Amount.Neg(),
+		Transaction: txn,
+	}, nil
+}
+
+// releaseHold moves the hold to status and returns the posting that gives
+// the reservation back to the wallet
+func releaseHold(hold *models.Hold, status models.HoldStatus, now time.Time) (*Posting, error) {
+	if hold.Status != models.HoldStatusActive {
+		return nil, util.ErrHoldNotActive
+	}
+	hold.Status = status
+	hold.UpdatedAt = now
+	return &Posting{
+		WalletID: hold.WalletID,
+		Held:     hold.Amount.Neg(),
+	}, nil
+}
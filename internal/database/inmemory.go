@@ -1,6 +1,10 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/Oloruntobi1/qgdc/internal/models"
@@ -10,21 +14,102 @@ import (
 )
 
 type InMemory struct {
-	Users   []*models.User
-	Wallets []*models.Wallet
+	Users           []*models.User
+	Wallets         []*models.Wallet
+	Transactions    []*models.Transaction
+	IdempotencyKeys []*models.IdempotencyKey
+	Holds           []*models.Hold
+	FXQuotes        []*models.FXQuote
+	GameRounds      []*models.GameRound
+	RoundActions    []*models.GameRoundAction
+	BonusGrants     []*models.BonusGrant
+	GamingLimits    []*models.GamingLimit
+	Tournaments     []*models.Tournament
+	Entries         []*models.TournamentEntry
+	Matches         []*models.Match
+	Deposits        []*models.Deposit
+	FeeSchedules    []*models.FeeSchedule
+	JackpotPools    []*models.JackpotPool
+	JackpotEvents   []*models.JackpotEvent
+	Vouchers        []*models.Voucher
+	Redemptions     []*models.VoucherRedemption
+	LoyaltyRules    []*models.LoyaltyRule
+	LoyaltyAccounts []*models.LoyaltyAccount
+	LoyaltyEntries  []*models.LoyaltyEntry
+
+	RestrictionEvents []*models.RestrictionEvent
+	VelocityRules     []*models.VelocityRule
+
+	LoginEvents []*models.LoginEvent
+	// FraudReviews is the queue of operations flagged by the fraud rules
+	FraudReviews []*models.FraudReview
+
+	Withdrawals []*models.Withdrawal
+	// WithdrawalTransitions is the history of every withdrawal
+	WithdrawalTransitions []*models.WithdrawalTransition
+
+	// mu guards the slices above except Transactions which is guarded by
+	// journalMu, BonusGrants which is guarded by bonusMu and GamingLimits
+	// which is guarded by limitMu. Wallet balances are guarded by a lock per
+	// wallet so debits and credits on different wallets never wait on each
+	// other, a bonus grant is only changed while the lock of its wallet is
+	// held as well. The loyalty slices and cursor are guarded by loyaltyMu
+	// so accruals never hold up stakes. Locks are always taken in the order
+	// loyaltyMu, mu, wallet locks by ascending id, bonusMu, limitMu,
+	// journalMu.
+	mu          sync.RWMutex
+	walletLocks sync.Map
+	bonusMu     sync.Mutex
+	limitMu     sync.Mutex
+	journalMu   sync.Mutex
+	loyaltyMu   sync.Mutex
+	// loyaltyCursor is the last journal entry loyalty points were accrued
+	// for
+	loyaltyCursor int64
 }
 
 var _ Repository = (*InMemory)(nil)
 
 func NewInMemory() *InMemory {
 	return &InMemory{
-		Users:   []*models.User{},
-		Wallets: []*models.Wallet{},
+		Users:           []*models.User{},
+		Wallets:         []*models.Wallet{},
+		Transactions:    []*models.Transaction{},
+		IdempotencyKeys: []*models.IdempotencyKey{},
+		Holds:           []*models.Hold{},
+		FXQuotes:        []*models.FXQuote{},
+		GameRounds:      []*models.GameRound{},
+		RoundActions:    []*models.GameRoundAction{},
+		BonusGrants:     []*models.BonusGrant{},
+		GamingLimits:    []*models.GamingLimit{},
+		Tournaments:     []*models.Tournament{},
+		Entries:         []*models.TournamentEntry{},
+		Matches:         []*models.Match{},
+		Deposits:        []*models.Deposit{},
+		FeeSchedules:    []*models.FeeSchedule{},
+		JackpotPools:    []*models.JackpotPool{},
+		JackpotEvents:   []*models.JackpotEvent{},
+		Vouchers:        []*models.Voucher{},
+		Redemptions:     []*models.VoucherRedemption{},
+		LoyaltyRules:    []*models.LoyaltyRule{},
+		LoyaltyAccounts: []*models.LoyaltyAccount{},
+		LoyaltyEntries:  []*models.LoyaltyEntry{},
+
+		RestrictionEvents: []*models.RestrictionEvent{},
+		VelocityRules:     []*models.VelocityRule{},
+		LoginEvents:       []*models.LoginEvent{},
+		FraudReviews:      []*models.FraudReview{},
+
+		Withdrawals:           []*models.Withdrawal{},
+		WithdrawalTransitions: []*models.WithdrawalTransition{},
 	}
 }
 
 // implement Reader interface
 func (m *InMemory) GetUserByEmail(email string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, user := range m.Users {
 		if user.Email == email {
 			return user, nil
@@ -33,84 +118,2188 @@ func (m *InMemory) GetUserByEmail(email string) (*models.User, error) {
 	return nil, util.ErrUserNotFound
 }
 
-func (m *InMemory) GetWallet(id int64) (*models.Wallet, error) {
-	for _, wallet := range m.Wallets {
-		if wallet.ID == id {
-			return wallet, nil
+func (m *InMemory) GetUser(id int64) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.Users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, util.ErrUserNotFound
+}
+
+func (m *InMemory) GetRestrictionEvents(userID int64) ([]*models.RestrictionEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := []*models.RestrictionEvent{}
+	for _, e := range m.RestrictionEvents {
+		if e.UserID == userID {
+			event := *e
+			events = append(events, &event)
 		}
 	}
-	return nil, util.ErrWalletNotFound
+	return events, nil
+}
+
+func (m *InMemory) GetWallet(id int64) (*models.Wallet, error) {
+	m.mu.RLock()
+	wallet := m.findWallet(id)
+	m.mu.RUnlock()
+
+	if wallet == nil {
+		return nil, util.ErrWalletNotFound
+	}
+	return m.snapshotWallet(wallet), nil
 }
 
 func (m *InMemory) GetAllWallets() ([]*models.Wallet, error) {
-	return m.Wallets, nil
+	m.mu.RLock()
+	stored := append([]*models.Wallet{}, m.Wallets...)
+	m.mu.RUnlock()
+
+	wallets := make([]*models.Wallet, 0, len(stored))
+	for _, wallet := range stored {
+		wallets = append(wallets, m.snapshotWallet(wallet))
+	}
+	return wallets, nil
 }
 
 func (m *InMemory) GetWalletByUserID(userID int64) (*models.Wallet, error) {
+	m.mu.RLock()
+	var found *models.Wallet
+	for _, wallet := range m.Wallets {
+		if wallet.UserID == userID {
+			found = wallet
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if found == nil {
+		return nil, util.ErrWalletNotFound
+	}
+	return m.snapshotWallet(found), nil
+}
+
+func (m *InMemory) GetWalletsByUserID(userID int64) ([]*models.Wallet, error) {
+	m.mu.RLock()
+	var found []*models.Wallet
 	for _, wallet := range m.Wallets {
 		if wallet.UserID == userID {
-			return wallet, nil
+			found = append(found, wallet)
 		}
 	}
-	return nil, util.ErrWalletNotFound
+	m.mu.RUnlock()
+
+	wallets := make([]*models.Wallet, 0, len(found))
+	for _, wallet := range found {
+		wallets = append(wallets, m.snapshotWallet(wallet))
+	}
+	return wallets, nil
 }
 
 func (m *InMemory) GetAllUsers() ([]*UserWallet, error) {
+	m.mu.RLock()
+	users := append([]*models.User{}, m.Users...)
+	m.mu.RUnlock()
+
+	// one row per wallet like the users and wallets join of MySQL
 	var userWallets []*UserWallet
-	for _, user := range m.Users {
-		wallet, err := m.GetWalletByUserID(user.ID)
+	for _, user := range users {
+		wallets, err := m.GetWalletsByUserID(user.ID)
 		if err != nil {
 			return nil, err
 		}
-		userWallets = append(userWallets, &UserWallet{
-			ID:                user.ID,
-			UUID:              user.UUID,
-			FullName:          user.FullName,
-			Email:             user.Email,
-			Password:          user.Password,
-			HashedPassword:    user.HashedPassword,
-			PasswordChangedAt: user.PasswordChangedAt,
-			CreatedAt:         user.CreatedAt,
-			UpdatedAt:         user.UpdatedAt,
-			WalletID:          wallet.ID,
-			WalletBalance:     wallet.Balance,
-		})
+		for _, wallet := range wallets {
+			userWallets = append(userWallets, &UserWallet{
+				ID:                user.ID,
+				UUID:              user.UUID,
+				FullName:          user.FullName,
+				Email:             user.Email,
+				Password:          user.Password,
+				HashedPassword:    user.HashedPassword,
+				PasswordChangedAt: user.PasswordChangedAt,
+				CreatedAt:         user.CreatedAt,
+				UpdatedAt:         user.UpdatedAt,
+				WalletID:          wallet.ID,
+				WalletCurrency:    wallet.CurrencyCode(),
+				WalletBalance:     wallet.Balance,
+			})
+		}
 	}
 
 	return userWallets, nil
 }
 
+func (m *InMemory) GetTransaction(id int64) (*models.Transaction, error) {
+	m.journalMu.Lock()
+	defer m.journalMu.Unlock()
+
+	for _, txn := range m.Transactions {
+		if txn.ID == id {
+			return txn, nil
+		}
+	}
+	return nil, util.ErrTransactionNotFound
+}
+
+func (m *InMemory) GetTransactionsByWallet(filter *TransactionFilter) ([]*models.Transaction, error) {
+	m.journalMu.Lock()
+	defer m.journalMu.Unlock()
+
+	transactions := []*models.Transaction{}
+	// walk the journal backwards so the newest entries come first
+	for i := len(m.Transactions) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(transactions) == filter.Limit {
+			break
+		}
+		txn := m.Transactions[i]
+		if txn.WalletID != filter.WalletID {
+			continue
+		}
+		if filter.BeforeID > 0 && txn.ID >= filter.BeforeID {
+			continue
+		}
+		if filter.Type != "" && txn.Type != filter.Type {
+			continue
+		}
+		if filter.From != nil && txn.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && txn.CreatedAt.After(*filter.To) {
+			continue
+		}
+		if filter.MinAmount != nil && txn.Amount.LessThan(*filter.MinAmount) {
+			continue
+		}
+		if filter.MaxAmount != nil && txn.Amount.GreaterThan(*filter.MaxAmount) {
+			continue
+		}
+		transactions = append(transactions, txn)
+	}
+	return transactions, nil
+}
+
+func (m *InMemory) GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.IdempotencyKeys {
+		if k.Scope == scope && k.Key == key {
+			// hand out a copy so callers cannot race with updates
+			found := *k
+			return &found, nil
+		}
+	}
+	return nil, util.ErrIdempotencyKeyNotFound
+}
+
+func (m *InMemory) GetHold(id int64) (*models.Hold, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hold := m.findHold(id)
+	if hold == nil {
+		return nil, util.ErrHoldNotFound
+	}
+	found := *hold
+	return &found, nil
+}
+
+func (m *InMemory) GetHoldsByWallet(walletID int64, status models.HoldStatus) ([]*models.Hold, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	holds := []*models.Hold{}
+	for i := len(m.Holds) - 1; i >= 0; i-- {
+		hold := m.Holds[i]
+		if hold.WalletID != walletID || (status != "" && hold.Status != status) {
+			continue
+		}
+		found := *hold
+		holds = append(holds, &found)
+	}
+	return holds, nil
+}
+
+func (m *InMemory) GetReversals(originalID int64) ([]*models.Transaction, error) {
+	m.journalMu.Lock()
+	defer m.journalMu.Unlock()
+
+	reversals := []*models.Transaction{}
+	for _, txn := range m.Transactions {
+		if txn.OriginalID == originalID {
+			found := *txn
+			reversals = append(reversals, &found)
+		}
+	}
+	return reversals, nil
+}
+
+// ReverseTransaction holds mu for writing so reversals are serialised and
+// cannot reverse more than the original amount between them
+func (m *InMemory) ReverseTransaction(
+	ctx context.Context,
+	originalID int64,
+	amount *models.Money,
+	txn *models.Transaction,
+) (*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	original, err := m.GetTransaction(originalID)
+	if err != nil {
+		return nil, err
+	}
+	reversals, err := m.GetReversals(originalID)
+	if err != nil {
+		return nil, err
+	}
+	posting, err := reversalPosting(original, reversals, amount, txn)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := m.postStateLocked(posting)
+	if err != nil {
+		return nil, err
+	}
+	return wallets[0], nil
+}
+
+func (m *InMemory) GetFXQuote(id int64) (*models.FXQuote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	quote := m.findFXQuote(id)
+	if quote == nil {
+		return nil, util.ErrQuoteNotFound
+	}
+	found := *quote
+	return &found, nil
+}
+
+func (m *InMemory) GetGameRound(provider, roundID string) (*models.GameRound, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	round := m.findGameRound(provider, roundID)
+	if round == nil {
+		return nil, util.ErrGameRoundNotFound
+	}
+	result := *round
+	return &result, nil
+}
+
+func (m *InMemory) GetGameRoundActions(gameRoundID int64) ([]*models.GameRoundAction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	actions := []*models.GameRoundAction{}
+	for _, a := range m.RoundActions {
+		if a.GameRoundID == gameRoundID {
+			action := *a
+			actions = append(actions, &action)
+		}
+	}
+	return actions, nil
+}
+
 // implement Updater interface
 func (m *InMemory) CreateUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.Users = append(m.Users, user)
 	return nil
 }
 
+func (m *InMemory) PromoteAdmin(email string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, u := range m.Users {
+		if u.Email != email {
+			continue
+		}
+		user := *u
+		user.IsAdmin = true
+		user.UpdatedAt = time.Now()
+		m.Users[i] = &user
+		return &user, nil
+	}
+	return nil, util.ErrUserNotFound
+}
+
+func (m *InMemory) RestrictUser(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error) {
+	return m.updateRestriction(userID, event, imposeRestriction)
+}
+
+func (m *InMemory) LiftRestriction(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error) {
+	return m.updateRestriction(userID, event, liftRestriction)
+}
+
+// updateRestriction lets change update a copy of the user, which replaces
+// the stored user so the users handed out before are never changed
+func (m *InMemory) updateRestriction(
+	userID int64,
+	event *models.RestrictionEvent,
+	change func(user *models.User, event *models.RestrictionEvent, now time.Time) error,
+) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, u := range m.Users {
+		if u.ID != userID {
+			continue
+		}
+		user := *u
+		if err := change(&user, event, time.Now()); err != nil {
+			return nil, err
+		}
+		m.Users[i] = &user
+		event.ID = int64(len(m.RestrictionEvents) + 1)
+		recorded := *event
+		m.RestrictionEvents = append(m.RestrictionEvents, &recorded)
+		return &user, nil
+	}
+	return nil, util.ErrUserNotFound
+}
+
 func (m *InMemory) CreateWallet(wallet *models.Wallet) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if wallet.Currency == "" {
+		wallet.Currency = models.DefaultCurrency
+	}
+	var lastID int64
+	for _, w := range m.Wallets {
+		if w.UserID == wallet.UserID && w.CurrencyCode() == wallet.Currency {
+			return 0, util.ErrWalletExists
+		}
+		if w.ID > lastID {
+			lastID = w.ID
+		}
+	}
+	if wallet.ID == 0 {
+		wallet.ID = lastID + 1
+	}
 	m.Wallets = append(m.Wallets, wallet)
 	return wallet.ID, nil
 }
 
 func (m *InMemory) UpdateWallet(wallet *models.Wallet) (*models.Wallet, error) {
 	// get wallet by id
-	for i, w := range m.Wallets {
-		if w.ID == wallet.ID {
-			m.Wallets[i].Balance = wallet.Balance
-		}
+	m.mu.RLock()
+	stored := m.findWallet(wallet.ID)
+	m.mu.RUnlock()
+
+	if stored != nil {
+		unlock := m.lockWallets([]int64{stored.ID})
+		stored.Balance = wallet.Balance
+		unlock()
 	}
 	return wallet, nil
 }
 
-func (m *InMemory) DeleteWallet(id int64) error {
-	// get wallet by id
-	for i, w := range m.Wallets {
-		if w.ID == id {
-			m.Wallets = append(m.Wallets[:i], m.Wallets[i+1:]...)
+func (m *InMemory) AdjustBalance(
+	ctx context.Context,
+	walletID int64,
+	delta models.Money,
+	constraint BalanceConstraint,
+	txn *models.Transaction,
+) (*models.Wallet, error) {
+	wallets, err := m.ApplyPostings(ctx, &Posting{
+		WalletID:    walletID,
+		Amount:      delta,
+		Constraint:  constraint,
+		Transaction: txn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallets[0], nil
+}
+
+func (m *InMemory) ApplyPostings(ctx context.Context, postings ...*Posting) ([]*models.Wallet, error) {
+	ids := postingWalletIDs(postings)
+	stored, err := m.findWallets(ids)
+	if err != nil {
+		return nil, err
+	}
+	unlock := m.lockWallets(ids)
+	defer unlock()
+
+	return m.postLocked(stored, postings)
+}
+
+// postLocked applies postings to the stored wallets, the caller must hold
+// the locks of every wallet in stored
+func (m *InMemory) postLocked(stored map[int64]*models.Wallet, postings []*Posting) (_ []*models.Wallet, err error) {
+	// work on copies so a failed posting leaves the stored wallets untouched
+	wallets := make(map[int64]*models.Wallet, len(stored))
+	for id, w := range stored {
+		wallet := *w
+		wallets[id] = &wallet
+	}
+	now := time.Now()
+	// stakes are paid out of the bonus grants of their wallet as well
+	if anyStakes(postings) {
+		m.bonusMu.Lock()
+		defer m.bonusMu.Unlock()
+		save, forfeits := m.allocateWagersLocked(wallets, postings, now)
+		postings = append(postings, forfeits...)
+		defer func() {
+			if err == nil {
+				save()
+			}
+		}()
+	}
+	if anyLimited(postings) {
+		limits := m.gamingLimits(postingWalletIDs(postings))
+		if err := checkGamingLimits(limits, postings, now, m.limitUsage); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyPostings(wallets, postings, now); err != nil {
+		return nil, err
+	}
+	// only the balances are written under the wallet locks, the rest of
+	// the stored wallet is read under mu
+	for id, wallet := range wallets {
+		stored[id].Balance = wallet.Balance
+		stored[id].HeldBalance = wallet.HeldBalance
+		stored[id].BonusBalance = wallet.BonusBalance
+		stored[id].UpdatedAt = wallet.UpdatedAt
+	}
+
+	m.journalMu.Lock()
+	for _, p := range postings {
+		if p.Transaction == nil {
+			continue
+		}
+		p.Transaction.ID = int64(len(m.Transactions) + 1)
+		m.Transactions = append(m.Transactions, p.Transaction)
+	}
+	m.journalMu.Unlock()
+
+	return postedWallets(wallets, postings), nil
+}
+
+func (m *InMemory) CreateHold(ctx context.Context, hold *models.Hold) (*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wallets, err := m.postStateLocked(holdPosting(hold))
+	if err != nil {
+		return nil, err
+	}
+	hold.ID = int64(len(m.Holds) + 1)
+	stored := *hold
+	m.Holds = append(m.Holds, &stored)
+	return wallets[0], nil
+}
+
+func (m *InMemory) CaptureHold(
+	ctx context.Context,
+	holdID int64,
+	amount models.Money,
+	txn *models.Transaction,
+) (*models.Hold, *models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.settleHoldLocked(holdID, func(hold *models.Hold) (*Posting, error) {
+		return captureHold(hold, amount, txn, time.Now())
+	})
+}
+
+func (m *InMemory) ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, *models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.settleHoldLocked(holdID, func(hold *models.Hold) (*Posting, error) {
+		return releaseHold(hold, models.HoldStatusReleased, time.Now())
+	})
+}
+
+func (m *InMemory) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired int64
+	for _, hold := range m.Holds {
+		if hold.Status != models.HoldStatusActive || now.Before(hold.ExpiresAt) {
+			continue
 		}
+		_, _, err := m.settleHoldLocked(hold.ID, func(hold *models.Hold) (*Posting, error) {
+			return releaseHold(hold, models.HoldStatusExpired, now)
+		})
+		if err != nil {
+			return expired, err
+		}
+		expired++
 	}
+	return expired, nil
+}
+
+func (m *InMemory) CreateFXQuote(quote *models.FXQuote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	quote.ID = int64(len(m.FXQuotes) + 1)
+	stored := *quote
+	m.FXQuotes = append(m.FXQuotes, &stored)
 	return nil
 }
 
+func (m *InMemory) ExecuteFXQuote(
+	ctx context.Context,
+	quoteID int64,
+	debit, credit *models.Transaction,
+) (*models.FXQuote, []*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findFXQuote(quoteID)
+	if stored == nil {
+		return nil, nil, util.ErrQuoteNotFound
+	}
+	quote := *stored
+	postings, err := executeQuote(&quote, debit, credit, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := m.postStateLocked(postings...)
+	if err != nil {
+		return nil, nil, err
+	}
+	*stored = quote
+	return &quote, wallets, nil
+}
+
+func (m *InMemory) ApplyGameRoundAction(
+	ctx context.Context,
+	walletID int64,
+	roundID string,
+	action *models.GameRoundAction,
+	txn *models.Transaction,
+) (*RoundResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored := m.findRoundAction(action.Provider, action.ProviderTransactionID); stored != nil {
+		return m.repeatedRoundActionLocked(stored, walletID, roundID, action)
+	}
+
+	now := time.Now()
+	storedRound := m.findGameRound(action.Provider, roundID)
+	var round models.GameRound
+	switch {
+	case storedRound != nil:
+		round = *storedRound
+	case action.Type == models.GameRoundActionBet:
+		wallet := m.findWallet(walletID)
+		if wallet == nil {
+			return nil, util.ErrWalletNotFound
+		}
+		round = *newGameRound(wallet, action.Provider, roundID, now)
+	default:
+		return nil, util.ErrGameRoundNotFound
+	}
+	var bet, storedBet *models.GameRoundAction
+	if action.Type == models.GameRoundActionRollback {
+		storedBet = m.findRoundAction(action.Provider, action.RollbackOf)
+	}
+	if storedBet != nil {
+		copied := *storedBet
+		bet = &copied
+	}
+	posting, err := roundActionPosting(&round, bet, walletID, action, txn, now)
+	if err != nil {
+		return nil, err
+	}
+	var contributions []*jackpotContribution
+	switch {
+	case action.Type == models.GameRoundActionBet:
+		contributions = contributeToJackpots(m.jackpotPoolsLocked(action.Provider, round.Currency), &round, action, txn, now)
+	case bet != nil:
+		pools := m.jackpotPoolsLocked(action.Provider, round.Currency)
+		contributions = withdrawJackpotContributions(pools, m.jackpotContributionsLocked(bet), &round, action, txn, now)
+	}
+	wallets, err := m.postStateLocked(append([]*Posting{posting}, contributionPostings(contributions)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if storedRound == nil {
+		round.ID = int64(len(m.GameRounds) + 1)
+		created := round
+		m.GameRounds = append(m.GameRounds, &created)
+	} else {
+		*storedRound = round
+	}
+	if bet != nil {
+		*storedBet = *bet
+	}
+	action.ID = int64(len(m.RoundActions) + 1)
+	completeRoundAction(&round, action, posting)
+	stored := *action
+	m.RoundActions = append(m.RoundActions, &stored)
+	for _, c := range contributions {
+		*m.findJackpotPool(c.pool.ID) = *c.pool
+		m.appendJackpotEventLocked(c.event, c.posting)
+	}
+	return &RoundResult{Round: &round, Action: action, Wallet: wallets[0]}, nil
+}
+
+// repeatedRoundActionLocked answers an action that was already processed
+// with its first outcome, the caller must hold mu
+func (m *InMemory) repeatedRoundActionLocked(
+	stored *models.GameRoundAction,
+	walletID int64,
+	roundID string,
+	action *models.GameRoundAction,
+) (*RoundResult, error) {
+	var round models.GameRound
+	for _, r := range m.GameRounds {
+		if r.ID == stored.GameRoundID {
+			round = *r
+		}
+	}
+	if err := checkRepeatedRoundAction(stored, &round, walletID, roundID, action); err != nil {
+		return nil, err
+	}
+	wallet := m.findWallet(walletID)
+	if wallet == nil {
+		return nil, util.ErrWalletNotFound
+	}
+	first := *stored
+	return &RoundResult{
+		Round:     &round,
+		Action:    &first,
+		Wallet:    m.snapshotWallet(wallet),
+		Duplicate: true,
+	}, nil
+}
+
+// allocateWagersLocked pays the stakes of postings out of copies of the
+// active bonus grants of their wallets, and gives refunded stakes back to
+// them. It returns a func that saves the copies and the postings that
+// forfeit the bonus funds that could not be given back, the caller must
+// hold the locks of the wallets and bonusMu
+func (m *InMemory) allocateWagersLocked(wallets map[int64]*models.Wallet, postings []*Posting, now time.Time) (func(), []*Posting) {
+	stored := make(map[*models.BonusGrant]*models.BonusGrant)
+	grants := make(map[int64][]*models.BonusGrant)
+	for _, g := range m.BonusGrants {
+		if _, ok := wallets[g.WalletID]; ok && g.IsActive(now) {
+			grant := *g
+			stored[&grant] = g
+			grants[g.WalletID] = append(grants[g.WalletID], &grant)
+		}
+	}
+	var forfeits []*Posting
+	for _, p := range postings {
+		switch {
+		case p.wagersGame():
+			allocateWager(wallets[p.WalletID], grants[p.WalletID], p, now)
+		case p.refundsWager():
+			if forfeit := refundWager(grants[p.WalletID], p, now); forfeit != nil {
+				forfeits = append(forfeits, forfeit)
+			}
+		}
+	}
+	return func() {
+		for grant, s := range stored {
+			*s = *grant
+		}
+	}, forfeits
+}
+
+func (m *InMemory) GetBonusGrant(id int64) (*models.BonusGrant, error) {
+	m.bonusMu.Lock()
+	defer m.bonusMu.Unlock()
+
+	grant := m.findBonusGrant(id)
+	if grant == nil {
+		return nil, util.ErrBonusNotFound
+	}
+	result := *grant
+	return &result, nil
+}
+
+func (m *InMemory) GetBonusGrantsByWallet(walletID int64, status models.BonusStatus) ([]*models.BonusGrant, error) {
+	m.bonusMu.Lock()
+	defer m.bonusMu.Unlock()
+
+	grants := []*models.BonusGrant{}
+	// newest first
+	for i := len(m.BonusGrants) - 1; i >= 0; i-- {
+		g := m.BonusGrants[i]
+		if g.WalletID == walletID && (status == "" || g.Status == status) {
+			grant := *g
+			grants = append(grants, &grant)
+		}
+	}
+	return grants, nil
+}
+
+func (m *InMemory) CreateBonusGrant(ctx context.Context, grant *models.BonusGrant, txn *models.Transaction) (*models.Wallet, error) {
+	ids := []int64{grant.WalletID}
+	stored, err := m.findWallets(ids)
+	if err != nil {
+		return nil, err
+	}
+	unlock := m.lockWallets(ids)
+	defer unlock()
+
+	wallets, err := m.postLocked(stored, []*Posting{bonusGrantPosting(grant, txn)})
+	if err != nil {
+		return nil, err
+	}
+	m.bonusMu.Lock()
+	grant.ID = int64(len(m.BonusGrants) + 1)
+	created := *grant
+	m.BonusGrants = append(m.BonusGrants, &created)
+	m.bonusMu.Unlock()
+	return wallets[0], nil
+}
+
+func (m *InMemory) ForfeitBonusGrant(ctx context.Context, grantID int64, txn *models.Transaction) (*models.BonusGrant, *models.Wallet, error) {
+	return m.settleBonusGrant(grantID, func(grant *models.BonusGrant) (*Posting, error) {
+		return removeBonusGrant(grant, models.BonusStatusForfeited, txn, time.Now())
+	})
+}
+
+func (m *InMemory) ExpireBonusGrants(ctx context.Context, now time.Time) (int64, error) {
+	m.bonusMu.Lock()
+	var ids []int64
+	for _, g := range m.BonusGrants {
+		if g.Status == models.BonusStatusActive && !now.Before(g.ExpiresAt) {
+			ids = append(ids, g.ID)
+		}
+	}
+	m.bonusMu.Unlock()
+
+	var expired int64
+	for _, id := range ids {
+		_, _, err := m.settleBonusGrant(id, func(grant *models.BonusGrant) (*Posting, error) {
+			return removeBonusGrant(grant, models.BonusStatusExpired, bonusExpiryTransaction(grant), now)
+		})
+		// the grant may have been completed or forfeited since it was listed
+		if errors.Is(err, util.ErrBonusNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// settleBonusGrant lets remove move the grant out of the active status and
+// applies the posting it returns under the lock of the grant wallet
+func (m *InMemory) settleBonusGrant(
+	grantID int64,
+	remove func(grant *models.BonusGrant) (*Posting, error),
+) (*models.BonusGrant, *models.Wallet, error) {
+	m.bonusMu.Lock()
+	storedGrant := m.findBonusGrant(grantID)
+	m.bonusMu.Unlock()
+	if storedGrant == nil {
+		return nil, nil, util.ErrBonusNotFound
+	}
+	// the wallet of a grant never changes
+	ids := []int64{storedGrant.WalletID}
+	stored, err := m.findWallets(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock := m.lockWallets(ids)
+	defer unlock()
+
+	m.bonusMu.Lock()
+	grant := *storedGrant
+	m.bonusMu.Unlock()
+	posting, err := remove(&grant)
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := m.postLocked(stored, []*Posting{posting})
+	if err != nil {
+		return nil, nil, err
+	}
+	m.bonusMu.Lock()
+	*storedGrant = grant
+	m.bonusMu.Unlock()
+	return &grant, wallets[0], nil
+}
+
+func (m *InMemory) GetGamingLimits(walletID int64) ([]*models.GamingLimit, error) {
+	return m.gamingLimits([]int64{walletID}), nil
+}
+
+func (m *InMemory) SetGamingLimit(ctx context.Context, change *LimitChange) (*models.GamingLimit, error) {
+	if _, err := m.findWallets([]int64{change.WalletID}); err != nil {
+		return nil, err
+	}
+	m.limitMu.Lock()
+	defer m.limitMu.Unlock()
+
+	var stored *models.GamingLimit
+	for _, l := range m.GamingLimits {
+		if l.WalletID == change.WalletID && l.Kind == change.Kind && l.Period == change.Period {
+			stored = l
+			break
+		}
+	}
+	limit, err := changeGamingLimit(stored, change, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		limit.ID = int64(len(m.GamingLimits) + 1)
+		stored = &models.GamingLimit{}
+		m.GamingLimits = append(m.GamingLimits, stored)
+	}
+	*stored = *limit
+	return limit, nil
+}
+
+// gamingLimits returns copies of the limits set on the wallets with ids
+func (m *InMemory) gamingLimits(ids []int64) []*models.GamingLimit {
+	m.limitMu.Lock()
+	defer m.limitMu.Unlock()
+
+	limits := []*models.GamingLimit{}
+	for _, l := range m.GamingLimits {
+		for _, id := range ids {
+			if l.WalletID == id {
+				limit := *l
+				limits = append(limits, &limit)
+			}
+		}
+	}
+	return limits
+}
+
+// limitUsage adds up the journal entries of the wallet since a point in
+// time, the caller must hold the lock of the wallet so no entry is added
+// while it counts
+func (m *InMemory) limitUsage(walletID int64, since time.Time) (limitUsage, error) {
+	m.journalMu.Lock()
+	defer m.journalMu.Unlock()
+
+	var usage limitUsage
+	for _, txn := range m.Transactions {
+		if txn.WalletID == walletID && !txn.CreatedAt.Before(since) {
+			usage.add(txn.Type, txn.Amount)
+		}
+	}
+	return usage, nil
+}
+
+// findBonusGrant returns the stored grant with id, the caller must hold
+// bonusMu
+func (m *InMemory) findBonusGrant(id int64) *models.BonusGrant {
+	for _, g := range m.BonusGrants {
+		if g.ID == id {
+			return g
+		}
+	}
+	return nil
+}
+
+func (m *InMemory) GetTournament(id int64) (*models.Tournament, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t := m.findTournament(id)
+	if t == nil {
+		return nil, util.ErrTournamentNotFound
+	}
+	result := *t
+	return &result, nil
+}
+
+func (m *InMemory) GetTournamentEntries(tournamentID int64) ([]*models.TournamentEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.tournamentEntriesLocked(tournamentID), nil
+}
+
+func (m *InMemory) CreateTournament(t *models.Tournament) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t.ID = int64(len(m.Tournaments) + 1)
+	stored := *t
+	m.Tournaments = append(m.Tournaments, &stored)
+	return nil
+}
+
+func (m *InMemory) EnterTournament(
+	ctx context.Context,
+	entry *models.TournamentEntry,
+	actor string,
+) (*models.Tournament, *models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findTournament(entry.TournamentID)
+	if stored == nil {
+		return nil, nil, util.ErrTournamentNotFound
+	}
+	for _, e := range m.Entries {
+		if e.TournamentID == entry.TournamentID && e.WalletID == entry.WalletID {
+			return nil, nil, util.ErrTournamentAlreadyEntered
+		}
+	}
+	t := *stored
+	postings, err := enterTournament(&t, entry, actor, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := m.postStateLocked(postings...)
+	if err != nil {
+		return nil, nil, err
+	}
+	*stored = t
+	entry.ID = int64(len(m.Entries) + 1)
+	created := *entry
+	m.Entries = append(m.Entries, &created)
+	return &t, wallets[0], nil
+}
+
+func (m *InMemory) CloseTournament(
+	ctx context.Context,
+	tournamentID int64,
+	placements []int64,
+	actor string,
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	return m.settleTournament(tournamentID, func(t *models.Tournament, entries []*models.TournamentEntry) ([]*Posting, error) {
+		return closeTournament(t, entries, placements, actor, time.Now())
+	})
+}
+
+func (m *InMemory) CancelTournament(
+	ctx context.Context,
+	tournamentID int64,
+	actor string,
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	return m.settleTournament(tournamentID, func(t *models.Tournament, entries []*models.TournamentEntry) ([]*Posting, error) {
+		return cancelTournament(t, entries, actor, time.Now())
+	})
+}
+
+// settleTournament lets settle close or cancel copies of the tournament and
+// its entries and applies the postings it returns before saving them
+func (m *InMemory) settleTournament(
+	tournamentID int64,
+	settle func(t *models.Tournament, entries []*models.TournamentEntry) ([]*Posting, error),
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findTournament(tournamentID)
+	if stored == nil {
+		return nil, nil, util.ErrTournamentNotFound
+	}
+	t := *stored
+	entries := m.tournamentEntriesLocked(tournamentID)
+	postings, err := settle(&t, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(postings) > 0 {
+		if _, err := m.postStateLocked(postings...); err != nil {
+			return nil, nil, err
+		}
+	}
+	*stored = t
+	for _, entry := range entries {
+		*m.Entries[entry.ID-1] = *entry
+	}
+	return &t, entries, nil
+}
+
+// findTournament returns the stored tournament with id, the caller must
+// hold mu
+func (m *InMemory) findTournament(id int64) *models.Tournament {
+	for _, t := range m.Tournaments {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// tournamentEntriesLocked returns copies of the entries of a tournament in
+// the order they were made, the caller must hold mu
+func (m *InMemory) tournamentEntriesLocked(tournamentID int64) []*models.TournamentEntry {
+	entries := []*models.TournamentEntry{}
+	for _, e := range m.Entries {
+		if e.TournamentID == tournamentID {
+			entry := *e
+			entries = append(entries, &entry)
+		}
+	}
+	return entries
+}
+
+func (m *InMemory) GetJackpotPool(id int64) (*models.JackpotPool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pool := m.findJackpotPool(id)
+	if pool == nil {
+		return nil, util.ErrJackpotNotFound
+	}
+	result := *pool
+	return &result, nil
+}
+
+func (m *InMemory) GetJackpotEvents(poolID int64, limit int) ([]*models.JackpotEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := []*models.JackpotEvent{}
+	for i := len(m.JackpotEvents) - 1; i >= 0; i-- {
+		if limit > 0 && len(events) == limit {
+			break
+		}
+		if e := m.JackpotEvents[i]; e.PoolID == poolID {
+			event := *e
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
+
+func (m *InMemory) CreateJackpotPool(ctx context.Context, pool *models.JackpotPool, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := *pool
+	created.ID = int64(len(m.JackpotPools) + 1)
+	postings, event := seedJackpotPool(&created, actor, time.Now())
+	if len(postings) > 0 {
+		if _, err := m.postStateLocked(postings...); err != nil {
+			return err
+		}
+	}
+	*pool = created
+	m.JackpotPools = append(m.JackpotPools, &created)
+	event.PoolID = created.ID
+	var posting *Posting
+	if len(postings) > 0 {
+		posting = postings[1]
+	}
+	m.appendJackpotEventLocked(event, posting)
+	return nil
+}
+
+func (m *InMemory) HitJackpot(ctx context.Context, hit *JackpotHit) (*JackpotHitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findJackpotPool(hit.PoolID)
+	if stored == nil {
+		return nil, util.ErrJackpotNotFound
+	}
+	for _, e := range m.JackpotEvents {
+		if e.PoolID != hit.PoolID || e.ProviderTransactionID != hit.ProviderTransactionID {
+			continue
+		}
+		if err := checkRepeatedJackpotHit(stored, e, hit); err != nil {
+			return nil, err
+		}
+		wallet := m.findWallet(hit.WalletID)
+		if wallet == nil {
+			return nil, util.ErrWalletNotFound
+		}
+		pool, event := *stored, *e
+		return &JackpotHitResult{Pool: &pool, Event: &event, Wallet: m.snapshotWallet(wallet), Duplicate: true}, nil
+	}
+	pool := *stored
+	postings, event, err := hitJackpot(&pool, hit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := m.postStateLocked(postings...)
+	if err != nil {
+		return nil, err
+	}
+	*stored = pool
+	m.appendJackpotEventLocked(event, postings[1])
+	return &JackpotHitResult{Pool: &pool, Event: event, Wallet: wallets[0]}, nil
+}
+
+func (m *InMemory) GetVoucherByCode(code string) (*models.Voucher, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v := m.findVoucher(code)
+	if v == nil {
+		return nil, util.ErrVoucherNotFound
+	}
+	result := *v
+	return &result, nil
+}
+
+func (m *InMemory) GetVoucherRedemptions(voucherID int64) ([]*models.VoucherRedemption, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	redemptions := []*models.VoucherRedemption{}
+	for _, r := range m.Redemptions {
+		if r.VoucherID == voucherID {
+			redemption := *r
+			redemptions = append(redemptions, &redemption)
+		}
+	}
+	return redemptions, nil
+}
+
+func (m *InMemory) CreateVoucher(v *models.Voucher) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v.Code = models.NormalizeVoucherCode(v.Code)
+	if m.findVoucher(v.Code) != nil {
+		return util.ErrVoucherCodeExists
+	}
+	v.ID = int64(len(m.Vouchers) + 1)
+	stored := *v
+	m.Vouchers = append(m.Vouchers, &stored)
+	return nil
+}
+
+func (m *InMemory) RedeemVoucher(
+	ctx context.Context,
+	code string,
+	redemption *models.VoucherRedemption,
+	txn *models.Transaction,
+) (*models.Voucher, *models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findVoucher(code)
+	if stored == nil {
+		return nil, nil, util.ErrVoucherNotFound
+	}
+	redeemedBefore := false
+	for _, r := range m.Redemptions {
+		if r.VoucherID == stored.ID && r.UserID == redemption.UserID {
+			redeemedBefore = true
+		}
+	}
+	v := *stored
+	posting, err := redeemVoucher(&v, redemption, redeemedBefore, txn, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := m.postStateLocked(posting)
+	if err != nil {
+		return nil, nil, err
+	}
+	*stored = v
+	redemption.ID = int64(len(m.Redemptions) + 1)
+	redemption.TransactionID = txn.ID
+	created := *redemption
+	m.Redemptions = append(m.Redemptions, &created)
+	return &v, wallets[0], nil
+}
+
+func (m *InMemory) GetLoyaltyRules() ([]*models.LoyaltyRule, error) {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+
+	rules := make([]*models.LoyaltyRule, 0, len(m.LoyaltyRules))
+	for _, rule := range m.LoyaltyRules {
+		r := *rule
+		rules = append(rules, &r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Category != rules[j].Category {
+			return rules[i].Category < rules[j].Category
+		}
+		return rules[i].Currency < rules[j].Currency
+	})
+	return rules, nil
+}
+
+func (m *InMemory) GetLoyaltyAccount(userID int64) (*models.LoyaltyAccount, error) {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+
+	stored := m.findLoyaltyAccount(userID)
+	if stored == nil {
+		return nil, util.ErrLoyaltyAccountNotFound
+	}
+	account := *stored
+	return &account, nil
+}
+
+func (m *InMemory) GetLoyaltyEntries(userID int64, limit int) ([]*models.LoyaltyEntry, error) {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+
+	entries := []*models.LoyaltyEntry{}
+	for i := len(m.LoyaltyEntries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if entry := m.LoyaltyEntries[i]; entry.UserID == userID {
+			e := *entry
+			entries = append(entries, &e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *InMemory) SetLoyaltyRule(rule *models.LoyaltyRule) error {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+
+	for _, stored := range m.LoyaltyRules {
+		if stored.Category == rule.Category && stored.Currency == rule.Currency {
+			rule.ID = stored.ID
+			rule.CreatedAt = stored.CreatedAt
+			*stored = *rule
+			return nil
+		}
+	}
+	rule.ID = int64(len(m.LoyaltyRules) + 1)
+	created := *rule
+	m.LoyaltyRules = append(m.LoyaltyRules, &created)
+	return nil
+}
+
+// AccrueLoyaltyPoints only reads the journal under journalMu, stakes keep
+// flowing while the points are worked out
+func (m *InMemory) AccrueLoyaltyPoints(ctx context.Context, until time.Time, limit int) (int, error) {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+
+	m.journalMu.Lock()
+	pending := m.Transactions[m.loyaltyCursor:]
+	m.journalMu.Unlock()
+
+	rules := indexLoyaltyRules(m.LoyaltyRules)
+	owners := map[int64]int64{}
+	now := time.Now()
+	wagers := 0
+	for _, txn := range pending {
+		if wagers == limit || !txn.CreatedAt.Before(until) {
+			break
+		}
+		m.loyaltyCursor = txn.ID
+		if !isLoyaltyWager(txn) {
+			continue
+		}
+		wagers++
+		points, category := loyaltyPoints(rules, txn)
+		if !points.IsPositive() {
+			continue
+		}
+		userID, ok := owners[txn.WalletID]
+		if !ok {
+			wallet, err := m.GetWallet(txn.WalletID)
+			// the wagers of a deleted wallet earn nothing
+			if err != nil {
+				continue
+			}
+			userID = wallet.UserID
+			owners[txn.WalletID] = userID
+		}
+		account := m.findLoyaltyAccount(userID)
+		if account == nil {
+			account = newLoyaltyAccount(userID, txn.CreatedAt)
+			account.ID = int64(len(m.LoyaltyAccounts) + 1)
+			m.LoyaltyAccounts = append(m.LoyaltyAccounts, account)
+		}
+		m.appendLoyaltyEntryLocked(accrueLoyaltyPoints(account, txn, points, category, now))
+	}
+	return wagers, nil
+}
+
+func (m *InMemory) ExpireLoyaltyPoints(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+
+	now := time.Now()
+	var expired int64
+	for _, account := range m.LoyaltyAccounts {
+		if account.LastActivityAt.After(inactiveSince) {
+			continue
+		}
+		if account.Points.IsZero() && account.TierPoints.IsZero() {
+			continue
+		}
+		m.appendLoyaltyEntryLocked(expireLoyaltyPoints(account, now))
+		expired++
+	}
+	return expired, nil
+}
+
+func (m *InMemory) RedeemLoyaltyPoints(
+	ctx context.Context,
+	redemption *LoyaltyRedemption,
+	txn *models.Transaction,
+) (*models.LoyaltyAccount, *models.Wallet, error) {
+	m.loyaltyMu.Lock()
+	defer m.loyaltyMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findLoyaltyAccount(redemption.UserID)
+	var account *models.LoyaltyAccount
+	if stored != nil {
+		copied := *stored
+		account = &copied
+	}
+	posting, entry, err := redeemLoyaltyPoints(account, redemption, txn, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := m.postStateLocked(posting)
+	if err != nil {
+		return nil, nil, err
+	}
+	*stored = *account
+	entry.TransactionID = txn.ID
+	m.appendLoyaltyEntryLocked(entry)
+	return account, wallets[0], nil
+}
+
+// findLoyaltyAccount returns the stored account of the user, the caller
+// must hold loyaltyMu
+func (m *InMemory) findLoyaltyAccount(userID int64) *models.LoyaltyAccount {
+	for _, account := range m.LoyaltyAccounts {
+		if account.UserID == userID {
+			return account
+		}
+	}
+	return nil
+}
+
+// appendLoyaltyEntryLocked saves an entry of the loyalty ledger, the caller
+// must hold loyaltyMu
+func (m *InMemory) appendLoyaltyEntryLocked(entry *models.LoyaltyEntry) {
+	entry.ID = int64(len(m.LoyaltyEntries) + 1)
+	created := *entry
+	m.LoyaltyEntries = append(m.LoyaltyEntries, &created)
+}
+
+func (m *InMemory) GetMatch(id int64) (*models.Match, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.findMatch(id)
+	if stored == nil {
+		return nil, util.ErrMatchNotFound
+	}
+	match := *stored
+	return &match, nil
+}
+
+func (m *InMemory) StartMatch(ctx context.Context, match *models.Match) (*MatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.Matches {
+		if stored.Reference != match.Reference {
+			continue
+		}
+		if err := checkRepeatedMatch(stored, match); err != nil {
+			return nil, err
+		}
+		existing := *stored
+		return &MatchResult{Match: &existing, Duplicate: true}, nil
+	}
+	started := *match
+	started.ID = int64(len(m.Matches) + 1)
+	wallets, err := m.postStateLocked(startMatch(&started, time.Now())...)
+	if err != nil {
+		return nil, err
+	}
+	*match = started
+	m.Matches = append(m.Matches, &started)
+	return &MatchResult{Match: match, Wallets: wallets}, nil
+}
+
+func (m *InMemory) FinishMatch(ctx context.Context, outcome *MatchOutcome) (*MatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findMatch(outcome.MatchID)
+	if stored == nil {
+		return nil, util.ErrMatchNotFound
+	}
+	match := *stored
+	if finishedAs(&match, outcome) {
+		return &MatchResult{Match: &match, Duplicate: true}, nil
+	}
+	postings, err := finishMatch(&match, outcome, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := m.postStateLocked(postings...)
+	if err != nil {
+		return nil, err
+	}
+	*stored = match
+	return &MatchResult{Match: &match, Wallets: wallets}, nil
+}
+
+func (m *InMemory) GetDeposit(id int64) (*models.Deposit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, stored := range m.Deposits {
+		if stored.ID == id {
+			deposit := *stored
+			return &deposit, nil
+		}
+	}
+	return nil, util.ErrDepositNotFound
+}
+
+func (m *InMemory) CreateDeposit(d *models.Deposit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d.ID = int64(len(m.Deposits) + 1)
+	created := *d
+	m.Deposits = append(m.Deposits, &created)
+	return nil
+}
+
+func (m *InMemory) SetDepositProviderReference(id int64, providerReference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.Deposits {
+		if stored.ID == id {
+			stored.ProviderReference = providerReference
+			stored.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return util.ErrDepositNotFound
+}
+
+func (m *InMemory) ConfirmDeposit(ctx context.Context, c *DepositConfirmation) (*DepositResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findDeposit(c.Reference)
+	if stored == nil {
+		return nil, util.ErrDepositNotFound
+	}
+	deposit := *stored
+	result := &DepositResult{Deposit: &deposit}
+	if depositConfirmedAs(&deposit, c) {
+		result.Duplicate = true
+		return result, nil
+	}
+	now := time.Now()
+	posting, err := confirmDeposit(&deposit, c, now)
+	if err != nil {
+		return nil, err
+	}
+	if posting != nil {
+		wallets, err := m.postStateLocked(posting)
+		switch {
+		case isDepositLimitError(err):
+			refuseDeposit(&deposit, err.Error(), now)
+		case err != nil:
+			return nil, err
+		default:
+			deposit.TransactionID = posting.Transaction.ID
+			result.Wallet = wallets[0]
+		}
+	}
+	*stored = deposit
+	return result, nil
+}
+
+func (m *InMemory) RefundDeposit(ctx context.Context, id int64, actor string) (*models.Deposit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.Deposits {
+		if stored.ID == id {
+			deposit := *stored
+			if err := refundDeposit(&deposit, actor, time.Now()); err != nil {
+				return nil, err
+			}
+			*stored = deposit
+			return &deposit, nil
+		}
+	}
+	return nil, util.ErrDepositNotFound
+}
+
+func (m *InMemory) GetDeposits(status models.DepositStatus) ([]*models.Deposit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deposits := []*models.Deposit{}
+	for _, stored := range m.Deposits {
+		if status != "" && stored.Status != status {
+			continue
+		}
+		deposit := *stored
+		deposits = append(deposits, &deposit)
+	}
+	return deposits, nil
+}
+
+func (m *InMemory) CheckGamingLimits(ctx context.Context, postings ...*Posting) error {
+	if !anyLimited(postings) {
+		return nil
+	}
+	limits := m.gamingLimits(postingWalletIDs(postings))
+	return checkGamingLimits(limits, postings, time.Now(), m.limitUsage)
+}
+
+func (m *InMemory) GetWithdrawal(id int64) (*models.Withdrawal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.findWithdrawal(id)
+	if stored == nil {
+		return nil, util.ErrWithdrawalNotFound
+	}
+	withdrawal := *stored
+	return &withdrawal, nil
+}
+
+func (m *InMemory) GetWithdrawals(status models.WithdrawalStatus) ([]*models.Withdrawal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	withdrawals := []*models.Withdrawal{}
+	for _, stored := range m.Withdrawals {
+		if status != "" && stored.Status != status {
+			continue
+		}
+		withdrawal := *stored
+		withdrawals = append(withdrawals, &withdrawal)
+	}
+	return withdrawals, nil
+}
+
+func (m *InMemory) GetWithdrawalTransitions(withdrawalID int64) ([]*models.WithdrawalTransition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transitions := []*models.WithdrawalTransition{}
+	for _, stored := range m.WithdrawalTransitions {
+		if stored.WithdrawalID == withdrawalID {
+			transition := *stored
+			transitions = append(transitions, &transition)
+		}
+	}
+	return transitions, nil
+}
+
+func (m *InMemory) RequestWithdrawal(ctx context.Context, w *models.Withdrawal) (*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requested := *w
+	posting, transition := requestWithdrawal(&requested, time.Now())
+	wallets, err := m.postStateLocked(posting)
+	if err != nil {
+		return nil, err
+	}
+	requested.ID = int64(len(m.Withdrawals) + 1)
+	*w = requested
+	m.Withdrawals = append(m.Withdrawals, &requested)
+	m.addWithdrawalTransitionLocked(w.ID, transition)
+	return wallets[0], nil
+}
+
+func (m *InMemory) MoveWithdrawal(ctx context.Context, c *WithdrawalChange) (*WithdrawalResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findWithdrawal(c.WithdrawalID)
+	if stored == nil {
+		return nil, util.ErrWithdrawalNotFound
+	}
+	withdrawal := *stored
+	postings, transition, err := moveWithdrawal(&withdrawal, c, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result := &WithdrawalResult{Withdrawal: &withdrawal, Transition: transition}
+	if len(postings) > 0 {
+		wallets, err := m.postStateLocked(postings...)
+		if err != nil {
+			return nil, err
+		}
+		withdrawnBy(result, postings, wallets)
+	}
+	*stored = withdrawal
+	m.addWithdrawalTransitionLocked(withdrawal.ID, transition)
+	return result, nil
+}
+
+func (m *InMemory) GetFeeSchedules() ([]*models.FeeSchedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schedules := make([]*models.FeeSchedule, 0, len(m.FeeSchedules))
+	for _, stored := range m.FeeSchedules {
+		schedule := *stored
+		schedules = append(schedules, &schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].Operation != schedules[j].Operation {
+			return schedules[i].Operation < schedules[j].Operation
+		}
+		return schedules[i].Currency < schedules[j].Currency
+	})
+	return schedules, nil
+}
+
+func (m *InMemory) GetFeeSchedule(operation models.FeeOperation, currency string) (*models.FeeSchedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, stored := range m.FeeSchedules {
+		if stored.Operation == operation && stored.Currency == currency {
+			schedule := *stored
+			return &schedule, nil
+		}
+	}
+	return nil, util.ErrFeeScheduleNotFound
+}
+
+func (m *InMemory) SetFeeSchedule(s *models.FeeSchedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.FeeSchedules {
+		if stored.Operation == s.Operation && stored.Currency == s.Currency {
+			s.ID = stored.ID
+			s.CreatedAt = stored.CreatedAt
+			*stored = *s
+			return nil
+		}
+	}
+	s.ID = int64(len(m.FeeSchedules) + 1)
+	created := *s
+	m.FeeSchedules = append(m.FeeSchedules, &created)
+	return nil
+}
+
+func (m *InMemory) RemoveFeeSchedule(operation models.FeeOperation, currency string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, stored := range m.FeeSchedules {
+		if stored.Operation == operation && stored.Currency == currency {
+			m.FeeSchedules = append(m.FeeSchedules[:i], m.FeeSchedules[i+1:]...)
+			return nil
+		}
+	}
+	return util.ErrFeeScheduleNotFound
+}
+
+func (m *InMemory) GetVelocityRules() ([]*models.VelocityRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make([]*models.VelocityRule, 0, len(m.VelocityRules))
+	for _, stored := range m.VelocityRules {
+		rule := *stored
+		rules = append(rules, &rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		// rules of tiers have no wallet and come last
+		if (rules[i].WalletID == 0) != (rules[j].WalletID == 0) {
+			return rules[i].WalletID != 0
+		}
+		if rules[i].WalletID != rules[j].WalletID {
+			return rules[i].WalletID < rules[j].WalletID
+		}
+		if rules[i].Tier != rules[j].Tier {
+			return rules[i].Tier < rules[j].Tier
+		}
+		return rules[i].Currency < rules[j].Currency
+	})
+	return rules, nil
+}
+
+func (m *InMemory) GetVelocityRule(walletID int64, tier, currency string) (*models.VelocityRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *models.VelocityRule
+	for _, stored := range m.VelocityRules {
+		if stored.WalletID == walletID {
+			found = stored
+			break
+		}
+		if stored.WalletID == 0 && stored.Tier == tier && stored.Currency == currency {
+			found = stored
+		}
+	}
+	if found == nil {
+		return nil, util.ErrVelocityRuleNotFound
+	}
+	rule := *found
+	return &rule, nil
+}
+
+func (m *InMemory) SetVelocityRule(rule *models.VelocityRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.VelocityRules {
+		if stored.WalletID == rule.WalletID && stored.Tier == rule.Tier && stored.Currency == rule.Currency {
+			rule.ID = stored.ID
+			rule.CreatedAt = stored.CreatedAt
+			*stored = *rule
+			return nil
+		}
+	}
+	rule.ID = m.nextVelocityRuleIDLocked()
+	created := *rule
+	m.VelocityRules = append(m.VelocityRules, &created)
+	return nil
+}
+
+func (m *InMemory) RemoveVelocityRule(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, stored := range m.VelocityRules {
+		if stored.ID == id {
+			m.VelocityRules = append(m.VelocityRules[:i], m.VelocityRules[i+1:]...)
+			return nil
+		}
+	}
+	return util.ErrVelocityRuleNotFound
+}
+
+// nextVelocityRuleIDLocked returns an id above those of the stored rules,
+// rules are removed so their count cannot be used. The caller must hold mu.
+func (m *InMemory) nextVelocityRuleIDLocked() int64 {
+	var id int64
+	for _, stored := range m.VelocityRules {
+		if stored.ID > id {
+			id = stored.ID
+		}
+	}
+	return id + 1
+}
+
+func (m *InMemory) GetLoginEvents(email string, since time.Time) ([]*models.LoginEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := []*models.LoginEvent{}
+	for i := len(m.LoginEvents) - 1; i >= 0; i-- {
+		stored := m.LoginEvents[i]
+		if stored.Email != email || stored.CreatedAt.Before(since) {
+			continue
+		}
+		event := *stored
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+func (m *InMemory) RecordLogin(event *models.LoginEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = int64(len(m.LoginEvents) + 1)
+	created := *event
+	m.LoginEvents = append(m.LoginEvents, &created)
+	return nil
+}
+
+func (m *InMemory) GetFraudReview(id int64) (*models.FraudReview, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.findFraudReview(id)
+	if stored == nil {
+		return nil, util.ErrFraudReviewNotFound
+	}
+	review := *stored
+	return &review, nil
+}
+
+func (m *InMemory) GetFraudReviews(status models.FraudReviewStatus) ([]*models.FraudReview, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reviews := []*models.FraudReview{}
+	for _, stored := range m.FraudReviews {
+		if status != "" && stored.Status != status {
+			continue
+		}
+		review := *stored
+		reviews = append(reviews, &review)
+	}
+	return reviews, nil
+}
+
+func (m *InMemory) CreateFraudReview(review *models.FraudReview) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	review.ID = int64(len(m.FraudReviews) + 1)
+	created := *review
+	m.FraudReviews = append(m.FraudReviews, &created)
+	return nil
+}
+
+func (m *InMemory) MoveFraudReview(ctx context.Context, c *FraudReviewChange) (*models.FraudReview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findFraudReview(c.ReviewID)
+	if stored == nil {
+		return nil, util.ErrFraudReviewNotFound
+	}
+	if err := moveFraudReview(stored, c, time.Now()); err != nil {
+		return nil, err
+	}
+	review := *stored
+	return &review, nil
+}
+
+// addWithdrawalTransitionLocked records a transition of the withdrawal
+// with id, the caller must hold mu
+func (m *InMemory) addWithdrawalTransitionLocked(id int64, transition *models.WithdrawalTransition) {
+	transition.WithdrawalID = id
+	transition.ID = int64(len(m.WithdrawalTransitions) + 1)
+	stored := *transition
+	m.WithdrawalTransitions = append(m.WithdrawalTransitions, &stored)
+}
+
+// findDeposit returns the stored deposit with reference, the caller must
+// hold mu
+func (m *InMemory) findDeposit(reference string) *models.Deposit {
+	for _, d := range m.Deposits {
+		if d.Reference == reference {
+			return d
+		}
+	}
+	return nil
+}
+
+// findWithdrawal returns the stored withdrawal with id, the caller must
+// hold mu
+func (m *InMemory) findWithdrawal(id int64) *models.Withdrawal {
+	for _, w := range m.Withdrawals {
+		if w.ID == id {
+			return w
+		}
+	}
+	return nil
+}
+
+// findFraudReview returns the stored review with id, the caller must hold
+// mu
+func (m *InMemory) findFraudReview(id int64) *models.FraudReview {
+	for _, review := range m.FraudReviews {
+		if review.ID == id {
+			return review
+		}
+	}
+	return nil
+}
+
+// findMatch returns the stored match with id, the caller must hold mu
+func (m *InMemory) findMatch(id int64) *models.Match {
+	for _, match := range m.Matches {
+		if match.ID == id {
+			return match
+		}
+	}
+	return nil
+}
+
+// findVoucher returns the stored voucher with code, the caller must hold mu
+func (m *InMemory) findVoucher(code string) *models.Voucher {
+	code = models.NormalizeVoucherCode(code)
+	for _, v := range m.Vouchers {
+		if v.Code == code {
+			return v
+		}
+	}
+	return nil
+}
+
+// findJackpotPool returns the stored pool with id, the caller must hold mu
+func (m *InMemory) findJackpotPool(id int64) *models.JackpotPool {
+	for _, pool := range m.JackpotPools {
+		if pool.ID == id {
+			return pool
+		}
+	}
+	return nil
+}
+
+// jackpotPoolsLocked returns copies of the pools bets with the provider in
+// currency contribute to, the caller must hold mu
+func (m *InMemory) jackpotPoolsLocked(provider, currency string) []*models.JackpotPool {
+	var pools []*models.JackpotPool
+	for _, p := range m.JackpotPools {
+		if p.Provider == provider && p.Currency == currency {
+			pool := *p
+			pools = append(pools, &pool)
+		}
+	}
+	return pools
+}
+
+// jackpotContributionsLocked returns the contributions of bet, the caller
+// must hold mu
+func (m *InMemory) jackpotContributionsLocked(bet *models.GameRoundAction) []*models.JackpotEvent {
+	var events []*models.JackpotEvent
+	for _, e := range m.JackpotEvents {
+		if e.Type == models.JackpotEventContribution && e.ProviderTransactionID == bet.ProviderTransactionID {
+			event := *e
+			events = append(events, &event)
+		}
+	}
+	return events
+}
+
+// appendJackpotEventLocked saves an event once the posting of the pool
+// wallet it records, if any, went through, the caller must hold mu
+func (m *InMemory) appendJackpotEventLocked(event *models.JackpotEvent, posting *Posting) {
+	event.ID = int64(len(m.JackpotEvents) + 1)
+	completeJackpotEvent(event, posting)
+	stored := *event
+	m.JackpotEvents = append(m.JackpotEvents, &stored)
+}
+
+// findGameRound returns the stored round, the caller must hold mu
+func (m *InMemory) findGameRound(provider, roundID string) *models.GameRound {
+	for _, r := range m.GameRounds {
+		if r.Provider == provider && r.RoundID == roundID {
+			return r
+		}
+	}
+	return nil
+}
+
+// findRoundAction returns the stored action with the provider transaction
+// id, the caller must hold mu
+func (m *InMemory) findRoundAction(provider, providerTransactionID string) *models.GameRoundAction {
+	for _, a := range m.RoundActions {
+		if a.Provider == provider && a.ProviderTransactionID == providerTransactionID {
+			return a
+		}
+	}
+	return nil
+}
+
+// findFXQuote returns the stored quote with id, the caller must hold mu
+func (m *InMemory) findFXQuote(id int64) *models.FXQuote {
+	for _, q := range m.FXQuotes {
+		if q.ID == id {
+			return q
+		}
+	}
+	return nil
+}
+
+// settleHoldLocked lets settle move the hold out of the active status and
+// applies the posting it returns, the caller must hold mu
+func (m *InMemory) settleHoldLocked(
+	holdID int64,
+	settle func(hold *models.Hold) (*Posting, error),
+) (*models.Hold, *models.Wallet, error) {
+	stored := m.findHold(holdID)
+	if stored == nil {
+		return nil, nil, util.ErrHoldNotFound
+	}
+	hold := *stored
+	posting, err := settle(&hold)
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := m.postStateLocked(posting)
+	if err != nil {
+		return nil, nil, err
+	}
+	*stored = hold
+	return &hold, wallets[0], nil
+}
+
+// postStateLocked applies postings that go together with a change to other
+// state guarded by mu, the caller must hold mu
+func (m *InMemory) postStateLocked(postings ...*Posting) ([]*models.Wallet, error) {
+	ids := postingWalletIDs(postings)
+	stored := make(map[int64]*models.Wallet, len(ids))
+	for _, id := range ids {
+		wallet := m.findWallet(id)
+		if wallet == nil {
+			return nil, util.ErrWalletNotFound
+		}
+		stored[id] = wallet
+	}
+	unlock := m.lockWallets(ids)
+	defer unlock()
+
+	return m.postLocked(stored, postings)
+}
+
+// findHold returns the stored hold with id, the caller must hold mu
+func (m *InMemory) findHold(id int64) *models.Hold {
+	for _, h := range m.Holds {
+		if h.ID == id {
+			return h
+		}
+	}
+	return nil
+}
+
+// findWallet returns the stored wallet with id, the caller must hold mu
+func (m *InMemory) findWallet(id int64) *models.Wallet {
+	for _, w := range m.Wallets {
+		if w.ID == id {
+			return w
+		}
+	}
+	return nil
+}
+
+func (m *InMemory) findWallets(ids []int64) (map[int64]*models.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wallets := make(map[int64]*models.Wallet, len(ids))
+	for _, id := range ids {
+		wallet := m.findWallet(id)
+		if wallet == nil {
+			return nil, util.ErrWalletNotFound
+		}
+		wallets[id] = wallet
+	}
+	return wallets, nil
+}
+
+// lockWallets takes the lock of every wallet in ids in ascending order and
+// returns a func that releases them
+func (m *InMemory) lockWallets(ids []int64) func() {
+	sorted := append([]int64{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	locks := make([]*sync.Mutex, 0, len(sorted))
+	for _, id := range sorted {
+		lock, _ := m.walletLocks.LoadOrStore(id, &sync.Mutex{})
+		mutex := lock.(*sync.Mutex)
+		mutex.Lock()
+		locks = append(locks, mutex)
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// snapshotWallet copies the stored wallet while holding its lock so the
+// copy never observes a balance that is halfway through being updated
+func (m *InMemory) snapshotWallet(stored *models.Wallet) *models.Wallet {
+	unlock := m.lockWallets([]int64{stored.ID})
+	defer unlock()
+
+	wallet := *stored
+	return &wallet
+}
+
+func (m *InMemory) DeleteWallet(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// get wallet by id
+	for i, w := range m.Wallets {
+		if w.ID == id {
+			m.Wallets = append(m.Wallets[:i], m.Wallets[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *InMemory) CreateIdempotencyKey(key *models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lastID int64
+	for _, k := range m.IdempotencyKeys {
+		if k.Scope == key.Scope && k.Key == key.Key {
+			return util.ErrIdempotencyKeyExists
+		}
+		lastID = k.ID
+	}
+	key.ID = lastID + 1
+	stored := *key
+	m.IdempotencyKeys = append(m.IdempotencyKeys, &stored)
+	return nil
+}
+
+func (m *InMemory) UpdateIdempotencyKey(key *models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, k := range m.IdempotencyKeys {
+		if k.ID == key.ID {
+			stored := *key
+			m.IdempotencyKeys[i] = &stored
+			return nil
+		}
+	}
+	return util.ErrIdempotencyKeyNotFound
+}
+
+func (m *InMemory) DeleteIdempotencyKey(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, k := range m.IdempotencyKeys {
+		if k.ID == id {
+			m.IdempotencyKeys = append(m.IdempotencyKeys[:i], m.IdempotencyKeys[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *InMemory) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	kept := m.IdempotencyKeys[:0]
+	for _, k := range m.IdempotencyKeys {
+		if k.Expired(now) {
+			deleted++
+			continue
+		}
+		kept = append(kept, k)
+	}
+	m.IdempotencyKeys = kept
+	return deleted, nil
+}
+
 // implement Repository interface
 func (m *InMemory) Open() error {
 	return nil
@@ -137,6 +2326,7 @@ func (m *InMemory) Seed() {
 				HashedPassword: hashedPassword,
 				FullName:       util.RandomUserName(),
 				Email:          util.RandomEmail(),
+				IsAdmin:        i == 1, // the first seeded user administers the rest
 				CreatedAt:      time.Now(),
 				UpdatedAt:      time.Now(),
 			}
@@ -151,7 +2341,8 @@ func (m *InMemory) Seed() {
 				ID:        i,
 				UUID:      uuid.New(),
 				UserID:    i,
-				Balance:   util.RandomDecimal(),
+				Currency:  models.DefaultCurrency,
+				Balance:   models.NewMoney(util.RandomDecimal().Round(2)),
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
 			}
@@ -0,0 +1,1742 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWallet(t *testing.T, repo *InMemory, id int64, balance models.Money) {
+	_, err := repo.CreateWallet(&models.Wallet{
+		ID:        id,
+		UUID:      uuid.New(),
+		UserID:    id,
+		Balance:   balance,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+}
+
+func newTestTransaction(txnType models.TransactionType) *models.Transaction {
+	return &models.Transaction{UUID: uuid.New(), Type: txnType}
+}
+
+func TestInMemoryAdjustBalanceConcurrently(t *testing.T) {
+	const (
+		debits  = 200
+		credits = 100
+	)
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(1000))
+
+	ctx := context.Background()
+	debitAmount := models.NewMoneyFromInt(10)
+	creditAmount := models.NewMoneyFromInt(1)
+
+	var succeeded, rejected int64
+	var wg sync.WaitGroup
+	for i := 0; i < debits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.AdjustBalance(ctx, 1, debitAmount.Neg(), NonNegativeBalance, newTestTransaction(models.TransactionTypeDebit))
+			switch {
+			case err == nil:
+				atomic.AddInt64(&succeeded, 1)
+			case errors.Is(err, util.ErrInsufficientFunds):
+				atomic.AddInt64(&rejected, 1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	for i := 0; i < credits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.AdjustBalance(ctx, 1, creditAmount, NonNegativeBalance, newTestTransaction(models.TransactionTypeCredit))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(debits), succeeded+rejected)
+
+	// every credit and every accepted debit must be reflected in the balance
+	wallet, err := repo.GetWallet(1)
+	require.NoError(t, err)
+	want := models.NewMoneyFromInt(1000).
+		Add(creditAmount.Mul(decimal.NewFromInt(credits))).
+		Sub(debitAmount.Mul(decimal.NewFromInt(succeeded)))
+	require.True(t, want.Equal(wallet.Balance), "want balance %s, got %s", want, wallet.Balance)
+	require.False(t, wallet.Balance.IsNegative())
+
+	// the journal must chain, each entry starting where the previous ended
+	transactions, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 1})
+	require.NoError(t, err)
+	require.Len(t, transactions, credits+int(succeeded))
+	for i := len(transactions) - 1; i > 0; i-- {
+		require.True(t, transactions[i].BalanceAfter.Equal(transactions[i-1].BalanceBefore))
+	}
+	require.True(t, transactions[0].BalanceAfter.Equal(wallet.Balance))
+}
+
+func TestInMemoryApplyPostingsConcurrently(t *testing.T) {
+	const transfers = 100
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(transfers))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(transfers))
+
+	ctx := context.Background()
+	one := models.NewMoneyFromInt(1)
+
+	// transfers in both directions at once must neither deadlock nor lose money
+	var wg sync.WaitGroup
+	for i := 0; i < transfers; i++ {
+		for _, pair := range [][2]int64{{1, 2}, {2, 1}} {
+			wg.Add(1)
+			go func(from, to int64) {
+				defer wg.Done()
+				_, err := repo.ApplyPostings(ctx,
+					&Posting{WalletID: from, Amount: one.Neg(), Transaction: newTestTransaction(models.TransactionTypeTransferOut)},
+					&Posting{WalletID: to, Amount: one, Transaction: newTestTransaction(models.TransactionTypeTransferIn)},
+				)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(pair[0], pair[1])
+		}
+	}
+	wg.Wait()
+
+	first, err := repo.GetWallet(1)
+	require.NoError(t, err)
+	second, err := repo.GetWallet(2)
+	require.NoError(t, err)
+	require.True(t, first.Balance.Add(second.Balance).Equal(models.NewMoneyFromInt(2*transfers)))
+}
+
+func TestInMemoryApplyPostingsIsAtomic(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(10))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(0))
+
+	_, err := repo.ApplyPostings(context.Background(),
+		&Posting{WalletID: 2, Amount: models.NewMoneyFromInt(50), Transaction: newTestTransaction(models.TransactionTypeTransferIn)},
+		&Posting{WalletID: 1, Amount: models.NewMoneyFromInt(-50), Transaction: newTestTransaction(models.TransactionTypeTransferOut)},
+	)
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+
+	// the credit leg must not have been applied on its own
+	wallet, err := repo.GetWallet(2)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.IsZero())
+	require.Empty(t, repo.Transactions)
+}
+
+func newTestHold(walletID int64, amount models.Money, expiresAt time.Time) *models.Hold {
+	return &models.Hold{
+		UUID:      uuid.New(),
+		WalletID:  walletID,
+		Amount:    amount,
+		Status:    models.HoldStatusActive,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func TestInMemoryHolds(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	hold := newTestHold(1, models.NewMoneyFromInt(60), expiresAt)
+	wallet, err := repo.CreateHold(ctx, hold)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(100)))
+	require.True(t, wallet.AvailableBalance().Equal(models.NewMoneyFromInt(40)))
+
+	// held funds cannot be spent or held again
+	_, err = repo.CreateHold(ctx, newTestHold(1, models.NewMoneyFromInt(50), expiresAt))
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+	_, err = repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-50), NonNegativeBalance, newTestTransaction(models.TransactionTypeDebit))
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+
+	// a partial capture debits the captured amount and frees the rest
+	_, _, err = repo.CaptureHold(ctx, hold.ID, models.NewMoneyFromInt(70), newTestTransaction(models.TransactionTypeHoldCapture))
+	require.ErrorIs(t, err, util.ErrCaptureExceedsHold)
+	captured, wallet, err := repo.CaptureHold(ctx, hold.ID, models.NewMoneyFromInt(25), newTestTransaction(models.TransactionTypeHoldCapture))
+	require.NoError(t, err)
+	require.Equal(t, models.HoldStatusCaptured, captured.Status)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(75)))
+	require.True(t, wallet.AvailableBalance().Equal(models.NewMoneyFromInt(75)))
+	require.Len(t, repo.Transactions, 1)
+
+	_, _, err = repo.ReleaseHold(ctx, hold.ID)
+	require.ErrorIs(t, err, util.ErrHoldNotActive)
+
+	// expired holds give their funds back without a journal entry
+	expiring := newTestHold(1, models.NewMoneyFromInt(30), time.Now().Add(time.Minute))
+	_, err = repo.CreateHold(ctx, expiring)
+	require.NoError(t, err)
+	expired, err := repo.ExpireHolds(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), expired)
+
+	stored, err := repo.GetHold(expiring.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.HoldStatusExpired, stored.Status)
+	wallet, err = repo.GetWallet(1)
+	require.NoError(t, err)
+	require.True(t, wallet.HeldBalance.IsZero())
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(75)))
+	require.Len(t, repo.Transactions, 1)
+}
+
+func TestInMemoryWalletCurrencies(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+
+	// one wallet per currency per user
+	_, err := repo.CreateWallet(&models.Wallet{UUID: uuid.New(), UserID: 1, Currency: models.DefaultCurrency})
+	require.ErrorIs(t, err, util.ErrWalletExists)
+	id, err := repo.CreateWallet(&models.Wallet{UUID: uuid.New(), UserID: 1, Currency: "JPY"})
+	require.NoError(t, err)
+	wallets, err := repo.GetWalletsByUserID(1)
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+
+	ctx := context.Background()
+	_, err = repo.ApplyPostings(ctx,
+		&Posting{WalletID: 1, Currency: models.DefaultCurrency, Amount: models.NewMoneyFromInt(-10), Transaction: newTestTransaction(models.TransactionTypeTransferOut)},
+		&Posting{WalletID: id, Currency: models.DefaultCurrency, Amount: models.NewMoneyFromInt(10), Transaction: newTestTransaction(models.TransactionTypeTransferIn)},
+	)
+	require.ErrorIs(t, err, util.ErrCurrencyMismatch)
+
+	// yen have no minor unit
+	_, err = repo.AdjustBalance(ctx, id, models.RequireMoney("10.5"), NonNegativeBalance, newTestTransaction(models.TransactionTypeCredit))
+	require.ErrorIs(t, err, util.ErrInvalidAmountPrecision)
+	_, err = repo.AdjustBalance(ctx, 1, models.RequireMoney("10.505"), NonNegativeBalance, newTestTransaction(models.TransactionTypeCredit))
+	require.ErrorIs(t, err, util.ErrInvalidAmountPrecision)
+
+	txn := newTestTransaction(models.TransactionTypeCredit)
+	_, err = repo.AdjustBalance(ctx, id, models.NewMoneyFromInt(10), NonNegativeBalance, txn)
+	require.NoError(t, err)
+	require.Equal(t, "JPY", txn.Currency)
+}
+
+func TestInMemoryExecuteFXQuote(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	jpy, err := repo.CreateWallet(&models.Wallet{UUID: uuid.New(), UserID: 1, Currency: "JPY"})
+	require.NoError(t, err)
+
+	quote := &models.FXQuote{
+		UUID:            uuid.New(),
+		UserID:          1,
+		FromWalletID:    1,
+		ToWalletID:      jpy,
+		FromCurrency:    models.DefaultCurrency,
+		ToCurrency:      "JPY",
+		Rate:            decimal.NewFromInt(150),
+		Amount:          models.NewMoneyFromInt(10),
+		ConvertedAmount: models.NewMoneyFromInt(1500),
+		ExpiresAt:       time.Now().Add(time.Minute),
+	}
+	require.NoError(t, repo.CreateFXQuote(quote))
+
+	ctx := context.Background()
+	_, wallets, err := repo.ExecuteFXQuote(ctx, quote.ID,
+		newTestTransaction(models.TransactionTypeConversionOut),
+		newTestTransaction(models.TransactionTypeConversionIn),
+	)
+	require.NoError(t, err)
+	require.True(t, wallets[0].Balance.Equal(models.NewMoneyFromInt(90)))
+	require.True(t, wallets[1].Balance.Equal(models.NewMoneyFromInt(1500)))
+
+	// a quote can only be used once
+	_, _, err = repo.ExecuteFXQuote(ctx, quote.ID,
+		newTestTransaction(models.TransactionTypeConversionOut),
+		newTestTransaction(models.TransactionTypeConversionIn),
+	)
+	require.ErrorIs(t, err, util.ErrQuoteExecuted)
+	require.Len(t, repo.Transactions, 2)
+}
+
+func TestInMemoryFees(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	// revenue
+	newTestWallet(t, repo, 2, models.ZeroMoney)
+	jpy, err := repo.CreateWallet(&models.Wallet{UUID: uuid.New(), UserID: 1, Currency: "JPY"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	balance := func(id int64) models.Money {
+		wallet, err := repo.GetWallet(id)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+
+	// the fee of a conversion is charged out of the quoted amount
+	quote := &models.FXQuote{
+		UUID:            uuid.New(),
+		UserID:          1,
+		FromWalletID:    1,
+		ToWalletID:      jpy,
+		FromCurrency:    models.DefaultCurrency,
+		ToCurrency:      "JPY",
+		Rate:            decimal.NewFromInt(150),
+		Amount:          models.NewMoneyFromInt(10),
+		ConvertedAmount: models.NewMoneyFromInt(1350),
+		Fee:             models.NewMoneyFromInt(1),
+		FeeWalletID:     2,
+		ExpiresAt:       time.Now().Add(time.Minute),
+	}
+	require.NoError(t, repo.CreateFXQuote(quote))
+	_, wallets, err := repo.ExecuteFXQuote(ctx, quote.ID,
+		newTestTransaction(models.TransactionTypeConversionOut),
+		newTestTransaction(models.TransactionTypeConversionIn),
+	)
+	require.NoError(t, err)
+	require.Len(t, wallets, 3)
+	require.Equal(t, jpy, wallets[1].ID)
+	require.True(t, balance(1).Equal(models.NewMoneyFromInt(90)))
+	require.True(t, balance(2).Equal(models.NewMoneyFromInt(1)))
+
+	// the fee of a withdrawal is only charged when it is paid
+	withdrawal := &models.Withdrawal{
+		UUID:        uuid.New(),
+		Reference:   "withdrawal-1",
+		WalletID:    1,
+		Currency:    models.DefaultCurrency,
+		Amount:      models.NewMoneyFromInt(50),
+		Fee:         models.NewMoneyFromInt(2),
+		FeeWalletID: 2,
+	}
+	_, err = repo.RequestWithdrawal(ctx, withdrawal)
+	require.NoError(t, err)
+	for _, status := range []models.WithdrawalStatus{models.WithdrawalStatusUnderReview, models.WithdrawalStatusApproved} {
+		_, err = repo.MoveWithdrawal(ctx, &WithdrawalChange{WithdrawalID: withdrawal.ID, Status: status})
+		require.NoError(t, err)
+	}
+	require.True(t, balance(2).Equal(models.NewMoneyFromInt(1)))
+	result, err := repo.MoveWithdrawal(ctx, &WithdrawalChange{WithdrawalID: withdrawal.ID, Status: models.WithdrawalStatusPaid})
+	require.NoError(t, err)
+	require.True(t, result.Wallet.Balance.Equal(models.NewMoneyFromInt(40)))
+	require.True(t, result.Wallet.HeldBalance.IsZero())
+	require.True(t, result.FeeWallet.Balance.Equal(models.NewMoneyFromInt(3)))
+
+	// the payout is journaled net of the fee, which is journaled on its own
+	fees, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 1, Type: models.TransactionTypeFee})
+	require.NoError(t, err)
+	require.Len(t, fees, 2)
+	payouts, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 1, Type: models.TransactionTypeWithdrawal})
+	require.NoError(t, err)
+	require.Len(t, payouts, 1)
+	require.True(t, payouts[0].Amount.Equal(models.NewMoneyFromInt(48)))
+	require.Equal(t, payouts[0].CorrelationID, fees[0].CorrelationID)
+}
+
+func TestInMemoryVelocityRules(t *testing.T) {
+	repo := NewInMemory()
+
+	tier := &models.VelocityRule{Tier: "gold", Currency: "USD", MaxDebit: models.NewMoneyFromInt(500)}
+	require.NoError(t, repo.SetVelocityRule(tier))
+	wallet := &models.VelocityRule{WalletID: 7, Currency: "USD", MaxOperations: 3, WindowSeconds: 60}
+	require.NoError(t, repo.SetVelocityRule(wallet))
+
+	// setting the rule of the same tier and currency replaces it
+	require.NoError(t, repo.SetVelocityRule(&models.VelocityRule{Tier: "gold", Currency: "USD", MaxDebit: models.NewMoneyFromInt(800)}))
+
+	rules, err := repo.GetVelocityRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	// the rules of wallets come first
+	require.Equal(t, int64(7), rules[0].WalletID)
+	require.Equal(t, tier.ID, rules[1].ID)
+	require.True(t, rules[1].MaxDebit.Equal(models.NewMoneyFromInt(800)))
+
+	// the rule of a wallet comes before the rule of its tier
+	rule, err := repo.GetVelocityRule(7, "gold", "USD")
+	require.NoError(t, err)
+	require.Equal(t, wallet.ID, rule.ID)
+	rule, err = repo.GetVelocityRule(8, "gold", "USD")
+	require.NoError(t, err)
+	require.Equal(t, tier.ID, rule.ID)
+	_, err = repo.GetVelocityRule(8, "gold", "EUR")
+	require.ErrorIs(t, err, util.ErrVelocityRuleNotFound)
+
+	require.NoError(t, repo.RemoveVelocityRule(wallet.ID))
+	require.ErrorIs(t, repo.RemoveVelocityRule(wallet.ID), util.ErrVelocityRuleNotFound)
+	rules, err = repo.GetVelocityRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+}
+
+func TestInMemoryFraudReview(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+
+	newReview := func(operation models.TransactionType, amount int64) *models.FraudReview {
+		review := &models.FraudReview{
+			UUID:      uuid.New(),
+			WalletID:  1,
+			UserID:    1,
+			Operation: operation,
+			Currency:  "USD",
+			Amount:    models.NewMoneyFromInt(amount),
+			Actor:     "player@email.com",
+			Status:    models.FraudReviewStatusPending,
+		}
+		require.NoError(t, repo.CreateFraudReview(review))
+		return review
+	}
+	debit := newReview(models.TransactionTypeDebit, 40)
+	credit := newReview(models.TransactionTypeCredit, 500)
+
+	pending, err := repo.GetFraudReviews(models.FraudReviewStatusPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, debit.ID, pending[0].ID)
+
+	approving := &FraudReviewChange{ReviewID: debit.ID, Status: models.FraudReviewStatusApproving, Reviewer: "admin@email.com"}
+	review, err := repo.MoveFraudReview(ctx, approving)
+	require.NoError(t, err)
+	require.Equal(t, models.FraudReviewStatusApproving, review.Status)
+	require.Nil(t, review.ReviewedAt)
+
+	// a review being approved cannot be claimed or denied meanwhile
+	_, err = repo.MoveFraudReview(ctx, approving)
+	require.ErrorIs(t, err, util.ErrFraudReviewTransition)
+	_, err = repo.MoveFraudReview(ctx, &FraudReviewChange{ReviewID: debit.ID, Status: models.FraudReviewStatusDenied, Reviewer: "admin@email.com"})
+	require.ErrorIs(t, err, util.ErrFraudReviewTransition)
+
+	// the operation was refused, so the review waits for a reviewer again
+	review, err = repo.MoveFraudReview(ctx, &FraudReviewChange{ReviewID: debit.ID, Status: models.FraudReviewStatusPending})
+	require.NoError(t, err)
+	require.Equal(t, models.FraudReviewStatusPending, review.Status)
+	require.Empty(t, review.Reviewer)
+
+	_, err = repo.MoveFraudReview(ctx, approving)
+	require.NoError(t, err)
+	review, err = repo.MoveFraudReview(ctx, &FraudReviewChange{ReviewID: debit.ID, Status: models.FraudReviewStatusApproved, Reviewer: "admin@email.com"})
+	require.NoError(t, err)
+	require.Equal(t, models.FraudReviewStatusApproved, review.Status)
+	require.Equal(t, "admin@email.com", review.Reviewer)
+	require.NotNil(t, review.ReviewedAt)
+
+	_, err = repo.MoveFraudReview(ctx, &FraudReviewChange{ReviewID: debit.ID, Status: models.FraudReviewStatusDenied, Reviewer: "admin@email.com", Note: "changed my mind"})
+	require.ErrorIs(t, err, util.ErrFraudReviewResolved)
+
+	review, err = repo.MoveFraudReview(ctx, &FraudReviewChange{ReviewID: credit.ID, Status: models.FraudReviewStatusDenied, Reviewer: "admin@email.com", Note: "stolen card"})
+	require.NoError(t, err)
+	require.Equal(t, models.FraudReviewStatusDenied, review.Status)
+	require.Equal(t, "stolen card", review.Note)
+
+	// moving a review leaves the wallet to the caller
+	wallet, err := repo.GetWallet(1)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(100)))
+
+	pending, err = repo.GetFraudReviews(models.FraudReviewStatusPending)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	_, err = repo.GetFraudReview(99)
+	require.ErrorIs(t, err, util.ErrFraudReviewNotFound)
+}
+
+func TestInMemoryReverseTransaction(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+
+	debit := newTestTransaction(models.TransactionTypeDebit)
+	_, err := repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-40), NonNegativeBalance, debit)
+	require.NoError(t, err)
+
+	// partial refunds add up to at most the original amount
+	refund := newTestTransaction(models.TransactionTypeReversal)
+	wallet, err := repo.ReverseTransaction(ctx, debit.ID, moneyPtr(15), refund)
+	require.NoError(t, err)
+	require.Equal(t, debit.ID, refund.OriginalID)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(75)))
+
+	_, err = repo.ReverseTransaction(ctx, debit.ID, moneyPtr(30), newTestTransaction(models.TransactionTypeReversal))
+	require.ErrorIs(t, err, util.ErrReversalExceedsTransaction)
+
+	// without an amount the rest is reversed
+	rest := newTestTransaction(models.TransactionTypeReversal)
+	wallet, err = repo.ReverseTransaction(ctx, debit.ID, nil, rest)
+	require.NoError(t, err)
+	require.True(t, rest.Amount.Equal(models.NewMoneyFromInt(25)))
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(100)))
+
+	_, err = repo.ReverseTransaction(ctx, debit.ID, nil, newTestTransaction(models.TransactionTypeReversal))
+	require.ErrorIs(t, err, util.ErrTransactionAlreadyReversed)
+
+	// reversals themselves cannot be reversed
+	_, err = repo.ReverseTransaction(ctx, rest.ID, nil, newTestTransaction(models.TransactionTypeReversal))
+	require.ErrorIs(t, err, util.ErrTransactionNotReversible)
+
+	reversals, err := repo.GetReversals(debit.ID)
+	require.NoError(t, err)
+	require.Len(t, reversals, 2)
+}
+
+func TestInMemoryReverseTransactionConcurrently(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(0))
+	ctx := context.Background()
+
+	credit := newTestTransaction(models.TransactionTypeCredit)
+	_, err := repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(100), NonNegativeBalance, credit)
+	require.NoError(t, err)
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.ReverseTransaction(ctx, credit.ID, nil, newTestTransaction(models.TransactionTypeReversal))
+			if err == nil {
+				atomic.AddInt64(&succeeded, 1)
+			} else if !errors.Is(err, util.ErrTransactionAlreadyReversed) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(1), succeeded)
+	wallet, err := repo.GetWallet(1)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.IsZero())
+}
+
+func moneyPtr(amount int64) *models.Money {
+	money := models.NewMoneyFromInt(amount)
+	return &money
+}
+
+func newTestRoundAction(actionType models.GameRoundActionType, providerTxnID string, amount int64) *models.GameRoundAction {
+	return &models.GameRoundAction{
+		Provider:              "acme",
+		ProviderTransactionID: providerTxnID,
+		Type:                  actionType,
+		Amount:                models.NewMoneyFromInt(amount),
+	}
+}
+
+func TestInMemoryGameRound(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+
+	// the first bet starts the round
+	result, err := repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-1", 30), newTestTransaction(models.TransactionTypeBet))
+	require.NoError(t, err)
+	require.False(t, result.Duplicate)
+	require.Equal(t, models.GameRoundStatusOpen, result.Round.Status)
+	require.Equal(t, models.DefaultCurrency, result.Round.Currency)
+	require.True(t, result.Wallet.Balance.Equal(models.NewMoneyFromInt(70)))
+	require.NotZero(t, result.Action.TransactionID)
+
+	// a retried bet is answered with the first outcome and moves nothing
+	result, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-1", 30), newTestTransaction(models.TransactionTypeBet))
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+	require.True(t, result.Wallet.Balance.Equal(models.NewMoneyFromInt(70)))
+	require.Len(t, repo.Transactions, 1)
+
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-1", 40), newTestTransaction(models.TransactionTypeBet))
+	require.ErrorIs(t, err, util.ErrGameRoundActionConflict)
+	_, err = repo.ApplyGameRoundAction(ctx, 2, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-2", 10), newTestTransaction(models.TransactionTypeBet))
+	require.ErrorIs(t, err, util.ErrGameRoundWalletMismatch)
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-2", 500), newTestTransaction(models.TransactionTypeBet))
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+
+	// the win settles the round, its bets can no longer be rolled back
+	result, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionWin, "tx-3", 50), newTestTransaction(models.TransactionTypeWin))
+	require.NoError(t, err)
+	require.Equal(t, models.GameRoundStatusSettled, result.Round.Status)
+	require.True(t, result.Round.TotalWin.Equal(models.NewMoneyFromInt(50)))
+	require.True(t, result.Wallet.Balance.Equal(models.NewMoneyFromInt(120)))
+
+	rollback := newTestRoundAction(models.GameRoundActionRollback, "tx-4", 0)
+	rollback.RollbackOf = "tx-1"
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", rollback, newTestTransaction(models.TransactionTypeRollback))
+	require.ErrorIs(t, err, util.ErrGameRoundClosed)
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-5", 10), newTestTransaction(models.TransactionTypeBet))
+	require.ErrorIs(t, err, util.ErrGameRoundClosed)
+
+	// rolling back the only bet of an open round closes it
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-2", newTestRoundAction(models.GameRoundActionBet, "tx-6", 20), newTestTransaction(models.TransactionTypeBet))
+	require.NoError(t, err)
+	rollback = newTestRoundAction(models.GameRoundActionRollback, "tx-7", 0)
+	rollback.RollbackOf = "tx-6"
+	result, err = repo.ApplyGameRoundAction(ctx, 1, "round-2", rollback, newTestTransaction(models.TransactionTypeRollback))
+	require.NoError(t, err)
+	require.Equal(t, models.GameRoundStatusRolledBack, result.Round.Status)
+	require.True(t, result.Action.Amount.Equal(models.NewMoneyFromInt(20)))
+	require.True(t, result.Wallet.Balance.Equal(models.NewMoneyFromInt(120)))
+
+	again := newTestRoundAction(models.GameRoundActionRollback, "tx-8", 0)
+	again.RollbackOf = "tx-6"
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-2", again, newTestTransaction(models.TransactionTypeRollback))
+	require.ErrorIs(t, err, util.ErrGameRoundBetAlreadyRolled)
+
+	_, err = repo.ApplyGameRoundAction(ctx, 1, "round-3", newTestRoundAction(models.GameRoundActionWin, "tx-9", 10), newTestTransaction(models.TransactionTypeWin))
+	require.ErrorIs(t, err, util.ErrGameRoundNotFound)
+
+	actions, err := repo.GetGameRoundActions(result.Round.ID)
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+	require.True(t, actions[0].RolledBack)
+}
+
+func TestInMemoryGameRoundRetriedConcurrently(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+
+	// a provider retrying a bet before the first attempt answered must not
+	// take the stake twice
+	const attempts = 20
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-1", 10), newTestTransaction(models.TransactionTypeBet))
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	wallet, err := repo.GetWallet(1)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(90)))
+	require.Len(t, repo.Transactions, 1)
+}
+
+func newTestBonusGrant(walletID int64, amount, requirement int64, order models.BonusDebitOrder) *models.BonusGrant {
+	return &models.BonusGrant{
+		UUID:                uuid.New(),
+		WalletID:            walletID,
+		Amount:              models.NewMoneyFromInt(amount),
+		Remaining:           models.NewMoneyFromInt(amount),
+		WageringRequirement: models.NewMoneyFromInt(requirement),
+		DebitOrder:          order,
+		Status:              models.BonusStatusActive,
+		ExpiresAt:           time.Now().Add(time.Hour),
+	}
+}
+
+func TestInMemoryBonusGrants(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	newTestWallet(t, repo, 2, models.ZeroMoney)
+	ctx := context.Background()
+
+	bonusFirst := newTestBonusGrant(1, 50, 100, models.BonusDebitOrderBonusFirst)
+	wallet, err := repo.CreateBonusGrant(ctx, bonusFirst, newTestTransaction(models.TransactionTypeBonusGrant))
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(150)))
+	require.True(t, wallet.BonusBalance.Equal(models.NewMoneyFromInt(50)))
+
+	// bonus funds cannot leave the wallet other than as a stake
+	_, err = repo.ApplyPostings(ctx,
+		&Posting{WalletID: 1, Amount: models.NewMoneyFromInt(-120), Transaction: newTestTransaction(models.TransactionTypeTransferOut)},
+		&Posting{WalletID: 2, Amount: models.NewMoneyFromInt(120), Transaction: newTestTransaction(models.TransactionTypeTransferIn)},
+	)
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+
+	// a bonus first grant pays the stake before real money
+	debit := newTestTransaction(models.TransactionTypeDebit)
+	wallet, err = repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-30), NonNegativeBalance, debit)
+	require.NoError(t, err)
+	require.True(t, debit.BonusAmount.Equal(models.NewMoneyFromInt(30)))
+	require.True(t, wallet.BonusBalance.Equal(models.NewMoneyFromInt(20)))
+	require.True(t, wallet.RealBalance().Equal(models.NewMoneyFromInt(100)))
+
+	// a real first grant is only used once real money runs out
+	realFirst := newTestBonusGrant(1, 40, 1000, models.BonusDebitOrderRealFirst)
+	_, err = repo.CreateBonusGrant(ctx, realFirst, newTestTransaction(models.TransactionTypeBonusGrant))
+	require.NoError(t, err)
+	debit = newTestTransaction(models.TransactionTypeDebit)
+	wallet, err = repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-130), NonNegativeBalance, debit)
+	require.NoError(t, err)
+	require.True(t, debit.BonusAmount.Equal(models.NewMoneyFromInt(30)))
+	require.True(t, wallet.RealBalance().IsZero())
+
+	// the 160 wagered met the requirement of the first grant, its remaining
+	// funds are real money now
+	grant, err := repo.GetBonusGrant(bonusFirst.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.BonusStatusCompleted, grant.Status)
+	grant, err = repo.GetBonusGrant(realFirst.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.BonusStatusActive, grant.Status)
+	require.True(t, grant.Remaining.Equal(models.NewMoneyFromInt(30)))
+	require.True(t, grant.Wagered.Equal(models.NewMoneyFromInt(60)))
+	require.True(t, wallet.BonusBalance.Equal(models.NewMoneyFromInt(30)))
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(30)))
+
+	// forfeiting takes what is left of the grant out of the wallet
+	forfeited, wallet, err := repo.ForfeitBonusGrant(ctx, realFirst.ID, newTestTransaction(models.TransactionTypeBonusForfeit))
+	require.NoError(t, err)
+	require.Equal(t, models.BonusStatusForfeited, forfeited.Status)
+	require.True(t, wallet.Balance.IsZero())
+	require.True(t, wallet.BonusBalance.IsZero())
+	_, _, err = repo.ForfeitBonusGrant(ctx, realFirst.ID, newTestTransaction(models.TransactionTypeBonusForfeit))
+	require.ErrorIs(t, err, util.ErrBonusNotActive)
+}
+
+func TestInMemoryRefundedBonusStakes(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+
+	bonusFirst := newTestBonusGrant(1, 50, 1000, models.BonusDebitOrderBonusFirst)
+	_, err := repo.CreateBonusGrant(ctx, bonusFirst, newTestTransaction(models.TransactionTypeBonusGrant))
+	require.NoError(t, err)
+	requireGrant := func(remaining, wagered int64) {
+		grant, err := repo.GetBonusGrant(bonusFirst.ID)
+		require.NoError(t, err)
+		require.True(t, grant.Remaining.Equal(models.NewMoneyFromInt(remaining)))
+		require.True(t, grant.Wagered.Equal(models.NewMoneyFromInt(wagered)))
+	}
+
+	// a rolled back bet gives the bonus funds it was paid out of back to
+	// the grant, not as withdrawable money
+	result, err := repo.ApplyGameRoundAction(ctx, 1, "round-1", newTestRoundAction(models.GameRoundActionBet, "tx-1", 30), newTestTransaction(models.TransactionTypeBet))
+	require.NoError(t, err)
+	require.True(t, result.Action.BonusAmount.Equal(models.NewMoneyFromInt(30)))
+	requireGrant(20, 30)
+	rollback := newTestRoundAction(models.GameRoundActionRollback, "tx-2", 0)
+	rollback.RollbackOf = "tx-1"
+	result, err = repo.ApplyGameRoundAction(ctx, 1, "round-1", rollback, newTestTransaction(models.TransactionTypeRollback))
+	require.NoError(t, err)
+	require.True(t, result.Wallet.RealBalance().Equal(models.NewMoneyFromInt(100)))
+	require.True(t, result.Wallet.BonusBalance.Equal(models.NewMoneyFromInt(50)))
+	requireGrant(50, 0)
+
+	// so does a reversal, one part at a time
+	debit := newTestTransaction(models.TransactionTypeDebit)
+	_, err = repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-40), NonNegativeBalance, debit)
+	require.NoError(t, err)
+	refund := newTestTransaction(models.TransactionTypeReversal)
+	wallet, err := repo.ReverseTransaction(ctx, debit.ID, moneyPtr(25), refund)
+	require.NoError(t, err)
+	require.True(t, refund.BonusAmount.Equal(models.NewMoneyFromInt(25)))
+	require.True(t, wallet.RealBalance().Equal(models.NewMoneyFromInt(100)))
+	requireGrant(35, 15)
+	wallet, err = repo.ReverseTransaction(ctx, debit.ID, nil, newTestTransaction(models.TransactionTypeReversal))
+	require.NoError(t, err)
+	require.True(t, wallet.RealBalance().Equal(models.NewMoneyFromInt(100)))
+	require.True(t, wallet.BonusBalance.Equal(models.NewMoneyFromInt(50)))
+	requireGrant(50, 0)
+
+	// bonus funds of a grant that was forfeited since are forfeited again
+	debit = newTestTransaction(models.TransactionTypeDebit)
+	_, err = repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-20), NonNegativeBalance, debit)
+	require.NoError(t, err)
+	_, _, err = repo.ForfeitBonusGrant(ctx, bonusFirst.ID, newTestTransaction(models.TransactionTypeBonusForfeit))
+	require.NoError(t, err)
+	wallet, err = repo.ReverseTransaction(ctx, debit.ID, nil, newTestTransaction(models.TransactionTypeReversal))
+	require.NoError(t, err)
+	require.True(t, wallet.RealBalance().Equal(models.NewMoneyFromInt(100)))
+	require.True(t, wallet.BonusBalance.IsZero())
+	forfeit := repo.Transactions[len(repo.Transactions)-1]
+	require.Equal(t, models.TransactionTypeBonusForfeit, forfeit.Type)
+	require.True(t, forfeit.BonusAmount.Equal(models.NewMoneyFromInt(20)))
+}
+
+func TestInMemoryExpireBonusGrants(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(10))
+	ctx := context.Background()
+
+	grant := newTestBonusGrant(1, 25, 250, models.BonusDebitOrderRealFirst)
+	grant.ExpiresAt = time.Now().Add(time.Minute)
+	_, err := repo.CreateBonusGrant(ctx, grant, newTestTransaction(models.TransactionTypeBonusGrant))
+	require.NoError(t, err)
+
+	expired, err := repo.ExpireBonusGrants(ctx, time.Now())
+	require.NoError(t, err)
+	require.Zero(t, expired)
+	expired, err = repo.ExpireBonusGrants(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), expired)
+
+	wallet, err := repo.GetWallet(1)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(10)))
+	require.True(t, wallet.BonusBalance.IsZero())
+	last := repo.Transactions[len(repo.Transactions)-1]
+	require.Equal(t, models.TransactionTypeBonusExpiry, last.Type)
+	require.True(t, last.Amount.Equal(models.NewMoneyFromInt(25)))
+}
+
+func TestInMemoryGamingLimits(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(1000))
+	ctx := context.Background()
+
+	credit := func(amount int64) error {
+		_, err := repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(amount), NonNegativeBalance, newTestTransaction(models.TransactionTypeCredit))
+		return err
+	}
+	debit := func(amount int64) error {
+		_, err := repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(-amount), NonNegativeBalance, newTestTransaction(models.TransactionTypeDebit))
+		return err
+	}
+	setLimit := func(kind models.LimitKind, period models.LimitPeriod, amount int64, coolingOff time.Duration) *models.GamingLimit {
+		limit, err := repo.SetGamingLimit(ctx, &LimitChange{
+			WalletID:   1,
+			Kind:       kind,
+			Period:     period,
+			Amount:     models.NewMoneyFromInt(amount),
+			CoolingOff: coolingOff,
+		})
+		require.NoError(t, err)
+		return limit
+	}
+
+	// a new limit applies at once
+	setLimit(models.LimitKindDeposit, models.LimitPeriodDaily, 100, time.Hour)
+	require.NoError(t, credit(60))
+	require.ErrorIs(t, credit(50), util.ErrDepositLimitExceeded)
+	// debits are not deposits
+	require.NoError(t, debit(10))
+
+	// a raise waits for the cooling-off period
+	limit := setLimit(models.LimitKindDeposit, models.LimitPeriodDaily, 200, time.Hour)
+	require.True(t, limit.Amount.Equal(models.NewMoneyFromInt(100)))
+	require.True(t, limit.PendingAmount.Equal(models.NewMoneyFromInt(200)))
+	require.ErrorIs(t, credit(50), util.ErrDepositLimitExceeded)
+
+	// lowering applies at once and drops the pending raise
+	limit = setLimit(models.LimitKindDeposit, models.LimitPeriodDaily, 80, time.Hour)
+	require.True(t, limit.Amount.Equal(models.NewMoneyFromInt(80)))
+	require.Nil(t, limit.PendingFrom)
+	require.ErrorIs(t, credit(30), util.ErrDepositLimitExceeded)
+	require.NoError(t, credit(20))
+
+	// a raise whose cooling-off period is over is in force
+	setLimit(models.LimitKindDeposit, models.LimitPeriodDaily, 150, 0)
+	require.NoError(t, credit(70))
+
+	// wins are taken off the losses but not off the stakes
+	setLimit(models.LimitKindLoss, models.LimitPeriodWeekly, 100, time.Hour)
+	setLimit(models.LimitKindWager, models.LimitPeriodMonthly, 200, time.Hour)
+	require.NoError(t, debit(80))
+	require.ErrorIs(t, debit(20), util.ErrLossLimitExceeded)
+	_, err := repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(200), NonNegativeBalance, newTestTransaction(models.TransactionTypeWin))
+	require.NoError(t, err)
+	require.NoError(t, debit(100))
+	require.ErrorIs(t, debit(20), util.ErrWagerLimitExceeded)
+
+	// lifting a limit waits for the cooling-off period too
+	_, err = repo.SetGamingLimit(ctx, &LimitChange{
+		WalletID:   1,
+		Kind:       models.LimitKindWager,
+		Period:     models.LimitPeriodMonthly,
+		Remove:     true,
+		CoolingOff: time.Hour,
+	})
+	require.NoError(t, err)
+	limits, err := repo.GetGamingLimits(1)
+	require.NoError(t, err)
+	require.Len(t, limits, 3)
+	require.True(t, limits[2].PendingRemoval)
+	_, err = repo.SetGamingLimit(ctx, &LimitChange{
+		WalletID: 1,
+		Kind:     models.LimitKindDeposit,
+		Period:   models.LimitPeriodWeekly,
+		Remove:   true,
+	})
+	require.ErrorIs(t, err, util.ErrGamingLimitNotFound)
+}
+
+func TestInMemoryGamingLimitsOfTournaments(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(1000))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(1000))
+	// escrow and rake
+	newTestWallet(t, repo, 3, models.ZeroMoney)
+	newTestWallet(t, repo, 4, models.ZeroMoney)
+	ctx := context.Background()
+	for kind, amount := range map[models.LimitKind]int64{models.LimitKindLoss: 50, models.LimitKindWager: 100} {
+		_, err := repo.SetGamingLimit(ctx, &LimitChange{WalletID: 1, Kind: kind, Period: models.LimitPeriodDaily, Amount: models.NewMoneyFromInt(amount)})
+		require.NoError(t, err)
+	}
+	create := func(fee int64) *models.Tournament {
+		tournament := &models.Tournament{
+			UUID:           uuid.New(),
+			Currency:       models.DefaultCurrency,
+			EntryFee:       models.NewMoneyFromInt(fee),
+			PayoutTable:    "100",
+			EscrowWalletID: 3,
+			RakeWalletID:   4,
+			Status:         models.TournamentStatusOpen,
+		}
+		require.NoError(t, repo.CreateTournament(tournament))
+		return tournament
+	}
+	enter := func(tournament *models.Tournament, walletID int64) error {
+		_, _, err := repo.EnterTournament(ctx, &models.TournamentEntry{TournamentID: tournament.ID, WalletID: walletID, UserID: walletID}, "player@email.com")
+		return err
+	}
+
+	// entry fees are losses
+	cancelled := create(40)
+	require.NoError(t, enter(cancelled, 1))
+	require.ErrorIs(t, enter(create(20), 1), util.ErrLossLimitExceeded)
+
+	// refunded entry fees and prizes are taken off the losses but not off
+	// the stakes
+	_, _, err := repo.CancelTournament(ctx, cancelled.ID, "admin@email.com")
+	require.NoError(t, err)
+	won := create(30)
+	require.NoError(t, enter(won, 1))
+	require.NoError(t, enter(won, 2))
+	_, _, err = repo.CloseTournament(ctx, won.ID, []int64{1}, "admin@email.com")
+	require.NoError(t, err)
+	require.ErrorIs(t, enter(create(31), 1), util.ErrWagerLimitExceeded)
+	require.NoError(t, enter(create(30), 1))
+}
+
+func TestInMemoryGamingLimitsOfMatches(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(1000))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(1000))
+	// escrow and rake
+	newTestWallet(t, repo, 3, models.ZeroMoney)
+	newTestWallet(t, repo, 4, models.ZeroMoney)
+	ctx := context.Background()
+	for kind, amount := range map[models.LimitKind]int64{models.LimitKindLoss: 50, models.LimitKindWager: 100} {
+		_, err := repo.SetGamingLimit(ctx, &LimitChange{WalletID: 1, Kind: kind, Period: models.LimitPeriodDaily, Amount: models.NewMoneyFromInt(amount)})
+		require.NoError(t, err)
+	}
+	start := func(reference string, stake int64) (*models.Match, error) {
+		result, err := repo.StartMatch(ctx, &models.Match{
+			UUID:              uuid.New(),
+			Reference:         reference,
+			Currency:          models.DefaultCurrency,
+			Stake:             models.NewMoneyFromInt(stake),
+			RakePercent:       decimal.Zero,
+			PlayerOneWalletID: 1,
+			PlayerTwoWalletID: 2,
+			EscrowWalletID:    3,
+			RakeWalletID:      4,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.Match, nil
+	}
+	finish := func(match *models.Match, status models.MatchStatus, winner int64) {
+		_, err := repo.FinishMatch(ctx, &MatchOutcome{MatchID: match.ID, Status: status, WinnerWalletID: winner, Actor: "admin@email.com"})
+		require.NoError(t, err)
+	}
+
+	// stakes are losses
+	drawn, err := start("match-1", 40)
+	require.NoError(t, err)
+	_, err = start("match-2", 20)
+	require.ErrorIs(t, err, util.ErrLossLimitExceeded)
+
+	// refunded stakes and prizes are taken off the losses but not off the
+	// stakes
+	finish(drawn, models.MatchStatusDrawn, 0)
+	won, err := start("match-2", 30)
+	require.NoError(t, err)
+	finish(won, models.MatchStatusSettled, 1)
+	_, err = start("match-3", 31)
+	require.ErrorIs(t, err, util.ErrWagerLimitExceeded)
+	_, err = start("match-3", 30)
+	require.NoError(t, err)
+}
+
+func TestInMemoryRestrictions(t *testing.T) {
+	repo := NewInMemory()
+	require.NoError(t, repo.CreateUser(&models.User{ID: 1, Email: "player@email.com"}))
+	ctx := context.Background()
+	now := time.Now()
+	restrict := func(restriction models.RestrictionType, until *time.Time) (*models.User, error) {
+		return repo.RestrictUser(ctx, 1, &models.RestrictionEvent{Restriction: restriction, Until: until, Actor: "player@email.com"})
+	}
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	user, err := restrict(models.RestrictionTimeOut, at(24*time.Hour))
+	require.NoError(t, err)
+	require.True(t, user.IsRestricted(now))
+	require.False(t, user.IsRestricted(now.Add(25*time.Hour)))
+
+	// a restriction in force is only ever extended
+	_, err = restrict(models.RestrictionTimeOut, at(time.Hour))
+	require.ErrorIs(t, err, util.ErrRestrictionShortened)
+	_, err = restrict(models.RestrictionSelfExclusion, nil)
+	require.NoError(t, err)
+	_, err = restrict(models.RestrictionTimeOut, at(1000*time.Hour))
+	require.ErrorIs(t, err, util.ErrRestrictionShortened)
+	_, err = restrict(models.RestrictionSelfExclusion, at(1000*time.Hour))
+	require.ErrorIs(t, err, util.ErrRestrictionShortened)
+
+	user, err = repo.LiftRestriction(ctx, 1, &models.RestrictionEvent{Reason: "opened in error", Actor: "admin@email.com"})
+	require.NoError(t, err)
+	require.False(t, user.IsRestricted(now))
+	_, err = repo.LiftRestriction(ctx, 1, &models.RestrictionEvent{Reason: "again", Actor: "admin@email.com"})
+	require.ErrorIs(t, err, util.ErrUserNotRestricted)
+
+	events, err := repo.GetRestrictionEvents(1)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	lifted := events[2]
+	require.Equal(t, models.RestrictionActionLifted, lifted.Action)
+	require.Equal(t, models.RestrictionSelfExclusion, lifted.Restriction)
+	require.Nil(t, lifted.Until)
+	require.Equal(t, "admin@email.com", lifted.Actor)
+	require.Equal(t, "opened in error", lifted.Reason)
+}
+
+func TestInMemoryTournament(t *testing.T) {
+	repo := NewInMemory()
+	for id := int64(1); id <= 4; id++ {
+		newTestWallet(t, repo, id, models.NewMoneyFromInt(10))
+	}
+	// escrow and rake
+	newTestWallet(t, repo, 5, models.ZeroMoney)
+	newTestWallet(t, repo, 6, models.ZeroMoney)
+	ctx := context.Background()
+	money := func(value string) models.Money {
+		return models.NewMoney(decimal.RequireFromString(value))
+	}
+	balance := func(id int64) models.Money {
+		wallet, err := repo.GetWallet(id)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+	create := func() *models.Tournament {
+		tournament := &models.Tournament{
+			UUID:           uuid.New(),
+			Currency:       models.DefaultCurrency,
+			EntryFee:       money("0.07"),
+			RakePercent:    decimal.NewFromInt(10),
+			PayoutTable:    "50,30,20",
+			EscrowWalletID: 5,
+			RakeWalletID:   6,
+			Status:         models.TournamentStatusOpen,
+		}
+		require.NoError(t, repo.CreateTournament(tournament))
+		return tournament
+	}
+	enter := func(tournamentID, walletID int64) error {
+		_, _, err := repo.EnterTournament(ctx, &models.TournamentEntry{TournamentID: tournamentID, WalletID: walletID, UserID: walletID}, "player@email.com")
+		return err
+	}
+
+	tournament := create()
+	for id := int64(1); id <= 3; id++ {
+		require.NoError(t, enter(tournament.ID, id))
+	}
+	require.ErrorIs(t, enter(tournament.ID, 1), util.ErrTournamentAlreadyEntered)
+	require.True(t, balance(1).Equal(money("9.93")))
+	require.True(t, balance(5).Equal(money("0.21")))
+
+	// one placement per line of the payout table
+	_, _, err := repo.CloseTournament(ctx, tournament.ID, []int64{3, 1}, "admin@email.com")
+	require.ErrorIs(t, err, util.ErrTournamentPlacements)
+	_, _, err = repo.CloseTournament(ctx, tournament.ID, []int64{3, 1, 4}, "admin@email.com")
+	require.ErrorIs(t, err, util.ErrTournamentPlacements)
+
+	// the rake of 0.02 is taken off the pool of 0.21, the prizes are rounded
+	// down and the 0.02 left over goes to the rake wallet
+	closed, entries, err := repo.CloseTournament(ctx, tournament.ID, []int64{3, 1, 2}, "admin@email.com")
+	require.NoError(t, err)
+	require.Equal(t, models.TournamentStatusClosed, closed.Status)
+	require.True(t, closed.Rake.Equal(money("0.04")))
+	require.Equal(t, 2, entries[0].Place)
+	require.True(t, balance(3).Equal(money("10.02")))
+	require.True(t, balance(1).Equal(money("9.98")))
+	require.True(t, balance(2).Equal(money("9.96")))
+	require.True(t, balance(5).IsZero())
+	require.True(t, balance(6).Equal(money("0.04")))
+	_, _, err = repo.CloseTournament(ctx, tournament.ID, []int64{3, 1, 2}, "admin@email.com")
+	require.ErrorIs(t, err, util.ErrTournamentNotOpen)
+	require.ErrorIs(t, enter(tournament.ID, 4), util.ErrTournamentNotOpen)
+
+	// cancelling refunds every entry fee out of escrow
+	cancelled := create()
+	require.NoError(t, enter(cancelled.ID, 4))
+	require.True(t, balance(4).Equal(money("9.93")))
+	_, entries, err = repo.CancelTournament(ctx, cancelled.ID, "admin@email.com")
+	require.NoError(t, err)
+	require.True(t, entries[0].Refunded)
+	require.True(t, balance(4).Equal(models.NewMoneyFromInt(10)))
+	require.True(t, balance(5).IsZero())
+
+	// the journal entries carry the tournament id
+	txns, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 4, Type: models.TransactionTypeTournamentRefund})
+	require.NoError(t, err)
+	require.Len(t, txns, 1)
+	require.Equal(t, strconv.FormatInt(cancelled.ID, 10), txns[0].Reference)
+	require.Equal(t, cancelled.UUID.String(), txns[0].CorrelationID)
+}
+
+func TestInMemoryMatch(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(10))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(10))
+	// escrow and rake
+	newTestWallet(t, repo, 3, models.ZeroMoney)
+	newTestWallet(t, repo, 4, models.ZeroMoney)
+	ctx := context.Background()
+	money := func(value string) models.Money {
+		return models.NewMoney(decimal.RequireFromString(value))
+	}
+	balance := func(id int64) models.Money {
+		wallet, err := repo.GetWallet(id)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+	newMatch := func(reference string) *models.Match {
+		return &models.Match{
+			UUID:              uuid.New(),
+			Reference:         reference,
+			Currency:          models.DefaultCurrency,
+			Stake:             money("1.05"),
+			RakePercent:       decimal.NewFromInt(3),
+			PlayerOneWalletID: 1,
+			PlayerTwoWalletID: 2,
+			EscrowWalletID:    3,
+			RakeWalletID:      4,
+		}
+	}
+	finish := func(id int64, status models.MatchStatus, winner int64) (*MatchResult, error) {
+		return repo.FinishMatch(ctx, &MatchOutcome{MatchID: id, Status: status, WinnerWalletID: winner, Actor: "admin@email.com"})
+	}
+
+	result, err := repo.StartMatch(ctx, newMatch("match-1"))
+	require.NoError(t, err)
+	require.False(t, result.Duplicate)
+	require.Len(t, result.Wallets, 3)
+	match := result.Match
+	require.Equal(t, models.MatchStatusStarted, match.Status)
+	require.True(t, balance(1).Equal(money("8.95")))
+	require.True(t, balance(3).Equal(money("2.1")))
+
+	// starting the match again moves nothing, unless it is a different match
+	result, err = repo.StartMatch(ctx, newMatch("match-1"))
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+	require.Equal(t, match.ID, result.Match.ID)
+	require.True(t, balance(1).Equal(money("8.95")))
+	conflicting := newMatch("match-1")
+	conflicting.Stake = money("2")
+	_, err = repo.StartMatch(ctx, conflicting)
+	require.ErrorIs(t, err, util.ErrMatchConflict)
+
+	_, err = finish(match.ID, models.MatchStatusSettled, 3)
+	require.ErrorIs(t, err, util.ErrMatchWinner)
+	_, err = finish(match.ID, models.MatchStatusDrawn, 1)
+	require.ErrorIs(t, err, util.ErrMatchWinner)
+
+	// the rake of 3% of the pot of 2.10 is rounded down to 0.06
+	result, err = finish(match.ID, models.MatchStatusSettled, 2)
+	require.NoError(t, err)
+	require.True(t, result.Match.Rake.Equal(money("0.06")))
+	require.True(t, result.Match.Prize.Equal(money("2.04")))
+	require.True(t, balance(2).Equal(money("10.99")))
+	require.True(t, balance(3).IsZero())
+	require.True(t, balance(4).Equal(money("0.06")))
+
+	// settling again the same way is answered with the settled match
+	result, err = finish(match.ID, models.MatchStatusSettled, 2)
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+	require.Empty(t, result.Wallets)
+	require.True(t, balance(2).Equal(money("10.99")))
+	_, err = finish(match.ID, models.MatchStatusSettled, 1)
+	require.ErrorIs(t, err, util.ErrMatchFinished)
+	_, err = finish(match.ID, models.MatchStatusAborted, 0)
+	require.ErrorIs(t, err, util.ErrMatchFinished)
+
+	// a draw refunds both stakes out of escrow
+	result, err = repo.StartMatch(ctx, newMatch("match-2"))
+	require.NoError(t, err)
+	_, err = finish(result.Match.ID, models.MatchStatusDrawn, 0)
+	require.NoError(t, err)
+	require.True(t, balance(1).Equal(money("8.95")))
+	require.True(t, balance(2).Equal(money("10.99")))
+	require.True(t, balance(3).IsZero())
+
+	stored, err := repo.GetMatch(result.Match.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.MatchStatusDrawn, stored.Status)
+	require.Equal(t, "admin@email.com", stored.FinishedBy)
+	_, err = repo.GetMatch(99)
+	require.ErrorIs(t, err, util.ErrMatchNotFound)
+
+	// a player who cannot cover the stake does not start the match
+	newTestWallet(t, repo, 5, money("0.5"))
+	short := newMatch("match-3")
+	short.PlayerTwoWalletID = 5
+	_, err = repo.StartMatch(ctx, short)
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+	require.True(t, balance(1).Equal(money("8.95")))
+
+	// the refunds carry the match reference and uuid
+	txns, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 1, Type: models.TransactionTypeMatchRefund})
+	require.NoError(t, err)
+	require.Len(t, txns, 1)
+	require.Equal(t, "match-2", txns[0].Reference)
+	require.Equal(t, result.Match.UUID.String(), txns[0].CorrelationID)
+}
+
+func TestInMemoryDeposit(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(10))
+	ctx := context.Background()
+	money := func(value string) models.Money {
+		return models.NewMoney(decimal.RequireFromString(value))
+	}
+	balance := func(id int64) models.Money {
+		wallet, err := repo.GetWallet(id)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+	newDeposit := func(reference string, amount models.Money) *models.Deposit {
+		deposit := &models.Deposit{
+			UUID:      uuid.New(),
+			Reference: reference,
+			WalletID:  1,
+			UserID:    1,
+			Provider:  "fake",
+			Currency:  models.DefaultCurrency,
+			Amount:    amount,
+			Status:    models.DepositStatusPending,
+		}
+		require.NoError(t, repo.CreateDeposit(deposit))
+		require.NoError(t, repo.SetDepositProviderReference(deposit.ID, "fake_"+reference))
+		return deposit
+	}
+	confirm := func(reference string, status models.DepositStatus, amount models.Money) (*DepositResult, error) {
+		return repo.ConfirmDeposit(ctx, &DepositConfirmation{
+			Reference:         reference,
+			ProviderReference: "fake_" + reference,
+			Status:            status,
+			Amount:            amount,
+			Currency:          models.DefaultCurrency,
+		})
+	}
+
+	// a pending deposit credits nothing
+	deposit := newDeposit("deposit-1", money("5.25"))
+	require.True(t, balance(1).Equal(money("10")))
+
+	// a payment of another amount is rejected and leaves the deposit pending
+	_, err := confirm("deposit-1", models.DepositStatusSucceeded, money("50"))
+	require.ErrorIs(t, err, util.ErrDepositMismatch)
+	pending, err := repo.GetDeposit(deposit.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.DepositStatusPending, pending.Status)
+
+	result, err := confirm("deposit-1", models.DepositStatusSucceeded, money("5.25"))
+	require.NoError(t, err)
+	require.False(t, result.Duplicate)
+	require.NotNil(t, result.Wallet)
+	require.Equal(t, models.DepositStatusSucceeded, result.Deposit.Status)
+	require.NotZero(t, result.Deposit.TransactionID)
+	require.True(t, balance(1).Equal(money("15.25")))
+
+	// the webhook delivered again credits nothing
+	result, err = confirm("deposit-1", models.DepositStatusSucceeded, money("5.25"))
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+	require.Nil(t, result.Wallet)
+	require.True(t, balance(1).Equal(money("15.25")))
+	_, err = confirm("deposit-1", models.DepositStatusFailed, money("5.25"))
+	require.ErrorIs(t, err, util.ErrDepositFinished)
+
+	// a failed payment credits nothing
+	newDeposit("deposit-2", money("3"))
+	result, err = confirm("deposit-2", models.DepositStatusFailed, money("3"))
+	require.NoError(t, err)
+	require.Equal(t, models.DepositStatusFailed, result.Deposit.Status)
+	require.True(t, balance(1).Equal(money("15.25")))
+
+	_, err = confirm("unknown", models.DepositStatusSucceeded, money("3"))
+	require.ErrorIs(t, err, util.ErrDepositNotFound)
+
+	// a payment beyond the deposit limit is not credited, it waits to be
+	// refunded
+	_, err = repo.SetGamingLimit(ctx, &LimitChange{
+		WalletID: 1,
+		Kind:     models.LimitKindDeposit,
+		Period:   models.LimitPeriodDaily,
+		Amount:   models.NewMoneyFromInt(10),
+	})
+	require.NoError(t, err)
+	credit := &Posting{WalletID: 1, Amount: money("20"), Transaction: newTestTransaction(models.TransactionTypeCredit)}
+	require.ErrorIs(t, repo.CheckGamingLimits(ctx, credit), util.ErrDepositLimitExceeded)
+	refused := newDeposit("deposit-3", money("20"))
+	result, err = confirm("deposit-3", models.DepositStatusSucceeded, money("20"))
+	require.NoError(t, err)
+	require.Equal(t, models.DepositStatusRefundDue, result.Deposit.Status)
+	require.Contains(t, result.Deposit.FailureReason, util.ErrDepositLimitExceeded.Error())
+	require.True(t, balance(1).Equal(money("15.25")))
+	result, err = confirm("deposit-3", models.DepositStatusSucceeded, money("20"))
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+
+	due, err := repo.GetDeposits(models.DepositStatusRefundDue)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, refused.ID, due[0].ID)
+	refunded, err := repo.RefundDeposit(ctx, refused.ID, "admin@email.com")
+	require.NoError(t, err)
+	require.Equal(t, models.DepositStatusRefunded, refunded.Status)
+	require.Equal(t, "admin@email.com", refunded.RefundedBy)
+	_, err = repo.RefundDeposit(ctx, refused.ID, "admin@email.com")
+	require.ErrorIs(t, err, util.ErrDepositNoRefund)
+	_, err = repo.RefundDeposit(ctx, deposit.ID, "admin@email.com")
+	require.ErrorIs(t, err, util.ErrDepositNoRefund)
+	require.True(t, balance(1).Equal(money("15.25")))
+
+	// the credit is journaled with the deposit reference
+	txns, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 1, Type: models.TransactionTypeCredit})
+	require.NoError(t, err)
+	require.Len(t, txns, 1)
+	require.Equal(t, "deposit-1", txns[0].Reference)
+	require.Equal(t, "payments:fake", txns[0].Actor)
+}
+
+func TestInMemoryWithdrawal(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+	money := func(value string) models.Money {
+		return models.NewMoney(decimal.RequireFromString(value))
+	}
+	wallet := func(id int64) *models.Wallet {
+		wallet, err := repo.GetWallet(id)
+		require.NoError(t, err)
+		return wallet
+	}
+	request := func(amount models.Money) (*models.Withdrawal, error) {
+		id := uuid.New()
+		withdrawal := &models.Withdrawal{
+			UUID:        id,
+			Reference:   id.String(),
+			WalletID:    1,
+			UserID:      1,
+			Currency:    models.DefaultCurrency,
+			Amount:      amount,
+			Destination: "GB33BUKB20201555555555",
+			Actor:       "player@email.com",
+		}
+		_, err := repo.RequestWithdrawal(ctx, withdrawal)
+		return withdrawal, err
+	}
+	move := func(id int64, status models.WithdrawalStatus) (*WithdrawalResult, error) {
+		return repo.MoveWithdrawal(ctx, &WithdrawalChange{WithdrawalID: id, Status: status, Actor: "admin@email.com", Reason: "checked"})
+	}
+
+	// the amount is held at once but stays on the ledger until paid
+	withdrawal, err := request(money("30.5"))
+	require.NoError(t, err)
+	require.Equal(t, models.WithdrawalStatusRequested, withdrawal.Status)
+	require.True(t, wallet(1).Balance.Equal(money("100")))
+	require.True(t, wallet(1).AvailableBalance().Equal(money("69.5")))
+
+	// a withdrawal must be reviewed before it is approved and approved
+	// before it is paid
+	_, err = move(withdrawal.ID, models.WithdrawalStatusApproved)
+	require.ErrorIs(t, err, util.ErrWithdrawalTransition)
+	_, err = move(withdrawal.ID, models.WithdrawalStatusPaid)
+	require.ErrorIs(t, err, util.ErrWithdrawalTransition)
+	_, err = move(withdrawal.ID, models.WithdrawalStatusUnderReview)
+	require.NoError(t, err)
+	result, err := move(withdrawal.ID, models.WithdrawalStatusApproved)
+	require.NoError(t, err)
+	require.Nil(t, result.Wallet)
+	result, err = move(withdrawal.ID, models.WithdrawalStatusPaid)
+	require.NoError(t, err)
+	require.NotZero(t, result.Withdrawal.TransactionID)
+	require.True(t, result.Wallet.Balance.Equal(money("69.5")))
+	require.True(t, result.Wallet.HeldBalance.IsZero())
+	_, err = move(withdrawal.ID, models.WithdrawalStatusFailed)
+	require.ErrorIs(t, err, util.ErrWithdrawalTransition)
+
+	// every transition is recorded with who made it
+	transitions, err := repo.GetWithdrawalTransitions(withdrawal.ID)
+	require.NoError(t, err)
+	require.Len(t, transitions, 4)
+	require.Equal(t, models.WithdrawalStatus(""), transitions[0].From)
+	require.Equal(t, "player@email.com", transitions[0].Actor)
+	require.Equal(t, models.WithdrawalStatusApproved, transitions[3].From)
+	require.Equal(t, models.WithdrawalStatusPaid, transitions[3].To)
+	require.Equal(t, "admin@email.com", transitions[3].Actor)
+
+	// a failed payout and a rejection give the held amount back
+	failed, err := request(money("20"))
+	require.NoError(t, err)
+	_, err = move(failed.ID, models.WithdrawalStatusUnderReview)
+	require.NoError(t, err)
+	_, err = move(failed.ID, models.WithdrawalStatusApproved)
+	require.NoError(t, err)
+	result, err = move(failed.ID, models.WithdrawalStatusFailed)
+	require.NoError(t, err)
+	require.Equal(t, "checked", result.Withdrawal.Reason)
+	require.True(t, result.Wallet.AvailableBalance().Equal(money("69.5")))
+	rejected, err := request(money("20"))
+	require.NoError(t, err)
+	_, err = move(rejected.ID, models.WithdrawalStatusUnderReview)
+	require.NoError(t, err)
+	_, err = move(rejected.ID, models.WithdrawalStatusRejected)
+	require.NoError(t, err)
+	require.True(t, wallet(1).AvailableBalance().Equal(money("69.5")))
+	require.True(t, wallet(1).Balance.Equal(money("69.5")))
+
+	// only the real money that is not held yet can be withdrawn
+	_, err = request(money("69.51"))
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+	_, err = request(money("60"))
+	require.NoError(t, err)
+	_, err = request(money("10"))
+	require.ErrorIs(t, err, util.ErrInsufficientFunds)
+
+	requested, err := repo.GetWithdrawals(models.WithdrawalStatusRequested)
+	require.NoError(t, err)
+	require.Len(t, requested, 1)
+
+	// only the payout is journaled
+	txns, err := repo.GetTransactionsByWallet(&TransactionFilter{WalletID: 1, Type: models.TransactionTypeWithdrawal})
+	require.NoError(t, err)
+	require.Len(t, txns, 1)
+	require.Equal(t, withdrawal.Reference, txns[0].Reference)
+	require.Equal(t, "admin@email.com", txns[0].Actor)
+}
+
+func TestInMemoryJackpot(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(1000))
+	// seed and pool
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(1000))
+	newTestWallet(t, repo, 3, models.ZeroMoney)
+	ctx := context.Background()
+	balance := func(id int64) models.Money {
+		wallet, err := repo.GetWallet(id)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+	bet := func(provider, txnID, amount string) {
+		action := &models.GameRoundAction{
+			Provider:              provider,
+			ProviderTransactionID: txnID,
+			Type:                  models.GameRoundActionBet,
+			Amount:                models.RequireMoney(amount),
+		}
+		_, err := repo.ApplyGameRoundAction(ctx, 1, "round-"+txnID, action, newTestTransaction(models.TransactionTypeBet))
+		require.NoError(t, err)
+	}
+
+	pool := &models.JackpotPool{
+		UUID:                uuid.New(),
+		Provider:            "acme",
+		Currency:            models.DefaultCurrency,
+		ContributionPercent: decimal.RequireFromString("1.5"),
+		SeedAmount:          models.NewMoneyFromInt(100),
+		WalletID:            3,
+		SeedWalletID:        2,
+	}
+	require.NoError(t, repo.CreateJackpotPool(ctx, pool, "admin@email.com"))
+	require.True(t, balance(2).Equal(models.NewMoneyFromInt(900)))
+	require.True(t, balance(3).Equal(models.NewMoneyFromInt(100)))
+
+	// contributions are exact, the pool wallet moves by whole cents
+	bet("acme", "tx-1", "10")
+	bet("acme", "tx-2", "0.33")
+	stored, err := repo.GetJackpotPool(pool.ID)
+	require.NoError(t, err)
+	require.True(t, stored.Amount.Equal(models.RequireMoney("100.15495")))
+	require.True(t, balance(3).Equal(models.RequireMoney("100.15")))
+	bet("acme", "tx-3", "0.67")
+	require.True(t, balance(3).Equal(models.RequireMoney("100.16")))
+	// bets with other providers do not contribute
+	bet("studio", "tx-4", "10")
+	require.True(t, balance(3).Equal(models.RequireMoney("100.16")))
+
+	hit := &JackpotHit{
+		PoolID:                pool.ID,
+		Provider:              "acme",
+		ProviderTransactionID: "hit-1",
+		WalletID:              1,
+		RoundID:               "round-tx-3",
+		Actor:                 "provider:acme",
+	}
+	result, err := repo.HitJackpot(ctx, hit)
+	require.NoError(t, err)
+	require.False(t, result.Duplicate)
+	require.True(t, result.Event.Amount.Equal(models.RequireMoney("100.16")))
+	require.True(t, result.Wallet.Balance.Equal(models.RequireMoney("1079.16")))
+	// the pool is reset to its seed, the fraction of a cent is carried over
+	require.True(t, result.Pool.Amount.Equal(models.RequireMoney("100.005")))
+	require.True(t, balance(3).Equal(models.NewMoneyFromInt(100)))
+	require.True(t, balance(2).Equal(models.NewMoneyFromInt(800)))
+
+	// a retried hit pays nothing more
+	result, err = repo.HitJackpot(ctx, hit)
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+	require.True(t, balance(1).Equal(models.RequireMoney("1079.16")))
+	conflicting := *hit
+	conflicting.RoundID = "round-tx-2"
+	_, err = repo.HitJackpot(ctx, &conflicting)
+	require.ErrorIs(t, err, util.ErrJackpotHitConflict)
+	other := *hit
+	other.Provider = "studio"
+	other.ProviderTransactionID = "hit-2"
+	_, err = repo.HitJackpot(ctx, &other)
+	require.ErrorIs(t, err, util.ErrJackpotNotFound)
+
+	events, err := repo.GetJackpotEvents(pool.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	require.Equal(t, models.JackpotEventHit, events[0].Type)
+	require.Equal(t, models.JackpotEventSeed, events[4].Type)
+	// the contribution of tx-2 did not move the pool wallet
+	require.Zero(t, events[2].TransactionID)
+	require.NotZero(t, events[1].TransactionID)
+
+	rollback := func(txnID string) {
+		action := &models.GameRoundAction{
+			Provider:              "acme",
+			ProviderTransactionID: "rollback-" + txnID,
+			Type:                  models.GameRoundActionRollback,
+			RollbackOf:            txnID,
+		}
+		_, err := repo.ApplyGameRoundAction(ctx, 1, "round-"+txnID, action, newTestTransaction(models.TransactionTypeRollback))
+		require.NoError(t, err)
+	}
+	// a rolled back bet takes its contribution back out of the pool
+	bet("acme", "tx-5", "10")
+	require.True(t, balance(3).Equal(models.RequireMoney("100.15")))
+	rollback("tx-5")
+	stored, err = repo.GetJackpotPool(pool.ID)
+	require.NoError(t, err)
+	require.True(t, stored.Amount.Equal(models.RequireMoney("100.005")))
+	require.True(t, balance(3).Equal(models.NewMoneyFromInt(100)))
+	events, err = repo.GetJackpotEvents(pool.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 7)
+	require.Equal(t, models.JackpotEventContribution, events[0].Type)
+	require.True(t, events[0].Amount.Equal(models.RequireMoney("-0.15")))
+	require.NotZero(t, events[0].TransactionID)
+
+	// a contribution the pool paid out in a hit is not taken back
+	rollback("tx-2")
+	stored, err = repo.GetJackpotPool(pool.ID)
+	require.NoError(t, err)
+	require.True(t, stored.Amount.Equal(models.RequireMoney("100.005")))
+	require.True(t, balance(3).Equal(models.NewMoneyFromInt(100)))
+}
+
+func TestInMemoryVoucherRedeemedConcurrently(t *testing.T) {
+	const players = 20
+	repo := NewInMemory()
+	for id := int64(1); id <= players; id++ {
+		newTestWallet(t, repo, id, models.ZeroMoney)
+	}
+	ctx := context.Background()
+	newVoucher := func(code string, kind models.VoucherKind, budget int64) {
+		require.NoError(t, repo.CreateVoucher(&models.Voucher{
+			UUID:      uuid.New(),
+			Code:      code,
+			Kind:      kind,
+			Currency:  models.DefaultCurrency,
+			Amount:    models.NewMoneyFromInt(10),
+			Budget:    models.NewMoneyFromInt(budget),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}))
+	}
+	redeem := func(code string, walletID int64) error {
+		redemption := &models.VoucherRedemption{UserID: walletID, WalletID: walletID}
+		_, _, err := repo.RedeemVoucher(ctx, code, redemption, newTestTransaction(models.TransactionTypeVoucherRedemption))
+		return err
+	}
+	redeemAll := func(code string) int64 {
+		var redeemed int64
+		var wg sync.WaitGroup
+		for id := int64(1); id <= players; id++ {
+			wg.Add(1)
+			go func(walletID int64) {
+				defer wg.Done()
+				if redeem(code, walletID) == nil {
+					atomic.AddInt64(&redeemed, 1)
+				}
+			}(id)
+		}
+		wg.Wait()
+		return redeemed
+	}
+
+	newVoucher("once", models.VoucherSingleUse, 1000)
+	require.EqualValues(t, 1, redeemAll("ONCE"))
+	require.ErrorIs(t, redeem("once", 1), util.ErrVoucherRedeemed)
+
+	// the budget covers three redemptions
+	newVoucher("promo", models.VoucherMultiUse, 35)
+	require.EqualValues(t, 3, redeemAll("promo"))
+	v, err := repo.GetVoucherByCode("promo")
+	require.NoError(t, err)
+	require.True(t, v.Redeemed.Equal(models.NewMoneyFromInt(30)))
+	redemptions, err := repo.GetVoucherRedemptions(v.ID)
+	require.NoError(t, err)
+	require.Len(t, redemptions, 3)
+	require.NotZero(t, redemptions[0].TransactionID)
+
+	newVoucher("plenty", models.VoucherMultiUse, 1000)
+	require.NoError(t, redeem("plenty", 1))
+	require.ErrorIs(t, redeem("plenty", 1), util.ErrVoucherRedeemed)
+	require.NoError(t, redeem("plenty", 2))
+
+	require.ErrorIs(t, repo.CreateVoucher(&models.Voucher{Code: "Plenty"}), util.ErrVoucherCodeExists)
+	require.ErrorIs(t, redeem("missing", 1), util.ErrVoucherNotFound)
+
+	// a voucher issued to a player is hidden from the others
+	require.NoError(t, repo.CreateVoucher(&models.Voucher{
+		UUID:      uuid.New(),
+		Code:      "mine",
+		Kind:      models.VoucherPerUser,
+		UserID:    5,
+		Currency:  models.DefaultCurrency,
+		Amount:    models.NewMoneyFromInt(10),
+		Budget:    models.NewMoneyFromInt(10),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	require.ErrorIs(t, redeem("mine", 6), util.ErrVoucherNotFound)
+	require.NoError(t, redeem("mine", 5))
+
+	require.NoError(t, repo.CreateVoucher(&models.Voucher{
+		UUID:      uuid.New(),
+		Code:      "old",
+		Kind:      models.VoucherMultiUse,
+		Currency:  models.DefaultCurrency,
+		Amount:    models.NewMoneyFromInt(10),
+		Budget:    models.NewMoneyFromInt(10),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}))
+	require.ErrorIs(t, redeem("old", 1), util.ErrVoucherExpired)
+}
+
+func TestInMemoryLoyalty(t *testing.T) {
+	repo := NewInMemory()
+	newTestWallet(t, repo, 1, models.NewMoneyFromInt(1000))
+	newTestWallet(t, repo, 2, models.NewMoneyFromInt(100))
+	ctx := context.Background()
+	for category, points := range map[string]string{"slots": "1", models.LoyaltyDefaultCategory: "0.5", "live": "0"} {
+		require.NoError(t, repo.SetLoyaltyRule(&models.LoyaltyRule{
+			Category:      category,
+			Currency:      models.DefaultCurrency,
+			PointsPerUnit: decimal.RequireFromString(points),
+		}))
+	}
+	wager := func(walletID int64, amount, category string) {
+		txn := newTestTransaction(models.TransactionTypeDebit)
+		txn.Category = category
+		_, err := repo.AdjustBalance(ctx, walletID, models.RequireMoney(amount).Neg(), NonNegativeBalance, txn)
+		require.NoError(t, err)
+	}
+	wager(1, "100", "slots")
+	// a category without a rule earns by the default rule
+	wager(1, "10.5", "table")
+	wager(1, "20", "live")
+	_, err := repo.AdjustBalance(ctx, 1, models.NewMoneyFromInt(50), NonNegativeBalance, newTestTransaction(models.TransactionTypeCredit))
+	require.NoError(t, err)
+	wager(2, "3.33", "")
+
+	// wagers made after until wait for a later accrual
+	n, err := repo.AccrueLoyaltyPoints(ctx, time.Now().Add(-time.Hour), 100)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	until := time.Now().Add(time.Second)
+	n, err = repo.AccrueLoyaltyPoints(ctx, until, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	n, err = repo.AccrueLoyaltyPoints(ctx, until, 100)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	// every wager is accrued once
+	n, err = repo.AccrueLoyaltyPoints(ctx, until, 100)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	account, err := repo.GetLoyaltyAccount(1)
+	require.NoError(t, err)
+	require.Equal(t, "105.25", account.Points.String())
+	entries, err := repo.GetLoyaltyEntries(1, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, models.LoyaltyDefaultCategory, entries[0].Category)
+	// points are rounded down
+	account, err = repo.GetLoyaltyAccount(2)
+	require.NoError(t, err)
+	require.Equal(t, "1.66", account.Points.String())
+
+	redeem := func(points string) (*models.LoyaltyAccount, *models.Wallet, error) {
+		return repo.RedeemLoyaltyPoints(ctx, &LoyaltyRedemption{
+			UserID:   1,
+			WalletID: 1,
+			Currency: models.DefaultCurrency,
+			Points:   decimal.RequireFromString(points),
+			Rate:     decimal.RequireFromString("0.01"),
+		}, newTestTransaction(models.TransactionTypeLoyaltyRedemption))
+	}
+	account, wallet, err := redeem("100")
+	require.NoError(t, err)
+	require.Equal(t, "5.25", account.Points.String())
+	// redeeming does not lower the tier
+	require.Equal(t, "105.25", account.TierPoints.String())
+	require.True(t, wallet.Balance.Equal(models.RequireMoney("920.5")))
+	_, _, err = redeem("10")
+	require.ErrorIs(t, err, util.ErrInsufficientLoyaltyPoints)
+	_, _, err = redeem("0.5")
+	require.ErrorIs(t, err, util.ErrLoyaltyRedemptionTooSmall)
+	_, _, err = repo.RedeemLoyaltyPoints(ctx, &LoyaltyRedemption{UserID: 3, WalletID: 1, Points: decimal.NewFromInt(1)}, newTestTransaction(models.TransactionTypeLoyaltyRedemption))
+	require.ErrorIs(t, err, util.ErrInsufficientLoyaltyPoints)
+
+	expired, err := repo.ExpireLoyaltyPoints(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, expired)
+	expired, err = repo.ExpireLoyaltyPoints(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, expired)
+	account, err = repo.GetLoyaltyAccount(1)
+	require.NoError(t, err)
+	require.True(t, account.Points.IsZero())
+	require.True(t, account.TierPoints.IsZero())
+}
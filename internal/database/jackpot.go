@@ -0,0 +1,299 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// JackpotHit is a hit of a jackpot reported by the game server of the
+// provider of the pool, ProviderTransactionID makes retries safe
+type JackpotHit struct {
+	PoolID                int64
+	Provider              string
+	ProviderTransactionID string
+	// WalletID is the wallet of the winner, it must be in the pool currency
+	WalletID int64
+	RoundID  string
+	Actor    string
+}
+
+// JackpotHitResult is the outcome of a jackpot hit
+type JackpotHitResult struct {
+	Pool   *models.JackpotPool
+	Event  *models.JackpotEvent
+	Wallet *models.Wallet
+	// Duplicate is set when the hit had already been paid, nothing was
+	// changed and the outcome of the first attempt is returned
+	Duplicate bool
+}
+
+// jackpotContribution is the contribution of a bet to a pool, posting is
+// nil when the contribution does not add up to a unit of the currency yet
+type jackpotContribution struct {
+	pool    *models.JackpotPool
+	event   *models.JackpotEvent
+	posting *Posting
+}
+
+func jackpotTransaction(txnType models.TransactionType, reference, correlationID, actor string) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          txnType,
+		Reference:     reference,
+		CorrelationID: correlationID,
+		Actor:         actor,
+	}
+}
+
+// payable rounds an exact pool amount down to what the pool wallet holds
+func payable(pool *models.JackpotPool) models.Money {
+	precision := int32(2)
+	if currency, ok := models.LookupCurrency(pool.Currency); ok {
+		precision = currency.Precision
+	}
+	return models.RoundDown.Round(pool.Amount, precision)
+}
+
+// seedJackpotPool starts a new pool at its seed and returns the postings
+// moving the seed from the seed wallet into the pool wallet, with the event
+// recording it
+func seedJackpotPool(pool *models.JackpotPool, actor string, now time.Time) ([]*Posting, *models.JackpotEvent) {
+	pool.Amount = pool.SeedAmount
+	pool.CreatedAt = now
+	pool.UpdatedAt = now
+	event := &models.JackpotEvent{
+		Type:       models.JackpotEventSeed,
+		WalletID:   pool.SeedWalletID,
+		Amount:     pool.SeedAmount,
+		PoolAmount: pool.Amount,
+		CreatedAt:  now,
+	}
+	if !pool.SeedAmount.IsPositive() {
+		return nil, event
+	}
+	return []*Posting{
+		{
+			WalletID:    pool.SeedWalletID,
+			Currency:    pool.Currency,
+			Amount:      pool.SeedAmount.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: jackpotTransaction(models.TransactionTypeJackpotSeed, "", pool.UUID.String(), actor),
+		},
+		{
+			WalletID:    pool.WalletID,
+			Currency:    pool.Currency,
+			Amount:      pool.SeedAmount,
+			Transaction: jackpotTransaction(models.TransactionTypeJackpotSeed, "", pool.UUID.String(), actor),
+		},
+	}, event
+}
+
+// contributeToJackpots adds the exact contribution of the bet to each of
+// pools, which are updated in place. The pool wallet only moves by whole
+// units of the currency, a contribution smaller than that is kept in the
+// exact amount of the pool until later contributions make up a unit.
+func contributeToJackpots(
+	pools []*models.JackpotPool,
+	round *models.GameRound,
+	bet *models.GameRoundAction,
+	txn *models.Transaction,
+	now time.Time,
+) []*jackpotContribution {
+	contributions := make([]*jackpotContribution, 0, len(pools))
+	for _, pool := range pools {
+		exact := bet.Amount.Mul(pool.ContributionPercent.Div(decimal.NewFromInt(100)))
+		before := payable(pool)
+		pool.Amount = pool.Amount.Add(exact)
+		pool.UpdatedAt = now
+		contribution := &jackpotContribution{
+			pool: pool,
+			event: &models.JackpotEvent{
+				PoolID:                pool.ID,
+				ProviderTransactionID: bet.ProviderTransactionID,
+				Type:                  models.JackpotEventContribution,
+				WalletID:              round.WalletID,
+				RoundID:               round.RoundID,
+				Amount:                exact,
+				PoolAmount:            pool.Amount,
+				CreatedAt:             now,
+			},
+		}
+		if posted := payable(pool).Sub(before); posted.IsPositive() {
+			contribution.posting = &Posting{
+				WalletID: pool.WalletID,
+				Currency: pool.Currency,
+				Amount:   posted,
+				Transaction: jackpotTransaction(
+					models.TransactionTypeJackpotContribution,
+					round.RoundID,
+					round.UUID.String(),
+					txn.Actor,
+				),
+			}
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions
+}
+
+// withdrawJackpotContributions takes the contributions of a bet that is
+// rolled back out of pools again, which are updated in place. events are
+// the contributions of the bet, each is taken back with a negative
+// contribution keyed on the rollback. A pool hit since a contribution paid
+// it out to the winner already, the contribution is not taken back from it.
+func withdrawJackpotContributions(
+	pools []*models.JackpotPool,
+	events []*models.JackpotEvent,
+	round *models.GameRound,
+	rollback *models.GameRoundAction,
+	txn *models.Transaction,
+	now time.Time,
+) []*jackpotContribution {
+	byID := make(map[int64]*models.JackpotPool, len(pools))
+	for _, pool := range pools {
+		byID[pool.ID] = pool
+	}
+	contributions := make([]*jackpotContribution, 0, len(events))
+	for _, event := range events {
+		pool, ok := byID[event.PoolID]
+		if !ok || (pool.LastHitAt != nil && !pool.LastHitAt.Before(event.CreatedAt)) {
+			continue
+		}
+		before := payable(pool)
+		pool.Amount = pool.Amount.Sub(event.Amount)
+		pool.UpdatedAt = now
+		contribution := &jackpotContribution{
+			pool: pool,
+			event: &models.JackpotEvent{
+				PoolID:                pool.ID,
+				ProviderTransactionID: rollback.ProviderTransactionID,
+				Type:                  models.JackpotEventContribution,
+				WalletID:              round.WalletID,
+				RoundID:               round.RoundID,
+				Amount:                event.Amount.Neg(),
+				PoolAmount:            pool.Amount,
+				CreatedAt:             now,
+			},
+		}
+		if withdrawn := before.Sub(payable(pool)); withdrawn.IsPositive() {
+			contribution.posting = &Posting{
+				WalletID: pool.WalletID,
+				Currency: pool.Currency,
+				Amount:   withdrawn.Neg(),
+				Transaction: jackpotTransaction(
+					models.TransactionTypeJackpotContribution,
+					round.RoundID,
+					round.UUID.String(),
+					txn.Actor,
+				),
+			}
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions
+}
+
+// contributionPostings lists the postings of the contributions that move a
+// pool wallet
+func contributionPostings(contributions []*jackpotContribution) []*Posting {
+	var postings []*Posting
+	for _, c := range contributions {
+		if c.posting != nil {
+			postings = append(postings, c.posting)
+		}
+	}
+	return postings
+}
+
+// completeJackpotEvent links an event to the journal entry of the pool
+// wallet once it is saved
+func completeJackpotEvent(event *models.JackpotEvent, posting *Posting) {
+	if posting != nil && posting.Transaction != nil {
+		event.TransactionID = posting.Transaction.ID
+	}
+}
+
+// checkRepeatedJackpotHit makes sure a hit of pool sent again under the
+// provider transaction id of stored is the same hit
+func checkRepeatedJackpotHit(pool *models.JackpotPool, stored *models.JackpotEvent, hit *JackpotHit) error {
+	if pool.Provider != hit.Provider {
+		return util.ErrJackpotNotFound
+	}
+	if stored.Type != models.JackpotEventHit || stored.WalletID != hit.WalletID || stored.RoundID != hit.RoundID {
+		return util.ErrJackpotHitConflict
+	}
+	return nil
+}
+
+// hitJackpot pays what the pool wallet holds to the winner and starts the
+// pool again from its seed, funded by the seed wallet. The fraction of a
+// unit the pool wallet could not hold is carried into the new pool. It
+// returns the postings, the winner first and the pool wallet leg of the
+// payout second, with the event recording the hit.
+func hitJackpot(pool *models.JackpotPool, hit *JackpotHit, now time.Time) ([]*Posting, *models.JackpotEvent, error) {
+	// a provider can only hit its own jackpots
+	if pool.Provider != hit.Provider {
+		return nil, nil, util.ErrJackpotNotFound
+	}
+	payout := payable(pool)
+	pool.Amount = pool.Amount.Sub(payout).Add(pool.SeedAmount)
+	pool.Hits++
+	pool.LastHitAt = &now
+	pool.UpdatedAt = now
+	event := &models.JackpotEvent{
+		PoolID:                pool.ID,
+		ProviderTransactionID: hit.ProviderTransactionID,
+		Type:                  models.JackpotEventHit,
+		WalletID:              hit.WalletID,
+		RoundID:               hit.RoundID,
+		Amount:                payout,
+		PoolAmount:            pool.Amount,
+		CreatedAt:             now,
+	}
+	correlationID := uuid.New().String()
+	postings := []*Posting{
+		{
+			WalletID:    hit.WalletID,
+			Currency:    pool.Currency,
+			Amount:      payout,
+			Transaction: jackpotTransaction(models.TransactionTypeJackpotWin, hit.RoundID, correlationID, hit.Actor),
+		},
+		{
+			WalletID:    pool.WalletID,
+			Currency:    pool.Currency,
+			Amount:      payout.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: jackpotTransaction(models.TransactionTypeJackpotWin, hit.RoundID, correlationID, hit.Actor),
+		},
+	}
+	// an empty pool pays nothing, there is no movement worth a journal entry
+	if payout.IsZero() {
+		postings[0].Transaction = nil
+		postings[1].Transaction = nil
+	}
+	if pool.SeedAmount.IsPositive() {
+		// the winner is paid whatever the seed wallet holds, it may go
+		// below zero until the operator tops it up
+		postings = append(postings,
+			&Posting{
+				WalletID:    pool.SeedWalletID,
+				Currency:    pool.Currency,
+				Amount:      pool.SeedAmount.Neg(),
+				Constraint:  AllowNegativeBalance,
+				Transaction: jackpotTransaction(models.TransactionTypeJackpotSeed, hit.RoundID, correlationID, hit.Actor),
+			},
+			&Posting{
+				WalletID:    pool.WalletID,
+				Currency:    pool.Currency,
+				Amount:      pool.SeedAmount,
+				Transaction: jackpotTransaction(models.TransactionTypeJackpotSeed, hit.RoundID, correlationID, hit.Actor),
+			},
+		)
+	}
+	return postings, event, nil
+}
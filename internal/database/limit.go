@@ -0,0 +1,178 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// LimitChange asks for the limit of Kind over Period on a wallet to be set
+// to Amount, or to be lifted when Remove is set. A limit that is lowered or
+// set for the first time applies immediately, a limit that is raised or
+// lifted only applies once CoolingOff has passed.
+type LimitChange struct {
+	WalletID   int64
+	Kind       models.LimitKind
+	Period     models.LimitPeriod
+	Amount     models.Money
+	Remove     bool
+	CoolingOff time.Duration
+}
+
+// limitedTransactionTypes are the journal entries counted against limits
+var limitedTransactionTypes = []models.TransactionType{
+	models.TransactionTypeCredit,
+	models.TransactionTypeDebit,
+	models.TransactionTypeBet,
+	models.TransactionTypeHoldCapture,
+	models.TransactionTypeWin,
+	models.TransactionTypeRollback,
+	models.TransactionTypeTournamentEntry,
+	models.TransactionTypeTournamentPrize,
+	models.TransactionTypeTournamentRefund,
+	models.TransactionTypeMatchStake,
+	models.TransactionTypeMatchPrize,
+	models.TransactionTypeMatchRefund,
+}
+
+// limitUsage is what a wallet deposited, staked and got back from its
+// stakes over a period
+type limitUsage struct {
+	Deposits models.Money
+	Wagers   models.Money
+	Returns  models.Money
+}
+
+// add counts a journal entry of txnType for amount
+func (u *limitUsage) add(txnType models.TransactionType, amount models.Money) {
+	switch txnType {
+	case models.TransactionTypeCredit:
+		u.Deposits = u.Deposits.Add(amount)
+	case models.TransactionTypeDebit, models.TransactionTypeBet, models.TransactionTypeHoldCapture,
+		models.TransactionTypeTournamentEntry, models.TransactionTypeMatchStake:
+		u.Wagers = u.Wagers.Add(amount)
+	case models.TransactionTypeWin, models.TransactionTypeRollback,
+		models.TransactionTypeTournamentPrize, models.TransactionTypeTournamentRefund,
+		models.TransactionTypeMatchPrize, models.TransactionTypeMatchRefund:
+		u.Returns = u.Returns.Add(amount)
+	}
+}
+
+// anyLimited reports whether one of postings deposits or stakes and so has
+// to be checked against the limits of its wallet
+func anyLimited(postings []*Posting) bool {
+	for _, p := range postings {
+		if p.deposits() || p.wagers() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGamingLimits fails if postings would take a wallet over one of the
+// limits in force at now. usage returns what the wallet did since the start
+// of a period, it is only called for periods that have to be checked.
+func checkGamingLimits(
+	limits []*models.GamingLimit,
+	postings []*Posting,
+	now time.Time,
+	usage func(walletID int64, since time.Time) (limitUsage, error),
+) error {
+	pending := make(map[int64]*limitUsage)
+	for _, p := range postings {
+		if !p.deposits() && !p.wagers() {
+			continue
+		}
+		if pending[p.WalletID] == nil {
+			pending[p.WalletID] = &limitUsage{}
+		}
+		pending[p.WalletID].add(p.Transaction.Type, p.Amount.Abs())
+	}
+
+	type periodKey struct {
+		walletID int64
+		since    time.Time
+	}
+	used := make(map[periodKey]limitUsage)
+	for _, limit := range limits {
+		amount, ok := limit.Effective(now)
+		adding := pending[limit.WalletID]
+		if !ok || adding == nil {
+			continue
+		}
+		// a stake is only checked against loss and wager limits and a
+		// deposit against deposit limits
+		moved := adding.Wagers
+		if limit.Kind == models.LimitKindDeposit {
+			moved = adding.Deposits
+		}
+		if !moved.IsPositive() {
+			continue
+		}
+		key := periodKey{limit.WalletID, limit.Period.Start(now)}
+		u, ok := used[key]
+		if !ok {
+			var err error
+			if u, err = usage(key.walletID, key.since); err != nil {
+				return err
+			}
+			used[key] = u
+		}
+
+		var total models.Money
+		var exceeded error
+		switch limit.Kind {
+		case models.LimitKindDeposit:
+			total, exceeded = u.Deposits.Add(adding.Deposits), util.ErrDepositLimitExceeded
+		case models.LimitKindLoss:
+			total, exceeded = u.Wagers.Sub(u.Returns).Add(adding.Wagers), util.ErrLossLimitExceeded
+		default:
+			total, exceeded = u.Wagers.Add(adding.Wagers), util.ErrWagerLimitExceeded
+		}
+		if total.GreaterThan(amount) {
+			return fmt.Errorf("%w: %s limit of %s", exceeded, limit.Period, amount)
+		}
+	}
+	return nil
+}
+
+// changeGamingLimit applies change to the stored limit, which is nil when
+// the wallet has no limit of the kind over the period yet, and returns the
+// limit to save
+func changeGamingLimit(stored *models.GamingLimit, change *LimitChange, now time.Time) (*models.GamingLimit, error) {
+	limit := &models.GamingLimit{
+		WalletID:  change.WalletID,
+		Kind:      change.Kind,
+		Period:    change.Period,
+		CreatedAt: now,
+	}
+	current, limited := models.ZeroMoney, false
+	if stored != nil {
+		*limit = *stored
+		current, limited = stored.Effective(now)
+	}
+	// a change that took effect becomes the limit in force
+	limit.Amount = current
+	limit.PendingAmount = models.ZeroMoney
+	limit.PendingRemoval = false
+	limit.PendingFrom = nil
+	limit.UpdatedAt = now
+
+	pendingFrom := now.Add(change.CoolingOff)
+	switch {
+	case !limited && change.Remove:
+		return nil, util.ErrGamingLimitNotFound
+	case !limited, !change.Remove && !change.Amount.GreaterThan(current):
+		// lowering a limit also cancels a raise that was pending
+		limit.Amount = change.Amount
+	case change.Remove:
+		limit.PendingRemoval = true
+		limit.PendingFrom = &pendingFrom
+	default:
+		limit.PendingAmount = change.Amount
+		limit.PendingFrom = &pendingFrom
+	}
+	return limit, nil
+}
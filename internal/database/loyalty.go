@@ -0,0 +1,165 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/shopspring/decimal"
+)
+
+// LoyaltyRedemption turns Points of the loyalty account of UserID into real
+// money credited to WalletID. Rate is the amount of Currency, the currency
+// of the wallet, a point is worth.
+type LoyaltyRedemption struct {
+	UserID   int64
+	WalletID int64
+	Currency string
+	Points   decimal.Decimal
+	Rate     decimal.Decimal
+	// Amount is filled in with what the points paid
+	Amount models.Money
+}
+
+// loyaltyWagerTypes are the journal entries that earn loyalty points, the
+// stakes that count toward wagering requirements
+var loyaltyWagerTypes = []models.TransactionType{
+	models.TransactionTypeDebit,
+	models.TransactionTypeBet,
+	models.TransactionTypeHoldCapture,
+}
+
+func isLoyaltyWager(txn *models.Transaction) bool {
+	for _, t := range loyaltyWagerTypes {
+		if txn.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+type loyaltyRuleKey struct {
+	category string
+	currency string
+}
+
+func indexLoyaltyRules(rules []*models.LoyaltyRule) map[loyaltyRuleKey]*models.LoyaltyRule {
+	index := make(map[loyaltyRuleKey]*models.LoyaltyRule, len(rules))
+	for _, rule := range rules {
+		index[loyaltyRuleKey{rule.Category, rule.Currency}] = rule
+	}
+	return index
+}
+
+// loyaltyPoints returns the points the wager txn earns and the category of
+// the rule it earned them by. Only the real money of the wager earns
+// points, the part paid out of bonus funds does not.
+func loyaltyPoints(rules map[loyaltyRuleKey]*models.LoyaltyRule, txn *models.Transaction) (decimal.Decimal, string) {
+	staked := txn.Amount.Sub(txn.BonusAmount)
+	if !staked.IsPositive() {
+		return decimal.Zero, ""
+	}
+	category := txn.Category
+	rule, ok := rules[loyaltyRuleKey{category, txn.Currency}]
+	if !ok {
+		category = models.LoyaltyDefaultCategory
+		if rule, ok = rules[loyaltyRuleKey{category, txn.Currency}]; !ok {
+			return decimal.Zero, ""
+		}
+	}
+	return staked.Decimal.Mul(rule.PointsPerUnit).Truncate(models.LoyaltyPointsPrecision), category
+}
+
+func newLoyaltyAccount(userID int64, now time.Time) *models.LoyaltyAccount {
+	return &models.LoyaltyAccount{
+		UserID:         userID,
+		LastActivityAt: now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// accrueLoyaltyPoints adds points earned by the wager txn in category to
+// the account and returns the ledger entry recording them. The account is
+// active from the time of the wager.
+func accrueLoyaltyPoints(
+	account *models.LoyaltyAccount,
+	txn *models.Transaction,
+	points decimal.Decimal,
+	category string,
+	now time.Time,
+) *models.LoyaltyEntry {
+	account.Points = account.Points.Add(points)
+	account.TierPoints = account.TierPoints.Add(points)
+	if txn.CreatedAt.After(account.LastActivityAt) {
+		account.LastActivityAt = txn.CreatedAt
+	}
+	account.UpdatedAt = now
+	return &models.LoyaltyEntry{
+		UserID:        account.UserID,
+		Type:          models.LoyaltyEntryAccrual,
+		Points:        points,
+		Balance:       account.Points,
+		Category:      category,
+		TransactionID: txn.ID,
+		CreatedAt:     now,
+	}
+}
+
+// expireLoyaltyPoints removes the points of an inactive account and drops
+// it back to the lowest tier
+func expireLoyaltyPoints(account *models.LoyaltyAccount, now time.Time) *models.LoyaltyEntry {
+	entry := &models.LoyaltyEntry{
+		UserID:    account.UserID,
+		Type:      models.LoyaltyEntryExpiry,
+		Points:    account.Points.Neg(),
+		Balance:   decimal.Zero,
+		CreatedAt: now,
+	}
+	account.Points = decimal.Zero
+	account.TierPoints = decimal.Zero
+	account.UpdatedAt = now
+	return entry
+}
+
+// redeemLoyaltyPoints takes the points of redemption off the account and
+// returns the posting crediting what they are worth, rounded down to the
+// currency, to the wallet of the redemption with the ledger entry. The
+// caller links the entry to the journal entry once the posting went
+// through.
+func redeemLoyaltyPoints(
+	account *models.LoyaltyAccount,
+	redemption *LoyaltyRedemption,
+	txn *models.Transaction,
+	now time.Time,
+) (*Posting, *models.LoyaltyEntry, error) {
+	if account == nil || redemption.Points.GreaterThan(account.Points) {
+		return nil, nil, util.ErrInsufficientLoyaltyPoints
+	}
+	precision := int32(2)
+	if currency, ok := models.LookupCurrency(redemption.Currency); ok {
+		precision = currency.Precision
+	}
+	amount := models.RoundDown.Round(models.NewMoney(redemption.Points.Mul(redemption.Rate)), precision)
+	if !amount.IsPositive() {
+		return nil, nil, util.ErrLoyaltyRedemptionTooSmall
+	}
+	redemption.Amount = amount
+	account.Points = account.Points.Sub(redemption.Points)
+	account.LastActivityAt = now
+	account.UpdatedAt = now
+	entry := &models.LoyaltyEntry{
+		UserID:    account.UserID,
+		Type:      models.LoyaltyEntryRedemption,
+		Points:    redemption.Points.Neg(),
+		Balance:   account.Points,
+		CreatedAt: now,
+	}
+	return &Posting{
+		WalletID:    redemption.WalletID,
+		Currency:    redemption.Currency,
+		Amount:      amount,
+		Transaction: txn,
+	}, entry, nil
+}
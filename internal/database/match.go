@@ -0,0 +1,155 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MatchOutcome ends a started match, Status is models.MatchStatusSettled,
+// models.MatchStatusDrawn or models.MatchStatusAborted. WinnerWalletID is
+// only set when the match is settled.
+type MatchOutcome struct {
+	MatchID        int64
+	Status         models.MatchStatus
+	WinnerWalletID int64
+	Actor          string
+}
+
+// MatchResult is the outcome of starting or finishing a match
+type MatchResult struct {
+	Match *models.Match
+	// Wallets are the wallets that were moved, in the order they were first
+	// posted to
+	Wallets []*models.Wallet
+	// Duplicate is set when the match had already been started or finished
+	// the same way, nothing was changed and Wallets is empty
+	Duplicate bool
+}
+
+// matchTransaction is a journal entry of the match, it carries the match
+// reference as its reference and the match uuid as its correlation id
+func matchTransaction(m *models.Match, txnType models.TransactionType, actor string) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          txnType,
+		Reference:     m.Reference,
+		CorrelationID: m.UUID.String(),
+		Actor:         actor,
+	}
+}
+
+// startMatch puts the match on and returns the postings moving the stake of
+// each player into escrow
+func startMatch(m *models.Match, now time.Time) []*Posting {
+	m.Status = models.MatchStatusStarted
+	m.Pot = m.Stake.Add(m.Stake)
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	var postings []*Posting
+	for _, walletID := range m.PlayerWalletIDs() {
+		postings = append(postings, &Posting{
+			WalletID:    walletID,
+			Currency:    m.Currency,
+			Amount:      m.Stake.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: matchTransaction(m, models.TransactionTypeMatchStake, m.Actor),
+		})
+	}
+	return append(postings, &Posting{
+		WalletID:    m.EscrowWalletID,
+		Currency:    m.Currency,
+		Amount:      m.Pot,
+		Transaction: matchTransaction(m, models.TransactionTypeMatchEscrow, m.Actor),
+	})
+}
+
+// checkRepeatedMatch makes sure a match started again under the reference
+// of stored is the same match
+func checkRepeatedMatch(stored, m *models.Match) error {
+	same := stored.Currency == m.Currency &&
+		stored.Stake.Equal(m.Stake) &&
+		stored.RakePercent.Equal(m.RakePercent) &&
+		stored.PlayerOneWalletID == m.PlayerOneWalletID &&
+		stored.PlayerTwoWalletID == m.PlayerTwoWalletID &&
+		stored.EscrowWalletID == m.EscrowWalletID &&
+		stored.RakeWalletID == m.RakeWalletID
+	if !same {
+		return util.ErrMatchConflict
+	}
+	return nil
+}
+
+// finishedAs reports whether the match already ended with outcome
+func finishedAs(m *models.Match, outcome *MatchOutcome) bool {
+	return m.Status == outcome.Status && m.WinnerWalletID == outcome.WinnerWalletID
+}
+
+// finishMatch ends the started match with outcome and returns the postings
+// paying the pot out of escrow. A settled match pays the pot to the winner
+// less the rake, rounded down to the currency, a draw or an abort refunds
+// both stakes.
+func finishMatch(m *models.Match, outcome *MatchOutcome, now time.Time) ([]*Posting, error) {
+	if m.Status != models.MatchStatusStarted {
+		return nil, util.ErrMatchFinished
+	}
+	postings := []*Posting{
+		{
+			WalletID:    m.EscrowWalletID,
+			Currency:    m.Currency,
+			Amount:      m.Pot.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: matchTransaction(m, models.TransactionTypeMatchEscrow, outcome.Actor),
+		},
+	}
+	switch outcome.Status {
+	case models.MatchStatusSettled:
+		if outcome.WinnerWalletID != m.PlayerOneWalletID && outcome.WinnerWalletID != m.PlayerTwoWalletID {
+			return nil, util.ErrMatchWinner
+		}
+		precision := int32(2)
+		if currency, ok := models.LookupCurrency(m.Currency); ok {
+			precision = currency.Precision
+		}
+		m.Rake = models.RoundDown.Round(m.Pot.Mul(m.RakePercent.Div(decimal.NewFromInt(100))), precision)
+		m.Prize = m.Pot.Sub(m.Rake)
+		m.WinnerWalletID = outcome.WinnerWalletID
+		postings = append(postings, &Posting{
+			WalletID:    m.WinnerWalletID,
+			Currency:    m.Currency,
+			Amount:      m.Prize,
+			Transaction: matchTransaction(m, models.TransactionTypeMatchPrize, outcome.Actor),
+		})
+		if m.Rake.IsPositive() {
+			postings = append(postings, &Posting{
+				WalletID:    m.RakeWalletID,
+				Currency:    m.Currency,
+				Amount:      m.Rake,
+				Transaction: matchTransaction(m, models.TransactionTypeMatchRake, outcome.Actor),
+			})
+		}
+	case models.MatchStatusDrawn, models.MatchStatusAborted:
+		if outcome.WinnerWalletID != 0 {
+			return nil, util.ErrMatchWinner
+		}
+		for _, walletID := range m.PlayerWalletIDs() {
+			postings = append(postings, &Posting{
+				WalletID:    walletID,
+				Currency:    m.Currency,
+				Amount:      m.Stake,
+				Transaction: matchTransaction(m, models.TransactionTypeMatchRefund, outcome.Actor),
+			})
+		}
+	default:
+		return nil, util.ErrMatchFinished
+	}
+	m.Status = outcome.Status
+	m.FinishedBy = outcome.Actor
+	m.FinishedAt = &now
+	m.UpdatedAt = now
+	return postings, nil
+}
@@ -5,10 +5,12 @@
 package mockdb
 
 import (
-	database "github.com/Oloruntobi1/qgdc/internal/database"
-	models "github.com/Oloruntobi1/qgdc/internal/models"
+	context "context"
 	reflect "reflect"
+	time "time"
 
+	database "github.com/Oloruntobi1/qgdc/internal/database"
+	models "github.com/Oloruntobi1/qgdc/internal/models"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,6 +37,122 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AccrueLoyaltyPoints mocks base method.
+func (m *MockRepository) AccrueLoyaltyPoints(arg0 context.Context, arg1 time.Time, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrueLoyaltyPoints", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccrueLoyaltyPoints indicates an expected call of AccrueLoyaltyPoints.
+func (mr *MockRepositoryMockRecorder) AccrueLoyaltyPoints(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrueLoyaltyPoints", reflect.TypeOf((*MockRepository)(nil).AccrueLoyaltyPoints), arg0, arg1, arg2)
+}
+
+// AdjustBalance mocks base method.
+func (m *MockRepository) AdjustBalance(arg0 context.Context, arg1 int64, arg2 models.Money, arg3 database.BalanceConstraint, arg4 *models.Transaction) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockRepositoryMockRecorder) AdjustBalance(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockRepository)(nil).AdjustBalance), arg0, arg1, arg2, arg3, arg4)
+}
+
+// ApplyGameRoundAction mocks base method.
+func (m *MockRepository) ApplyGameRoundAction(arg0 context.Context, arg1 int64, arg2 string, arg3 *models.GameRoundAction, arg4 *models.Transaction) (*database.RoundResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyGameRoundAction", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*database.RoundResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyGameRoundAction indicates an expected call of ApplyGameRoundAction.
+func (mr *MockRepositoryMockRecorder) ApplyGameRoundAction(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyGameRoundAction", reflect.TypeOf((*MockRepository)(nil).ApplyGameRoundAction), arg0, arg1, arg2, arg3, arg4)
+}
+
+// ApplyPostings mocks base method.
+func (m *MockRepository) ApplyPostings(arg0 context.Context, arg1 ...*database.Posting) ([]*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ApplyPostings", varargs...)
+	ret0, _ := ret[0].([]*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyPostings indicates an expected call of ApplyPostings.
+func (mr *MockRepositoryMockRecorder) ApplyPostings(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyPostings", reflect.TypeOf((*MockRepository)(nil).ApplyPostings), varargs...)
+}
+
+// CancelTournament mocks base method.
+func (m *MockRepository) CancelTournament(arg0 context.Context, arg1 int64, arg2 string) (*models.Tournament, []*models.TournamentEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTournament", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Tournament)
+	ret1, _ := ret[1].([]*models.TournamentEntry)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CancelTournament indicates an expected call of CancelTournament.
+func (mr *MockRepositoryMockRecorder) CancelTournament(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTournament", reflect.TypeOf((*MockRepository)(nil).CancelTournament), arg0, arg1, arg2)
+}
+
+// CaptureHold mocks base method.
+func (m *MockRepository) CaptureHold(arg0 context.Context, arg1 int64, arg2 models.Money, arg3 *models.Transaction) (*models.Hold, *models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Hold)
+	ret1, _ := ret[1].(*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockRepositoryMockRecorder) CaptureHold(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockRepository)(nil).CaptureHold), arg0, arg1, arg2, arg3)
+}
+
+// CheckGamingLimits mocks base method.
+func (m *MockRepository) CheckGamingLimits(arg0 context.Context, arg1 ...*database.Posting) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CheckGamingLimits", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckGamingLimits indicates an expected call of CheckGamingLimits.
+func (mr *MockRepositoryMockRecorder) CheckGamingLimits(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckGamingLimits", reflect.TypeOf((*MockRepository)(nil).CheckGamingLimits), varargs...)
+}
+
 // Close mocks base method.
 func (m *MockRepository) Close() error {
 	m.ctrl.T.Helper()
@@ -49,6 +167,137 @@ func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
 }
 
+// CloseTournament mocks base method.
+func (m *MockRepository) CloseTournament(arg0 context.Context, arg1 int64, arg2 []int64, arg3 string) (*models.Tournament, []*models.TournamentEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseTournament", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Tournament)
+	ret1, _ := ret[1].([]*models.TournamentEntry)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CloseTournament indicates an expected call of CloseTournament.
+func (mr *MockRepositoryMockRecorder) CloseTournament(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseTournament", reflect.TypeOf((*MockRepository)(nil).CloseTournament), arg0, arg1, arg2, arg3)
+}
+
+// ConfirmDeposit mocks base method.
+func (m *MockRepository) ConfirmDeposit(arg0 context.Context, arg1 *database.DepositConfirmation) (*database.DepositResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmDeposit", arg0, arg1)
+	ret0, _ := ret[0].(*database.DepositResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmDeposit indicates an expected call of ConfirmDeposit.
+func (mr *MockRepositoryMockRecorder) ConfirmDeposit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmDeposit", reflect.TypeOf((*MockRepository)(nil).ConfirmDeposit), arg0, arg1)
+}
+
+// CreateBonusGrant mocks base method.
+func (m *MockRepository) CreateBonusGrant(arg0 context.Context, arg1 *models.BonusGrant, arg2 *models.Transaction) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBonusGrant", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBonusGrant indicates an expected call of CreateBonusGrant.
+func (mr *MockRepositoryMockRecorder) CreateBonusGrant(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBonusGrant", reflect.TypeOf((*MockRepository)(nil).CreateBonusGrant), arg0, arg1, arg2)
+}
+
+// CreateDeposit mocks base method.
+func (m *MockRepository) CreateDeposit(arg0 *models.Deposit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeposit", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateDeposit indicates an expected call of CreateDeposit.
+func (mr *MockRepositoryMockRecorder) CreateDeposit(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeposit", reflect.TypeOf((*MockRepository)(nil).CreateDeposit), arg0)
+}
+
+// CreateFXQuote mocks base method.
+func (m *MockRepository) CreateFXQuote(arg0 *models.FXQuote) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFXQuote", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateFXQuote indicates an expected call of CreateFXQuote.
+func (mr *MockRepositoryMockRecorder) CreateFXQuote(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFXQuote", reflect.TypeOf((*MockRepository)(nil).CreateFXQuote), arg0)
+}
+
+// CreateFraudReview mocks base method.
+func (m *MockRepository) CreateFraudReview(arg0 *models.FraudReview) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFraudReview", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateFraudReview indicates an expected call of CreateFraudReview.
+func (mr *MockRepositoryMockRecorder) CreateFraudReview(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFraudReview", reflect.TypeOf((*MockRepository)(nil).CreateFraudReview), arg0)
+}
+
+// CreateHold mocks base method.
+func (m *MockRepository) CreateHold(arg0 context.Context, arg1 *models.Hold) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateHold", arg0, arg1)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateHold indicates an expected call of CreateHold.
+func (mr *MockRepositoryMockRecorder) CreateHold(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateHold", reflect.TypeOf((*MockRepository)(nil).CreateHold), arg0, arg1)
+}
+
+// CreateIdempotencyKey mocks base method.
+func (m *MockRepository) CreateIdempotencyKey(arg0 *models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyKey", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIdempotencyKey indicates an expected call of CreateIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) CreateIdempotencyKey(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).CreateIdempotencyKey), arg0)
+}
+
+// CreateJackpotPool mocks base method.
+func (m *MockRepository) CreateJackpotPool(arg0 context.Context, arg1 *models.JackpotPool, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateJackpotPool", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateJackpotPool indicates an expected call of CreateJackpotPool.
+func (mr *MockRepositoryMockRecorder) CreateJackpotPool(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJackpotPool", reflect.TypeOf((*MockRepository)(nil).CreateJackpotPool), arg0, arg1, arg2)
+}
+
 // CreateTables mocks base method.
 func (m *MockRepository) CreateTables() error {
 	m.ctrl.T.Helper()
@@ -63,6 +312,20 @@ func (mr *MockRepositoryMockRecorder) CreateTables() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTables", reflect.TypeOf((*MockRepository)(nil).CreateTables))
 }
 
+// CreateTournament mocks base method.
+func (m *MockRepository) CreateTournament(arg0 *models.Tournament) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTournament", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTournament indicates an expected call of CreateTournament.
+func (mr *MockRepositoryMockRecorder) CreateTournament(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTournament", reflect.TypeOf((*MockRepository)(nil).CreateTournament), arg0)
+}
+
 // CreateUser mocks base method.
 func (m *MockRepository) CreateUser(arg0 *models.User) error {
 	m.ctrl.T.Helper()
@@ -77,6 +340,20 @@ func (mr *MockRepositoryMockRecorder) CreateUser(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), arg0)
 }
 
+// CreateVoucher mocks base method.
+func (m *MockRepository) CreateVoucher(arg0 *models.Voucher) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateVoucher", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateVoucher indicates an expected call of CreateVoucher.
+func (mr *MockRepositoryMockRecorder) CreateVoucher(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVoucher", reflect.TypeOf((*MockRepository)(nil).CreateVoucher), arg0)
+}
+
 // CreateWallet mocks base method.
 func (m *MockRepository) CreateWallet(arg0 *models.Wallet) (int64, error) {
 	m.ctrl.T.Helper()
@@ -92,6 +369,35 @@ func (mr *MockRepositoryMockRecorder) CreateWallet(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWallet", reflect.TypeOf((*MockRepository)(nil).CreateWallet), arg0)
 }
 
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockRepository) DeleteExpiredIdempotencyKeys(arg0 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredIdempotencyKeys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredIdempotencyKeys), arg0)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockRepository) DeleteIdempotencyKey(arg0 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) DeleteIdempotencyKey(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).DeleteIdempotencyKey), arg0)
+}
+
 // DeleteWallet mocks base method.
 func (m *MockRepository) DeleteWallet(arg0 int64) error {
 	m.ctrl.T.Helper()
@@ -106,6 +412,114 @@ func (mr *MockRepositoryMockRecorder) DeleteWallet(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWallet", reflect.TypeOf((*MockRepository)(nil).DeleteWallet), arg0)
 }
 
+// EnterTournament mocks base method.
+func (m *MockRepository) EnterTournament(arg0 context.Context, arg1 *models.TournamentEntry, arg2 string) (*models.Tournament, *models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnterTournament", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Tournament)
+	ret1, _ := ret[1].(*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// EnterTournament indicates an expected call of EnterTournament.
+func (mr *MockRepositoryMockRecorder) EnterTournament(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnterTournament", reflect.TypeOf((*MockRepository)(nil).EnterTournament), arg0, arg1, arg2)
+}
+
+// ExecuteFXQuote mocks base method.
+func (m *MockRepository) ExecuteFXQuote(arg0 context.Context, arg1 int64, arg2, arg3 *models.Transaction) (*models.FXQuote, []*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecuteFXQuote", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.FXQuote)
+	ret1, _ := ret[1].([]*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ExecuteFXQuote indicates an expected call of ExecuteFXQuote.
+func (mr *MockRepositoryMockRecorder) ExecuteFXQuote(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteFXQuote", reflect.TypeOf((*MockRepository)(nil).ExecuteFXQuote), arg0, arg1, arg2, arg3)
+}
+
+// ExpireBonusGrants mocks base method.
+func (m *MockRepository) ExpireBonusGrants(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireBonusGrants", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireBonusGrants indicates an expected call of ExpireBonusGrants.
+func (mr *MockRepositoryMockRecorder) ExpireBonusGrants(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireBonusGrants", reflect.TypeOf((*MockRepository)(nil).ExpireBonusGrants), arg0, arg1)
+}
+
+// ExpireHolds mocks base method.
+func (m *MockRepository) ExpireHolds(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockRepositoryMockRecorder) ExpireHolds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockRepository)(nil).ExpireHolds), arg0, arg1)
+}
+
+// ExpireLoyaltyPoints mocks base method.
+func (m *MockRepository) ExpireLoyaltyPoints(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireLoyaltyPoints", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireLoyaltyPoints indicates an expected call of ExpireLoyaltyPoints.
+func (mr *MockRepositoryMockRecorder) ExpireLoyaltyPoints(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireLoyaltyPoints", reflect.TypeOf((*MockRepository)(nil).ExpireLoyaltyPoints), arg0, arg1)
+}
+
+// FinishMatch mocks base method.
+func (m *MockRepository) FinishMatch(arg0 context.Context, arg1 *database.MatchOutcome) (*database.MatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishMatch", arg0, arg1)
+	ret0, _ := ret[0].(*database.MatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishMatch indicates an expected call of FinishMatch.
+func (mr *MockRepositoryMockRecorder) FinishMatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishMatch", reflect.TypeOf((*MockRepository)(nil).FinishMatch), arg0, arg1)
+}
+
+// ForfeitBonusGrant mocks base method.
+func (m *MockRepository) ForfeitBonusGrant(arg0 context.Context, arg1 int64, arg2 *models.Transaction) (*models.BonusGrant, *models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForfeitBonusGrant", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.BonusGrant)
+	ret1, _ := ret[1].(*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ForfeitBonusGrant indicates an expected call of ForfeitBonusGrant.
+func (mr *MockRepositoryMockRecorder) ForfeitBonusGrant(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForfeitBonusGrant", reflect.TypeOf((*MockRepository)(nil).ForfeitBonusGrant), arg0, arg1, arg2)
+}
+
 // GetAllUsers mocks base method.
 func (m *MockRepository) GetAllUsers() ([]*database.UserWallet, error) {
 	m.ctrl.T.Helper()
@@ -136,75 +550,955 @@ func (mr *MockRepositoryMockRecorder) GetAllWallets() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllWallets", reflect.TypeOf((*MockRepository)(nil).GetAllWallets))
 }
 
-// GetUserByEmail mocks base method.
-func (m *MockRepository) GetUserByEmail(arg0 string) (*models.User, error) {
+// GetBonusGrant mocks base method.
+func (m *MockRepository) GetBonusGrant(arg0 int64) (*models.BonusGrant, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByEmail", arg0)
-	ret0, _ := ret[0].(*models.User)
+	ret := m.ctrl.Call(m, "GetBonusGrant", arg0)
+	ret0, _ := ret[0].(*models.BonusGrant)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByEmail indicates an expected call of GetUserByEmail.
-func (mr *MockRepositoryMockRecorder) GetUserByEmail(arg0 interface{}) *gomock.Call {
+// GetBonusGrant indicates an expected call of GetBonusGrant.
+func (mr *MockRepositoryMockRecorder) GetBonusGrant(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepository)(nil).GetUserByEmail), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBonusGrant", reflect.TypeOf((*MockRepository)(nil).GetBonusGrant), arg0)
 }
 
-// GetWallet mocks base method.
-func (m *MockRepository) GetWallet(arg0 int64) (*models.Wallet, error) {
+// GetBonusGrantsByWallet mocks base method.
+func (m *MockRepository) GetBonusGrantsByWallet(arg0 int64, arg1 models.BonusStatus) ([]*models.BonusGrant, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWallet", arg0)
-	ret0, _ := ret[0].(*models.Wallet)
+	ret := m.ctrl.Call(m, "GetBonusGrantsByWallet", arg0, arg1)
+	ret0, _ := ret[0].([]*models.BonusGrant)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetWallet indicates an expected call of GetWallet.
-func (mr *MockRepositoryMockRecorder) GetWallet(arg0 interface{}) *gomock.Call {
+// GetBonusGrantsByWallet indicates an expected call of GetBonusGrantsByWallet.
+func (mr *MockRepositoryMockRecorder) GetBonusGrantsByWallet(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWallet", reflect.TypeOf((*MockRepository)(nil).GetWallet), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBonusGrantsByWallet", reflect.TypeOf((*MockRepository)(nil).GetBonusGrantsByWallet), arg0, arg1)
 }
 
-// GetWalletByUserID mocks base method.
-func (m *MockRepository) GetWalletByUserID(arg0 int64) (*models.Wallet, error) {
+// GetDeposit mocks base method.
+func (m *MockRepository) GetDeposit(arg0 int64) (*models.Deposit, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWalletByUserID", arg0)
-	ret0, _ := ret[0].(*models.Wallet)
+	ret := m.ctrl.Call(m, "GetDeposit", arg0)
+	ret0, _ := ret[0].(*models.Deposit)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetWalletByUserID indicates an expected call of GetWalletByUserID.
-func (mr *MockRepositoryMockRecorder) GetWalletByUserID(arg0 interface{}) *gomock.Call {
+// GetDeposit indicates an expected call of GetDeposit.
+func (mr *MockRepositoryMockRecorder) GetDeposit(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletByUserID", reflect.TypeOf((*MockRepository)(nil).GetWalletByUserID), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeposit", reflect.TypeOf((*MockRepository)(nil).GetDeposit), arg0)
 }
 
-// Open mocks base method.
-func (m *MockRepository) Open() error {
+// GetDeposits mocks base method.
+func (m *MockRepository) GetDeposits(arg0 models.DepositStatus) ([]*models.Deposit, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Open")
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "GetDeposits", arg0)
+	ret0, _ := ret[0].([]*models.Deposit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Open indicates an expected call of Open.
-func (mr *MockRepositoryMockRecorder) Open() *gomock.Call {
+// GetDeposits indicates an expected call of GetDeposits.
+func (mr *MockRepositoryMockRecorder) GetDeposits(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockRepository)(nil).Open))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeposits", reflect.TypeOf((*MockRepository)(nil).GetDeposits), arg0)
 }
 
-// Seed mocks base method.
-func (m *MockRepository) Seed() {
+// GetFXQuote mocks base method.
+func (m *MockRepository) GetFXQuote(arg0 int64) (*models.FXQuote, error) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Seed")
+	ret := m.ctrl.Call(m, "GetFXQuote", arg0)
+	ret0, _ := ret[0].(*models.FXQuote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Seed indicates an expected call of Seed.
-func (mr *MockRepositoryMockRecorder) Seed() *gomock.Call {
+// GetFXQuote indicates an expected call of GetFXQuote.
+func (mr *MockRepositoryMockRecorder) GetFXQuote(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Seed", reflect.TypeOf((*MockRepository)(nil).Seed))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFXQuote", reflect.TypeOf((*MockRepository)(nil).GetFXQuote), arg0)
+}
+
+// GetFeeSchedule mocks base method.
+func (m *MockRepository) GetFeeSchedule(arg0 models.FeeOperation, arg1 string) (*models.FeeSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeeSchedule", arg0, arg1)
+	ret0, _ := ret[0].(*models.FeeSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeeSchedule indicates an expected call of GetFeeSchedule.
+func (mr *MockRepositoryMockRecorder) GetFeeSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeeSchedule", reflect.TypeOf((*MockRepository)(nil).GetFeeSchedule), arg0, arg1)
+}
+
+// GetFeeSchedules mocks base method.
+func (m *MockRepository) GetFeeSchedules() ([]*models.FeeSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeeSchedules")
+	ret0, _ := ret[0].([]*models.FeeSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeeSchedules indicates an expected call of GetFeeSchedules.
+func (mr *MockRepositoryMockRecorder) GetFeeSchedules() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeeSchedules", reflect.TypeOf((*MockRepository)(nil).GetFeeSchedules))
+}
+
+// GetFraudReview mocks base method.
+func (m *MockRepository) GetFraudReview(arg0 int64) (*models.FraudReview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFraudReview", arg0)
+	ret0, _ := ret[0].(*models.FraudReview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFraudReview indicates an expected call of GetFraudReview.
+func (mr *MockRepositoryMockRecorder) GetFraudReview(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFraudReview", reflect.TypeOf((*MockRepository)(nil).GetFraudReview), arg0)
+}
+
+// GetFraudReviews mocks base method.
+func (m *MockRepository) GetFraudReviews(arg0 models.FraudReviewStatus) ([]*models.FraudReview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFraudReviews", arg0)
+	ret0, _ := ret[0].([]*models.FraudReview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFraudReviews indicates an expected call of GetFraudReviews.
+func (mr *MockRepositoryMockRecorder) GetFraudReviews(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFraudReviews", reflect.TypeOf((*MockRepository)(nil).GetFraudReviews), arg0)
+}
+
+// GetGameRound mocks base method.
+func (m *MockRepository) GetGameRound(arg0, arg1 string) (*models.GameRound, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGameRound", arg0, arg1)
+	ret0, _ := ret[0].(*models.GameRound)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGameRound indicates an expected call of GetGameRound.
+func (mr *MockRepositoryMockRecorder) GetGameRound(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGameRound", reflect.TypeOf((*MockRepository)(nil).GetGameRound), arg0, arg1)
+}
+
+// GetGameRoundActions mocks base method.
+func (m *MockRepository) GetGameRoundActions(arg0 int64) ([]*models.GameRoundAction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGameRoundActions", arg0)
+	ret0, _ := ret[0].([]*models.GameRoundAction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGameRoundActions indicates an expected call of GetGameRoundActions.
+func (mr *MockRepositoryMockRecorder) GetGameRoundActions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGameRoundActions", reflect.TypeOf((*MockRepository)(nil).GetGameRoundActions), arg0)
+}
+
+// GetGamingLimits mocks base method.
+func (m *MockRepository) GetGamingLimits(arg0 int64) ([]*models.GamingLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGamingLimits", arg0)
+	ret0, _ := ret[0].([]*models.GamingLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGamingLimits indicates an expected call of GetGamingLimits.
+func (mr *MockRepositoryMockRecorder) GetGamingLimits(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGamingLimits", reflect.TypeOf((*MockRepository)(nil).GetGamingLimits), arg0)
+}
+
+// GetHold mocks base method.
+func (m *MockRepository) GetHold(arg0 int64) (*models.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHold", arg0)
+	ret0, _ := ret[0].(*models.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHold indicates an expected call of GetHold.
+func (mr *MockRepositoryMockRecorder) GetHold(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHold", reflect.TypeOf((*MockRepository)(nil).GetHold), arg0)
+}
+
+// GetHoldsByWallet mocks base method.
+func (m *MockRepository) GetHoldsByWallet(arg0 int64, arg1 models.HoldStatus) ([]*models.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHoldsByWallet", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHoldsByWallet indicates an expected call of GetHoldsByWallet.
+func (mr *MockRepositoryMockRecorder) GetHoldsByWallet(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHoldsByWallet", reflect.TypeOf((*MockRepository)(nil).GetHoldsByWallet), arg0, arg1)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockRepository) GetIdempotencyKey(arg0, arg1 string) (*models.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(*models.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) GetIdempotencyKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).GetIdempotencyKey), arg0, arg1)
+}
+
+// GetJackpotEvents mocks base method.
+func (m *MockRepository) GetJackpotEvents(arg0 int64, arg1 int) ([]*models.JackpotEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJackpotEvents", arg0, arg1)
+	ret0, _ := ret[0].([]*models.JackpotEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJackpotEvents indicates an expected call of GetJackpotEvents.
+func (mr *MockRepositoryMockRecorder) GetJackpotEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJackpotEvents", reflect.TypeOf((*MockRepository)(nil).GetJackpotEvents), arg0, arg1)
+}
+
+// GetJackpotPool mocks base method.
+func (m *MockRepository) GetJackpotPool(arg0 int64) (*models.JackpotPool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJackpotPool", arg0)
+	ret0, _ := ret[0].(*models.JackpotPool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJackpotPool indicates an expected call of GetJackpotPool.
+func (mr *MockRepositoryMockRecorder) GetJackpotPool(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJackpotPool", reflect.TypeOf((*MockRepository)(nil).GetJackpotPool), arg0)
+}
+
+// GetLoginEvents mocks base method.
+func (m *MockRepository) GetLoginEvents(arg0 string, arg1 time.Time) ([]*models.LoginEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginEvents", arg0, arg1)
+	ret0, _ := ret[0].([]*models.LoginEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginEvents indicates an expected call of GetLoginEvents.
+func (mr *MockRepositoryMockRecorder) GetLoginEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginEvents", reflect.TypeOf((*MockRepository)(nil).GetLoginEvents), arg0, arg1)
+}
+
+// GetLoyaltyAccount mocks base method.
+func (m *MockRepository) GetLoyaltyAccount(arg0 int64) (*models.LoyaltyAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoyaltyAccount", arg0)
+	ret0, _ := ret[0].(*models.LoyaltyAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoyaltyAccount indicates an expected call of GetLoyaltyAccount.
+func (mr *MockRepositoryMockRecorder) GetLoyaltyAccount(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoyaltyAccount", reflect.TypeOf((*MockRepository)(nil).GetLoyaltyAccount), arg0)
+}
+
+// GetLoyaltyEntries mocks base method.
+func (m *MockRepository) GetLoyaltyEntries(arg0 int64, arg1 int) ([]*models.LoyaltyEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoyaltyEntries", arg0, arg1)
+	ret0, _ := ret[0].([]*models.LoyaltyEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoyaltyEntries indicates an expected call of GetLoyaltyEntries.
+func (mr *MockRepositoryMockRecorder) GetLoyaltyEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoyaltyEntries", reflect.TypeOf((*MockRepository)(nil).GetLoyaltyEntries), arg0, arg1)
+}
+
+// GetLoyaltyRules mocks base method.
+func (m *MockRepository) GetLoyaltyRules() ([]*models.LoyaltyRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoyaltyRules")
+	ret0, _ := ret[0].([]*models.LoyaltyRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoyaltyRules indicates an expected call of GetLoyaltyRules.
+func (mr *MockRepositoryMockRecorder) GetLoyaltyRules() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoyaltyRules", reflect.TypeOf((*MockRepository)(nil).GetLoyaltyRules))
+}
+
+// GetMatch mocks base method.
+func (m *MockRepository) GetMatch(arg0 int64) (*models.Match, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMatch", arg0)
+	ret0, _ := ret[0].(*models.Match)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMatch indicates an expected call of GetMatch.
+func (mr *MockRepositoryMockRecorder) GetMatch(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMatch", reflect.TypeOf((*MockRepository)(nil).GetMatch), arg0)
+}
+
+// GetRestrictionEvents mocks base method.
+func (m *MockRepository) GetRestrictionEvents(arg0 int64) ([]*models.RestrictionEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRestrictionEvents", arg0)
+	ret0, _ := ret[0].([]*models.RestrictionEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRestrictionEvents indicates an expected call of GetRestrictionEvents.
+func (mr *MockRepositoryMockRecorder) GetRestrictionEvents(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRestrictionEvents", reflect.TypeOf((*MockRepository)(nil).GetRestrictionEvents), arg0)
+}
+
+// GetReversals mocks base method.
+func (m *MockRepository) GetReversals(arg0 int64) ([]*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReversals", arg0)
+	ret0, _ := ret[0].([]*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReversals indicates an expected call of GetReversals.
+func (mr *MockRepositoryMockRecorder) GetReversals(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReversals", reflect.TypeOf((*MockRepository)(nil).GetReversals), arg0)
+}
+
+// GetTournament mocks base method.
+func (m *MockRepository) GetTournament(arg0 int64) (*models.Tournament, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTournament", arg0)
+	ret0, _ := ret[0].(*models.Tournament)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTournament indicates an expected call of GetTournament.
+func (mr *MockRepositoryMockRecorder) GetTournament(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTournament", reflect.TypeOf((*MockRepository)(nil).GetTournament), arg0)
+}
+
+// GetTournamentEntries mocks base method.
+func (m *MockRepository) GetTournamentEntries(arg0 int64) ([]*models.TournamentEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTournamentEntries", arg0)
+	ret0, _ := ret[0].([]*models.TournamentEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTournamentEntries indicates an expected call of GetTournamentEntries.
+func (mr *MockRepositoryMockRecorder) GetTournamentEntries(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTournamentEntries", reflect.TypeOf((*MockRepository)(nil).GetTournamentEntries), arg0)
+}
+
+// GetTransaction mocks base method.
+func (m *MockRepository) GetTransaction(arg0 int64) (*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransaction", arg0)
+	ret0, _ := ret[0].(*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransaction indicates an expected call of GetTransaction.
+func (mr *MockRepositoryMockRecorder) GetTransaction(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransaction", reflect.TypeOf((*MockRepository)(nil).GetTransaction), arg0)
+}
+
+// GetTransactionsByWallet mocks base method.
+func (m *MockRepository) GetTransactionsByWallet(arg0 *database.TransactionFilter) ([]*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionsByWallet", arg0)
+	ret0, _ := ret[0].([]*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionsByWallet indicates an expected call of GetTransactionsByWallet.
+func (mr *MockRepositoryMockRecorder) GetTransactionsByWallet(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionsByWallet", reflect.TypeOf((*MockRepository)(nil).GetTransactionsByWallet), arg0)
+}
+
+// GetUser mocks base method.
+func (m *MockRepository) GetUser(arg0 int64) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", arg0)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockRepositoryMockRecorder) GetUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), arg0)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockRepository) GetUserByEmail(arg0 string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", arg0)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockRepositoryMockRecorder) GetUserByEmail(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepository)(nil).GetUserByEmail), arg0)
+}
+
+// GetVelocityRule mocks base method.
+func (m *MockRepository) GetVelocityRule(arg0 int64, arg1, arg2 string) (*models.VelocityRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVelocityRule", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.VelocityRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVelocityRule indicates an expected call of GetVelocityRule.
+func (mr *MockRepositoryMockRecorder) GetVelocityRule(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVelocityRule", reflect.TypeOf((*MockRepository)(nil).GetVelocityRule), arg0, arg1, arg2)
+}
+
+// GetVelocityRules mocks base method.
+func (m *MockRepository) GetVelocityRules() ([]*models.VelocityRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVelocityRules")
+	ret0, _ := ret[0].([]*models.VelocityRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVelocityRules indicates an expected call of GetVelocityRules.
+func (mr *MockRepositoryMockRecorder) GetVelocityRules() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVelocityRules", reflect.TypeOf((*MockRepository)(nil).GetVelocityRules))
+}
+
+// GetVoucherByCode mocks base method.
+func (m *MockRepository) GetVoucherByCode(arg0 string) (*models.Voucher, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVoucherByCode", arg0)
+	ret0, _ := ret[0].(*models.Voucher)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVoucherByCode indicates an expected call of GetVoucherByCode.
+func (mr *MockRepositoryMockRecorder) GetVoucherByCode(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVoucherByCode", reflect.TypeOf((*MockRepository)(nil).GetVoucherByCode), arg0)
+}
+
+// GetVoucherRedemptions mocks base method.
+func (m *MockRepository) GetVoucherRedemptions(arg0 int64) ([]*models.VoucherRedemption, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVoucherRedemptions", arg0)
+	ret0, _ := ret[0].([]*models.VoucherRedemption)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVoucherRedemptions indicates an expected call of GetVoucherRedemptions.
+func (mr *MockRepositoryMockRecorder) GetVoucherRedemptions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVoucherRedemptions", reflect.TypeOf((*MockRepository)(nil).GetVoucherRedemptions), arg0)
+}
+
+// GetWallet mocks base method.
+func (m *MockRepository) GetWallet(arg0 int64) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWallet", arg0)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWallet indicates an expected call of GetWallet.
+func (mr *MockRepositoryMockRecorder) GetWallet(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWallet", reflect.TypeOf((*MockRepository)(nil).GetWallet), arg0)
+}
+
+// GetWalletByUserID mocks base method.
+func (m *MockRepository) GetWalletByUserID(arg0 int64) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWalletByUserID", arg0)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWalletByUserID indicates an expected call of GetWalletByUserID.
+func (mr *MockRepositoryMockRecorder) GetWalletByUserID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletByUserID", reflect.TypeOf((*MockRepository)(nil).GetWalletByUserID), arg0)
+}
+
+// GetWalletsByUserID mocks base method.
+func (m *MockRepository) GetWalletsByUserID(arg0 int64) ([]*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWalletsByUserID", arg0)
+	ret0, _ := ret[0].([]*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWalletsByUserID indicates an expected call of GetWalletsByUserID.
+func (mr *MockRepositoryMockRecorder) GetWalletsByUserID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletsByUserID", reflect.TypeOf((*MockRepository)(nil).GetWalletsByUserID), arg0)
+}
+
+// GetWithdrawal mocks base method.
+func (m *MockRepository) GetWithdrawal(arg0 int64) (*models.Withdrawal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawal", arg0)
+	ret0, _ := ret[0].(*models.Withdrawal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawal indicates an expected call of GetWithdrawal.
+func (mr *MockRepositoryMockRecorder) GetWithdrawal(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawal", reflect.TypeOf((*MockRepository)(nil).GetWithdrawal), arg0)
+}
+
+// GetWithdrawalTransitions mocks base method.
+func (m *MockRepository) GetWithdrawalTransitions(arg0 int64) ([]*models.WithdrawalTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawalTransitions", arg0)
+	ret0, _ := ret[0].([]*models.WithdrawalTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawalTransitions indicates an expected call of GetWithdrawalTransitions.
+func (mr *MockRepositoryMockRecorder) GetWithdrawalTransitions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalTransitions", reflect.TypeOf((*MockRepository)(nil).GetWithdrawalTransitions), arg0)
+}
+
+// GetWithdrawals mocks base method.
+func (m *MockRepository) GetWithdrawals(arg0 models.WithdrawalStatus) ([]*models.Withdrawal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawals", arg0)
+	ret0, _ := ret[0].([]*models.Withdrawal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawals indicates an expected call of GetWithdrawals.
+func (mr *MockRepositoryMockRecorder) GetWithdrawals(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockRepository)(nil).GetWithdrawals), arg0)
+}
+
+// HitJackpot mocks base method.
+func (m *MockRepository) HitJackpot(arg0 context.Context, arg1 *database.JackpotHit) (*database.JackpotHitResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HitJackpot", arg0, arg1)
+	ret0, _ := ret[0].(*database.JackpotHitResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HitJackpot indicates an expected call of HitJackpot.
+func (mr *MockRepositoryMockRecorder) HitJackpot(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HitJackpot", reflect.TypeOf((*MockRepository)(nil).HitJackpot), arg0, arg1)
+}
+
+// LiftRestriction mocks base method.
+func (m *MockRepository) LiftRestriction(arg0 context.Context, arg1 int64, arg2 *models.RestrictionEvent) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LiftRestriction", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LiftRestriction indicates an expected call of LiftRestriction.
+func (mr *MockRepositoryMockRecorder) LiftRestriction(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LiftRestriction", reflect.TypeOf((*MockRepository)(nil).LiftRestriction), arg0, arg1, arg2)
+}
+
+// MoveFraudReview mocks base method.
+func (m *MockRepository) MoveFraudReview(arg0 context.Context, arg1 *database.FraudReviewChange) (*models.FraudReview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveFraudReview", arg0, arg1)
+	ret0, _ := ret[0].(*models.FraudReview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MoveFraudReview indicates an expected call of MoveFraudReview.
+func (mr *MockRepositoryMockRecorder) MoveFraudReview(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveFraudReview", reflect.TypeOf((*MockRepository)(nil).MoveFraudReview), arg0, arg1)
+}
+
+// MoveWithdrawal mocks base method.
+func (m *MockRepository) MoveWithdrawal(arg0 context.Context, arg1 *database.WithdrawalChange) (*database.WithdrawalResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveWithdrawal", arg0, arg1)
+	ret0, _ := ret[0].(*database.WithdrawalResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MoveWithdrawal indicates an expected call of MoveWithdrawal.
+func (mr *MockRepositoryMockRecorder) MoveWithdrawal(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveWithdrawal", reflect.TypeOf((*MockRepository)(nil).MoveWithdrawal), arg0, arg1)
+}
+
+// Open mocks base method.
+func (m *MockRepository) Open() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Open")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Open indicates an expected call of Open.
+func (mr *MockRepositoryMockRecorder) Open() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockRepository)(nil).Open))
+}
+
+// PromoteAdmin mocks base method.
+func (m *MockRepository) PromoteAdmin(arg0 string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PromoteAdmin", arg0)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PromoteAdmin indicates an expected call of PromoteAdmin.
+func (mr *MockRepositoryMockRecorder) PromoteAdmin(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PromoteAdmin", reflect.TypeOf((*MockRepository)(nil).PromoteAdmin), arg0)
+}
+
+// RecordLogin mocks base method.
+func (m *MockRepository) RecordLogin(arg0 *models.LoginEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLogin", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordLogin indicates an expected call of RecordLogin.
+func (mr *MockRepositoryMockRecorder) RecordLogin(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLogin", reflect.TypeOf((*MockRepository)(nil).RecordLogin), arg0)
+}
+
+// RedeemLoyaltyPoints mocks base method.
+func (m *MockRepository) RedeemLoyaltyPoints(arg0 context.Context, arg1 *database.LoyaltyRedemption, arg2 *models.Transaction) (*models.LoyaltyAccount, *models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemLoyaltyPoints", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.LoyaltyAccount)
+	ret1, _ := ret[1].(*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RedeemLoyaltyPoints indicates an expected call of RedeemLoyaltyPoints.
+func (mr *MockRepositoryMockRecorder) RedeemLoyaltyPoints(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemLoyaltyPoints", reflect.TypeOf((*MockRepository)(nil).RedeemLoyaltyPoints), arg0, arg1, arg2)
+}
+
+// RedeemVoucher mocks base method.
+func (m *MockRepository) RedeemVoucher(arg0 context.Context, arg1 string, arg2 *models.VoucherRedemption, arg3 *models.Transaction) (*models.Voucher, *models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemVoucher", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Voucher)
+	ret1, _ := ret[1].(*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RedeemVoucher indicates an expected call of RedeemVoucher.
+func (mr *MockRepositoryMockRecorder) RedeemVoucher(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemVoucher", reflect.TypeOf((*MockRepository)(nil).RedeemVoucher), arg0, arg1, arg2, arg3)
+}
+
+// RefundDeposit mocks base method.
+func (m *MockRepository) RefundDeposit(arg0 context.Context, arg1 int64, arg2 string) (*models.Deposit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundDeposit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Deposit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundDeposit indicates an expected call of RefundDeposit.
+func (mr *MockRepositoryMockRecorder) RefundDeposit(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundDeposit", reflect.TypeOf((*MockRepository)(nil).RefundDeposit), arg0, arg1, arg2)
+}
+
+// ReleaseHold mocks base method.
+func (m *MockRepository) ReleaseHold(arg0 context.Context, arg1 int64) (*models.Hold, *models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHold", arg0, arg1)
+	ret0, _ := ret[0].(*models.Hold)
+	ret1, _ := ret[1].(*models.Wallet)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ReleaseHold indicates an expected call of ReleaseHold.
+func (mr *MockRepositoryMockRecorder) ReleaseHold(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHold", reflect.TypeOf((*MockRepository)(nil).ReleaseHold), arg0, arg1)
+}
+
+// RemoveFeeSchedule mocks base method.
+func (m *MockRepository) RemoveFeeSchedule(arg0 models.FeeOperation, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFeeSchedule", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFeeSchedule indicates an expected call of RemoveFeeSchedule.
+func (mr *MockRepositoryMockRecorder) RemoveFeeSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFeeSchedule", reflect.TypeOf((*MockRepository)(nil).RemoveFeeSchedule), arg0, arg1)
+}
+
+// RemoveVelocityRule mocks base method.
+func (m *MockRepository) RemoveVelocityRule(arg0 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveVelocityRule", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveVelocityRule indicates an expected call of RemoveVelocityRule.
+func (mr *MockRepositoryMockRecorder) RemoveVelocityRule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveVelocityRule", reflect.TypeOf((*MockRepository)(nil).RemoveVelocityRule), arg0)
+}
+
+// RequestWithdrawal mocks base method.
+func (m *MockRepository) RequestWithdrawal(arg0 context.Context, arg1 *models.Withdrawal) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestWithdrawal", arg0, arg1)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestWithdrawal indicates an expected call of RequestWithdrawal.
+func (mr *MockRepositoryMockRecorder) RequestWithdrawal(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestWithdrawal", reflect.TypeOf((*MockRepository)(nil).RequestWithdrawal), arg0, arg1)
+}
+
+// RestrictUser mocks base method.
+func (m *MockRepository) RestrictUser(arg0 context.Context, arg1 int64, arg2 *models.RestrictionEvent) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestrictUser", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestrictUser indicates an expected call of RestrictUser.
+func (mr *MockRepositoryMockRecorder) RestrictUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestrictUser", reflect.TypeOf((*MockRepository)(nil).RestrictUser), arg0, arg1, arg2)
+}
+
+// ReverseTransaction mocks base method.
+func (m *MockRepository) ReverseTransaction(arg0 context.Context, arg1 int64, arg2 *models.Money, arg3 *models.Transaction) (*models.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransaction", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransaction indicates an expected call of ReverseTransaction.
+func (mr *MockRepositoryMockRecorder) ReverseTransaction(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransaction", reflect.TypeOf((*MockRepository)(nil).ReverseTransaction), arg0, arg1, arg2, arg3)
+}
+
+// Seed mocks base method.
+func (m *MockRepository) Seed() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Seed")
+}
+
+// Seed indicates an expected call of Seed.
+func (mr *MockRepositoryMockRecorder) Seed() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Seed", reflect.TypeOf((*MockRepository)(nil).Seed))
+}
+
+// SetDepositProviderReference mocks base method.
+func (m *MockRepository) SetDepositProviderReference(arg0 int64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDepositProviderReference", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDepositProviderReference indicates an expected call of SetDepositProviderReference.
+func (mr *MockRepositoryMockRecorder) SetDepositProviderReference(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDepositProviderReference", reflect.TypeOf((*MockRepository)(nil).SetDepositProviderReference), arg0, arg1)
+}
+
+// SetFeeSchedule mocks base method.
+func (m *MockRepository) SetFeeSchedule(arg0 *models.FeeSchedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFeeSchedule", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFeeSchedule indicates an expected call of SetFeeSchedule.
+func (mr *MockRepositoryMockRecorder) SetFeeSchedule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFeeSchedule", reflect.TypeOf((*MockRepository)(nil).SetFeeSchedule), arg0)
+}
+
+// SetGamingLimit mocks base method.
+func (m *MockRepository) SetGamingLimit(arg0 context.Context, arg1 *database.LimitChange) (*models.GamingLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetGamingLimit", arg0, arg1)
+	ret0, _ := ret[0].(*models.GamingLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetGamingLimit indicates an expected call of SetGamingLimit.
+func (mr *MockRepositoryMockRecorder) SetGamingLimit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetGamingLimit", reflect.TypeOf((*MockRepository)(nil).SetGamingLimit), arg0, arg1)
+}
+
+// SetLoyaltyRule mocks base method.
+func (m *MockRepository) SetLoyaltyRule(arg0 *models.LoyaltyRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLoyaltyRule", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLoyaltyRule indicates an expected call of SetLoyaltyRule.
+func (mr *MockRepositoryMockRecorder) SetLoyaltyRule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLoyaltyRule", reflect.TypeOf((*MockRepository)(nil).SetLoyaltyRule), arg0)
+}
+
+// SetVelocityRule mocks base method.
+func (m *MockRepository) SetVelocityRule(arg0 *models.VelocityRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetVelocityRule", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetVelocityRule indicates an expected call of SetVelocityRule.
+func (mr *MockRepositoryMockRecorder) SetVelocityRule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetVelocityRule", reflect.TypeOf((*MockRepository)(nil).SetVelocityRule), arg0)
+}
+
+// StartMatch mocks base method.
+func (m *MockRepository) StartMatch(arg0 context.Context, arg1 *models.Match) (*database.MatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartMatch", arg0, arg1)
+	ret0, _ := ret[0].(*database.MatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartMatch indicates an expected call of StartMatch.
+func (mr *MockRepositoryMockRecorder) StartMatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartMatch", reflect.TypeOf((*MockRepository)(nil).StartMatch), arg0, arg1)
+}
+
+// UpdateIdempotencyKey mocks base method.
+func (m *MockRepository) UpdateIdempotencyKey(arg0 *models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdempotencyKey", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdempotencyKey indicates an expected call of UpdateIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) UpdateIdempotencyKey(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).UpdateIdempotencyKey), arg0)
 }
 
 // UpdateWallet mocks base method.
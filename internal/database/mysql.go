@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -9,9 +11,11 @@ import (
 
 	"github.com/Oloruntobi1/qgdc/util"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -23,6 +27,9 @@ var _ Repository = (*MySQL)(nil)
 
 const (
 	SEEDNUMBER = 10
+
+	// mysql error number for a duplicate entry on a unique index
+	errDuplicateEntry = 1062
 )
 
 // get dsn from env
@@ -83,6 +90,60 @@ func (m *MySQL) GetUserByEmail(email string) (*models.User, error) {
 	return &user, err
 }
 
+func (m *MySQL) GetUser(id int64) (*models.User, error) {
+	var user models.User
+	err := m.DB.First(&user, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrUserNotFound
+	}
+	return &user, err
+}
+
+func (m *MySQL) GetRestrictionEvents(userID int64) ([]*models.RestrictionEvent, error) {
+	var events []*models.RestrictionEvent
+	err := m.DB.Where("user_id = ?", userID).Order("id").Find(&events).Error
+	return events, err
+}
+
+func (m *MySQL) RestrictUser(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error) {
+	return m.updateRestriction(ctx, userID, event, imposeRestriction)
+}
+
+func (m *MySQL) LiftRestriction(ctx context.Context, userID int64, event *models.RestrictionEvent) (*models.User, error) {
+	return m.updateRestriction(ctx, userID, event, liftRestriction)
+}
+
+// updateRestriction locks the user row, lets change update it and saves it
+// together with the audit event
+func (m *MySQL) updateRestriction(
+	ctx context.Context,
+	userID int64,
+	event *models.RestrictionEvent,
+	change func(user *models.User, event *models.RestrictionEvent, now time.Time) error,
+) (*models.User, error) {
+	var user models.User
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := change(&user, event, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (m *MySQL) CreateUser(user *models.User) error {
 	result := m.DB.Create(user)
 	if result != nil {
@@ -91,10 +152,28 @@ func (m *MySQL) CreateUser(user *models.User) error {
 	return nil
 }
 
+func (m *MySQL) PromoteAdmin(email string) (*models.User, error) {
+	user, err := m.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.DB.Model(user).Update("is_admin", true).Error; err != nil {
+		return nil, err
+	}
+	user.IsAdmin = true
+	return user, nil
+}
+
 func (m *MySQL) CreateWallet(wallet *models.Wallet) (int64, error) {
-	result := m.DB.Create(wallet)
-	if result != nil {
-		return 0, result.Error
+	if wallet.Currency == "" {
+		wallet.Currency = models.DefaultCurrency
+	}
+	err := m.DB.Create(wallet).Error
+	if isDuplicateEntry(err) {
+		return 0, util.ErrWalletExists
+	}
+	if err != nil {
+		return 0, err
 	}
 	return wallet.ID, nil
 }
@@ -114,12 +193,91 @@ func (m *MySQL) GetWalletByUserID(userID int64) (*models.Wallet, error) {
 	return &wallet, err
 }
 
+func (m *MySQL) GetWalletsByUserID(userID int64) ([]*models.Wallet, error) {
+	var wallets []*models.Wallet
+	err := m.DB.Where("user_id = ?", userID).Order("id").Find(&wallets).Error
+	return wallets, err
+}
+
 func (m *MySQL) GetAllWallets() ([]*models.Wallet, error) {
 	var wallets []*models.Wallet
 	err := m.DB.Find(&wallets).Error
 	return wallets, err
 }
 
+func (m *MySQL) GetTransaction(id int64) (*models.Transaction, error) {
+	var txn models.Transaction
+	err := m.DB.First(&txn, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrTransactionNotFound
+	}
+	return &txn, err
+}
+
+func (m *MySQL) GetTransactionsByWallet(filter *TransactionFilter) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	query := m.DB.Where("wallet_id = ?", filter.WalletID)
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	err := query.Order("id DESC").Find(&transactions).Error
+	return transactions, err
+}
+
+func (m *MySQL) GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+	err := m.DB.Where("scope = ? AND `key` = ?", scope, key).First(&k).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrIdempotencyKeyNotFound
+	}
+	return &k, err
+}
+
+func (m *MySQL) CreateIdempotencyKey(key *models.IdempotencyKey) error {
+	err := m.DB.Create(key).Error
+	if isDuplicateEntry(err) {
+		return util.ErrIdempotencyKeyExists
+	}
+	return err
+}
+
+func (m *MySQL) UpdateIdempotencyKey(key *models.IdempotencyKey) error {
+	return m.DB.Save(key).Error
+}
+
+func (m *MySQL) DeleteIdempotencyKey(id int64) error {
+	return m.DB.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+func (m *MySQL) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	result := m.DB.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// check if err was caused by a unique index violation
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
+
 // create foreign key constraints
 func (m *MySQL) CreateFK() error {
 	err := m.DB.Exec(`
@@ -140,7 +298,7 @@ func (m *MySQL) GetAllUsers() ([]*UserWallet, error) {
 	var users []*UserWallet
 	err := m.DB.Raw(`
 		SELECT u.id, u.uuid, u.full_name,  u.email, u.password, u.hashed_password, u.password_changed_at,
-		u.created_at, u.updated_at, w.id as wallet_id, w.balance as wallet_balance
+		u.created_at, u.updated_at, w.id as wallet_id, w.currency as wallet_currency, w.balance as wallet_balance
 		FROM users u
 		INNER JOIN wallets w ON u.id = w.user_id
 	`).Scan(&users).Error
@@ -158,6 +316,1576 @@ func (m *MySQL) UpdateWallet(wallet *models.Wallet) (*models.Wallet, error) {
 	return wallet, nil
 }
 
+// AdjustBalance locks the wallet row with SELECT ... FOR UPDATE before
+// checking the constraint, so concurrent adjustments are serialised by
+// the database
+func (m *MySQL) AdjustBalance(
+	ctx context.Context,
+	walletID int64,
+	delta models.Money,
+	constraint BalanceConstraint,
+	txn *models.Transaction,
+) (*models.Wallet, error) {
+	wallets, err := m.ApplyPostings(ctx, &Posting{
+		WalletID:    walletID,
+		Amount:      delta,
+		Constraint:  constraint,
+		Transaction: txn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallets[0], nil
+}
+
+func (m *MySQL) ApplyPostings(ctx context.Context, postings ...*Posting) ([]*models.Wallet, error) {
+	var result []*models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		wallets, err := postTx(tx, postings...)
+		result = wallets
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// postTx applies postings inside the database transaction tx
+func postTx(tx *gorm.DB, postings ...*Posting) ([]*models.Wallet, error) {
+	wallets, err := lockWallets(tx, postingWalletIDs(postings))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	// stakes are paid out of the bonus grants of their wallet as well
+	grants, forfeits, err := allocateWagersTx(tx, wallets, postings, now)
+	if err != nil {
+		return nil, err
+	}
+	postings = append(postings, forfeits...)
+	if anyLimited(postings) {
+		if err := checkGamingLimitsTx(tx, postings, now); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyPostings(wallets, postings, now); err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		if err := tx.Save(wallet).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, grant := range grants {
+		if err := tx.Save(grant).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range postings {
+		if p.Transaction == nil {
+			continue
+		}
+		if err := tx.Create(p.Transaction).Error; err != nil {
+			return nil, err
+		}
+	}
+	return postedWallets(wallets, postings), nil
+}
+
+// allocateWagersTx locks the active bonus grants of the wallets staked
+// from by postings, after their wallets, pays the stakes out of them and
+// gives refunded stakes back to them. It returns the grants that have to
+// be saved and the postings that forfeit the bonus funds that could not
+// be given back.
+func allocateWagersTx(tx *gorm.DB, wallets map[int64]*models.Wallet, postings []*Posting, now time.Time) ([]*models.BonusGrant, []*Posting, error) {
+	var changed []*models.BonusGrant
+	var forfeits []*Posting
+	grants := make(map[int64][]*models.BonusGrant)
+	for _, p := range postings {
+		if !p.wagersGame() && !p.refundsWager() {
+			continue
+		}
+		if _, ok := grants[p.WalletID]; !ok {
+			var active []*models.BonusGrant
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("wallet_id = ? AND status = ? AND expires_at > ?", p.WalletID, models.BonusStatusActive, now).
+				Order("id").
+				Find(&active).Error
+			if err != nil {
+				return nil, nil, err
+			}
+			grants[p.WalletID] = active
+			changed = append(changed, active...)
+		}
+		if p.wagersGame() {
+			allocateWager(wallets[p.WalletID], grants[p.WalletID], p, now)
+		} else if forfeit := refundWager(grants[p.WalletID], p, now); forfeit != nil {
+			forfeits = append(forfeits, forfeit)
+		}
+	}
+	return changed, forfeits, nil
+}
+
+// checkGamingLimitsTx checks postings against the limits of their wallets.
+// The wallets are locked so no entry they are counted from can be added
+// until the transaction ends.
+func checkGamingLimitsTx(tx *gorm.DB, postings []*Posting, now time.Time) error {
+	var limits []*models.GamingLimit
+	if err := tx.Where("wallet_id IN ?", postingWalletIDs(postings)).Find(&limits).Error; err != nil {
+		return err
+	}
+	return checkGamingLimits(limits, postings, now, func(walletID int64, since time.Time) (limitUsage, error) {
+		var totals []struct {
+			Type  models.TransactionType
+			Total models.Money
+		}
+		err := tx.Model(&models.Transaction{}).
+			Select("type, SUM(amount) AS total").
+			Where("wallet_id = ? AND created_at >= ? AND type IN ?", walletID, since, limitedTransactionTypes).
+			Group("type").
+			Scan(&totals).Error
+		var usage limitUsage
+		for _, t := range totals {
+			usage.add(t.Type, t.Total)
+		}
+		return usage, err
+	})
+}
+
+func (m *MySQL) GetHold(id int64) (*models.Hold, error) {
+	var hold models.Hold
+	err := m.DB.First(&hold, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrHoldNotFound
+	}
+	return &hold, err
+}
+
+func (m *MySQL) GetHoldsByWallet(walletID int64, status models.HoldStatus) ([]*models.Hold, error) {
+	var holds []*models.Hold
+	query := m.DB.Where("wallet_id = ?", walletID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id DESC").Find(&holds).Error
+	return holds, err
+}
+
+func (m *MySQL) CreateHold(ctx context.Context, hold *models.Hold) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		wallets, err := postTx(tx, holdPosting(hold))
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		return tx.Create(hold).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (m *MySQL) CaptureHold(
+	ctx context.Context,
+	holdID int64,
+	amount models.Money,
+	txn *models.Transaction,
+) (*models.Hold, *models.Wallet, error) {
+	return m.settleHold(ctx, holdID, func(hold *models.Hold) (*Posting, error) {
+		return captureHold(hold, amount, txn, time.Now())
+	})
+}
+
+func (m *MySQL) ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, *models.Wallet, error) {
+	return m.settleHold(ctx, holdID, func(hold *models.Hold) (*Posting, error) {
+		return releaseHold(hold, models.HoldStatusReleased, time.Now())
+	})
+}
+
+func (m *MySQL) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	var ids []int64
+	err := m.DB.WithContext(ctx).Model(&models.Hold{}).
+		Where("status = ? AND expires_at <= ?", models.HoldStatusActive, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	var expired int64
+	for _, id := range ids {
+		_, _, err := m.settleHold(ctx, id, func(hold *models.Hold) (*Posting, error) {
+			return releaseHold(hold, models.HoldStatusExpired, now)
+		})
+		// the hold may have been captured or released since it was listed
+		if errors.Is(err, util.ErrHoldNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func (m *MySQL) GetReversals(originalID int64) ([]*models.Transaction, error) {
+	var reversals []*models.Transaction
+	err := m.DB.Where("original_id = ?", originalID).Order("id").Find(&reversals).Error
+	return reversals, err
+}
+
+// ReverseTransaction locks the wallet of the original entry before summing
+// its reversals, every reversal of an entry locks the same row so they are
+// serialised and cannot reverse more than the original amount between them
+func (m *MySQL) ReverseTransaction(
+	ctx context.Context,
+	originalID int64,
+	amount *models.Money,
+	txn *models.Transaction,
+) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var original models.Transaction
+		err := tx.First(&original, originalID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := lockWallets(tx, []int64{original.WalletID}); err != nil {
+			return err
+		}
+		var reversals []*models.Transaction
+		if err := tx.Where("original_id = ?", originalID).Find(&reversals).Error; err != nil {
+			return err
+		}
+		posting, err := reversalPosting(&original, reversals, amount, txn)
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, posting)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (m *MySQL) GetFXQuote(id int64) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	err := m.DB.First(&quote, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrQuoteNotFound
+	}
+	return &quote, err
+}
+
+func (m *MySQL) CreateFXQuote(quote *models.FXQuote) error {
+	return m.DB.Create(quote).Error
+}
+
+// ExecuteFXQuote locks the quote row before the wallets so a quote can only
+// be executed once
+func (m *MySQL) ExecuteFXQuote(
+	ctx context.Context,
+	quoteID int64,
+	debit, credit *models.Transaction,
+) (*models.FXQuote, []*models.Wallet, error) {
+	var quote models.FXQuote
+	var wallets []*models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&quote, quoteID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrQuoteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		postings, err := executeQuote(&quote, debit, credit, time.Now())
+		if err != nil {
+			return err
+		}
+		wallets, err = postTx(tx, postings...)
+		if err != nil {
+			return err
+		}
+		return tx.Save(&quote).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &quote, wallets, nil
+}
+
+func (m *MySQL) GetGameRound(provider, roundID string) (*models.GameRound, error) {
+	var round models.GameRound
+	err := m.DB.Where("provider = ? AND round_id = ?", provider, roundID).First(&round).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrGameRoundNotFound
+	}
+	return &round, err
+}
+
+func (m *MySQL) GetGameRoundActions(gameRoundID int64) ([]*models.GameRoundAction, error) {
+	var actions []*models.GameRoundAction
+	err := m.DB.Where("game_round_id = ?", gameRoundID).Order("id").Find(&actions).Error
+	return actions, err
+}
+
+// ApplyGameRoundAction locks the round row before the wallet so the actions
+// of a round are serialised. Two first bets of a new round, or two attempts
+// of the same action, can still race to insert the same row, the loser is
+// retried once and then sees the row of the winner.
+func (m *MySQL) ApplyGameRoundAction(
+	ctx context.Context,
+	walletID int64,
+	roundID string,
+	action *models.GameRoundAction,
+	txn *models.Transaction,
+) (*RoundResult, error) {
+	var result *RoundResult
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		err = m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result, err = gameRoundActionTx(tx, walletID, roundID, action, txn)
+			return err
+		})
+		if !isDuplicateEntry(err) {
+			break
+		}
+		action.ID = 0
+		if txn != nil {
+			txn.ID = 0
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func gameRoundActionTx(
+	tx *gorm.DB,
+	walletID int64,
+	roundID string,
+	action *models.GameRoundAction,
+	txn *models.Transaction,
+) (*RoundResult, error) {
+	var stored models.GameRoundAction
+	err := tx.Where("provider = ? AND provider_transaction_id = ?", action.Provider, action.ProviderTransactionID).
+		First(&stored).Error
+	if err == nil {
+		var round models.GameRound
+		if err := tx.First(&round, stored.GameRoundID).Error; err != nil {
+			return nil, err
+		}
+		if err := checkRepeatedRoundAction(&stored, &round, walletID, roundID, action); err != nil {
+			return nil, err
+		}
+		var wallet models.Wallet
+		if err := tx.First(&wallet, walletID).Error; err != nil {
+			return nil, err
+		}
+		return &RoundResult{Round: &round, Action: &stored, Wallet: &wallet, Duplicate: true}, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	var round models.GameRound
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("provider = ? AND round_id = ?", action.Provider, roundID).
+		First(&round).Error
+	isNew := err == gorm.ErrRecordNotFound
+	switch {
+	case isNew && action.Type == models.GameRoundActionBet:
+		var wallet models.Wallet
+		err := tx.First(&wallet, walletID).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil, util.ErrWalletNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		round = *newGameRound(&wallet, action.Provider, roundID, now)
+	case isNew:
+		return nil, util.ErrGameRoundNotFound
+	case err != nil:
+		return nil, err
+	}
+	var bet *models.GameRoundAction
+	if action.Type == models.GameRoundActionRollback {
+		var found models.GameRoundAction
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_transaction_id = ?", action.Provider, action.RollbackOf).
+			First(&found).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		if err == nil {
+			bet = &found
+		}
+	}
+	posting, err := roundActionPosting(&round, bet, walletID, action, txn, now)
+	if err != nil {
+		return nil, err
+	}
+	// the pools a bet contributes to, or a rolled back bet takes its
+	// contributions back from, are locked after its round and before the
+	// wallets, like a jackpot hit locks them
+	var contributions []*jackpotContribution
+	if action.Type == models.GameRoundActionBet || bet != nil {
+		var pools []*models.JackpotPool
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND currency = ?", action.Provider, round.Currency).
+			Order("id").
+			Find(&pools).Error
+		if err != nil {
+			return nil, err
+		}
+		if bet == nil {
+			contributions = contributeToJackpots(pools, &round, action, txn, now)
+		} else {
+			var events []*models.JackpotEvent
+			err := tx.Where("type = ? AND provider_transaction_id = ?", models.JackpotEventContribution, bet.ProviderTransactionID).
+				Find(&events).Error
+			if err != nil {
+				return nil, err
+			}
+			contributions = withdrawJackpotContributions(pools, events, &round, action, txn, now)
+		}
+	}
+	wallets, err := postTx(tx, append([]*Posting{posting}, contributionPostings(contributions)...)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Save(&round).Error; err != nil {
+		return nil, err
+	}
+	if bet != nil {
+		if err := tx.Save(bet).Error; err != nil {
+			return nil, err
+		}
+	}
+	completeRoundAction(&round, action, posting)
+	if err := tx.Create(action).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range contributions {
+		if err := tx.Save(c.pool).Error; err != nil {
+			return nil, err
+		}
+		completeJackpotEvent(c.event, c.posting)
+		if err := tx.Create(c.event).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &RoundResult{Round: &round, Action: action, Wallet: wallets[0]}, nil
+}
+
+func (m *MySQL) GetBonusGrant(id int64) (*models.BonusGrant, error) {
+	var grant models.BonusGrant
+	err := m.DB.First(&grant, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrBonusNotFound
+	}
+	return &grant, err
+}
+
+func (m *MySQL) GetBonusGrantsByWallet(walletID int64, status models.BonusStatus) ([]*models.BonusGrant, error) {
+	var grants []*models.BonusGrant
+	query := m.DB.Where("wallet_id = ?", walletID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id DESC").Find(&grants).Error
+	return grants, err
+}
+
+func (m *MySQL) CreateBonusGrant(ctx context.Context, grant *models.BonusGrant, txn *models.Transaction) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		wallets, err := postTx(tx, bonusGrantPosting(grant, txn))
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		return tx.Create(grant).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (m *MySQL) ForfeitBonusGrant(ctx context.Context, grantID int64, txn *models.Transaction) (*models.BonusGrant, *models.Wallet, error) {
+	return m.settleBonusGrant(ctx, grantID, func(grant *models.BonusGrant) (*Posting, error) {
+		return removeBonusGrant(grant, models.BonusStatusForfeited, txn, time.Now())
+	})
+}
+
+func (m *MySQL) ExpireBonusGrants(ctx context.Context, now time.Time) (int64, error) {
+	var ids []int64
+	err := m.DB.WithContext(ctx).Model(&models.BonusGrant{}).
+		Where("status = ? AND expires_at <= ?", models.BonusStatusActive, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	var expired int64
+	for _, id := range ids {
+		_, _, err := m.settleBonusGrant(ctx, id, func(grant *models.BonusGrant) (*Posting, error) {
+			return removeBonusGrant(grant, models.BonusStatusExpired, bonusExpiryTransaction(grant), now)
+		})
+		// the grant may have been completed or forfeited since it was listed
+		if errors.Is(err, util.ErrBonusNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func (m *MySQL) GetGamingLimits(walletID int64) ([]*models.GamingLimit, error) {
+	var limits []*models.GamingLimit
+	err := m.DB.Where("wallet_id = ?", walletID).Order("id").Find(&limits).Error
+	return limits, err
+}
+
+// SetGamingLimit changes the limit under the lock of its wallet, which
+// postings check the limits under as well
+func (m *MySQL) SetGamingLimit(ctx context.Context, change *LimitChange) (*models.GamingLimit, error) {
+	var limit *models.GamingLimit
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockWallets(tx, []int64{change.WalletID}); err != nil {
+			return err
+		}
+		var stored *models.GamingLimit
+		var existing models.GamingLimit
+		err := tx.Where("wallet_id = ? AND kind = ? AND period = ?", change.WalletID, change.Kind, change.Period).
+			First(&existing).Error
+		switch {
+		case err == nil:
+			stored = &existing
+		case err != gorm.ErrRecordNotFound:
+			return err
+		}
+		changed, err := changeGamingLimit(stored, change, time.Now())
+		if err != nil {
+			return err
+		}
+		limit = changed
+		return tx.Save(limit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
+
+func (m *MySQL) GetTournament(id int64) (*models.Tournament, error) {
+	var t models.Tournament
+	err := m.DB.First(&t, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrTournamentNotFound
+	}
+	return &t, err
+}
+
+func (m *MySQL) GetTournamentEntries(tournamentID int64) ([]*models.TournamentEntry, error) {
+	var entries []*models.TournamentEntry
+	err := m.DB.Where("tournament_id = ?", tournamentID).Order("id").Find(&entries).Error
+	return entries, err
+}
+
+func (m *MySQL) GetJackpotPool(id int64) (*models.JackpotPool, error) {
+	var pool models.JackpotPool
+	err := m.DB.First(&pool, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrJackpotNotFound
+	}
+	return &pool, err
+}
+
+func (m *MySQL) GetJackpotEvents(poolID int64, limit int) ([]*models.JackpotEvent, error) {
+	var events []*models.JackpotEvent
+	query := m.DB.Where("pool_id = ?", poolID).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
+
+func (m *MySQL) CreateJackpotPool(ctx context.Context, pool *models.JackpotPool, actor string) error {
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		postings, event := seedJackpotPool(pool, actor, time.Now())
+		if err := tx.Create(pool).Error; err != nil {
+			return err
+		}
+		var posting *Posting
+		if len(postings) > 0 {
+			if _, err := postTx(tx, postings...); err != nil {
+				return err
+			}
+			posting = postings[1]
+		}
+		event.PoolID = pool.ID
+		completeJackpotEvent(event, posting)
+		return tx.Create(event).Error
+	})
+}
+
+// HitJackpot locks the pool before the wallets, a hit sent again waits for
+// the first one and finds its event
+func (m *MySQL) HitJackpot(ctx context.Context, hit *JackpotHit) (*JackpotHitResult, error) {
+	var result *JackpotHitResult
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pool models.JackpotPool
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pool, hit.PoolID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrJackpotNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var stored models.JackpotEvent
+		err = tx.Where("pool_id = ? AND provider_transaction_id = ?", hit.PoolID, hit.ProviderTransactionID).
+			First(&stored).Error
+		if err == nil {
+			if err := checkRepeatedJackpotHit(&pool, &stored, hit); err != nil {
+				return err
+			}
+			var wallet models.Wallet
+			if err := tx.First(&wallet, hit.WalletID).Error; err != nil {
+				return err
+			}
+			result = &JackpotHitResult{Pool: &pool, Event: &stored, Wallet: &wallet, Duplicate: true}
+			return nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		postings, event, err := hitJackpot(&pool, hit, time.Now())
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, postings...)
+		if err != nil {
+			return err
+		}
+		completeJackpotEvent(event, postings[1])
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&pool).Error; err != nil {
+			return err
+		}
+		result = &JackpotHitResult{Pool: &pool, Event: event, Wallet: wallets[0]}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *MySQL) GetVoucherByCode(code string) (*models.Voucher, error) {
+	var v models.Voucher
+	err := m.DB.Where("code = ?", models.NormalizeVoucherCode(code)).First(&v).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrVoucherNotFound
+	}
+	return &v, err
+}
+
+func (m *MySQL) GetVoucherRedemptions(voucherID int64) ([]*models.VoucherRedemption, error) {
+	var redemptions []*models.VoucherRedemption
+	err := m.DB.Where("voucher_id = ?", voucherID).Order("id").Find(&redemptions).Error
+	return redemptions, err
+}
+
+func (m *MySQL) CreateVoucher(v *models.Voucher) error {
+	v.Code = models.NormalizeVoucherCode(v.Code)
+	err := m.DB.Create(v).Error
+	if isDuplicateEntry(err) {
+		return util.ErrVoucherCodeExists
+	}
+	return err
+}
+
+// RedeemVoucher locks the voucher before the wallet, concurrent redemptions
+// of a voucher queue on its row and see the redemptions made before them.
+// The unique index on the voucher and the player backs this up.
+func (m *MySQL) RedeemVoucher(
+	ctx context.Context,
+	code string,
+	redemption *models.VoucherRedemption,
+	txn *models.Transaction,
+) (*models.Voucher, *models.Wallet, error) {
+	var v models.Voucher
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code = ?", models.NormalizeVoucherCode(code)).
+			First(&v).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrVoucherNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var count int64
+		err = tx.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND user_id = ?", v.ID, redemption.UserID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		posting, err := redeemVoucher(&v, redemption, count > 0, txn, time.Now())
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, posting)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		redemption.TransactionID = txn.ID
+		err = tx.Create(redemption).Error
+		if isDuplicateEntry(err) {
+			return util.ErrVoucherRedeemed
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Save(&v).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &v, wallet, nil
+}
+
+func (m *MySQL) GetLoyaltyRules() ([]*models.LoyaltyRule, error) {
+	var rules []*models.LoyaltyRule
+	err := m.DB.Order("category").Order("currency").Find(&rules).Error
+	return rules, err
+}
+
+func (m *MySQL) GetLoyaltyAccount(userID int64) (*models.LoyaltyAccount, error) {
+	var account models.LoyaltyAccount
+	err := m.DB.Where("user_id = ?", userID).First(&account).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrLoyaltyAccountNotFound
+	}
+	return &account, err
+}
+
+func (m *MySQL) GetLoyaltyEntries(userID int64, limit int) ([]*models.LoyaltyEntry, error) {
+	var entries []*models.LoyaltyEntry
+	err := m.DB.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func (m *MySQL) SetLoyaltyRule(rule *models.LoyaltyRule) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.LoyaltyRule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("category = ? AND currency = ?", rule.Category, rule.Currency).
+			First(&stored).Error
+		if err == nil {
+			rule.ID = stored.ID
+			rule.CreatedAt = stored.CreatedAt
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return tx.Save(rule).Error
+	})
+}
+
+// AccrueLoyaltyPoints locks the cursor so the servers sharing the database
+// never accrue a wager twice. It only reads the journal, the wallets of the
+// wagers are not locked. A wager whose database transaction has not
+// committed by until would be passed over, until is kept behind the clock
+// for that.
+func (m *MySQL) AccrueLoyaltyPoints(ctx context.Context, until time.Time, limit int) (int, error) {
+	var wagers []*models.Transaction
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		cursor := models.LoyaltyCursor{ID: 1}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).FirstOrCreate(&cursor).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("id > ? AND type IN ? AND created_at < ?", cursor.TransactionID, loyaltyWagerTypes, until).
+			Order("id").
+			Limit(limit).
+			Find(&wagers).Error
+		if err != nil || len(wagers) == 0 {
+			return err
+		}
+		var rules []*models.LoyaltyRule
+		if err := tx.Find(&rules).Error; err != nil {
+			return err
+		}
+		walletIDs := make([]int64, 0, len(wagers))
+		for _, txn := range wagers {
+			walletIDs = append(walletIDs, txn.WalletID)
+		}
+		var wallets []*models.Wallet
+		if err := tx.Select("id", "user_id").Where("id IN ?", walletIDs).Find(&wallets).Error; err != nil {
+			return err
+		}
+		owners := make(map[int64]int64, len(wallets))
+		userIDs := make([]int64, 0, len(wallets))
+		for _, wallet := range wallets {
+			owners[wallet.ID] = wallet.UserID
+			userIDs = append(userIDs, wallet.UserID)
+		}
+		var stored []*models.LoyaltyAccount
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id IN ?", userIDs).
+			Find(&stored).Error
+		if err != nil {
+			return err
+		}
+		accounts := make(map[int64]*models.LoyaltyAccount, len(stored))
+		for _, account := range stored {
+			accounts[account.UserID] = account
+		}
+
+		index := indexLoyaltyRules(rules)
+		now := time.Now()
+		var entries []*models.LoyaltyEntry
+		for _, txn := range wagers {
+			points, category := loyaltyPoints(index, txn)
+			userID, ok := owners[txn.WalletID]
+			// the wagers of a deleted wallet earn nothing
+			if !points.IsPositive() || !ok {
+				continue
+			}
+			account, ok := accounts[userID]
+			if !ok {
+				account = newLoyaltyAccount(userID, txn.CreatedAt)
+				accounts[userID] = account
+			}
+			entries = append(entries, accrueLoyaltyPoints(account, txn, points, category, now))
+		}
+		for _, account := range accounts {
+			if err := tx.Save(account).Error; err != nil {
+				return err
+			}
+		}
+		if len(entries) > 0 {
+			if err := tx.Create(&entries).Error; err != nil {
+				return err
+			}
+		}
+		cursor.TransactionID = wagers[len(wagers)-1].ID
+		cursor.UpdatedAt = now
+		return tx.Save(&cursor).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(wagers), nil
+}
+
+func (m *MySQL) ExpireLoyaltyPoints(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	var expired int64
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var accounts []*models.LoyaltyAccount
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("last_activity_at <= ? AND (points <> 0 OR tier_points <> 0)", inactiveSince).
+			Find(&accounts).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, account := range accounts {
+			entry := expireLoyaltyPoints(account, now)
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+			if err := tx.Save(account).Error; err != nil {
+				return err
+			}
+		}
+		expired = int64(len(accounts))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
+// RedeemLoyaltyPoints locks the account before the wallet
+func (m *MySQL) RedeemLoyaltyPoints(
+	ctx context.Context,
+	redemption *LoyaltyRedemption,
+	txn *models.Transaction,
+) (*models.LoyaltyAccount, *models.Wallet, error) {
+	var account *models.LoyaltyAccount
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored models.LoyaltyAccount
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", redemption.UserID).
+			First(&stored).Error
+		if err == nil {
+			account = &stored
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		posting, entry, err := redeemLoyaltyPoints(account, redemption, txn, time.Now())
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, posting)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		entry.TransactionID = txn.ID
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Save(account).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, wallet, nil
+}
+
+func (m *MySQL) GetMatch(id int64) (*models.Match, error) {
+	var match models.Match
+	err := m.DB.First(&match, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrMatchNotFound
+	}
+	return &match, err
+}
+
+// StartMatch saves the match before moving the stakes, a match started
+// twice at once is caught by the unique index on its reference and the
+// second attempt is answered with the first
+func (m *MySQL) StartMatch(ctx context.Context, match *models.Match) (*MatchResult, error) {
+	started := *match
+	var wallets []*models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		postings := startMatch(&started, time.Now())
+		if err := tx.Create(&started).Error; err != nil {
+			return err
+		}
+		var err error
+		wallets, err = postTx(tx, postings...)
+		return err
+	})
+	if isDuplicateEntry(err) {
+		var stored models.Match
+		if err := m.DB.WithContext(ctx).Where("reference = ?", match.Reference).First(&stored).Error; err != nil {
+			return nil, err
+		}
+		if err := checkRepeatedMatch(&stored, match); err != nil {
+			return nil, err
+		}
+		return &MatchResult{Match: &stored, Duplicate: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	*match = started
+	return &MatchResult{Match: match, Wallets: wallets}, nil
+}
+
+// FinishMatch locks the match before the wallets
+func (m *MySQL) FinishMatch(ctx context.Context, outcome *MatchOutcome) (*MatchResult, error) {
+	var match models.Match
+	result := &MatchResult{Match: &match}
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, outcome.MatchID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrMatchNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if finishedAs(&match, outcome) {
+			result.Duplicate = true
+			return nil
+		}
+		postings, err := finishMatch(&match, outcome, time.Now())
+		if err != nil {
+			return err
+		}
+		if result.Wallets, err = postTx(tx, postings...); err != nil {
+			return err
+		}
+		return tx.Save(&match).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *MySQL) GetDeposit(id int64) (*models.Deposit, error) {
+	var deposit models.Deposit
+	err := m.DB.First(&deposit, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrDepositNotFound
+	}
+	return &deposit, err
+}
+
+func (m *MySQL) CreateDeposit(d *models.Deposit) error {
+	return m.DB.Create(d).Error
+}
+
+func (m *MySQL) SetDepositProviderReference(id int64, providerReference string) error {
+	result := m.DB.Model(&models.Deposit{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"provider_reference": providerReference,
+		"updated_at":         time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return util.ErrDepositNotFound
+	}
+	return nil
+}
+
+// ConfirmDeposit locks the deposit before the wallet, a deposit refused by
+// a deposit limit is refused in a second transaction once the credit rolled
+// back
+func (m *MySQL) ConfirmDeposit(ctx context.Context, c *DepositConfirmation) (*DepositResult, error) {
+	var deposit models.Deposit
+	result := &DepositResult{Deposit: &deposit}
+	now := time.Now()
+	confirm := func(tx *gorm.DB, fail error) error {
+		deposit = models.Deposit{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", c.Reference).First(&deposit).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrDepositNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if depositConfirmedAs(&deposit, c) {
+			result.Duplicate = true
+			return nil
+		}
+		posting, err := confirmDeposit(&deposit, c, now)
+		if err != nil {
+			return err
+		}
+		if posting != nil && fail != nil {
+			refuseDeposit(&deposit, fail.Error(), now)
+		} else if posting != nil {
+			wallets, err := postTx(tx, posting)
+			if err != nil {
+				return err
+			}
+			deposit.TransactionID = posting.Transaction.ID
+			result.Wallet = wallets[0]
+		}
+		return tx.Save(&deposit).Error
+	}
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return confirm(tx, nil)
+	})
+	if isDepositLimitError(err) {
+		err = m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return confirm(tx, err)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *MySQL) RefundDeposit(ctx context.Context, id int64, actor string) (*models.Deposit, error) {
+	var deposit models.Deposit
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, id).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrDepositNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := refundDeposit(&deposit, actor, time.Now()); err != nil {
+			return err
+		}
+		return tx.Save(&deposit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+func (m *MySQL) GetDeposits(status models.DepositStatus) ([]*models.Deposit, error) {
+	var deposits []*models.Deposit
+	query := m.DB
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id").Find(&deposits).Error
+	return deposits, err
+}
+
+func (m *MySQL) CheckGamingLimits(ctx context.Context, postings ...*Posting) error {
+	if !anyLimited(postings) {
+		return nil
+	}
+	return checkGamingLimitsTx(m.DB.WithContext(ctx), postings, time.Now())
+}
+
+func (m *MySQL) GetWithdrawal(id int64) (*models.Withdrawal, error) {
+	var withdrawal models.Withdrawal
+	err := m.DB.First(&withdrawal, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrWithdrawalNotFound
+	}
+	return &withdrawal, err
+}
+
+func (m *MySQL) GetWithdrawals(status models.WithdrawalStatus) ([]*models.Withdrawal, error) {
+	var withdrawals []*models.Withdrawal
+	query := m.DB
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id").Find(&withdrawals).Error
+	return withdrawals, err
+}
+
+func (m *MySQL) GetWithdrawalTransitions(withdrawalID int64) ([]*models.WithdrawalTransition, error) {
+	var transitions []*models.WithdrawalTransition
+	err := m.DB.Where("withdrawal_id = ?", withdrawalID).Order("id").Find(&transitions).Error
+	return transitions, err
+}
+
+func (m *MySQL) GetFeeSchedules() ([]*models.FeeSchedule, error) {
+	var schedules []*models.FeeSchedule
+	err := m.DB.Order("operation, currency").Find(&schedules).Error
+	return schedules, err
+}
+
+func (m *MySQL) GetFeeSchedule(operation models.FeeOperation, currency string) (*models.FeeSchedule, error) {
+	var schedule models.FeeSchedule
+	err := m.DB.Where("operation = ? AND currency = ?", operation, currency).First(&schedule).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrFeeScheduleNotFound
+	}
+	return &schedule, err
+}
+
+func (m *MySQL) SetFeeSchedule(s *models.FeeSchedule) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.FeeSchedule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("operation = ? AND currency = ?", s.Operation, s.Currency).
+			First(&stored).Error
+		if err == nil {
+			s.ID = stored.ID
+			s.CreatedAt = stored.CreatedAt
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return tx.Save(s).Error
+	})
+}
+
+func (m *MySQL) RemoveFeeSchedule(operation models.FeeOperation, currency string) error {
+	result := m.DB.Where("operation = ? AND currency = ?", operation, currency).Delete(&models.FeeSchedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return util.ErrFeeScheduleNotFound
+	}
+	return nil
+}
+
+func (m *MySQL) GetVelocityRules() ([]*models.VelocityRule, error) {
+	var rules []*models.VelocityRule
+	err := m.DB.Order("wallet_id = 0, wallet_id, tier, currency").Find(&rules).Error
+	return rules, err
+}
+
+func (m *MySQL) GetVelocityRule(walletID int64, tier, currency string) (*models.VelocityRule, error) {
+	var rule models.VelocityRule
+	// the rule of the wallet comes before the rule of the tier
+	err := m.DB.
+		Where("wallet_id = ? OR (wallet_id = 0 AND tier = ? AND currency = ?)", walletID, tier, currency).
+		Order("wallet_id = 0").
+		First(&rule).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrVelocityRuleNotFound
+	}
+	return &rule, err
+}
+
+func (m *MySQL) SetVelocityRule(rule *models.VelocityRule) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.VelocityRule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("wallet_id = ? AND tier = ? AND currency = ?", rule.WalletID, rule.Tier, rule.Currency).
+			First(&stored).Error
+		if err == nil {
+			rule.ID = stored.ID
+			rule.CreatedAt = stored.CreatedAt
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return tx.Save(rule).Error
+	})
+}
+
+func (m *MySQL) RemoveVelocityRule(id int64) error {
+	result := m.DB.Delete(&models.VelocityRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return util.ErrVelocityRuleNotFound
+	}
+	return nil
+}
+
+func (m *MySQL) GetLoginEvents(email string, since time.Time) ([]*models.LoginEvent, error) {
+	var events []*models.LoginEvent
+	err := m.DB.Where("email = ? AND created_at >= ?", email, since).Order("id DESC").Find(&events).Error
+	return events, err
+}
+
+func (m *MySQL) RecordLogin(event *models.LoginEvent) error {
+	return m.DB.Create(event).Error
+}
+
+func (m *MySQL) GetFraudReview(id int64) (*models.FraudReview, error) {
+	var review models.FraudReview
+	err := m.DB.First(&review, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, util.ErrFraudReviewNotFound
+	}
+	return &review, err
+}
+
+func (m *MySQL) GetFraudReviews(status models.FraudReviewStatus) ([]*models.FraudReview, error) {
+	var reviews []*models.FraudReview
+	query := m.DB
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id").Find(&reviews).Error
+	return reviews, err
+}
+
+func (m *MySQL) CreateFraudReview(review *models.FraudReview) error {
+	return m.DB.Create(review).Error
+}
+
+func (m *MySQL) MoveFraudReview(ctx context.Context, c *FraudReviewChange) (*models.FraudReview, error) {
+	var review models.FraudReview
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, c.ReviewID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrFraudReviewNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := moveFraudReview(&review, c, time.Now()); err != nil {
+			return err
+		}
+		return tx.Save(&review).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (m *MySQL) RequestWithdrawal(ctx context.Context, w *models.Withdrawal) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		posting, transition := requestWithdrawal(w, time.Now())
+		wallets, err := postTx(tx, posting)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		if err := tx.Create(w).Error; err != nil {
+			return err
+		}
+		transition.WithdrawalID = w.ID
+		return tx.Create(transition).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// MoveWithdrawal locks the withdrawal before its wallet so two admins
+// moving it at once cannot both pay or give back the held amount
+func (m *MySQL) MoveWithdrawal(ctx context.Context, c *WithdrawalChange) (*WithdrawalResult, error) {
+	var withdrawal models.Withdrawal
+	result := &WithdrawalResult{Withdrawal: &withdrawal}
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawal, c.WithdrawalID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrWithdrawalNotFound
+		}
+		if err != nil {
+			return err
+		}
+		postings, transition, err := moveWithdrawal(&withdrawal, c, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(postings) > 0 {
+			wallets, err := postTx(tx, postings...)
+			if err != nil {
+				return err
+			}
+			withdrawnBy(result, postings, wallets)
+		}
+		if err := tx.Save(&withdrawal).Error; err != nil {
+			return err
+		}
+		result.Transition = transition
+		return tx.Create(transition).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *MySQL) CreateTournament(t *models.Tournament) error {
+	return m.DB.Create(t).Error
+}
+
+// EnterTournament locks the tournament before the wallets, like closing and
+// cancelling it do
+func (m *MySQL) EnterTournament(
+	ctx context.Context,
+	entry *models.TournamentEntry,
+	actor string,
+) (*models.Tournament, *models.Wallet, error) {
+	var t models.Tournament
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockTournament(tx, entry.TournamentID, &t); err != nil {
+			return err
+		}
+		postings, err := enterTournament(&t, entry, actor, time.Now())
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, postings...)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		err = tx.Create(entry).Error
+		if isDuplicateEntry(err) {
+			return util.ErrTournamentAlreadyEntered
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Save(&t).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &t, wallet, nil
+}
+
+func (m *MySQL) CloseTournament(
+	ctx context.Context,
+	tournamentID int64,
+	placements []int64,
+	actor string,
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	return m.settleTournament(ctx, tournamentID, func(t *models.Tournament, entries []*models.TournamentEntry) ([]*Posting, error) {
+		return closeTournament(t, entries, placements, actor, time.Now())
+	})
+}
+
+func (m *MySQL) CancelTournament(
+	ctx context.Context,
+	tournamentID int64,
+	actor string,
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	return m.settleTournament(ctx, tournamentID, func(t *models.Tournament, entries []*models.TournamentEntry) ([]*Posting, error) {
+		return cancelTournament(t, entries, actor, time.Now())
+	})
+}
+
+// settleTournament locks the tournament, lets settle close or cancel it and
+// applies the postings it returns in the same transaction. Entries only
+// change under the lock of their tournament.
+func (m *MySQL) settleTournament(
+	ctx context.Context,
+	tournamentID int64,
+	settle func(t *models.Tournament, entries []*models.TournamentEntry) ([]*Posting, error),
+) (*models.Tournament, []*models.TournamentEntry, error) {
+	var t models.Tournament
+	var entries []*models.TournamentEntry
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockTournament(tx, tournamentID, &t); err != nil {
+			return err
+		}
+		if err := tx.Where("tournament_id = ?", tournamentID).Order("id").Find(&entries).Error; err != nil {
+			return err
+		}
+		postings, err := settle(&t, entries)
+		if err != nil {
+			return err
+		}
+		if len(postings) > 0 {
+			if _, err := postTx(tx, postings...); err != nil {
+				return err
+			}
+		}
+		for _, entry := range entries {
+			if err := tx.Save(entry).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&t).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &t, entries, nil
+}
+
+func lockTournament(tx *gorm.DB, id int64, t *models.Tournament) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(t, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return util.ErrTournamentNotFound
+	}
+	return err
+}
+
+// settleBonusGrant locks the wallet of the grant and then the grant, the
+// same order stakes lock them in, lets remove move the grant out of the
+// active status and applies the posting it returns
+func (m *MySQL) settleBonusGrant(
+	ctx context.Context,
+	grantID int64,
+	remove func(grant *models.BonusGrant) (*Posting, error),
+) (*models.BonusGrant, *models.Wallet, error) {
+	var grant models.BonusGrant
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&grant, grantID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrBonusNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := lockWallets(tx, []int64{grant.WalletID}); err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&grant, grantID).Error; err != nil {
+			return err
+		}
+		posting, err := remove(&grant)
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, posting)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		return tx.Save(&grant).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &grant, wallet, nil
+}
+
+// settleHold locks the hold row, lets settle move it out of the active
+// status and applies the posting it returns in the same transaction. The
+// hold is always locked before its wallet.
+func (m *MySQL) settleHold(
+	ctx context.Context,
+	holdID int64,
+	settle func(hold *models.Hold) (*Posting, error),
+) (*models.Hold, *models.Wallet, error) {
+	var hold models.Hold
+	var wallet *models.Wallet
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, holdID).Error
+		if err == gorm.ErrRecordNotFound {
+			return util.ErrHoldNotFound
+		}
+		if err != nil {
+			return err
+		}
+		posting, err := settle(&hold)
+		if err != nil {
+			return err
+		}
+		wallets, err := postTx(tx, posting)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		return tx.Save(&hold).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hold, wallet, nil
+}
+
+// lockWallets selects the wallets FOR UPDATE one at a time in the order of
+// ids. Callers pass ids sorted ascending so that concurrent transactions
+// always lock in the same order and cannot deadlock each other.
+func lockWallets(tx *gorm.DB, ids []int64) (map[int64]*models.Wallet, error) {
+	wallets := make(map[int64]*models.Wallet, len(ids))
+	for _, id := range ids {
+		var wallet models.Wallet
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, id).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil, util.ErrWalletNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		wallets[id] = &wallet
+	}
+	return wallets, nil
+}
+
 func (m *MySQL) DeleteWallet(id int64) error {
 	return m.DB.Delete(&models.Wallet{}, id).Error
 }
@@ -167,7 +1895,7 @@ func NewMySQL() *MySQL {
 }
 
 func (m *MySQL) CreateTables() error {
-	err := m.DB.AutoMigrate(&models.User{}, &models.Wallet{})
+	err := m.DB.AutoMigrate(&models.User{}, &models.Wallet{}, &models.Transaction{}, &models.IdempotencyKey{}, &models.Hold{}, &models.FXQuote{}, &models.GameRound{}, &models.GameRoundAction{}, &models.BonusGrant{}, &models.GamingLimit{}, &models.RestrictionEvent{}, &models.Tournament{}, &models.TournamentEntry{}, &models.Match{}, &models.Deposit{}, &models.Withdrawal{}, &models.WithdrawalTransition{}, &models.FeeSchedule{}, &models.VelocityRule{}, &models.LoginEvent{}, &models.FraudReview{}, &models.JackpotPool{}, &models.JackpotEvent{}, &models.Voucher{}, &models.VoucherRedemption{}, &models.LoyaltyRule{}, &models.LoyaltyAccount{}, &models.LoyaltyEntry{}, &models.LoyaltyCursor{})
 	if err != nil {
 		return util.NewCreateSchemaError(err)
 	}
@@ -202,7 +1930,8 @@ func seedWallets(db *gorm.DB) {
 				ID:        i,
 				UUID:      uuid.New(),
 				UserID:    i,
-				Balance:   util.RandomDecimal(),
+				Currency:  models.DefaultCurrency,
+				Balance:   models.NewMoney(util.RandomDecimal().Round(2)),
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
 			}
@@ -229,6 +1958,7 @@ func seedUsers(db *gorm.DB) {
 			HashedPassword: hashedPassword,
 			FullName:       util.RandomUserName(),
 			Email:          util.RandomEmail(),
+			IsAdmin:        i == 1, // the first seeded user administers the rest
 			CreatedAt:      time.Now(),
 			UpdatedAt:      time.Now(),
 		}
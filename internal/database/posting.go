@@ -0,0 +1,203 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// BalanceConstraint is checked against the new available balance of a
+// wallet before a debit or a hold is committed. Credits and releases are
+// never rejected by a constraint.
+type BalanceConstraint int
+
+const (
+	// NonNegativeBalance rejects debits that would take the available
+	// balance or the real money of the wallet below zero
+	NonNegativeBalance BalanceConstraint = iota
+	// AllowNegativeBalance lets a debit take the balance below zero, e.g. for
+	// corrections that must be recorded whatever the current balance is
+	AllowNegativeBalance
+	// WithdrawableBalance is NonNegativeBalance that also rejects holds
+	// beyond the real money not held yet, for money that is held to leave
+	// the platform
+	WithdrawableBalance
+)
+
+// Posting is one leg of an atomic balance movement. Amount is signed, it
+// is positive for money coming into the wallet and negative for money
+// leaving it. Held is the signed change to the funds reserved by holds and
+// is applied before Amount. Bonus is the signed change to the part of the
+// balance that is bonus funds, the repository works it out itself for
+// stakes, which are paid out of the bonus grants of the wallet too. The
+// Bonus of the refund of a stake is the part of it that was bonus funds,
+// the repository gives it back to the grants.
+// Transaction is the journal entry written for the leg, the repository
+// fills in its wallet, currency, amount and balances. It may only be nil
+// for postings that do not change the ledger balance. Currency is optional, when it is set the
+// posting fails with util.ErrCurrencyMismatch unless the wallet is in that
+// currency.
+type Posting struct {
+	WalletID    int64
+	Currency    string
+	Amount      models.Money
+	Held        models.Money
+	Bonus       models.Money
+	Constraint  BalanceConstraint
+	Transaction *models.Transaction
+}
+
+// reduces the available balance of the wallet
+func (p *Posting) withdraws() bool {
+	return p.Amount.IsNegative() || p.Held.IsPositive()
+}
+
+// stakes a game, a tournament or a match, stakes count against the loss
+// and wager limits of the wallet
+func (p *Posting) wagers() bool {
+	if p.Transaction == nil || !p.Amount.IsNegative() {
+		return false
+	}
+	switch p.Transaction.Type {
+	case models.TransactionTypeDebit, models.TransactionTypeBet, models.TransactionTypeHoldCapture,
+		models.TransactionTypeTournamentEntry, models.TransactionTypeMatchStake:
+		return true
+	default:
+		return false
+	}
+}
+
+// stakes a game, stakes of games may be paid out of bonus funds and count
+// toward the wagering requirements of bonus grants. Tournament entries and
+// match stakes are refunded without the part that was bonus funds, so they
+// are paid in real money only.
+func (p *Posting) wagersGame() bool {
+	if !p.wagers() {
+		return false
+	}
+	switch p.Transaction.Type {
+	case models.TransactionTypeTournamentEntry, models.TransactionTypeMatchStake:
+		return false
+	default:
+		return true
+	}
+}
+
+// gives a stake back, the bonus funds of the stake go back to the grants
+// of the wallet and the stake comes off their wagering progress
+func (p *Posting) refundsWager() bool {
+	if p.Transaction == nil || !p.Amount.IsPositive() {
+		return false
+	}
+	switch p.Transaction.Type {
+	case models.TransactionTypeRollback, models.TransactionTypeReversal:
+		return true
+	default:
+		return false
+	}
+}
+
+// deposits money into the wallet, deposits count toward deposit limits
+func (p *Posting) deposits() bool {
+	return p.Transaction != nil && p.Amount.IsPositive() && p.Transaction.Type == models.TransactionTypeCredit
+}
+
+// anyStakes reports whether one of postings stakes a game or refunds a
+// stake
+func anyStakes(postings []*Posting) bool {
+	for _, p := range postings {
+		if p.wagersGame() || p.refundsWager() {
+			return true
+		}
+	}
+	return false
+}
+
+// postingWalletIDs returns the distinct wallets touched by postings in
+// ascending order, which is the order their locks must be taken in
+func postingWalletIDs(postings []*Posting) []int64 {
+	seen := make(map[int64]bool, len(postings))
+	ids := make([]int64, 0, len(postings))
+	for _, p := range postings {
+		if !seen[p.WalletID] {
+			seen[p.WalletID] = true
+			ids = append(ids, p.WalletID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// postedWallets lists the wallets in the order they were first posted to
+func postedWallets(wallets map[int64]*models.Wallet, postings []*Posting) []*models.Wallet {
+	seen := make(map[int64]bool, len(postings))
+	result := make([]*models.Wallet, 0, len(wallets))
+	for _, p := range postings {
+		if !seen[p.WalletID] {
+			seen[p.WalletID] = true
+			result = append(result, wallets[p.WalletID])
+		}
+	}
+	return result
+}
+
+// applyPostings moves the balances of the locked wallets and completes the
+// journal entry of every posting. Nothing should be persisted if it fails.
+func applyPostings(wallets map[int64]*models.Wallet, postings []*Posting, now time.Time) error {
+	for _, p := range postings {
+		wallet, ok := wallets[p.WalletID]
+		if !ok {
+			return util.ErrWalletNotFound
+		}
+		if err := checkPostingCurrency(wallet, p); err != nil {
+			return err
+		}
+		balanceBefore := wallet.Balance
+		wallet.HeldBalance = wallet.HeldBalance.Add(p.Held)
+		wallet.BonusBalance = wallet.BonusBalance.Add(p.Bonus)
+		wallet.Balance = wallet.Balance.Add(p.Amount)
+		wallet.UpdatedAt = now
+		if p.withdraws() && p.Constraint != AllowNegativeBalance &&
+			(wallet.AvailableBalance().IsNegative() || wallet.RealBalance().IsNegative()) {
+			return util.ErrInsufficientFunds
+		}
+		if p.withdraws() && p.Constraint == WithdrawableBalance &&
+			wallet.RealBalance().Sub(wallet.HeldBalance).IsNegative() {
+			return util.ErrInsufficientFunds
+		}
+
+		txn := p.Transaction
+		if txn == nil {
+			continue
+		}
+		txn.WalletID = wallet.ID
+		txn.Currency = wallet.CurrencyCode()
+		txn.Amount = p.Amount.Abs()
+		txn.BalanceBefore = balanceBefore
+		txn.BalanceAfter = wallet.Balance
+		if txn.CreatedAt.IsZero() {
+			txn.CreatedAt = now
+		}
+	}
+	return nil
+}
+
+// checkPostingCurrency rejects postings in another currency than the wallet
+// and amounts with more decimal places than the wallet currency has
+func checkPostingCurrency(wallet *models.Wallet, p *Posting) error {
+	code := wallet.CurrencyCode()
+	if p.Currency != "" && !strings.EqualFold(p.Currency, code) {
+		return util.ErrCurrencyMismatch
+	}
+	currency, ok := models.LookupCurrency(code)
+	if !ok {
+		return nil
+	}
+	if !currency.Fits(p.Amount) || !currency.Fits(p.Held) || !currency.Fits(p.Bonus) {
+		return util.ErrInvalidAmountPrecision
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// imposeRestriction restricts user as event describes and completes the
+// event. A restriction in force can be extended, never shortened nor turned
+// from a self exclusion into a time out.
+func imposeRestriction(user *models.User, event *models.RestrictionEvent, now time.Time) error {
+	if user.IsRestricted(now) {
+		downgraded := user.Restriction == models.RestrictionSelfExclusion &&
+			event.Restriction != models.RestrictionSelfExclusion
+		shortened := event.Until != nil &&
+			(user.RestrictedUntil == nil || event.Until.Before(*user.RestrictedUntil))
+		if downgraded || shortened {
+			return util.ErrRestrictionShortened
+		}
+	}
+	user.Restriction = event.Restriction
+	user.RestrictedUntil = event.Until
+	user.UpdatedAt = now
+	event.UserID = user.ID
+	event.Action = models.RestrictionActionImposed
+	event.CreatedAt = now
+	return nil
+}
+
+// liftRestriction ends the restriction in force on user and completes the
+// event recording it
+func liftRestriction(user *models.User, event *models.RestrictionEvent, now time.Time) error {
+	if !user.IsRestricted(now) {
+		return util.ErrUserNotRestricted
+	}
+	event.UserID = user.ID
+	event.Action = models.RestrictionActionLifted
+	event.Restriction = user.Restriction
+	event.Until = user.RestrictedUntil
+	event.CreatedAt = now
+	user.Restriction = ""
+	user.RestrictedUntil = nil
+	user.UpdatedAt = now
+	return nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/shopspring/decimal"
+)
+
+// reversalPosting returns the posting that compensates amount of original,
+// or whatever is left of it when amount is nil. reversals are the
+// entries that already reversed part of original.
+func reversalPosting(
+	original *models.Transaction,
+	reversals []*models.Transaction,
+	amount *models.Money,
+	txn *models.Transaction,
+) (*Posting, error) {
+	var sign int64
+	switch original.Type {
+	case models.TransactionTypeCredit:
+		sign = -1
+	case models.TransactionTypeDebit, models.TransactionTypeHoldCapture:
+		sign = 1
+	default:
+		return nil, util.ErrTransactionNotReversible
+	}
+
+	remaining := original.Amount
+	for _, reversal := range reversals {
+		remaining = remaining.Sub(reversal.Amount)
+	}
+	if !remaining.IsPositive() {
+		return nil, util.ErrTransactionAlreadyReversed
+	}
+	reversed := remaining
+	if amount != nil {
+		if amount.GreaterThan(remaining) {
+			return nil, util.ErrReversalExceedsTransaction
+		}
+		reversed = *amount
+	}
+
+	// the bonus funds a stake was paid out of are given back first, as
+	// bonus funds
+	var bonus models.Money
+	if sign > 0 {
+		bonus = original.BonusAmount
+		for _, reversal := range reversals {
+			bonus = bonus.Sub(reversal.BonusAmount)
+		}
+		bonus = minMoney(reversed, bonus)
+		txn.BonusAmount = bonus
+	}
+
+	txn.OriginalID = original.ID
+	return &Posting{
+		WalletID:    original.WalletID,
+		Currency:    original.Currency,
+		Amount:      reversed.Mul(decimal.NewFromInt(sign)),
+		Bonus:       bonus,
+		Transaction: txn,
+	}, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// tournamentTransaction is a journal entry of the tournament, it carries the
+// tournament id as its reference and the tournament uuid as its
+// correlation id
+func tournamentTransaction(t *models.Tournament, txnType models.TransactionType, actor string) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          txnType,
+		Reference:     strconv.FormatInt(t.ID, 10),
+		CorrelationID: t.UUID.String(),
+		Actor:         actor,
+	}
+}
+
+// enterTournament adds the entry fee to the pool of the open tournament and
+// returns the postings moving it from the wallet of entry into escrow
+func enterTournament(t *models.Tournament, entry *models.TournamentEntry, actor string, now time.Time) ([]*Posting, error) {
+	if t.Status != models.TournamentStatusOpen {
+		return nil, util.ErrTournamentNotOpen
+	}
+	t.PrizePool = t.PrizePool.Add(t.EntryFee)
+	t.Entrants++
+	t.UpdatedAt = now
+	entry.TournamentID = t.ID
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	return []*Posting{
+		{
+			WalletID:    entry.WalletID,
+			Currency:    t.Currency,
+			Amount:      t.EntryFee.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: tournamentTransaction(t, models.TransactionTypeTournamentEntry, actor),
+		},
+		{
+			WalletID:    t.EscrowWalletID,
+			Currency:    t.Currency,
+			Amount:      t.EntryFee,
+			Transaction: tournamentTransaction(t, models.TransactionTypeTournamentEscrow, actor),
+		},
+	}, nil
+}
+
+// closeTournament pays the pool of the open tournament out of escrow.
+// placements are the wallets of the best placed entrants, the winner first,
+// there must be one per line of the payout table or one per entrant when
+// there are fewer entrants. The rake is taken off the pool first and the
+// rest is split following the payout table, the shares of places left
+// empty are spread over the others. Prizes are rounded down to the currency
+// and what is left over goes to the rake wallet with the rake. The entries
+// are updated in place.
+func closeTournament(
+	t *models.Tournament,
+	entries []*models.TournamentEntry,
+	placements []int64,
+	actor string,
+	now time.Time,
+) ([]*Posting, error) {
+	if t.Status != models.TournamentStatusOpen {
+		return nil, util.ErrTournamentNotOpen
+	}
+	payouts, err := t.Payouts()
+	if err != nil {
+		return nil, err
+	}
+	places := len(payouts)
+	if len(entries) < places {
+		places = len(entries)
+	}
+	if len(placements) != places {
+		return nil, util.ErrTournamentPlacements
+	}
+	byWallet := make(map[int64]*models.TournamentEntry, len(entries))
+	for _, entry := range entries {
+		byWallet[entry.WalletID] = entry
+	}
+	placed := make([]*models.TournamentEntry, 0, places)
+	for _, walletID := range placements {
+		entry, ok := byWallet[walletID]
+		if !ok || entry.Place != 0 {
+			return nil, util.ErrTournamentPlacements
+		}
+		entry.Place = len(placed) + 1
+		placed = append(placed, entry)
+	}
+
+	precision := int32(2)
+	if currency, ok := models.LookupCurrency(t.Currency); ok {
+		precision = currency.Precision
+	}
+	rake := models.RoundDown.Round(t.PrizePool.Mul(t.RakePercent.Div(decimal.NewFromInt(100))), precision)
+	net := t.PrizePool.Sub(rake)
+	shares := decimal.Zero
+	for _, percentage := range payouts[:places] {
+		shares = shares.Add(percentage)
+	}
+
+	var postings []*Posting
+	if t.PrizePool.IsPositive() {
+		postings = append(postings, &Posting{
+			WalletID:    t.EscrowWalletID,
+			Currency:    t.Currency,
+			Amount:      t.PrizePool.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: tournamentTransaction(t, models.TransactionTypeTournamentEscrow, actor),
+		})
+	}
+	paid := models.ZeroMoney
+	for i, entry := range placed {
+		prize := models.RoundDown.Round(net.Mul(payouts[i].Div(shares)), precision)
+		entry.Prize = prize
+		entry.UpdatedAt = now
+		paid = paid.Add(prize)
+		if prize.IsPositive() {
+			postings = append(postings, &Posting{
+				WalletID:    entry.WalletID,
+				Currency:    t.Currency,
+				Amount:      prize,
+				Transaction: tournamentTransaction(t, models.TransactionTypeTournamentPrize, actor),
+			})
+		}
+	}
+	rake = rake.Add(net.Sub(paid))
+	if rake.IsPositive() {
+		postings = append(postings, &Posting{
+			WalletID:    t.RakeWalletID,
+			Currency:    t.Currency,
+			Amount:      rake,
+			Transaction: tournamentTransaction(t, models.TransactionTypeTournamentRake, actor),
+		})
+	}
+	t.Status = models.TournamentStatusClosed
+	t.Rake = rake
+	t.ClosedAt = &now
+	t.UpdatedAt = now
+	return postings, nil
+}
+
+// cancelTournament refunds the entry fee of every entrant of the open
+// tournament out of escrow, the entries are updated in place
+func cancelTournament(t *models.Tournament, entries []*models.TournamentEntry, actor string, now time.Time) ([]*Posting, error) {
+	if t.Status != models.TournamentStatusOpen {
+		return nil, util.ErrTournamentNotOpen
+	}
+	var postings []*Posting
+	if t.PrizePool.IsPositive() {
+		postings = append(postings, &Posting{
+			WalletID:    t.EscrowWalletID,
+			Currency:    t.Currency,
+			Amount:      t.PrizePool.Neg(),
+			Constraint:  NonNegativeBalance,
+			Transaction: tournamentTransaction(t, models.TransactionTypeTournamentEscrow, actor),
+		})
+	}
+	for _, entry := range entries {
+		entry.Refunded = true
+		entry.UpdatedAt = now
+		postings = append(postings, &Posting{
+			WalletID:    entry.WalletID,
+			Currency:    t.Currency,
+			Amount:      t.EntryFee,
+			Transaction: tournamentTransaction(t, models.TransactionTypeTournamentRefund, actor),
+		})
+	}
+	t.Status = models.TournamentStatusCancelled
+	t.ClosedAt = &now
+	t.UpdatedAt = now
+	return postings, nil
+}
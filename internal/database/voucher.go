@@ -0,0 +1,49 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// redeemVoucher checks that the player of redemption may redeem the voucher
+// and returns the posting crediting it to the wallet of the redemption.
+// redeemedBefore tells whether that player redeemed the voucher already.
+// The voucher, the redemption and the journal entry are completed in
+// place, the caller saves them once the posting went through.
+func redeemVoucher(
+	v *models.Voucher,
+	redemption *models.VoucherRedemption,
+	redeemedBefore bool,
+	txn *models.Transaction,
+	now time.Time,
+) (*Posting, error) {
+	// a voucher issued to another player is not disclosed
+	if v.Kind == models.VoucherPerUser && v.UserID != redemption.UserID {
+		return nil, util.ErrVoucherNotFound
+	}
+	if !now.Before(v.ExpiresAt) {
+		return nil, util.ErrVoucherExpired
+	}
+	if redeemedBefore || (v.Kind == models.VoucherSingleUse && v.Redemptions > 0) {
+		return nil, util.ErrVoucherRedeemed
+	}
+	if v.Redeemed.Add(v.Amount).GreaterThan(v.Budget) {
+		return nil, util.ErrVoucherBudgetExhausted
+	}
+	v.Redeemed = v.Redeemed.Add(v.Amount)
+	v.Redemptions++
+	v.UpdatedAt = now
+	redemption.VoucherID = v.ID
+	redemption.Amount = v.Amount
+	redemption.CreatedAt = now
+	txn.Reference = v.Code
+	txn.CorrelationID = v.UUID.String()
+	return &Posting{
+		WalletID:    redemption.WalletID,
+		Currency:    v.Currency,
+		Amount:      v.Amount,
+		Transaction: txn,
+	}, nil
+}
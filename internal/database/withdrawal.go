@@ -0,0 +1,107 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/google/uuid"
+)
+
+// WithdrawalChange moves the withdrawal with WithdrawalID to Status on
+// behalf of Actor
+type WithdrawalChange struct {
+	WithdrawalID int64
+	Status       models.WithdrawalStatus
+	Actor        string
+	// Reason explains a rejection or a failed payout
+	Reason string
+}
+
+// WithdrawalResult is the outcome of moving a withdrawal
+type WithdrawalResult struct {
+	Withdrawal *models.Withdrawal
+	Transition *models.WithdrawalTransition
+	// Wallet is set when the move debited the wallet or gave the held
+	// amount back
+	Wallet *models.Wallet
+	// FeeWallet is the wallet the fee of a paid withdrawal was credited to
+	FeeWallet *models.Wallet
+}
+
+// requestWithdrawal marks the withdrawal requested and returns the posting
+// holding its amount, which must be real money that is not held yet, and
+// the first transition of the withdrawal
+func requestWithdrawal(w *models.Withdrawal, now time.Time) (*Posting, *models.WithdrawalTransition) {
+	w.Status = models.WithdrawalStatusRequested
+	w.UpdatedAt = now
+	posting := &Posting{
+		WalletID:   w.WalletID,
+		Currency:   w.Currency,
+		Held:       w.Amount,
+		Constraint: WithdrawableBalance,
+	}
+	return posting, &models.WithdrawalTransition{
+		To:        models.WithdrawalStatusRequested,
+		Actor:     w.Actor,
+		CreatedAt: now,
+	}
+}
+
+// moveWithdrawal moves the withdrawal the way c says and returns the
+// transition to record and the postings that go with it, if any. Paying
+// the withdrawal debits the held amount and charges its fee out of it,
+// rejecting it or failing its payout gives the held amount back.
+func moveWithdrawal(w *models.Withdrawal, c *WithdrawalChange, now time.Time) ([]*Posting, *models.WithdrawalTransition, error) {
+	if !w.Status.CanMoveTo(c.Status) {
+		return nil, nil, util.ErrWithdrawalTransition
+	}
+	transition := &models.WithdrawalTransition{
+		WithdrawalID: w.ID,
+		From:         w.Status,
+		To:           c.Status,
+		Actor:        c.Actor,
+		Reason:       c.Reason,
+		CreatedAt:    now,
+	}
+	var postings []*Posting
+	switch c.Status {
+	case models.WithdrawalStatusPaid:
+		payout := &models.Transaction{
+			UUID:          uuid.New(),
+			Type:          models.TransactionTypeWithdrawal,
+			Reference:     w.Reference,
+			CorrelationID: w.UUID.String(),
+			Actor:         c.Actor,
+		}
+		postings = append(postings, &Posting{
+			WalletID:    w.WalletID,
+			Amount:      w.Amount.Sub(w.Fee).Neg(),
+			Held:        w.Amount.Neg(),
+			Transaction: payout,
+		})
+		postings = append(postings, FeePostings(w.WalletID, w.FeeWalletID, w.Currency, w.Fee, feeTransaction(payout), feeTransaction(payout))...)
+	case models.WithdrawalStatusRejected, models.WithdrawalStatusFailed:
+		postings = append(postings, &Posting{
+			WalletID: w.WalletID,
+			Held:     w.Amount.Neg(),
+		})
+		w.Reason = c.Reason
+	}
+	w.Status = c.Status
+	w.UpdatedAt = now
+	return postings, transition, nil
+}
+
+// withdrawnBy fills in the wallets the postings of a move were applied to
+// and the payout they journaled, wallets are in the order they were posted
+func withdrawnBy(result *WithdrawalResult, postings []*Posting, wallets []*models.Wallet) {
+	result.Wallet = wallets[0]
+	if len(wallets) > 1 {
+		result.FeeWallet = wallets[1]
+	}
+	if txn := postings[0].Transaction; txn != nil {
+		result.Withdrawal.TransactionID = txn.ID
+	}
+}
@@ -0,0 +1,155 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+)
+
+// Decision is what becomes of an operation, the stricter decision of two
+// rules wins
+type Decision string
+
+const (
+	// the operation goes ahead
+	DecisionAllow Decision = "allow"
+	// the operation is held back until an admin reviews it
+	DecisionFlag Decision = "flag"
+	// the operation is refused
+	DecisionBlock Decision = "block"
+)
+
+var decisionSeverity = map[Decision]int{
+	DecisionAllow: 0,
+	DecisionFlag:  1,
+	DecisionBlock: 2,
+}
+
+// stricter reports whether d is stricter than other
+func (d Decision) stricter(other Decision) bool {
+	return decisionSeverity[d] > decisionSeverity[other]
+}
+
+// Operation is a wallet operation about to be made
+type Operation struct {
+	// Type is the journal entry the operation writes
+	Type     models.TransactionType
+	WalletID int64
+	UserID   int64
+	Currency string
+	Amount   models.Money
+	// Email is the logged in user who asked for the operation and IP where
+	// the request came from
+	Email string
+	IP    string
+	At    time.Time
+}
+
+// Verdict is the decision of a rule on an operation, Reason explains a
+// decision other than allow
+type Verdict struct {
+	Decision Decision
+	Reason   string
+}
+
+// Allow is the verdict of a rule that finds nothing wrong
+var Allow = Verdict{Decision: DecisionAllow}
+
+// Rule looks at an operation and decides what becomes of it
+type Rule interface {
+	// Name identifies the rule in the findings
+	Name() string
+	Evaluate(ctx context.Context, op *Operation) (Verdict, error)
+}
+
+type ruleFunc struct {
+	name     string
+	evaluate func(ctx context.Context, op *Operation) (Verdict, error)
+}
+
+// NewRule turns a function into a rule, for custom rules that need no
+// state of their own
+func NewRule(name string, evaluate func(ctx context.Context, op *Operation) (Verdict, error)) Rule {
+	return &ruleFunc{name: name, evaluate: evaluate}
+}
+
+func (r *ruleFunc) Name() string {
+	return r.name
+}
+
+func (r *ruleFunc) Evaluate(ctx context.Context, op *Operation) (Verdict, error) {
+	return r.evaluate(ctx, op)
+}
+
+// Finding is a verdict other than allow and the rule that reached it
+type Finding struct {
+	Rule     string
+	Decision Decision
+	Reason   string
+}
+
+// Assessment is the decision on an operation, the strictest of the
+// verdicts of the rules, and the findings behind it
+type Assessment struct {
+	Decision Decision
+	Findings []Finding
+}
+
+// Reasons joins the findings into one line
+func (a *Assessment) Reasons() string {
+	reasons := make([]string, 0, len(a.Findings))
+	for _, f := range a.Findings {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", f.Rule, f.Reason))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Engine screens operations with every rule registered with it
+type Engine struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Register adds a rule to those operations are screened with
+func (e *Engine) Register(rule Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = append(e.rules, rule)
+}
+
+// Screen runs every rule on op. It fails when a rule cannot reach a
+// verdict rather than let the operation through unscreened.
+func (e *Engine) Screen(ctx context.Context, op *Operation) (*Assessment, error) {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	assessment := &Assessment{Decision: DecisionAllow}
+	for _, rule := range rules {
+		verdict, err := rule.Evaluate(ctx, op)
+		if err != nil {
+			return nil, fmt.Errorf("fraud rule %s: %w", rule.Name(), err)
+		}
+		if verdict.Decision == DecisionAllow || verdict.Decision == "" {
+			continue
+		}
+		assessment.Findings = append(assessment.Findings, Finding{
+			Rule:     rule.Name(),
+			Decision: verdict.Decision,
+			Reason:   verdict.Reason,
+		})
+		if verdict.Decision.stricter(assessment.Decision) {
+			assessment.Decision = verdict.Decision
+		}
+	}
+	return assessment, nil
+}
@@ -0,0 +1,197 @@
+package fraud
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) *database.InMemory {
+	repo := database.NewInMemory()
+	_, err := repo.CreateWallet(&models.Wallet{
+		ID:        1,
+		UUID:      uuid.New(),
+		UserID:    1,
+		Balance:   models.NewMoneyFromInt(1000),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	return repo
+}
+
+func adjust(t *testing.T, repo *database.InMemory, txnType models.TransactionType, amount int64) {
+	_, err := repo.AdjustBalance(context.Background(), 1, models.NewMoneyFromInt(amount), database.NonNegativeBalance, &models.Transaction{
+		UUID: uuid.New(),
+		Type: txnType,
+	})
+	require.NoError(t, err)
+}
+
+func login(t *testing.T, repo *database.InMemory, ip string, succeeded bool) {
+	require.NoError(t, repo.RecordLogin(&models.LoginEvent{
+		Email:     "player@email.com",
+		IP:        ip,
+		Succeeded: succeeded,
+		CreatedAt: time.Now(),
+	}))
+}
+
+func newTestOperation(txnType models.TransactionType, amount int64) *Operation {
+	return &Operation{
+		Type:     txnType,
+		WalletID: 1,
+		UserID:   1,
+		Currency: "USD",
+		Amount:   models.NewMoneyFromInt(amount),
+		Email:    "player@email.com",
+		IP:       "10.0.0.1",
+		At:       time.Now(),
+	}
+}
+
+func TestEngineScreen(t *testing.T) {
+	ctx := context.Background()
+	flag := NewRule("flag", func(ctx context.Context, op *Operation) (Verdict, error) {
+		return Verdict{Decision: DecisionFlag, Reason: "looks odd"}, nil
+	})
+	engine := NewEngine(NewRule("allow", func(ctx context.Context, op *Operation) (Verdict, error) {
+		return Allow, nil
+	}), flag)
+
+	assessment, err := engine.Screen(ctx, newTestOperation(models.TransactionTypeCredit, 10))
+	require.NoError(t, err)
+	require.Equal(t, DecisionFlag, assessment.Decision)
+	require.Equal(t, "flag: looks odd", assessment.Reasons())
+
+	// the strictest verdict wins whatever order the rules run in
+	engine.Register(NewRule("block", func(ctx context.Context, op *Operation) (Verdict, error) {
+		return Verdict{Decision: DecisionBlock, Reason: "known fraudster"}, nil
+	}))
+	engine.Register(flag)
+	assessment, err = engine.Screen(ctx, newTestOperation(models.TransactionTypeCredit, 10))
+	require.NoError(t, err)
+	require.Equal(t, DecisionBlock, assessment.Decision)
+	require.Len(t, assessment.Findings, 3)
+
+	// an operation is never let through unscreened
+	failure := errors.New("lookup failed")
+	engine.Register(NewRule("broken", func(ctx context.Context, op *Operation) (Verdict, error) {
+		return Verdict{}, failure
+	}))
+	_, err = engine.Screen(ctx, newTestOperation(models.TransactionTypeCredit, 10))
+	require.ErrorIs(t, err, failure)
+}
+
+func TestAmountAnomaly(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestStore(t)
+	rule := DefaultRules(repo)[0]
+
+	// a wallet without enough history has no usual amount
+	verdict, err := rule.Evaluate(ctx, newTestOperation(models.TransactionTypeCredit, 5000))
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+
+	for i := 0; i < 5; i++ {
+		adjust(t, repo, models.TransactionTypeCredit, 20)
+	}
+	verdict, err = rule.Evaluate(ctx, newTestOperation(models.TransactionTypeCredit, 200))
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+
+	verdict, err = rule.Evaluate(ctx, newTestOperation(models.TransactionTypeCredit, 201))
+	require.NoError(t, err)
+	require.Equal(t, DecisionFlag, verdict.Decision)
+
+	// debits are compared with past debits only
+	verdict, err = rule.Evaluate(ctx, newTestOperation(models.TransactionTypeDebit, 201))
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+}
+
+func TestNewIP(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestStore(t)
+	rule := DefaultRules(repo)[1]
+	op := newTestOperation(models.TransactionTypeDebit, 10)
+
+	// a user with no logins on record has no known address yet
+	verdict, err := rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+
+	// a failed login does not make an address known
+	login(t, repo, "10.0.0.2", true)
+	login(t, repo, op.IP, false)
+	verdict, err = rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionFlag, verdict.Decision)
+
+	login(t, repo, op.IP, true)
+	verdict, err = rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+}
+
+func TestRapidCreditDebit(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestStore(t)
+	rule := DefaultRules(repo)[2]
+
+	adjust(t, repo, models.TransactionTypeCredit, 500)
+
+	verdict, err := rule.Evaluate(ctx, newTestOperation(models.TransactionTypeDebit, 449))
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+
+	verdict, err = rule.Evaluate(ctx, newTestOperation(models.TransactionTypeDebit, 450))
+	require.NoError(t, err)
+	require.Equal(t, DecisionFlag, verdict.Decision)
+
+	// whatever takes the money out again counts
+	verdict, err = rule.Evaluate(ctx, newTestOperation(models.TransactionTypeWithdrawal, 450))
+	require.NoError(t, err)
+	require.Equal(t, DecisionFlag, verdict.Decision)
+
+	verdict, err = rule.Evaluate(ctx, newTestOperation(models.TransactionTypeVoucherRedemption, 450))
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+
+	// credits older than the window do not count
+	op := newTestOperation(models.TransactionTypeDebit, 450)
+	op.At = op.At.Add(time.Hour)
+	verdict, err = rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+}
+
+func TestFailedLogins(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestStore(t)
+	rule := &FailedLogins{Store: repo, Window: time.Hour, FlagAt: 2, BlockAt: 3}
+	op := newTestOperation(models.TransactionTypeDebit, 10)
+
+	login(t, repo, op.IP, false)
+	login(t, repo, op.IP, true)
+	verdict, err := rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionAllow, verdict.Decision)
+
+	login(t, repo, op.IP, false)
+	verdict, err = rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionFlag, verdict.Decision)
+
+	login(t, repo, op.IP, false)
+	verdict, err = rule.Evaluate(ctx, op)
+	require.NoError(t, err)
+	require.Equal(t, DecisionBlock, verdict.Decision)
+}
@@ -0,0 +1,214 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// Store is what the built-in rules look up. It is satisfied by
+// database.Repository.
+type Store interface {
+	GetTransactionsByWallet(filter *database.TransactionFilter) ([]*models.Transaction, error)
+	GetLoginEvents(email string, since time.Time) ([]*models.LoginEvent, error)
+}
+
+// DefaultRules are the built-in rules with their default settings
+func DefaultRules(store Store) []Rule {
+	return []Rule{
+		&AmountAnomaly{
+			Store:      store,
+			Lookback:   50,
+			MinHistory: 5,
+			Factor:     decimal.NewFromInt(10),
+		},
+		&NewIP{
+			Store:    store,
+			Lookback: 90 * 24 * time.Hour,
+		},
+		&RapidCreditDebit{
+			Store:  store,
+			Window: 10 * time.Minute,
+			Share:  decimal.RequireFromString("0.9"),
+		},
+		&FailedLogins{
+			Store:   store,
+			Window:  time.Hour,
+			FlagAt:  3,
+			BlockAt: 10,
+		},
+	}
+}
+
+// AmountAnomaly flags an operation far larger than the operations of the
+// same type the wallet usually sees
+type AmountAnomaly struct {
+	Store Store
+	// Lookback is how many past operations make the history, a wallet with
+	// fewer than MinHistory of them has no usual amount yet
+	Lookback   int
+	MinHistory int
+	// Factor times the average past operation is the largest usual amount
+	Factor decimal.Decimal
+}
+
+func (r *AmountAnomaly) Name() string {
+	return "amount_anomaly"
+}
+
+func (r *AmountAnomaly) Evaluate(ctx context.Context, op *Operation) (Verdict, error) {
+	history, err := r.Store.GetTransactionsByWallet(&database.TransactionFilter{
+		WalletID: op.WalletID,
+		Type:     op.Type,
+		Limit:    r.Lookback,
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+	if len(history) == 0 || len(history) < r.MinHistory {
+		return Allow, nil
+	}
+	total := models.ZeroMoney
+	for _, txn := range history {
+		total = total.Add(txn.Amount.Abs())
+	}
+	usual := total.Mul(r.Factor.Div(decimal.NewFromInt(int64(len(history)))))
+	if !op.Amount.GreaterThan(usual) {
+		return Allow, nil
+	}
+	return Verdict{
+		Decision: DecisionFlag,
+		Reason:   fmt.Sprintf("%s is more than %s times the average of the last %d operations", op.Amount, r.Factor, len(history)),
+	}, nil
+}
+
+// NewIP flags an operation from an address the user never logged in from
+// over the lookback period. A user with no logins on record has no known
+// address yet.
+type NewIP struct {
+	Store    Store
+	Lookback time.Duration
+}
+
+func (r *NewIP) Name() string {
+	return "new_ip"
+}
+
+func (r *NewIP) Evaluate(ctx context.Context, op *Operation) (Verdict, error) {
+	if op.IP == "" {
+		return Allow, nil
+	}
+	events, err := r.Store.GetLoginEvents(op.Email, op.At.Add(-r.Lookback))
+	if err != nil {
+		return Verdict{}, err
+	}
+	known := false
+	for _, event := range events {
+		if !event.Succeeded {
+			continue
+		}
+		if event.IP == op.IP {
+			return Allow, nil
+		}
+		known = true
+	}
+	if !known {
+		return Allow, nil
+	}
+	return Verdict{
+		Decision: DecisionFlag,
+		Reason:   fmt.Sprintf("no login from %s", op.IP),
+	}, nil
+}
+
+// outgoing are the operations that take money out of the wallet, a hold
+// is screened as the capture it leads to
+var outgoing = map[models.TransactionType]bool{
+	models.TransactionTypeDebit:           true,
+	models.TransactionTypeTransferOut:     true,
+	models.TransactionTypeConversionOut:   true,
+	models.TransactionTypeHoldCapture:     true,
+	models.TransactionTypeWithdrawal:      true,
+	models.TransactionTypeBet:             true,
+	models.TransactionTypeTournamentEntry: true,
+}
+
+// RapidCreditDebit flags an operation that takes Share or more of what was
+// credited to the wallet within Window out again, money passed straight
+// through
+type RapidCreditDebit struct {
+	Store  Store
+	Window time.Duration
+	Share  decimal.Decimal
+}
+
+func (r *RapidCreditDebit) Name() string {
+	return "rapid_credit_debit"
+}
+
+func (r *RapidCreditDebit) Evaluate(ctx context.Context, op *Operation) (Verdict, error) {
+	if !outgoing[op.Type] {
+		return Allow, nil
+	}
+	since := op.At.Add(-r.Window)
+	credits, err := r.Store.GetTransactionsByWallet(&database.TransactionFilter{
+		WalletID: op.WalletID,
+		Type:     models.TransactionTypeCredit,
+		From:     &since,
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+	credited := models.ZeroMoney
+	for _, txn := range credits {
+		credited = credited.Add(txn.Amount)
+	}
+	if !credited.IsPositive() || op.Amount.LessThan(credited.Mul(r.Share)) {
+		return Allow, nil
+	}
+	return Verdict{
+		Decision: DecisionFlag,
+		Reason:   fmt.Sprintf("debit of %s within %s of credits of %s", op.Amount, r.Window, credited),
+	}, nil
+}
+
+// FailedLogins flags the operations of a user who failed to log in FlagAt
+// times within Window and blocks them from BlockAt failures, the account
+// may have been taken over. A zero threshold is not checked.
+type FailedLogins struct {
+	Store   Store
+	Window  time.Duration
+	FlagAt  int
+	BlockAt int
+}
+
+func (r *FailedLogins) Name() string {
+	return "failed_logins"
+}
+
+func (r *FailedLogins) Evaluate(ctx context.Context, op *Operation) (Verdict, error) {
+	events, err := r.Store.GetLoginEvents(op.Email, op.At.Add(-r.Window))
+	if err != nil {
+		return Verdict{}, err
+	}
+	failed := 0
+	for _, event := range events {
+		if !event.Succeeded {
+			failed++
+		}
+	}
+	reason := fmt.Sprintf("%d failed logins within %s", failed, r.Window)
+	switch {
+	case r.BlockAt > 0 && failed >= r.BlockAt:
+		return Verdict{Decision: DecisionBlock, Reason: reason}, nil
+	case r.FlagAt > 0 && failed >= r.FlagAt:
+		return Verdict{Decision: DecisionFlag, Reason: reason}, nil
+	default:
+		return Allow, nil
+	}
+}
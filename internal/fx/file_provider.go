@@ -0,0 +1,100 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// FileRateProvider reads rates from a JSON file of the form
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "NGN": "1550"}}
+//
+// where every rate is the price of one unit of base. Rates between two
+// currencies other than base are crossed through it. The file is read
+// again whenever it changes, so rates can be updated without a restart.
+type FileRateProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	base    string
+	rates   map[string]decimal.Decimal
+}
+
+var _ RateProvider = (*FileRateProvider)(nil)
+
+type rateFile struct {
+	Base  string                     `json:"base"`
+	Rates map[string]decimal.Decimal `json:"rates"`
+}
+
+func NewFileRateProvider(path string) *FileRateProvider {
+	return &FileRateProvider{path: path}
+}
+
+func (p *FileRateProvider) Rate(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return decimal.Decimal{}, err
+	}
+	fromRate, ok := p.rate(from)
+	if !ok {
+		return decimal.Decimal{}, ErrRateNotFound
+	}
+	toRate, ok := p.rate(to)
+	if !ok {
+		return decimal.Decimal{}, ErrRateNotFound
+	}
+	return toRate.Div(fromRate), nil
+}
+
+// rate of currency against base, the caller must hold mu
+func (p *FileRateProvider) rate(currency string) (decimal.Decimal, bool) {
+	if currency == p.base {
+		return decimal.NewFromInt(1), true
+	}
+	rate, ok := p.rates[currency]
+	return rate, ok && rate.IsPositive()
+}
+
+// load reads the file if it changed since it was last read, the caller
+// must hold mu
+func (p *FileRateProvider) load() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("cannot read exchange rates: %w", err)
+	}
+	if p.rates != nil && info.ModTime().Equal(p.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("cannot read exchange rates: %w", err)
+	}
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("cannot parse exchange rates: %w", err)
+	}
+	rates := make(map[string]decimal.Decimal, len(file.Rates))
+	for currency, rate := range file.Rates {
+		rates[strings.ToUpper(currency)] = rate
+	}
+	p.base = strings.ToUpper(file.Base)
+	p.rates = rates
+	p.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,53 @@
+package fx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRateProvider(t *testing.T) {
+	provider := NewFileRateProvider("testdata/rates.json")
+	ctx := context.Background()
+
+	testCases := []struct {
+		from, to string
+		want     string
+	}{
+		{from: "USD", to: "EUR", want: "0.5"},
+		{from: "EUR", to: "USD", want: "2"},
+		{from: "eur", to: "ngn", want: "2000"},
+		{from: "NGN", to: "NGN", want: "1"},
+	}
+	for _, tt := range testCases {
+		rate, err := provider.Rate(ctx, tt.from, tt.to)
+		require.NoError(t, err)
+		require.True(t, rate.Equal(decimal.RequireFromString(tt.want)), "%s/%s: want %s, got %s", tt.from, tt.to, tt.want, rate)
+	}
+
+	_, err := provider.Rate(ctx, "USD", "JPY")
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestFileRateProviderReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":"0.5"}}`), 0o600))
+
+	provider := NewFileRateProvider(path)
+	rate, err := provider.Rate(context.Background(), "USD", "EUR")
+	require.NoError(t, err)
+	require.True(t, rate.Equal(decimal.RequireFromString("0.5")))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":"0.8"}}`), 0o600))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	rate, err = provider.Rate(context.Background(), "USD", "EUR")
+	require.NoError(t, err)
+	require.True(t, rate.Equal(decimal.RequireFromString("0.8")))
+}
@@ -0,0 +1,30 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrRateNotFound = errors.New("exchange rate not found")
+)
+
+// RateProvider returns the rate to convert one unit of the from currency
+// into the to currency
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (decimal.Decimal, error)
+}
+
+func GetCurrentRateProvider(provider string) RateProvider {
+	switch provider {
+	default:
+		path := os.Getenv("FX_RATES_FILE")
+		if path == "" {
+			path = "fx_rates.json"
+		}
+		return NewFileRateProvider(path)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrAdminRequired = errors.New("only an admin can perform this operation")
+)
+
+// UserStore looks up the caller of a request. It is satisfied by
+// database.Repository.
+type UserStore interface {
+	GetUserByEmail(email string) (*models.User, error)
+}
+
+// AdminMiddleware rejects callers who are not admins with 403 Forbidden.
+// It must run after AuthMiddleware.
+func AdminMiddleware(users UserStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		email, err := authorizedEmail(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		user, err := users.GetUserByEmail(email)
+		if errors.Is(err, util.ErrUserNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if !user.IsAdmin {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(ErrAdminRequired))
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -53,3 +53,16 @@ func AuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// authorizedEmail returns the email of the caller set by AuthMiddleware
+func authorizedEmail(ctx *gin.Context) (string, error) {
+	payload, ok := ctx.Get(AuthorizationPayloadKey)
+	if !ok {
+		return "", errors.New("authorization payload not found")
+	}
+	payloadData, ok := payload.(*token.Payload)
+	if !ok {
+		return "", errors.New("authorization payload invalid")
+	}
+	return payloadData.Email, nil
+}
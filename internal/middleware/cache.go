@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -14,16 +15,21 @@ import (
 func CacheMiddleware(cacher cache.Cacher) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		walletID := ctx.Param("wallet_id")
-		balance, err := cacher.Get(ctx, walletID)
+		cached, err := cacher.Get(ctx, walletID)
 		if errors.Is(err, cache.ErrNil) {
 			ctx.Next()
 		} else if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusInternalServerError, err)
 			return
 		} else {
-			response := util.BuildResponseEntity(true, "", gin.H{
-				"balance": balance,
-			})
+			// entries written before the balances were cached as JSON hold a
+			// bare balance, treat them as a miss so they get refreshed
+			var balances map[string]string
+			if err := json.Unmarshal([]byte(cached), &balances); err != nil {
+				ctx.Next()
+				return
+			}
+			response := util.BuildResponseEntity(true, "", balances)
 			ctx.AbortWithStatusJSON(http.StatusOK, response)
 			return
 		}
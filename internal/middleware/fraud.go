@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Oloruntobi1/qgdc/internal/fraud"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrorCodeFraudBlocked is sent along with the error of an operation the
+// fraud rules blocked
+const ErrorCodeFraudBlocked = "FRAUD_BLOCKED"
+
+// maxFindingsLength is the size of the column the findings are saved in
+const maxFindingsLength = 1024
+
+var (
+	ErrOperationBlocked = errors.New("operation blocked by the fraud rules")
+)
+
+// FraudScreener decides what becomes of a wallet operation. It is
+// satisfied by *fraud.Engine.
+type FraudScreener interface {
+	Screen(ctx context.Context, op *fraud.Operation) (*fraud.Assessment, error)
+}
+
+// approvedFraudReviewKey marks the context of a request an admin approved
+// after the fraud rules flagged it
+type approvedFraudReviewKey struct{}
+
+// WithApprovedFraudReview returns a copy of ctx for sending the request of
+// an approved review through again, the request is not screened a second
+// time
+func WithApprovedFraudReview(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvedFraudReviewKey{}, true)
+}
+
+// FraudReviewStore queues the operations the fraud rules flag. It is
+// satisfied by database.Repository.
+type FraudReviewStore interface {
+	CreateFraudReview(review *models.FraudReview) error
+}
+
+// FraudTarget is what a request is about to do, move Amount in or out of
+// Wallet. Email is the player the rules look up, it defaults to the logged
+// in user.
+type FraudTarget struct {
+	Wallet    *models.Wallet
+	Amount    models.Money
+	Reference string
+	Category  string
+	Email     string
+}
+
+// FraudLocator reads the target of a request from its uri and body. It
+// returns nil for a request the handler is going to reject, such as one
+// for a wallet that does not exist, and leaves it to the handler.
+type FraudLocator func(ctx *gin.Context, body []byte) (*FraudTarget, error)
+
+// FraudScreen is how FraudMiddleware screens the requests of a route
+type FraudScreen struct {
+	// Operation is the journal entry the requests write
+	Operation models.TransactionType
+	Locate    FraudLocator
+	// Immediate routes answer callers that cannot wait for an admin, such
+	// as game servers. Their flagged requests are refused like blocked
+	// ones instead of being held for review.
+	Immediate bool
+	// Refuse answers a request the middleware turns away, it defaults to
+	// the error format of the wallet api
+	Refuse func(ctx *gin.Context, status int, err error)
+}
+
+// FraudMiddleware screens the operation of screen before the handler
+// makes it. A blocked operation is refused with 403 Forbidden and a
+// flagged one is queued for review and answered with 202 Accepted, the
+// handler only runs for operations that are allowed. The review keeps the
+// request so that approving it can send it through again. A request the
+// locator of screen cannot read is left to the handler to reject. It must
+// run after AuthMiddleware or ProviderSignatureMiddleware.
+func FraudMiddleware(screener FraudScreener, store FraudReviewStore, screen FraudScreen) gin.HandlerFunc {
+	refuse := screen.Refuse
+	if refuse == nil {
+		refuse = refuseFraudScreened
+	}
+	return func(ctx *gin.Context) {
+		if ctx.Request.Context().Value(approvedFraudReviewKey{}) != nil {
+			ctx.Next()
+			return
+		}
+		var body []byte
+		// some operations, such as executing a quote, take no body
+		if ctx.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(ctx.Request.Body)
+			if err != nil {
+				refuse(ctx, http.StatusBadRequest, err)
+				return
+			}
+			ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		target, err := screen.Locate(ctx, body)
+		if err != nil {
+			refuse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		if target == nil {
+			ctx.Next()
+			return
+		}
+		email := target.Email
+		if email == "" {
+			email, err = authorizedEmail(ctx)
+			if err != nil {
+				refuse(ctx, http.StatusUnauthorized, err)
+				return
+			}
+		}
+		// a provider calls from its game server, which says nothing of
+		// where the player is
+		ip := ctx.ClientIP()
+		if ctx.GetString(ProviderPayloadKey) != "" {
+			ip = ""
+		}
+
+		now := time.Now()
+		op := &fraud.Operation{
+			Type:     screen.Operation,
+			WalletID: target.Wallet.ID,
+			UserID:   target.Wallet.UserID,
+			Currency: target.Wallet.CurrencyCode(),
+			Amount:   target.Amount,
+			Email:    email,
+			IP:       ip,
+			At:       now,
+		}
+		assessment, err := screener.Screen(ctx, op)
+		if err != nil {
+			refuse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		switch {
+		case assessment.Decision == fraud.DecisionBlock,
+			assessment.Decision == fraud.DecisionFlag && screen.Immediate:
+			refuse(ctx, http.StatusForbidden, ErrOperationBlocked)
+		case assessment.Decision == fraud.DecisionFlag:
+			findings := truncateFindings(assessment.Reasons())
+			review := &models.FraudReview{
+				UUID:      uuid.New(),
+				WalletID:  op.WalletID,
+				UserID:    op.UserID,
+				Operation: op.Type,
+				Method:    ctx.Request.Method,
+				Path:      ctx.Request.URL.RequestURI(),
+				Body:      string(body),
+				Currency:  op.Currency,
+				Amount:    op.Amount,
+				Reference: target.Reference,
+				Category:  target.Category,
+				IP:        op.IP,
+				Findings:  findings,
+				Actor:     email,
+				Status:    models.FraudReviewStatusPending,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := store.CreateFraudReview(review); err != nil {
+				refuse(ctx, http.StatusInternalServerError, err)
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusAccepted, util.BuildResponseEntity(true, util.FraudReviewPending, gin.H{
+				"review_id": review.ID,
+				"status":    review.Status,
+			}))
+		default:
+			ctx.Next()
+		}
+	}
+}
+
+// truncateFindings cuts findings down to fit their column, without splitting
+// a character in two
+func truncateFindings(findings string) string {
+	if len(findings) <= maxFindingsLength {
+		return findings
+	}
+	n := maxFindingsLength
+	for n > 0 && !utf8.RuneStart(findings[n]) {
+		n--
+	}
+	return findings[:n]
+}
+
+func refuseFraudScreened(ctx *gin.Context, status int, err error) {
+	response := errorResponse(err)
+	if errors.Is(err, ErrOperationBlocked) {
+		response["code"] = ErrorCodeFraudBlocked
+	}
+	ctx.AbortWithStatusJSON(status, response)
+}
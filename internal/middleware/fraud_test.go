@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/fraud"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/internal/token"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// locateDebit reads a debit of the wallet in the uri the way the wallet
+// api sends it
+func locateDebit(repo *database.InMemory) FraudLocator {
+	return func(ctx *gin.Context, body []byte) (*FraudTarget, error) {
+		var req struct {
+			Amount    models.Money `json:"amount"`
+			Reference string       `json:"reference"`
+		}
+		walletID, err := strconv.ParseInt(ctx.Param("wallet_id"), 10, 64)
+		if err != nil || json.Unmarshal(body, &req) != nil {
+			return nil, nil
+		}
+		wallet, err := repo.GetWallet(walletID)
+		if err != nil {
+			return nil, err
+		}
+		return &FraudTarget{Wallet: wallet, Amount: req.Amount, Reference: req.Reference}, nil
+	}
+}
+
+// newScreenedRouter serves a debit handler that counts how often it actually
+// ran, every debit over 100 gets decision
+func newScreenedRouter(t *testing.T, decision fraud.Decision, calls *int) (*gin.Engine, *database.InMemory) {
+	return newScreenedRouterWith(t, decision, false, calls)
+}
+
+// newScreenedRouterWith is newScreenedRouter for a route that cannot hold
+// operations for review when immediate is set
+func newScreenedRouterWith(t *testing.T, decision fraud.Decision, immediate bool, calls *int) (*gin.Engine, *database.InMemory) {
+	repo := database.NewInMemory()
+	_, err := repo.CreateWallet(&models.Wallet{
+		ID:        1,
+		UUID:      uuid.New(),
+		UserID:    1,
+		Balance:   models.NewMoneyFromInt(1000),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	engine := fraud.NewEngine(fraud.NewRule("large", func(ctx context.Context, op *fraud.Operation) (fraud.Verdict, error) {
+		if op.Amount.GreaterThan(models.NewMoneyFromInt(100)) {
+			return fraud.Verdict{Decision: decision, Reason: "large debit"}, nil
+		}
+		return fraud.Allow, nil
+	}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/wallets/:wallet_id/debit",
+		func(ctx *gin.Context) {
+			ctx.Set(AuthorizationPayloadKey, &token.Payload{Email: "player@email.com"})
+		},
+		FraudMiddleware(engine, repo, FraudScreen{
+			Operation: models.TransactionTypeDebit,
+			Locate:    locateDebit(repo),
+			Immediate: immediate,
+		}),
+		func(ctx *gin.Context) {
+			*calls++
+			ctx.JSON(http.StatusOK, gin.H{"calls": *calls})
+		},
+	)
+	return router, repo
+}
+
+func sendScreened(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/wallets/1/debit", strings.NewReader(body))
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestFraudMiddleware(t *testing.T) {
+	t.Run("should let allowed operations through to the handler", func(t *testing.T) {
+		var calls int
+		router, _ := newScreenedRouter(t, fraud.DecisionBlock, &calls)
+
+		recorder := sendScreened(router, `{"amount":50}`)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("should refuse blocked operations", func(t *testing.T) {
+		var calls int
+		router, repo := newScreenedRouter(t, fraud.DecisionBlock, &calls)
+
+		recorder := sendScreened(router, `{"amount":500}`)
+
+		require.Equal(t, http.StatusForbidden, recorder.Code)
+		require.Contains(t, recorder.Body.String(), ErrorCodeFraudBlocked)
+		require.Zero(t, calls)
+		reviews, err := repo.GetFraudReviews("")
+		require.NoError(t, err)
+		require.Empty(t, reviews)
+	})
+
+	t.Run("should queue flagged operations for review", func(t *testing.T) {
+		var calls int
+		router, repo := newScreenedRouter(t, fraud.DecisionFlag, &calls)
+
+		recorder := sendScreened(router, `{"amount":500,"reference":"order-1"}`)
+
+		require.Equal(t, http.StatusAccepted, recorder.Code)
+		require.Zero(t, calls)
+		reviews, err := repo.GetFraudReviews(models.FraudReviewStatusPending)
+		require.NoError(t, err)
+		require.Len(t, reviews, 1)
+		require.Equal(t, models.TransactionTypeDebit, reviews[0].Operation)
+		require.Equal(t, "order-1", reviews[0].Reference)
+		require.Equal(t, "player@email.com", reviews[0].Actor)
+		require.Equal(t, "large: large debit", reviews[0].Findings)
+		// the request is kept to be sent through again once approved
+		require.Equal(t, http.MethodPost, reviews[0].Method)
+		require.Equal(t, "/wallets/1/debit", reviews[0].Path)
+		require.Equal(t, `{"amount":500,"reference":"order-1"}`, reviews[0].Body)
+	})
+
+	t.Run("should refuse flagged operations of callers that cannot wait", func(t *testing.T) {
+		var calls int
+		router, repo := newScreenedRouterWith(t, fraud.DecisionFlag, true, &calls)
+
+		recorder := sendScreened(router, `{"amount":500}`)
+
+		require.Equal(t, http.StatusForbidden, recorder.Code)
+		require.Contains(t, recorder.Body.String(), ErrorCodeFraudBlocked)
+		require.Zero(t, calls)
+		reviews, err := repo.GetFraudReviews("")
+		require.NoError(t, err)
+		require.Empty(t, reviews)
+	})
+
+	t.Run("should not screen the request of an approved review again", func(t *testing.T) {
+		var calls int
+		router, repo := newScreenedRouter(t, fraud.DecisionFlag, &calls)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/wallets/1/debit", strings.NewReader(`{"amount":500}`))
+		request = request.WithContext(WithApprovedFraudReview(request.Context()))
+		router.ServeHTTP(recorder, request)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, 1, calls)
+		reviews, err := repo.GetFraudReviews("")
+		require.NoError(t, err)
+		require.Empty(t, reviews)
+	})
+
+	t.Run("should leave requests it cannot read to the handler", func(t *testing.T) {
+		var calls int
+		router, _ := newScreenedRouter(t, fraud.DecisionBlock, &calls)
+
+		recorder := sendScreened(router, `{"amount":"lots"}`)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestTruncateFindings(t *testing.T) {
+	short := "amount_anomaly: unusual amount"
+	require.Equal(t, short, truncateFindings(short))
+
+	// a character straddling the limit is dropped whole
+	long := strings.Repeat("a", maxFindingsLength-1) + "é" + "tail"
+	truncated := truncateFindings(long)
+	require.True(t, utf8.ValidString(truncated))
+	require.Equal(t, strings.Repeat("a", maxFindingsLength-1), truncated)
+
+	exact := strings.Repeat("é", maxFindingsLength)
+	truncated = truncateFindings(exact)
+	require.True(t, utf8.ValidString(truncated))
+	require.Len(t, truncated, maxFindingsLength)
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 191
+	idempotencyReplayMimeType = "application/json; charset=utf-8"
+)
+
+var (
+	ErrIdempotencyKeyInvalid    = errors.New("idempotency key must be between 1 and 191 characters")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyStore persists the outcome of requests sent with an
+// Idempotency-Key header. It is satisfied by database.Repository.
+type IdempotencyStore interface {
+	GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error)
+	CreateIdempotencyKey(key *models.IdempotencyKey) error
+	UpdateIdempotencyKey(key *models.IdempotencyKey) error
+	DeleteIdempotencyKey(id int64) error
+}
+
+// IdempotencyMiddleware makes the routes it wraps safe to retry. The first
+// request carrying an Idempotency-Key has its response stored for the
+// retention window, identical retries get that response replayed and a
+// retry with a different body is rejected with 409 Conflict.
+// It must run after AuthMiddleware since keys are scoped to the caller.
+func IdempotencyMiddleware(store IdempotencyStore, retention time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(ErrIdempotencyKeyInvalid))
+			return
+		}
+		scope, err := idempotencyScope(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		body, err := ioutil.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(ctx.Request.Method, ctx.Request.URL.Path, body)
+
+		now := time.Now()
+		record := &models.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(retention),
+		}
+		// claim the key, the unique index makes sure only one of several
+		// concurrent requests with the same key gets through
+		err = store.CreateIdempotencyKey(record)
+		if errors.Is(err, util.ErrIdempotencyKeyExists) {
+			replayed := replayIdempotentResponse(ctx, store, record)
+			if replayed {
+				return
+			}
+			err = store.CreateIdempotencyKey(record)
+		}
+		if errors.Is(err, util.ErrIdempotencyKeyExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, errorResponse(ErrIdempotencyKeyInProgress))
+			return
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		writer := &bodyRecorder{ResponseWriter: ctx.Writer, body: &bytes.Buffer{}}
+		ctx.Writer = writer
+		ctx.Next()
+
+		// server errors are not stored so the client can try again
+		if ctx.Writer.Status() >= http.StatusInternalServerError {
+			_ = store.DeleteIdempotencyKey(record.ID)
+			return
+		}
+		record.Completed = true
+		record.StatusCode = ctx.Writer.Status()
+		record.Response = writer.body.String()
+		_ = store.UpdateIdempotencyKey(record)
+	}
+}
+
+// replayIdempotentResponse answers the request from the stored record for
+// its key. It returns false when the stored record has expired and was
+// removed, in which case the request should be processed as new.
+func replayIdempotentResponse(ctx *gin.Context, store IdempotencyStore, record *models.IdempotencyKey) bool {
+	existing, err := store.GetIdempotencyKey(record.Scope, record.Key)
+	if errors.Is(err, util.ErrIdempotencyKeyNotFound) {
+		return false
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return true
+	}
+	if existing.Expired(time.Now()) {
+		if err := store.DeleteIdempotencyKey(existing.ID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return true
+		}
+		return false
+	}
+	if existing.Fingerprint != record.Fingerprint {
+		ctx.AbortWithStatusJSON(http.StatusConflict, errorResponse(ErrIdempotencyKeyReused))
+		return true
+	}
+	if !existing.Completed {
+		ctx.AbortWithStatusJSON(http.StatusConflict, errorResponse(ErrIdempotencyKeyInProgress))
+		return true
+	}
+	ctx.Header(IdempotentReplayedHeader, "true")
+	ctx.Data(existing.StatusCode, idempotencyReplayMimeType, []byte(existing.Response))
+	ctx.Abort()
+	return true
+}
+
+// keys are scoped to the authenticated user so two callers cannot collide
+func idempotencyScope(ctx *gin.Context) (string, error) {
+	return authorizedEmail(ctx)
+}
+
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// bodyRecorder keeps a copy of everything written to the response
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func errorResponse(err error) gin.H {
+	return gin.H{
+		"success": false,
+		"message": "",
+		"error":   err.Error(),
+	}
+}
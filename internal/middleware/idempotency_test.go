@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/token"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// newIdempotentRouter serves a handler that counts how often it actually ran
+func newIdempotentRouter(store IdempotencyStore, retention time.Duration, email string, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/wallets/:wallet_id/credit",
+		func(ctx *gin.Context) {
+			ctx.Set(AuthorizationPayloadKey, &token.Payload{Email: email})
+		},
+		IdempotencyMiddleware(store, retention),
+		func(ctx *gin.Context) {
+			*calls++
+			if ctx.Query("fail") != "" {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"calls": *calls})
+				return
+			}
+			ctx.JSON(http.StatusOK, gin.H{"calls": *calls})
+		},
+	)
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, url, key, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if key != "" {
+		request.Header.Set(IdempotencyKeyHeader, key)
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	const url = "/wallets/1/credit"
+
+	t.Run("should replay the stored response for identical retries", func(t *testing.T) {
+		var calls int
+		router := newIdempotentRouter(database.NewInMemory(), time.Hour, util.RandomEmail(), &calls)
+
+		first := sendIdempotent(router, url, "key-1", `{"amount":10}`)
+		second := sendIdempotent(router, url, "key-1", `{"amount":10}`)
+
+		require.Equal(t, 1, calls)
+		require.Equal(t, http.StatusOK, second.Code)
+		require.Equal(t, first.Body.String(), second.Body.String())
+		require.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("should reject a reused key with a different body", func(t *testing.T) {
+		var calls int
+		router := newIdempotentRouter(database.NewInMemory(), time.Hour, util.RandomEmail(), &calls)
+
+		sendIdempotent(router, url, "key-1", `{"amount":10}`)
+		second := sendIdempotent(router, url, "key-1", `{"amount":20}`)
+
+		require.Equal(t, 1, calls)
+		require.Equal(t, http.StatusConflict, second.Code)
+		require.Contains(t, second.Body.String(), ErrIdempotencyKeyReused.Error())
+	})
+
+	t.Run("should process every request without a key", func(t *testing.T) {
+		var calls int
+		router := newIdempotentRouter(database.NewInMemory(), time.Hour, util.RandomEmail(), &calls)
+
+		sendIdempotent(router, url, "", `{"amount":10}`)
+		sendIdempotent(router, url, "", `{"amount":10}`)
+
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("should process the request again once the key expired", func(t *testing.T) {
+		var calls int
+		router := newIdempotentRouter(database.NewInMemory(), time.Nanosecond, util.RandomEmail(), &calls)
+
+		sendIdempotent(router, url, "key-1", `{"amount":10}`)
+		second := sendIdempotent(router, url, "key-1", `{"amount":20}`)
+
+		require.Equal(t, 2, calls)
+		require.Equal(t, http.StatusOK, second.Code)
+	})
+
+	t.Run("should not store server errors", func(t *testing.T) {
+		var calls int
+		router := newIdempotentRouter(database.NewInMemory(), time.Hour, util.RandomEmail(), &calls)
+
+		first := sendIdempotent(router, url+"?fail=1", "key-1", `{"amount":10}`)
+		second := sendIdempotent(router, url+"?fail=1", "key-1", `{"amount":10}`)
+
+		require.Equal(t, http.StatusInternalServerError, first.Code)
+		require.Equal(t, 2, calls)
+		require.Empty(t, second.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("should scope keys to the authenticated user", func(t *testing.T) {
+		var calls int
+		store := database.NewInMemory()
+		alice := newIdempotentRouter(store, time.Hour, util.RandomEmail(), &calls)
+		bob := newIdempotentRouter(store, time.Hour, util.RandomEmail(), &calls)
+
+		sendIdempotent(alice, url, "key-1", `{"amount":10}`)
+		second := sendIdempotent(bob, url, "key-1", `{"amount":20}`)
+
+		require.Equal(t, 2, calls)
+		require.Equal(t, http.StatusOK, second.Code)
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/aggregator"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ProviderPayloadKey = "provider_payload"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown provider")
+	ErrInvalidTimestamp = errors.New("timestamp is missing or outside of the allowed window")
+	ErrInvalidSignature = errors.New("signature does not match the request")
+)
+
+// ProviderSignatureMiddleware only lets through callbacks signed with the
+// secret of the provider named in their header, within maxAge of their
+// timestamp. The provider id is made available to the handlers under
+// ProviderPayloadKey.
+func ProviderSignatureMiddleware(secrets aggregator.Secrets, maxAge time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		provider := ctx.GetHeader(aggregator.ProviderHeader)
+		secret, ok := secrets[provider]
+		if !ok {
+			abortUnsigned(ctx, ErrUnknownProvider)
+			return
+		}
+		timestamp := ctx.GetHeader(aggregator.TimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			abortUnsigned(ctx, ErrInvalidTimestamp)
+			return
+		}
+		age := time.Since(time.Unix(seconds, 0))
+		if age > maxAge || age < -maxAge {
+			abortUnsigned(ctx, ErrInvalidTimestamp)
+			return
+		}
+		body, err := ioutil.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+			return
+		}
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if !aggregator.Verify(secret, timestamp, body, ctx.GetHeader(aggregator.SignatureHeader)) {
+			abortUnsigned(ctx, ErrInvalidSignature)
+			return
+		}
+		ctx.Set(ProviderPayloadKey, provider)
+		ctx.Next()
+	}
+}
+
+func abortUnsigned(ctx *gin.Context, err error) {
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidSignature, err))
+}
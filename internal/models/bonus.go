@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidBonusDebitOrder = errors.New("invalid bonus debit order")
+)
+
+type BonusStatus string
+
+const (
+	BonusStatusActive BonusStatus = "active"
+	// the wagering requirement was met and what was left of the bonus
+	// became real money
+	BonusStatusCompleted BonusStatus = "completed"
+	BonusStatusExpired   BonusStatus = "expired"
+	BonusStatusForfeited BonusStatus = "forfeited"
+)
+
+// BonusDebitOrder decides whether a stake is paid out of a bonus before or
+// after the real money of the wallet
+type BonusDebitOrder string
+
+const (
+	BonusDebitOrderBonusFirst BonusDebitOrder = "bonus_first"
+	BonusDebitOrderRealFirst  BonusDebitOrder = "real_first"
+)
+
+// BonusGrant is promotional money given to a wallet. It can only be staked
+// until WageringRequirement has been wagered, after which Remaining becomes
+// real money. Whatever is left when the grant expires or is forfeited is
+// taken out of the wallet.
+type BonusGrant struct {
+	ID       int64
+	UUID     uuid.UUID
+	WalletID int64 `gorm:"index"`
+	Amount   Money
+	// Remaining is the part of Amount that was not staked yet
+	Remaining           Money
+	WageringRequirement Money
+	// Wagered is the total staked since the grant, from any funds
+	Wagered    Money
+	DebitOrder BonusDebitOrder `gorm:"size:16"`
+	Status     BonusStatus     `gorm:"size:16;index"`
+	Reference  string
+	Actor      string
+	ExpiresAt  time.Time `gorm:"index"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// IsActive reports whether the grant still holds bonus funds at now
+func (g *BonusGrant) IsActive(now time.Time) bool {
+	return g.Status == BonusStatusActive && now.Before(g.ExpiresAt)
+}
+
+// ParseBonusDebitOrder reads a debit order, the empty name selects
+// BonusDebitOrderRealFirst
+func ParseBonusDebitOrder(name string) (BonusDebitOrder, error) {
+	switch order := BonusDebitOrder(strings.ToLower(name)); order {
+	case "":
+		return BonusDebitOrderRealFirst, nil
+	case BonusDebitOrderBonusFirst, BonusDebitOrderRealFirst:
+		return order, nil
+	default:
+		return "", ErrInvalidBonusDebitOrder
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+)
+
+// DefaultCurrency is given to wallets created without a currency, including
+// every wallet that existed before wallets had one
+const DefaultCurrency = "USD"
+
+// Currency is an ISO 4217 currency together with the number of decimal
+// places amounts in it may have
+type Currency struct {
+	Code      string
+	Precision int32
+}
+
+var currencies = map[string]Currency{
+	"USD": {Code: "USD", Precision: 2},
+	"EUR": {Code: "EUR", Precision: 2},
+	"GBP": {Code: "GBP", Precision: 2},
+	"NGN": {Code: "NGN", Precision: 2},
+	"GHS": {Code: "GHS", Precision: 2},
+	"KES": {Code: "KES", Precision: 2},
+	"ZAR": {Code: "ZAR", Precision: 2},
+	"BRL": {Code: "BRL", Precision: 2},
+	"INR": {Code: "INR", Precision: 2},
+	"JPY": {Code: "JPY", Precision: 0},
+	"KRW": {Code: "KRW", Precision: 0},
+	"UGX": {Code: "UGX", Precision: 0},
+	"KWD": {Code: "KWD", Precision: 3},
+	"BHD": {Code: "BHD", Precision: 3},
+	"TND": {Code: "TND", Precision: 3},
+}
+
+// LookupCurrency returns the supported currency with code, the code is
+// matched case insensitively
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	return currency, ok
+}
+
+// Fits reports whether amount has no more decimal places than the currency
+// allows
+func (c Currency) Fits(amount Money) bool {
+	return amount.Decimal.Equal(amount.Decimal.Truncate(c.Precision))
+}
+
+// Round rounds a computed amount to the precision of the currency
+func (c Currency) Round(amount Money, policy RoundingPolicy) Money {
+	return policy.Round(amount, c.Precision)
+}
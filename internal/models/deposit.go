@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DepositStatus string
+
+const (
+	// the player was sent to the payment provider to pay
+	DepositStatusPending DepositStatus = "pending"
+	// the provider confirmed the payment and the wallet was credited
+	DepositStatusSucceeded DepositStatus = "succeeded"
+	// the payment was declined or abandoned, nothing was credited
+	DepositStatusFailed DepositStatus = "failed"
+	// the player paid but the credit would break a deposit limit, nothing
+	// was credited and the payment has to be refunded by hand
+	DepositStatusRefundDue DepositStatus = "refund_due"
+	// the payment of a deposit refused by a deposit limit was refunded
+	DepositStatusRefunded DepositStatus = "refunded"
+)
+
+// Deposit is money a player pays into a wallet through a payment provider.
+// It is created pending and the wallet is only credited once the signed
+// webhook of the provider confirms the payment. Reference is sent to the
+// provider, which sends it back in its webhooks.
+type Deposit struct {
+	ID        int64
+	UUID      uuid.UUID
+	Reference string `gorm:"size:64;uniqueIndex"`
+	WalletID  int64  `gorm:"index"`
+	UserID    int64
+	Provider  string `gorm:"size:32"`
+	// ProviderReference is the id of the payment at the provider, it is
+	// set once the provider accepted the deposit
+	ProviderReference string `gorm:"size:128"`
+	Currency          string `gorm:"size:3"`
+	Amount            Money
+	Status            DepositStatus `gorm:"size:16;index"`
+	// FailureReason explains a failed deposit, or why the payment of a
+	// deposit has to be refunded
+	FailureReason string
+	// TransactionID is the credit of a succeeded deposit
+	TransactionID int64
+	Actor         string
+	// RefundedBy is the admin who refunded the payment
+	RefundedBy  string
+	RefundedAt  *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
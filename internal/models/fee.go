@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidFeeSchedule = errors.New("fee schedule must set the fee of its type, with non negative amounts, percentages below 100 and a minimum fee not above the maximum")
+	ErrInvalidFeeTiers    = errors.New("fee tiers must be from:percent pairs with distinct amounts, one of them 0, and percentages below 100")
+)
+
+type FeeOperation string
+
+const (
+	FeeOperationWithdrawal FeeOperation = "withdrawal"
+	FeeOperationTransfer   FeeOperation = "transfer"
+	FeeOperationConversion FeeOperation = "conversion"
+)
+
+type FeeType string
+
+const (
+	// the same fee whatever the amount
+	FeeTypeFlat FeeType = "flat"
+	// a percentage of the amount
+	FeeTypePercentage FeeType = "percentage"
+	// a percentage of the amount that depends on the size of the amount
+	FeeTypeTiered FeeType = "tiered"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// FeeSchedule sets the fee charged on an operation in a currency. The fee
+// is taken out of the amount of the operation, the rest is the net amount
+// that reaches its destination, and it is credited to RevenueWalletID.
+type FeeSchedule struct {
+	ID        int64
+	Operation FeeOperation `gorm:"size:16;uniqueIndex:idx_fee_schedule_operation_currency"`
+	Currency  string       `gorm:"size:3;uniqueIndex:idx_fee_schedule_operation_currency"`
+	Type      FeeType      `gorm:"size:16"`
+	// Flat is the fee of a flat schedule
+	Flat Money
+	// Percent is the fee of a percentage schedule in percent of the amount
+	Percent decimal.Decimal `gorm:"type:decimal(9,4)"`
+	// Tiers is the fee of a tiered schedule as comma separated from:percent
+	// pairs, an amount pays the percent of the highest tier it reaches
+	Tiers string
+	// MinFee and MaxFee bound the fee, a zero MaxFee leaves it unbounded
+	MinFee          Money
+	MaxFee          Money
+	RevenueWalletID int64
+	Actor           string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// FeeTier is the percent charged on amounts of From and more
+type FeeTier struct {
+	From    Money
+	Percent decimal.Decimal
+}
+
+// Validate checks that the schedule sets the fee of its type
+func (s *FeeSchedule) Validate() error {
+	switch s.Type {
+	case FeeTypeFlat:
+		if !s.Flat.IsPositive() {
+			return ErrInvalidFeeSchedule
+		}
+	case FeeTypePercentage:
+		if !validFeePercent(s.Percent) || s.Percent.IsZero() {
+			return ErrInvalidFeeSchedule
+		}
+	case FeeTypeTiered:
+		if _, err := ParseFeeTiers(s.Tiers); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidFeeSchedule
+	}
+	if s.MinFee.IsNegative() || s.MaxFee.IsNegative() ||
+		(s.MaxFee.IsPositive() && s.MinFee.GreaterThan(s.MaxFee)) {
+		return ErrInvalidFeeSchedule
+	}
+	return nil
+}
+
+// Fee works out the fee on amount bounded by MinFee and MaxFee, it is not
+// rounded to the currency yet
+func (s *FeeSchedule) Fee(amount Money) (Money, error) {
+	var fee Money
+	switch s.Type {
+	case FeeTypeFlat:
+		fee = s.Flat
+	case FeeTypePercentage:
+		fee = amount.Mul(s.Percent.Div(hundred))
+	case FeeTypeTiered:
+		tiers, err := ParseFeeTiers(s.Tiers)
+		if err != nil {
+			return ZeroMoney, err
+		}
+		fee = amount.Mul(tiers.For(amount).Percent.Div(hundred))
+	default:
+		return ZeroMoney, ErrInvalidFeeSchedule
+	}
+	if fee.LessThan(s.MinFee) {
+		fee = s.MinFee
+	}
+	if s.MaxFee.IsPositive() && fee.GreaterThan(s.MaxFee) {
+		fee = s.MaxFee
+	}
+	return fee, nil
+}
+
+type FeeTiers []FeeTier
+
+// ParseFeeTiers reads tiers from comma separated from:percent pairs
+func ParseFeeTiers(spec string) (FeeTiers, error) {
+	var tiers FeeTiers
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			return nil, ErrInvalidFeeTiers
+		}
+		from, err := ParseMoney(parts[0])
+		if err != nil || from.IsNegative() {
+			return nil, ErrInvalidFeeTiers
+		}
+		percent, err := decimal.NewFromString(parts[1])
+		if err != nil || !validFeePercent(percent) {
+			return nil, ErrInvalidFeeTiers
+		}
+		tiers = append(tiers, FeeTier{From: from, Percent: percent})
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return tiers[i].From.LessThan(tiers[j].From)
+	})
+	if !tiers[0].From.IsZero() {
+		return nil, ErrInvalidFeeTiers
+	}
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].From.Equal(tiers[i-1].From) {
+			return nil, ErrInvalidFeeTiers
+		}
+	}
+	return tiers, nil
+}
+
+// For returns the highest tier amount reaches
+func (tiers FeeTiers) For(amount Money) FeeTier {
+	tier := tiers[0]
+	for _, t := range tiers[1:] {
+		if amount.LessThan(t.From) {
+			break
+		}
+		tier = t
+	}
+	return tier
+}
+
+func validFeePercent(percent decimal.Decimal) bool {
+	return !percent.IsNegative() && percent.LessThan(hundred)
+}
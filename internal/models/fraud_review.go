@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FraudReviewStatus string
+
+const (
+	FraudReviewStatusPending FraudReviewStatus = "pending"
+	// an admin approved the review and its operation is being applied
+	FraudReviewStatusApproving FraudReviewStatus = "approving"
+	FraudReviewStatusApproved  FraudReviewStatus = "approved"
+	FraudReviewStatusDenied    FraudReviewStatus = "denied"
+)
+
+// fraudReviewTransitions lists the statuses a review can move to from each
+// status, an approving review goes back to pending when its operation is
+// refused. Approved and denied are final.
+var fraudReviewTransitions = map[FraudReviewStatus][]FraudReviewStatus{
+	FraudReviewStatusPending:   {FraudReviewStatusApproving, FraudReviewStatusDenied},
+	FraudReviewStatusApproving: {FraudReviewStatusApproved, FraudReviewStatusPending},
+}
+
+// CanMoveTo reports whether a review in status s can move to status
+func (s FraudReviewStatus) CanMoveTo(status FraudReviewStatus) bool {
+	for _, next := range fraudReviewTransitions[s] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether a review in status s was approved or denied
+func (s FraudReviewStatus) IsFinal() bool {
+	return s == FraudReviewStatusApproved || s == FraudReviewStatusDenied
+}
+
+// FraudReview is a wallet operation the fraud rules flagged. The operation
+// is held back until an admin reviews it, approving it sends the request
+// of the operation through again and denying it drops it.
+type FraudReview struct {
+	ID       int64
+	UUID     uuid.UUID
+	WalletID int64 `gorm:"index"`
+	UserID   int64
+	// Operation is the journal entry the operation writes once approved
+	Operation TransactionType `gorm:"size:32"`
+	// Method, Path and Body are the request of the operation as it was sent
+	Method    string `gorm:"size:8"`
+	Path      string
+	Body      string `gorm:"type:text"`
+	Currency  string `gorm:"size:3"`
+	Amount    Money
+	Reference string
+	Category  string `gorm:"size:32"`
+	IP        string `gorm:"size:45"`
+	// Findings are the reasons of the rules that flagged the operation
+	Findings string `gorm:"size:1024"`
+	// Actor requested the operation, Reviewer approved or denied it
+	Actor      string
+	Status     FraudReviewStatus `gorm:"size:16;index"`
+	Reviewer   string
+	Note       string
+	ReviewedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// LoginEvent records an attempt to log in, successful or not, and where it
+// came from
+type LoginEvent struct {
+	ID        int64
+	Email     string `gorm:"size:191;index"`
+	IP        string `gorm:"size:45"`
+	Succeeded bool   `gorm:"not null;default:false"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// FXQuote locks an exchange rate for converting Amount from one of a user's
+// wallets into another until ExpiresAt. A quote can be executed once, its
+// UUID is the correlation id of the two journal entries of the conversion.
+type FXQuote struct {
+	ID           int64
+	UUID         uuid.UUID
+	UserID       int64 `gorm:"index"`
+	FromWalletID int64
+	ToWalletID   int64
+	FromCurrency string          `gorm:"size:3"`
+	ToCurrency   string          `gorm:"size:3"`
+	Rate         decimal.Decimal `gorm:"type:decimal(36,18)"`
+	// Amount is debited from the source wallet, ConvertedAmount is Amount
+	// less Fee at Rate rounded to the precision of the target currency
+	Amount          Money
+	ConvertedAmount Money
+	// Fee is charged in the source currency and credited to FeeWalletID
+	Fee         Money `gorm:"not null;default:0"`
+	FeeWalletID int64
+	ExpiresAt   time.Time
+	ExecutedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+// IsExecutable reports whether the quote can still be executed at now
+func (q *FXQuote) IsExecutable(now time.Time) bool {
+	return q.ExecutedAt == nil && now.Before(q.ExpiresAt)
+}
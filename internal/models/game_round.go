@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GameRoundStatus string
+
+const (
+	// bets can still be placed on the round and rolled back
+	GameRoundStatusOpen GameRoundStatus = "open"
+	// a win was paid against the round, it takes no more bets
+	GameRoundStatusSettled GameRoundStatus = "settled"
+	// every bet of the round was rolled back
+	GameRoundStatusRolledBack GameRoundStatus = "rolled_back"
+)
+
+// GameRound groups the bets and wins a game provider sends for one round of
+// a game, e.g. a spin or a hand, so wins can be matched with the bets they
+// pay out.
+type GameRound struct {
+	ID       int64
+	UUID     uuid.UUID
+	WalletID int64 `gorm:"index"`
+	// Provider and RoundID identify the round on the provider side
+	Provider string          `gorm:"size:64;uniqueIndex:idx_game_round_provider_round"`
+	RoundID  string          `gorm:"size:128;uniqueIndex:idx_game_round_provider_round"`
+	Currency string          `gorm:"size:3"`
+	Status   GameRoundStatus `gorm:"size:16"`
+	// TotalBet does not include bets that were rolled back
+	TotalBet  Money
+	TotalWin  Money
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type GameRoundActionType string
+
+const (
+	GameRoundActionBet      GameRoundActionType = "bet"
+	GameRoundActionWin      GameRoundActionType = "win"
+	GameRoundActionRollback GameRoundActionType = "rollback"
+)
+
+// GameRoundAction records a single bet, win or rollback of a round. The
+// provider transaction id is unique per provider, a provider retrying an
+// action is answered with the outcome of the first attempt.
+type GameRoundAction struct {
+	ID                    int64
+	GameRoundID           int64               `gorm:"index"`
+	Provider              string              `gorm:"size:64;uniqueIndex:idx_game_round_action_provider_txn"`
+	ProviderTransactionID string              `gorm:"size:128;uniqueIndex:idx_game_round_action_provider_txn"`
+	Type                  GameRoundActionType `gorm:"size:16"`
+	Amount                Money
+	// BonusAmount is the part of the stake of a bet that was paid out of
+	// bonus funds, a rollback gives it back as bonus funds
+	BonusAmount Money `gorm:"not null;default:0"`
+	// RollbackOf is the provider transaction id of the bet a rollback cancels
+	RollbackOf string `gorm:"size:128"`
+	// RolledBack is set on bets that were cancelled by a rollback
+	RolledBack bool
+	// TransactionID is the journal entry of the action, it is zero for wins
+	// of nothing
+	TransactionID int64
+	CreatedAt     time.Time
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// LimitKind is what a responsible gaming limit caps
+type LimitKind string
+
+const (
+	// LimitKindDeposit caps the credits made to the wallet
+	LimitKindDeposit LimitKind = "deposit"
+	// LimitKindLoss caps the stakes minus the wins and refunded stakes
+	LimitKindLoss LimitKind = "loss"
+	// LimitKindWager caps the stakes, whatever their outcome
+	LimitKindWager LimitKind = "wager"
+)
+
+// LimitPeriod is the calendar period, in UTC, a limit applies to
+type LimitPeriod string
+
+const (
+	LimitPeriodDaily   LimitPeriod = "daily"
+	LimitPeriodWeekly  LimitPeriod = "weekly"
+	LimitPeriodMonthly LimitPeriod = "monthly"
+)
+
+// Start returns the start of the period that now falls in, weeks start on
+// Monday
+func (p LimitPeriod) Start(now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case LimitPeriodWeekly:
+		// days since Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case LimitPeriodMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// GamingLimit caps the deposits, losses or stakes of a wallet over a
+// period. A player may lower a limit at any time, raising or removing it
+// only takes effect once the cooling-off period has passed, until then the
+// change is pending.
+type GamingLimit struct {
+	ID       int64
+	WalletID int64       `gorm:"uniqueIndex:idx_gaming_limit_wallet_kind_period"`
+	Kind     LimitKind   `gorm:"size:16;uniqueIndex:idx_gaming_limit_wallet_kind_period"`
+	Period   LimitPeriod `gorm:"size:16;uniqueIndex:idx_gaming_limit_wallet_kind_period"`
+	// Amount is the limit in force until PendingFrom
+	Amount Money
+	// PendingAmount replaces Amount from PendingFrom, unless PendingRemoval
+	// is set in which case the limit is lifted then
+	PendingAmount  Money
+	PendingRemoval bool `gorm:"not null;default:false"`
+	PendingFrom    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Effective returns the amount of the limit at now, ok is false when a
+// removal of the limit took effect
+func (l *GamingLimit) Effective(now time.Time) (amount Money, ok bool) {
+	if l.PendingFrom == nil || now.Before(*l.PendingFrom) {
+		return l.Amount, true
+	}
+	if l.PendingRemoval {
+		return ZeroMoney, false
+	}
+	return l.PendingAmount, true
+}
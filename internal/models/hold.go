@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusReleased HoldStatus = "released"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// Hold reserves part of a wallet's balance, e.g. the stake of a bet whose
+// outcome is not known yet. While it is active the reserved amount is not
+// available to spend but is still part of the ledger balance.
+type Hold struct {
+	ID       int64
+	UUID     uuid.UUID
+	WalletID int64 `gorm:"index"`
+	Amount   Money
+	// CapturedAmount is how much of Amount was finally debited, the rest was
+	// given back to the wallet when the hold was captured
+	CapturedAmount Money
+	Status         HoldStatus `gorm:"size:16;index"`
+	Reference      string
+	Actor          string
+	ExpiresAt      time.Time `gorm:"index"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// IsActive reports whether the hold still reserves funds at now
+func (h *Hold) IsActive(now time.Time) bool {
+	return h.Status == HoldStatusActive && now.Before(h.ExpiresAt)
+}
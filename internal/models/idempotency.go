@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the outcome of a request sent with an
+// Idempotency-Key header so that retries of it can be answered without
+// being applied again.
+type IdempotencyKey struct {
+	ID    int64
+	Scope string `gorm:"size:191;uniqueIndex:idx_idempotency_scope_key"`
+	Key   string `gorm:"size:191;uniqueIndex:idx_idempotency_scope_key"`
+	// Fingerprint is a hash of the method, path and body of the first request
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	Response    string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Expired reports whether the key has outlived its retention window
+func (k *IdempotencyKey) Expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
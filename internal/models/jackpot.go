@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// JackpotPool is a progressive jackpot fed by the bets placed with a game
+// provider in the currency of the pool. A percentage of every such bet goes
+// into the pool wallet and the game server of the provider pays the pool to
+// a winner when the jackpot is hit, after which the pool starts again from
+// its seed, funded by the seed wallet.
+type JackpotPool struct {
+	ID       int64
+	UUID     uuid.UUID
+	Name     string
+	Provider string `gorm:"size:64;index"`
+	Currency string `gorm:"size:3"`
+	// ContributionPercent of each eligible bet goes into the pool
+	ContributionPercent decimal.Decimal `gorm:"type:decimal(9,6)"`
+	SeedAmount          Money
+	WalletID            int64
+	SeedWalletID        int64
+	// Amount is the exact value of the pool, contributions are not rounded
+	// so it may have more decimal places than the currency. The pool wallet
+	// holds Amount rounded down to the currency, the fraction left over is
+	// carried into the next pool when the jackpot is hit.
+	Amount    Money
+	Hits      int64
+	Actor     string
+	LastHitAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type JackpotEventType string
+
+const (
+	// the pool was seeded when it was created
+	JackpotEventSeed JackpotEventType = "seed"
+	// a bet contributed to the pool, or a rollback of the bet took its
+	// contribution back with a negative amount
+	JackpotEventContribution JackpotEventType = "contribution"
+	// the pool was paid to a winner and reset to its seed
+	JackpotEventHit JackpotEventType = "hit"
+)
+
+// JackpotEvent is a line of the history of a pool. Contributions and hits
+// are keyed on the provider transaction id of the bet or hit they come from,
+// a hit sent again is answered with the outcome of the first one.
+type JackpotEvent struct {
+	ID                    int64
+	PoolID                int64            `gorm:"uniqueIndex:idx_jackpot_event_pool_provider_txn"`
+	ProviderTransactionID string           `gorm:"size:128;uniqueIndex:idx_jackpot_event_pool_provider_txn"`
+	Type                  JackpotEventType `gorm:"size:16"`
+	// WalletID is the wallet of the player who bet or won, the seed wallet
+	// for seeds
+	WalletID int64
+	// RoundID is the provider round the bet or hit was made on
+	RoundID string `gorm:"size:128"`
+	// Amount is the exact contribution, the seed, or the payout of a hit
+	Amount Money
+	// PoolAmount is the exact value of the pool after the event
+	PoolAmount Money
+	// TransactionID is the journal entry of the pool wallet, it is zero for
+	// contributions too small to move the pool wallet
+	TransactionID int64
+	CreatedAt     time.Time
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidLoyaltyTiers = errors.New("loyalty tiers must be name:threshold pairs with distinct thresholds, one of them 0")
+	ErrInvalidLoyaltyRates = errors.New("loyalty redemption rates must be currency:rate pairs with positive rates")
+)
+
+const (
+	// LoyaltyDefaultCategory is the rule for wagers made without a game
+	// category or in a category that has no rule of its own
+	LoyaltyDefaultCategory = "default"
+	// LoyaltyPointsPrecision is the number of decimal places points have,
+	// points earned are rounded down to it
+	LoyaltyPointsPrecision = 2
+)
+
+// DefaultLoyaltyTiers are used when no tiers are configured
+const DefaultLoyaltyTiers = "bronze:0,silver:1000,gold:5000,platinum:20000"
+
+// LoyaltyRule sets how many points a player earns for each unit of real
+// money wagered in a game category and currency. Bonus funds earn nothing.
+type LoyaltyRule struct {
+	ID       int64
+	Category string `gorm:"size:32;uniqueIndex:idx_loyalty_rule_category_currency"`
+	Currency string `gorm:"size:3;uniqueIndex:idx_loyalty_rule_category_currency"`
+	// PointsPerUnit may be zero to keep a category from earning points
+	PointsPerUnit decimal.Decimal `gorm:"type:decimal(18,6)"`
+	Actor         string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// LoyaltyAccount holds the points of a player across all of their wallets.
+// Points is what can be redeemed, TierPoints is everything earned since the
+// points last expired and decides the tier, redeeming points does not lower
+// the tier. Both expire once the player has been inactive for too long.
+type LoyaltyAccount struct {
+	ID             int64
+	UserID         int64           `gorm:"uniqueIndex"`
+	Points         decimal.Decimal `gorm:"type:decimal(20,2)"`
+	TierPoints     decimal.Decimal `gorm:"type:decimal(20,2)"`
+	LastActivityAt time.Time       `gorm:"index"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type LoyaltyEntryType string
+
+const (
+	// points earned by a wager
+	LoyaltyEntryAccrual LoyaltyEntryType = "accrual"
+	// points turned into real money
+	LoyaltyEntryRedemption LoyaltyEntryType = "redemption"
+	// points removed after a period of inactivity
+	LoyaltyEntryExpiry LoyaltyEntryType = "expiry"
+)
+
+// LoyaltyEntry is an immutable line of the loyalty ledger of a player.
+// Points is signed, Balance is the points of the account after the entry.
+type LoyaltyEntry struct {
+	ID       int64
+	UserID   int64            `gorm:"index"`
+	Type     LoyaltyEntryType `gorm:"size:16"`
+	Points   decimal.Decimal  `gorm:"type:decimal(20,2)"`
+	Balance  decimal.Decimal  `gorm:"type:decimal(20,2)"`
+	Category string           `gorm:"size:32"`
+	// TransactionID is the wager an accrual was earned by or the journal
+	// entry of the credit a redemption paid, it is zero for expiries
+	TransactionID int64 `gorm:"index"`
+	CreatedAt     time.Time
+}
+
+// LoyaltyCursor is the last journal entry points were accrued for, the
+// accrual picks up the wagers after it
+type LoyaltyCursor struct {
+	ID            int64
+	TransactionID int64
+	UpdatedAt     time.Time
+}
+
+// LoyaltyTier is reached once the tier points of an account are at least
+// Threshold
+type LoyaltyTier struct {
+	Name      string
+	Threshold decimal.Decimal
+}
+
+// LoyaltyTiers are ordered by threshold, the first one starts at 0
+type LoyaltyTiers []LoyaltyTier
+
+// ParseLoyaltyTiers reads tiers from comma separated name:threshold pairs,
+// the empty spec selects DefaultLoyaltyTiers
+func ParseLoyaltyTiers(spec string) (LoyaltyTiers, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultLoyaltyTiers
+	}
+	var tiers LoyaltyTiers
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, ErrInvalidLoyaltyTiers
+		}
+		threshold, err := decimal.NewFromString(parts[1])
+		if err != nil || threshold.IsNegative() {
+			return nil, ErrInvalidLoyaltyTiers
+		}
+		tiers = append(tiers, LoyaltyTier{Name: parts[0], Threshold: threshold})
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return tiers[i].Threshold.LessThan(tiers[j].Threshold)
+	})
+	if !tiers[0].Threshold.IsZero() {
+		return nil, ErrInvalidLoyaltyTiers
+	}
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].Threshold.Equal(tiers[i-1].Threshold) {
+			return nil, ErrInvalidLoyaltyTiers
+		}
+	}
+	return tiers, nil
+}
+
+// Tier returns the tier reached with tierPoints and the tier after it, next
+// is nil at the top tier
+func (t LoyaltyTiers) Tier(tierPoints decimal.Decimal) (current LoyaltyTier, next *LoyaltyTier) {
+	for i, tier := range t {
+		if tierPoints.LessThan(tier.Threshold) {
+			return current, &t[i]
+		}
+		current = tier
+	}
+	return current, nil
+}
+
+// LoyaltyRates is the amount of each currency a point is worth when it is
+// redeemed
+type LoyaltyRates map[string]decimal.Decimal
+
+// ParseLoyaltyRates reads rates from comma separated currency:rate pairs.
+// Points cannot be redeemed into a currency without a rate.
+func ParseLoyaltyRates(spec string) (LoyaltyRates, error) {
+	rates := LoyaltyRates{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, ErrInvalidLoyaltyRates
+		}
+		currency, ok := LookupCurrency(parts[0])
+		if !ok {
+			return nil, ErrInvalidLoyaltyRates
+		}
+		rate, err := decimal.NewFromString(parts[1])
+		if err != nil || !rate.IsPositive() {
+			return nil, ErrInvalidLoyaltyRates
+		}
+		rates[currency.Code] = rate
+	}
+	return rates, nil
+}
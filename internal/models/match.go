@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type MatchStatus string
+
+const (
+	// the stakes of both players are held in escrow
+	MatchStatusStarted MatchStatus = "started"
+	// the winner was paid the pot less the rake
+	MatchStatusSettled MatchStatus = "settled"
+	// the match ended level and both stakes were refunded
+	MatchStatusDrawn MatchStatus = "drawn"
+	// the match was called off and both stakes were refunded
+	MatchStatusAborted MatchStatus = "aborted"
+)
+
+// Match is a head-to-head game between two players who each stake the same
+// amount. The stakes are held in the escrow wallet while the match is on.
+// When it is settled the winner is paid the pot less the rake, which goes to
+// the rake wallet, a draw or an abort refunds both stakes. Reference is the
+// id of the match on the game side, starting a match again with it changes
+// nothing. The UUID of the match is the correlation id of every journal
+// entry it makes.
+type Match struct {
+	ID        int64
+	UUID      uuid.UUID
+	Reference string `gorm:"size:128;uniqueIndex"`
+	Currency  string `gorm:"size:3"`
+	// Stake is paid by each player
+	Stake Money
+	// RakePercent of the pot goes to the rake wallet when the match is
+	// settled, draws and aborts are not raked
+	RakePercent       decimal.Decimal `gorm:"type:decimal(9,6)"`
+	PlayerOneWalletID int64           `gorm:"index"`
+	PlayerTwoWalletID int64           `gorm:"index"`
+	EscrowWalletID    int64
+	RakeWalletID      int64
+	Status            MatchStatus `gorm:"size:16;index"`
+	// Pot is both stakes
+	Pot Money
+	// WinnerWalletID is set once the match is settled
+	WinnerWalletID int64
+	Prize          Money
+	Rake           Money
+	Actor          string
+	// FinishedBy is the email of the user who settled, drew or aborted the
+	// match
+	FinishedBy string
+	FinishedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// PlayerWalletIDs returns the wallets of both players, player one first
+func (m *Match) PlayerWalletIDs() []int64 {
+	return []int64{m.PlayerOneWalletID, m.PlayerTwoWalletID}
+}
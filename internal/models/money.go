@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidRoundingPolicy = errors.New("invalid rounding policy")
+)
+
+// Money is an exact decimal amount. It is stored in fixed precision
+// DECIMAL(36,18) columns and is sent to and read from clients as a decimal
+// string, so amounts never pass through a float on their way to the
+// ledger. JSON numbers are still accepted and parsed from their text.
+//
+// The arithmetic methods shadow the ones of the embedded decimal so that
+// money only ever combines with money.
+type Money struct {
+	decimal.Decimal
+}
+
+// ZeroMoney is the zero amount
+var ZeroMoney = Money{}
+
+func NewMoney(d decimal.Decimal) Money {
+	return Money{Decimal: d}
+}
+
+func NewMoneyFromInt(value int64) Money {
+	return Money{Decimal: decimal.NewFromInt(value)}
+}
+
+// ParseMoney parses a decimal string such as "10.25"
+func ParseMoney(value string) (Money, error) {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Decimal: d}, nil
+}
+
+// RequireMoney parses value and panics if it is not a decimal, it is meant
+// for constants and tests
+func RequireMoney(value string) Money {
+	return Money{Decimal: decimal.RequireFromString(value)}
+}
+
+// GormDataType stores money in fixed precision columns
+func (Money) GormDataType() string {
+	return "decimal(36,18)"
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{Decimal: m.Decimal.Add(other.Decimal)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Decimal: m.Decimal.Sub(other.Decimal)}
+}
+
+// Mul multiplies the amount by a factor such as an exchange rate, the
+// result is exact and usually has to be rounded to a currency
+func (m Money) Mul(factor decimal.Decimal) Money {
+	return Money{Decimal: m.Decimal.Mul(factor)}
+}
+
+func (m Money) Neg() Money {
+	return Money{Decimal: m.Decimal.Neg()}
+}
+
+func (m Money) Abs() Money {
+	return Money{Decimal: m.Decimal.Abs()}
+}
+
+func (m Money) Cmp(other Money) int {
+	return m.Decimal.Cmp(other.Decimal)
+}
+
+func (m Money) Equal(other Money) bool {
+	return m.Decimal.Equal(other.Decimal)
+}
+
+func (m Money) GreaterThan(other Money) bool {
+	return m.Decimal.GreaterThan(other.Decimal)
+}
+
+func (m Money) LessThan(other Money) bool {
+	return m.Decimal.LessThan(other.Decimal)
+}
+
+// RoundingPolicy decides what happens to the digits of a computed amount,
+// e.g. a converted amount or a percentage fee, beyond the precision of its
+// currency
+type RoundingPolicy string
+
+const (
+	// RoundDown drops the extra digits, rounding towards zero
+	RoundDown RoundingPolicy = "down"
+	// RoundUp rounds away from zero
+	RoundUp RoundingPolicy = "up"
+	// RoundHalfUp rounds to the nearest unit and halves away from zero
+	RoundHalfUp RoundingPolicy = "half_up"
+	// RoundHalfEven rounds to the nearest unit and halves to the even one
+	RoundHalfEven RoundingPolicy = "half_even"
+)
+
+// ParseRoundingPolicy parses a policy name, the empty string is RoundDown
+func ParseRoundingPolicy(name string) (RoundingPolicy, error) {
+	switch policy := RoundingPolicy(strings.ToLower(name)); policy {
+	case "":
+		return RoundDown, nil
+	case RoundDown, RoundUp, RoundHalfUp, RoundHalfEven:
+		return policy, nil
+	default:
+		return "", ErrInvalidRoundingPolicy
+	}
+}
+
+// Round rounds m to places decimal places following the policy
+func (p RoundingPolicy) Round(m Money, places int32) Money {
+	switch p {
+	case RoundUp:
+		return Money{Decimal: m.Decimal.RoundUp(places)}
+	case RoundHalfUp:
+		return Money{Decimal: m.Decimal.Round(places)}
+	case RoundHalfEven:
+		return Money{Decimal: m.Decimal.RoundBank(places)}
+	default:
+		return Money{Decimal: m.Decimal.RoundDown(places)}
+	}
+}
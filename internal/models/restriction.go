@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// RestrictionType is how a player chose to keep away from play
+type RestrictionType string
+
+const (
+	// RestrictionTimeOut is a short break, from hours to weeks
+	RestrictionTimeOut RestrictionType = "time_out"
+	// RestrictionSelfExclusion lasts months or is permanent
+	RestrictionSelfExclusion RestrictionType = "self_exclusion"
+)
+
+type RestrictionAction string
+
+const (
+	RestrictionActionImposed RestrictionAction = "imposed"
+	// the restriction was lifted by an admin before its end
+	RestrictionActionLifted RestrictionAction = "lifted"
+)
+
+// RestrictionEvent is the audit record of a restriction being imposed on a
+// player or lifted by an admin. Events are only ever appended.
+type RestrictionEvent struct {
+	ID          int64
+	UserID      int64             `gorm:"index"`
+	Action      RestrictionAction `gorm:"size:16"`
+	Restriction RestrictionType   `gorm:"size:16"`
+	// Until is the end of the restriction, nil for a permanent one
+	Until *time.Time
+	// Reason is required when an admin lifts a restriction
+	Reason string
+	// Actor is the email of the player who imposed the restriction or of the
+	// admin who lifted it
+	Actor     string
+	CreatedAt time.Time
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidPayoutTable = errors.New("payout table must list positive percentages adding up to 100")
+)
+
+type TournamentStatus string
+
+const (
+	TournamentStatusOpen TournamentStatus = "open"
+	// the prize pool was paid out to the placements
+	TournamentStatusClosed    TournamentStatus = "closed"
+	TournamentStatusCancelled TournamentStatus = "cancelled"
+)
+
+// Tournament collects the entry fees of its entrants in an escrow wallet.
+// When it closes the pool, less the rake, is paid out to the best placed
+// entrants following the payout table. When it is cancelled every entrant
+// gets the entry fee back. The UUID of the tournament is the correlation id
+// of every journal entry it makes.
+type Tournament struct {
+	ID       int64
+	UUID     uuid.UUID
+	Name     string
+	Currency string `gorm:"size:3"`
+	EntryFee Money
+	// RakePercent of the prize pool goes to the rake wallet at close
+	RakePercent decimal.Decimal `gorm:"type:decimal(9,6)"`
+	// PayoutTable is the comma separated percentages of the pool after rake
+	// paid to each placement, the winner first
+	PayoutTable    string
+	EscrowWalletID int64
+	RakeWalletID   int64
+	Status         TournamentStatus `gorm:"size:16;index"`
+	// PrizePool is the total of the entry fees held in escrow
+	PrizePool Money
+	// Rake is what went to the rake wallet at close, including what was left
+	// over from rounding the prizes down
+	Rake      Money
+	Entrants  int64
+	Actor     string
+	ClosedAt  *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Payouts returns the percentages of the payout table
+func (t *Tournament) Payouts() ([]decimal.Decimal, error) {
+	return ParsePayoutTable(t.PayoutTable)
+}
+
+// FormatPayoutTable checks the percentages of a payout table and formats
+// them to be stored
+func FormatPayoutTable(percentages []decimal.Decimal) (string, error) {
+	total := decimal.Zero
+	parts := make([]string, 0, len(percentages))
+	for _, percentage := range percentages {
+		if !percentage.IsPositive() {
+			return "", ErrInvalidPayoutTable
+		}
+		total = total.Add(percentage)
+		parts = append(parts, percentage.String())
+	}
+	if !total.Equal(decimal.NewFromInt(100)) {
+		return "", ErrInvalidPayoutTable
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// ParsePayoutTable parses a payout table stored by FormatPayoutTable
+func ParsePayoutTable(table string) ([]decimal.Decimal, error) {
+	var percentages []decimal.Decimal
+	for _, part := range strings.Split(table, ",") {
+		percentage, err := decimal.NewFromString(part)
+		if err != nil {
+			return nil, ErrInvalidPayoutTable
+		}
+		percentages = append(percentages, percentage)
+	}
+	if _, err := FormatPayoutTable(percentages); err != nil {
+		return nil, err
+	}
+	return percentages, nil
+}
+
+// TournamentEntry is the entry of a wallet in a tournament, a wallet enters
+// a tournament at most once
+type TournamentEntry struct {
+	ID           int64
+	TournamentID int64 `gorm:"uniqueIndex:idx_tournament_entry_wallet"`
+	WalletID     int64 `gorm:"uniqueIndex:idx_tournament_entry_wallet"`
+	UserID       int64
+	// Place is the placement of the entry at close starting from 1, zero
+	// when it did not place
+	Place     int
+	Prize     Money
+	Refunded  bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TransactionType string
+
+const (
+	TransactionTypeCredit      TransactionType = "credit"
+	TransactionTypeDebit       TransactionType = "debit"
+	TransactionTypeTransferIn  TransactionType = "transfer_in"
+	TransactionTypeTransferOut TransactionType = "transfer_out"
+	TransactionTypeHoldCapture TransactionType = "hold_capture"
+	// the two legs of a currency conversion between wallets of one user
+	TransactionTypeConversionOut TransactionType = "conversion_out"
+	TransactionTypeConversionIn  TransactionType = "conversion_in"
+	// a full or partial compensation of an earlier credit or debit
+	TransactionTypeReversal TransactionType = "reversal"
+	// the stake and payout of a game round and the refund of a cancelled stake
+	TransactionTypeBet      TransactionType = "bet"
+	TransactionTypeWin      TransactionType = "win"
+	TransactionTypeRollback TransactionType = "rollback"
+	// bonus funds given to a wallet and the removal of what is left of them
+	// when the bonus is forfeited or expires
+	TransactionTypeBonusGrant   TransactionType = "bonus_grant"
+	TransactionTypeBonusForfeit TransactionType = "bonus_forfeit"
+	TransactionTypeBonusExpiry  TransactionType = "bonus_expiry"
+	// an entry fee paid by a player, its prize or refund, the movements of
+	// the escrow wallet holding the fees and the rake kept at close
+	TransactionTypeTournamentEntry  TransactionType = "tournament_entry"
+	TransactionTypeTournamentPrize  TransactionType = "tournament_prize"
+	TransactionTypeTournamentRefund TransactionType = "tournament_refund"
+	TransactionTypeTournamentEscrow TransactionType = "tournament_escrow"
+	TransactionTypeTournamentRake   TransactionType = "tournament_rake"
+	// the part of a bet that goes into a jackpot pool, the seed put into a
+	// pool by the seed wallet and the pool paid to the winner of a jackpot
+	TransactionTypeJackpotContribution TransactionType = "jackpot_contribution"
+	TransactionTypeJackpotSeed         TransactionType = "jackpot_seed"
+	TransactionTypeJackpotWin          TransactionType = "jackpot_win"
+	// a promo code credited to the wallet of the player who redeemed it
+	TransactionTypeVoucherRedemption TransactionType = "voucher_redemption"
+	// the stake a player puts into a head-to-head match, the movements of
+	// the escrow wallet holding both stakes, the pot paid to the winner, the
+	// rake kept at settlement and the stakes refunded on a draw or an abort
+	TransactionTypeMatchStake  TransactionType = "match_stake"
+	TransactionTypeMatchEscrow TransactionType = "match_escrow"
+	TransactionTypeMatchPrize  TransactionType = "match_prize"
+	TransactionTypeMatchRake   TransactionType = "match_rake"
+	TransactionTypeMatchRefund TransactionType = "match_refund"
+	// loyalty points turned into real money
+	TransactionTypeLoyaltyRedemption TransactionType = "loyalty_redemption"
+	// money paid out of the wallet by an approved withdrawal
+	TransactionTypeWithdrawal TransactionType = "withdrawal"
+	// a fee charged on a withdrawal, transfer or conversion and its credit
+	// to the revenue wallet
+	TransactionTypeFee TransactionType = "fee"
+)
+
+// Transaction is an immutable journal entry recording a single change
+// to a wallet balance. Entries are only ever appended, never updated.
+type Transaction struct {
+	ID       int64
+	UUID     uuid.UUID
+	WalletID int64
+	Type     TransactionType
+	// Currency is the currency of the wallet at the time of the change
+	Currency      string `gorm:"size:3"`
+	Amount        Money
+	BalanceBefore Money
+	BalanceAfter  Money
+	// BonusAmount is the part of Amount that was paid out of bonus funds
+	BonusAmount Money `gorm:"not null;default:0"`
+	// Reference is an optional caller supplied identifier, e.g. a game round or ticket number
+	Reference string
+	// CorrelationID links the entries written by a single movement of funds,
+	// e.g. both legs of a transfer
+	CorrelationID string
+	// OriginalID is set on reversals to the id of the entry they compensate
+	OriginalID int64 `gorm:"index"`
+	// Reason explains why a reversal was made
+	Reason string
+	// Category is the game category of a wager, it decides the loyalty
+	// points the wager earns
+	Category string `gorm:"size:32"`
+	// Actor is the email of the authenticated user who made the change
+	Actor     string
+	CreatedAt time.Time
+}
@@ -10,12 +10,25 @@ type User struct {
 	ID   int64
 	UUID uuid.UUID
 	// Saving the password in clear text for YOUR testing purpose via Postman etc.
-	Password          string
-	HashedPassword    string
-	FullName          string
-	Email             string
+	Password       string
+	HashedPassword string
+	FullName       string
+	Email          string
+	// IsAdmin users can operate on the wallets of other users, e.g. to
+	// reverse a mistaken transaction
+	IsAdmin           bool `gorm:"not null;default:false"`
 	PasswordChangedAt *time.Time
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 	DeletedAt         *time.Time
+	// Restriction keeps the user from logging in and from staking until
+	// RestrictedUntil, a restriction without an end is permanent. Only an
+	// admin can lift it early.
+	Restriction     RestrictionType `gorm:"size:16"`
+	RestrictedUntil *time.Time
+}
+
+// IsRestricted reports whether a restriction is in force at now
+func (u *User) IsRestricted(now time.Time) bool {
+	return u.Restriction != "" && (u.RestrictedUntil == nil || now.Before(*u.RestrictedUntil))
 }
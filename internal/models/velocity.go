@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidVelocityRule = errors.New("velocity rule must apply to a wallet or to a tier, with non negative caps and a window for its operation count")
+)
+
+// VelocityRule caps how much money leaves a wallet and how often the wallet
+// is operated on. A rule applies to a single wallet, or to the wallets in
+// Currency of every player in the loyalty Tier, the rule of a wallet takes
+// precedence over the rule of its tier. A zero cap is not checked.
+type VelocityRule struct {
+	ID       int64
+	WalletID int64  `gorm:"uniqueIndex:idx_velocity_rule_scope"`
+	Tier     string `gorm:"size:32;uniqueIndex:idx_velocity_rule_scope"`
+	Currency string `gorm:"size:3;uniqueIndex:idx_velocity_rule_scope"`
+	// MaxDebit caps a single debit
+	MaxDebit Money
+	// MaxDailyDebit caps the debits of a wallet over a day, in UTC
+	MaxDailyDebit Money
+	// MaxOperations caps the operations on a wallet within any window of
+	// WindowSeconds
+	MaxOperations int
+	WindowSeconds int64
+	Actor         string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Validate checks that the rule applies to either a wallet or a tier and
+// that its caps make sense
+func (r *VelocityRule) Validate() error {
+	if (r.WalletID > 0) == (r.Tier != "") {
+		return ErrInvalidVelocityRule
+	}
+	if r.MaxDebit.IsNegative() || r.MaxDailyDebit.IsNegative() ||
+		r.MaxOperations < 0 || r.WindowSeconds < 0 ||
+		(r.MaxOperations > 0 && r.WindowSeconds == 0) {
+		return ErrInvalidVelocityRule
+	}
+	return nil
+}
+
+// Window is the rolling window operations are counted over
+func (r *VelocityRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VoucherKind string
+
+const (
+	// VoucherSingleUse is redeemed once, by whoever redeems it first
+	VoucherSingleUse VoucherKind = "single_use"
+	// VoucherMultiUse is redeemed once by each player until its budget runs
+	// out
+	VoucherMultiUse VoucherKind = "multi_use"
+	// VoucherPerUser is issued to a single player who redeems it once
+	VoucherPerUser VoucherKind = "per_user"
+)
+
+// Voucher is a promo code that credits Amount to the wallet of the player
+// redeeming it. The credits made by a voucher never add up to more than its
+// Budget and it cannot be redeemed once ExpiresAt has passed.
+type Voucher struct {
+	ID   int64
+	UUID uuid.UUID
+	// Code is stored upper case and matched case insensitively
+	Code string      `gorm:"size:64;uniqueIndex"`
+	Kind VoucherKind `gorm:"size:16"`
+	// UserID is the only player who may redeem a per user voucher
+	UserID    int64
+	Currency  string `gorm:"size:3"`
+	Amount    Money
+	Budget    Money
+	ExpiresAt time.Time
+	// Redeemed is the total credited so far
+	Redeemed    Money
+	Redemptions int64
+	Actor       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// NormalizeVoucherCode returns code the way it is stored
+func NormalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// VoucherRedemption records a voucher credited to a wallet, a player
+// redeems a voucher at most once
+type VoucherRedemption struct {
+	ID            int64
+	VoucherID     int64 `gorm:"uniqueIndex:idx_voucher_redemption_user"`
+	UserID        int64 `gorm:"uniqueIndex:idx_voucher_redemption_user"`
+	WalletID      int64
+	Amount        Money
+	TransactionID int64
+	CreatedAt     time.Time
+}
@@ -4,15 +4,43 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
 )
 
 type Wallet struct {
-	ID        int64
-	UUID      uuid.UUID
-	UserID    int64
-	Balance   decimal.Decimal
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time
+	ID     int64
+	UUID   uuid.UUID
+	UserID int64 `gorm:"uniqueIndex:idx_wallet_user_currency"`
+	// Currency is the ISO 4217 code of the wallet, a user has at most one
+	// wallet per currency
+	Currency string `gorm:"size:3;not null;default:USD;uniqueIndex:idx_wallet_user_currency"`
+	Balance  Money
+	// HeldBalance is the part of Balance reserved by active holds
+	HeldBalance Money `gorm:"not null;default:0"`
+	// BonusBalance is the part of Balance granted as bonus funds that are
+	// still locked by wagering requirements, the rest is real money
+	BonusBalance Money `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time
+}
+
+// CurrencyCode returns the wallet currency, wallets saved without one are
+// in DefaultCurrency
+func (w *Wallet) CurrencyCode() string {
+	if w.Currency == "" {
+		return DefaultCurrency
+	}
+	return w.Currency
+}
+
+// AvailableBalance is the part of the ledger balance that is not reserved
+// by holds and can be spent
+func (w *Wallet) AvailableBalance() Money {
+	return w.Balance.Sub(w.HeldBalance)
+}
+
+// RealBalance is the part of the ledger balance that is real money, only
+// real money can leave the wallet other than as a stake
+func (w *Wallet) RealBalance() Money {
+	return w.Balance.Sub(w.BonusBalance)
 }
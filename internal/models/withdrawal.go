@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WithdrawalStatus string
+
+const (
+	// the player asked to withdraw, the amount is held on the wallet
+	WithdrawalStatusRequested WithdrawalStatus = "requested"
+	// an admin picked the withdrawal up to review it
+	WithdrawalStatusUnderReview WithdrawalStatus = "under_review"
+	// the withdrawal may be paid out, the amount is still held
+	WithdrawalStatusApproved WithdrawalStatus = "approved"
+	// the withdrawal was refused and the held amount given back
+	WithdrawalStatusRejected WithdrawalStatus = "rejected"
+	// the money was paid out and debited from the wallet
+	WithdrawalStatusPaid WithdrawalStatus = "paid"
+	// the payout did not go through and the held amount was given back
+	WithdrawalStatusFailed WithdrawalStatus = "failed"
+)
+
+// withdrawalTransitions lists the statuses a withdrawal can move to from
+// each status, rejected, paid and failed are final
+var withdrawalTransitions = map[WithdrawalStatus][]WithdrawalStatus{
+	WithdrawalStatusRequested:   {WithdrawalStatusUnderReview},
+	WithdrawalStatusUnderReview: {WithdrawalStatusApproved, WithdrawalStatusRejected},
+	WithdrawalStatusApproved:    {WithdrawalStatusPaid, WithdrawalStatusFailed},
+}
+
+// CanMoveTo reports whether a withdrawal in status s can move to status
+func (s WithdrawalStatus) CanMoveTo(status WithdrawalStatus) bool {
+	for _, next := range withdrawalTransitions[s] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Withdrawal is money a player takes out of a wallet. The amount is held
+// as soon as the withdrawal is requested and only debited once it was
+// reviewed, approved and paid out, a rejected or failed withdrawal gives
+// it back.
+type Withdrawal struct {
+	ID        int64
+	UUID      uuid.UUID
+	Reference string `gorm:"size:64;uniqueIndex"`
+	WalletID  int64  `gorm:"index"`
+	UserID    int64
+	Currency  string `gorm:"size:3"`
+	// Amount is held and debited, Fee is the part of it credited to
+	// FeeWalletID when the withdrawal is paid, the rest is paid out
+	Amount      Money
+	Fee         Money `gorm:"not null;default:0"`
+	FeeWalletID int64
+	// Destination is where the money is paid out to, e.g. a bank account
+	Destination string
+	Status      WithdrawalStatus `gorm:"size:16;index"`
+	// Reason explains a rejected or failed withdrawal
+	Reason string
+	// TransactionID is the debit of a paid withdrawal
+	TransactionID int64
+	Actor         string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// WithdrawalTransition records a change of status of a withdrawal and who
+// made it. The first transition of a withdrawal is from no status to
+// requested.
+type WithdrawalTransition struct {
+	ID           int64
+	WithdrawalID int64            `gorm:"index"`
+	From         WithdrawalStatus `gorm:"size:16"`
+	To           WithdrawalStatus `gorm:"size:16"`
+	Actor        string
+	Reason       string
+	CreatedAt    time.Time
+}
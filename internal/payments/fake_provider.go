@@ -0,0 +1,187 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// headers of a signed webhook of the fake provider
+const (
+	FakeTimestampHeader = "X-Fake-Timestamp"
+	FakeSignatureHeader = "X-Fake-Signature"
+)
+
+// FakeProvider stands in for a real payment provider when running locally
+// and in tests. Payments stay pending until Webhook is called to end them,
+// it returns the webhook a real provider would send, signed with secret.
+// A webhook older than MaxAge is rejected so a captured one cannot be
+// replayed later.
+type FakeProvider struct {
+	secret string
+	MaxAge time.Duration
+	// Now stamps and checks webhooks, it can be moved to simulate stale
+	// ones
+	Now func() time.Time
+
+	mu       sync.Mutex
+	payments map[string]*Payment
+}
+
+var _ Provider = (*FakeProvider)(nil)
+
+type fakeWebhook struct {
+	ProviderReference string          `json:"provider_reference"`
+	Reference         string          `json:"reference"`
+	Status            Status          `json:"status"`
+	Amount            decimal.Decimal `json:"amount"`
+	Currency          string          `json:"currency"`
+	Reason            string          `json:"reason,omitempty"`
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:   secret,
+		MaxAge:   5 * time.Minute,
+		Now:      time.Now,
+		payments: make(map[string]*Payment),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) InitiateDeposit(ctx context.Context, req *DepositRequest) (*Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	providerReference := "fake_" + uuid.New().String()
+	payment := &Payment{
+		ProviderReference: providerReference,
+		Reference:         req.Reference,
+		Status:            StatusPending,
+		Amount:            req.Amount,
+		Currency:          strings.ToUpper(req.Currency),
+		CheckoutURL:       "https://checkout.fake.local/" + providerReference,
+	}
+	p.payments[providerReference] = payment
+	copied := *payment
+	return &copied, nil
+}
+
+func (p *FakeProvider) QueryStatus(ctx context.Context, providerReference string) (*Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerReference]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	copied := *payment
+	return &copied, nil
+}
+
+// Webhook ends the payment with status and returns the signed webhook
+// telling us about it
+func (p *FakeProvider) Webhook(providerReference string, status Status, reason string) (http.Header, []byte, error) {
+	p.mu.Lock()
+	payment, ok := p.payments[providerReference]
+	if ok {
+		payment.Status = status
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, nil, ErrPaymentNotFound
+	}
+	body, err := json.Marshal(fakeWebhook{
+		ProviderReference: payment.ProviderReference,
+		Reference:         payment.Reference,
+		Status:            status,
+		Amount:            payment.Amount,
+		Currency:          payment.Currency,
+		Reason:            reason,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	timestamp := strconv.FormatInt(p.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(FakeTimestampHeader, timestamp)
+	header.Set(FakeSignatureHeader, signWebhook(p.secret, timestamp, body))
+	return header, body, nil
+}
+
+// Deliver ends the payment with status and posts the webhook to url, it
+// returns the http status the webhook was answered with
+func (p *FakeProvider) Deliver(ctx context.Context, url, providerReference string, status Status) (int, error) {
+	header, body, err := p.Webhook(providerReference, status, "")
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header = header
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	return response.StatusCode, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(header http.Header, body []byte) (*Event, error) {
+	// without a secret no webhook can be trusted
+	if p.secret == "" {
+		return nil, ErrInvalidWebhook
+	}
+	timestamp := header.Get(FakeTimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	age := p.Now().Sub(time.Unix(seconds, 0))
+	if age > p.MaxAge || age < -p.MaxAge {
+		return nil, ErrInvalidWebhook
+	}
+	expected := signWebhook(p.secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(header.Get(FakeSignatureHeader)))) {
+		return nil, ErrInvalidWebhook
+	}
+	var webhook fakeWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, err
+	}
+	return &Event{
+		ProviderReference: webhook.ProviderReference,
+		Reference:         webhook.Reference,
+		Status:            webhook.Status,
+		Amount:            webhook.Amount,
+		Currency:          webhook.Currency,
+		Reason:            webhook.Reason,
+	}, nil
+}
+
+// signWebhook returns the hex encoded HMAC-SHA256 of the timestamp and the
+// raw body
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,48 @@
+package payments
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProviderWebhook(t *testing.T) {
+	provider := NewFakeProvider("secret")
+	ctx := context.Background()
+
+	payment, err := provider.InitiateDeposit(ctx, &DepositRequest{
+		Reference: "deposit-1",
+		Amount:    decimal.RequireFromString("25.50"),
+		Currency:  "usd",
+	})
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, payment.Status)
+	require.Equal(t, "USD", payment.Currency)
+
+	header, body, err := provider.Webhook(payment.ProviderReference, StatusSucceeded, "")
+	require.NoError(t, err)
+	event, err := provider.VerifyWebhook(header, body)
+	require.NoError(t, err)
+	require.Equal(t, "deposit-1", event.Reference)
+	require.Equal(t, StatusSucceeded, event.Status)
+	require.True(t, event.Amount.Equal(decimal.RequireFromString("25.5")))
+
+	queried, err := provider.QueryStatus(ctx, payment.ProviderReference)
+	require.NoError(t, err)
+	require.Equal(t, StatusSucceeded, queried.Status)
+
+	// a tampered body, another secret or a stale webhook are rejected
+	_, err = provider.VerifyWebhook(header, append(body, ' '))
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = NewFakeProvider("other").VerifyWebhook(header, body)
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+	provider.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = provider.VerifyWebhook(header, body)
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+
+	_, err = provider.QueryStatus(ctx, "unknown")
+	require.ErrorIs(t, err, ErrPaymentNotFound)
+}
@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidWebhook  = errors.New("webhook is not signed by the payment provider")
+	ErrPaymentNotFound = errors.New("payment not found")
+)
+
+type Status string
+
+const (
+	// the provider is waiting for the player to pay
+	StatusPending Status = "pending"
+	// the player paid and the money reached the provider
+	StatusSucceeded Status = "succeeded"
+	// the payment was declined, cancelled or abandoned
+	StatusFailed Status = "failed"
+)
+
+// DepositRequest asks the provider to collect Amount of Currency from the
+// player. Reference is our id of the deposit, the provider sends it back
+// in its webhooks.
+type DepositRequest struct {
+	Reference string
+	Amount    decimal.Decimal
+	Currency  string
+	Email     string
+}
+
+// Payment is a payment as the provider sees it
+type Payment struct {
+	// ProviderReference is the id of the payment at the provider
+	ProviderReference string
+	Reference         string
+	Status            Status
+	Amount            decimal.Decimal
+	Currency          string
+	// CheckoutURL is where the player completes the payment
+	CheckoutURL string
+}
+
+// Event is a change of status of a payment the provider notified us of
+// with a webhook
+type Event struct {
+	ProviderReference string
+	Reference         string
+	Status            Status
+	Amount            decimal.Decimal
+	Currency          string
+	// Reason explains a failed payment
+	Reason string
+}
+
+// Provider collects deposits from players. The provider tells us how a
+// payment ended with a signed webhook, which may be delivered more than
+// once.
+type Provider interface {
+	// Name identifies the provider in the journal
+	Name() string
+	InitiateDeposit(ctx context.Context, req *DepositRequest) (*Payment, error)
+	// VerifyWebhook checks the signature of a webhook and returns the event
+	// it carries, it fails with ErrInvalidWebhook if the webhook was not
+	// signed by the provider
+	VerifyWebhook(header http.Header, body []byte) (*Event, error)
+	// QueryStatus asks the provider where the payment with providerReference
+	// stands
+	QueryStatus(ctx context.Context, providerReference string) (*Payment, error)
+}
+
+func GetCurrentProvider(provider string) Provider {
+	switch provider {
+	default:
+		return NewFakeProvider(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+	}
+}
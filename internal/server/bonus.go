@@ -0,0 +1,203 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type bonusIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type bonusGrantResponse struct {
+	ID                  int64     `json:"id"`
+	UUID                uuid.UUID `json:"uuid"`
+	WalletID            int64     `json:"wallet_id"`
+	Amount              string    `json:"amount"`
+	Remaining           string    `json:"remaining"`
+	WageringRequirement string    `json:"wagering_requirement"`
+	Wagered             string    `json:"wagered"`
+	DebitOrder          string    `json:"debit_order"`
+	Status              string    `json:"status"`
+	Reference           string    `json:"reference"`
+	Actor               string    `json:"actor"`
+	ExpiresAt           time.Time `json:"expires_at"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func newBonusGrantResponse(grant *models.BonusGrant) bonusGrantResponse {
+	return bonusGrantResponse{
+		ID:                  grant.ID,
+		UUID:                grant.UUID,
+		WalletID:            grant.WalletID,
+		Amount:              grant.Amount.String(),
+		Remaining:           grant.Remaining.String(),
+		WageringRequirement: grant.WageringRequirement.String(),
+		Wagered:             grant.Wagered.String(),
+		DebitOrder:          string(grant.DebitOrder),
+		Status:              string(grant.Status),
+		Reference:           grant.Reference,
+		Actor:               grant.Actor,
+		ExpiresAt:           grant.ExpiresAt,
+		CreatedAt:           grant.CreatedAt,
+		UpdatedAt:           grant.UpdatedAt,
+	}
+}
+
+type createBonusRequest struct {
+	WalletID int64        `json:"wallet_id" binding:"required,min=1"`
+	Amount   models.Money `json:"amount"`
+	// WageringMultiplier is how many times the amount has to be staked
+	// before the bonus becomes real money
+	WageringMultiplier int64 `json:"wagering_multiplier" binding:"required,min=1"`
+	// DebitOrder defaults to the BONUS_DEBIT_ORDER of the server
+	DebitOrder string `json:"debit_order"`
+	Reference  string `json:"reference"`
+	// ExpiresIn is the lifetime of the bonus in seconds
+	ExpiresIn int64 `json:"expires_in" binding:"min=0"`
+}
+
+type listBonusesRequest struct {
+	Status string `form:"status"`
+}
+
+func (server *Server) createBonus(ctx *gin.Context) {
+	var req createBonusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	debitOrder := server.bonusDebitOrder
+	if req.DebitOrder != "" {
+		order, err := models.ParseBonusDebitOrder(req.DebitOrder)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		debitOrder = order
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	ttl := bonusDefaultTTL()
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	now := time.Now()
+	grant := &models.BonusGrant{
+		UUID:                uuid.New(),
+		WalletID:            req.WalletID,
+		Amount:              req.Amount,
+		Remaining:           req.Amount,
+		WageringRequirement: req.Amount.Mul(decimal.NewFromInt(req.WageringMultiplier)),
+		DebitOrder:          debitOrder,
+		Status:              models.BonusStatusActive,
+		Reference:           req.Reference,
+		Actor:               actor,
+		ExpiresAt:           now.Add(ttl),
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	// the journal entry is linked to the grant through its correlation id
+	txn := newTransaction(models.TransactionTypeBonusGrant, req.Reference, grant.UUID.String(), actor)
+	wallet, err := server.repo.CreateBonusGrant(ctx, grant, txn)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, wallet); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.BonusGrantSuccess, gin.H{
+		"bonus":   newBonusGrantResponse(grant),
+		"balance": newWalletBalanceResponse(wallet),
+	})
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (server *Server) forfeitBonus(ctx *gin.Context) {
+	var param bonusIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	grant, err := server.repo.GetBonusGrant(param.ID)
+	if err != nil {
+		ctx.JSON(bonusErrorStatus(err), errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	txn := newTransaction(models.TransactionTypeBonusForfeit, grant.Reference, grant.UUID.String(), actor)
+	forfeited, wallet, err := server.repo.ForfeitBonusGrant(ctx, grant.ID, txn)
+	if err != nil {
+		ctx.JSON(bonusErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, wallet); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.BonusForfeitSuccess, gin.H{
+		"bonus":   newBonusGrantResponse(forfeited),
+		"balance": newWalletBalanceResponse(wallet),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getWalletBonuses(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req listBonusesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	grants, err := server.repo.GetBonusGrantsByWallet(wallet.ID, models.BonusStatus(req.Status))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]bonusGrantResponse, 0, len(grants))
+	for _, grant := range grants {
+		data = append(data, newBonusGrantResponse(grant))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"bonuses": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func bonusErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrBonusNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrBonusNotActive):
+		return http.StatusConflict
+	default:
+		return postingErrorStatus(err)
+	}
+}
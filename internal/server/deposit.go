@@ -0,0 +1,332 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/internal/payments"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDepositUnavailable = errors.New("the payment provider could not start the deposit")
+)
+
+// depositStatuses maps the outcomes of a payment to the deposit status they
+// end it with, a pending payment changes nothing
+var depositStatuses = map[payments.Status]models.DepositStatus{
+	payments.StatusSucceeded: models.DepositStatusSucceeded,
+	payments.StatusFailed:    models.DepositStatusFailed,
+}
+
+type depositIDUriBinding struct {
+	WalletID  int64 `uri:"wallet_id" binding:"required,min=1"`
+	DepositID int64 `uri:"deposit_id" binding:"required,min=1"`
+}
+
+type adminDepositIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type listDepositsRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending succeeded failed refund_due refunded"`
+}
+
+type depositResponse struct {
+	ID                int64      `json:"id"`
+	UUID              uuid.UUID  `json:"uuid"`
+	Reference         string     `json:"reference"`
+	WalletID          int64      `json:"wallet_id"`
+	Provider          string     `json:"provider"`
+	ProviderReference string     `json:"provider_reference"`
+	Currency          string     `json:"currency"`
+	Amount            string     `json:"amount"`
+	Status            string     `json:"status"`
+	FailureReason     string     `json:"failure_reason,omitempty"`
+	TransactionID     int64      `json:"transaction_id,omitempty"`
+	RefundedBy        string     `json:"refunded_by,omitempty"`
+	RefundedAt        *time.Time `json:"refunded_at,omitempty"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func newDepositResponse(d *models.Deposit) depositResponse {
+	return depositResponse{
+		ID:                d.ID,
+		UUID:              d.UUID,
+		Reference:         d.Reference,
+		WalletID:          d.WalletID,
+		Provider:          d.Provider,
+		ProviderReference: d.ProviderReference,
+		Currency:          d.Currency,
+		Amount:            d.Amount.String(),
+		Status:            string(d.Status),
+		FailureReason:     d.FailureReason,
+		TransactionID:     d.TransactionID,
+		RefundedBy:        d.RefundedBy,
+		RefundedAt:        d.RefundedAt,
+		CompletedAt:       d.CompletedAt,
+		CreatedAt:         d.CreatedAt,
+	}
+}
+
+type createDepositRequest struct {
+	Amount models.Money `json:"amount"`
+	// Currency is optional, when it is sent it must be the wallet currency
+	Currency string `json:"currency"`
+}
+
+// createDeposit starts a deposit into a wallet of the logged in user with
+// the payment provider and returns where the player pays it. The wallet is
+// only credited once the provider confirms the payment.
+func (server *Server) createDeposit(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req createDepositRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if currency, ok := models.LookupCurrency(wallet.CurrencyCode()); ok && !currency.Fits(req.Amount) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	// a restricted player cannot pay money in
+	if err := server.checkPlayAllowed(wallet); err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	// a deposit the limits refuse is not started, the player would pay
+	// for nothing. The limits are checked again when the payment is
+	// credited.
+	err = server.repo.CheckGamingLimits(ctx, &database.Posting{
+		WalletID:    wallet.ID,
+		Amount:      req.Amount,
+		Transaction: &models.Transaction{Type: models.TransactionTypeCredit},
+	})
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	id := uuid.New()
+	deposit := &models.Deposit{
+		UUID:      id,
+		Reference: id.String(),
+		WalletID:  wallet.ID,
+		UserID:    wallet.UserID,
+		Provider:  server.payments.Name(),
+		Currency:  wallet.CurrencyCode(),
+		Amount:    req.Amount,
+		Status:    models.DepositStatusPending,
+		Actor:     actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	// the deposit is saved first so a webhook can never come in for a
+	// deposit we do not know about
+	if err := server.repo.CreateDeposit(deposit); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	payment, err := server.payments.InitiateDeposit(ctx, &payments.DepositRequest{
+		Reference: deposit.Reference,
+		Amount:    deposit.Amount.Decimal,
+		Currency:  deposit.Currency,
+		Email:     actor,
+	})
+	if err != nil {
+		// nothing was paid, the deposit is failed so it does not linger
+		_, _ = server.repo.ConfirmDeposit(ctx, &database.DepositConfirmation{
+			Reference: deposit.Reference,
+			Status:    models.DepositStatusFailed,
+			Reason:    err.Error(),
+		})
+		ctx.JSON(http.StatusBadGateway, errorResponse(ErrDepositUnavailable))
+		return
+	}
+	if err := server.repo.SetDepositProviderReference(deposit.ID, payment.ProviderReference); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	deposit.ProviderReference = payment.ProviderReference
+	response := util.BuildResponseEntity(true, util.DepositCreateSuccess, gin.H{
+		"deposit":      newDepositResponse(deposit),
+		"checkout_url": payment.CheckoutURL,
+	})
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// getDeposit returns a deposit into a wallet of the logged in user. While
+// the deposit is pending the provider is asked where the payment stands,
+// its answer is shown but only a webhook ends the deposit.
+func (server *Server) getDeposit(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var param depositIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	deposit, err := server.repo.GetDeposit(param.DepositID)
+	// a deposit into another wallet is reported as missing rather than
+	// forbidden
+	if errors.Is(err, util.ErrDepositNotFound) || (err == nil && deposit.WalletID != wallet.ID) {
+		ctx.JSON(http.StatusNotFound, errorResponse(util.ErrDepositNotFound))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := gin.H{
+		"deposit": newDepositResponse(deposit),
+	}
+	if deposit.Status == models.DepositStatusPending && deposit.ProviderReference != "" {
+		payment, err := server.payments.QueryStatus(ctx, deposit.ProviderReference)
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, errorResponse(err))
+			return
+		}
+		data["provider_status"] = payment.Status
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, "", data))
+}
+
+// depositWebhook ends a deposit the way the payment provider says. The
+// provider may deliver the same webhook more than once, a repeat changes
+// nothing and is acknowledged all the same.
+func (server *Server) depositWebhook(ctx *gin.Context) {
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	event, err := server.payments.VerifyWebhook(ctx.Request.Header, body)
+	if errors.Is(err, payments.ErrInvalidWebhook) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	status, ok := depositStatuses[event.Status]
+	if !ok {
+		ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, "", nil))
+		return
+	}
+	result, err := server.repo.ConfirmDeposit(ctx, &database.DepositConfirmation{
+		Reference:         event.Reference,
+		ProviderReference: event.ProviderReference,
+		Status:            status,
+		Amount:            models.NewMoney(event.Amount),
+		Currency:          strings.ToUpper(event.Currency),
+		Reason:            event.Reason,
+	})
+	if err != nil {
+		ctx.JSON(depositErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if result.Wallet != nil {
+		if err := server.cacheWalletBalance(ctx, result.Wallet); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	response := util.BuildResponseEntity(true, util.DepositConfirmSuccess, gin.H{
+		"deposit":   newDepositResponse(result.Deposit),
+		"duplicate": result.Duplicate,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getDeposits lists the deposits, those whose payment waits to be refunded
+// by hand are asked for with the status refund_due
+func (server *Server) getDeposits(ctx *gin.Context) {
+	var req listDepositsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	deposits, err := server.repo.GetDeposits(models.DepositStatus(req.Status))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]depositResponse, 0, len(deposits))
+	for _, d := range deposits {
+		data = append(data, newDepositResponse(d))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"deposits": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// refundDeposit records that the logged in admin refunded the payment of a
+// deposit refused by a deposit limit
+func (server *Server) refundDeposit(ctx *gin.Context) {
+	var param adminDepositIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	deposit, err := server.repo.RefundDeposit(ctx, param.ID, actor)
+	if err != nil {
+		ctx.JSON(depositErrorStatus(err), errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.DepositRefundSuccess, gin.H{
+		"deposit": newDepositResponse(deposit),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func depositErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrDepositNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrDepositNoRefund):
+		return http.StatusConflict
+	case errors.Is(err, util.ErrDepositFinished):
+		return http.StatusConflict
+	case errors.Is(err, util.ErrDepositMismatch):
+		return http.StatusBadRequest
+	default:
+		return postingErrorStatus(err)
+	}
+}
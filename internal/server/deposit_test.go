@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/internal/payments"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_depositWebhook(t *testing.T) {
+	deposit := &models.Deposit{
+		ID:        1,
+		Reference: "deposit-1",
+		WalletID:  1,
+		Provider:  "fake",
+		Currency:  models.DefaultCurrency,
+		Amount:    models.RequireMoney("25.5"),
+		Status:    models.DepositStatusSucceeded,
+	}
+
+	testCases := []struct {
+		name          string
+		tamper        bool
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should credit the deposit the provider confirmed",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().
+					ConfirmDeposit(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, c *database.DepositConfirmation) (*database.DepositResult, error) {
+						require.Equal(t, "deposit-1", c.Reference)
+						require.Equal(t, models.DepositStatusSucceeded, c.Status)
+						require.True(t, c.Amount.Equal(models.RequireMoney("25.5")))
+						require.Equal(t, models.DefaultCurrency, c.Currency)
+						return &database.DepositResult{
+							Deposit: deposit,
+							Wallet:  &models.Wallet{ID: 1, Currency: models.DefaultCurrency, Balance: models.RequireMoney("125.5")},
+						}, nil
+					})
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"duplicate":false`)
+			},
+		},
+		{
+			name: "should acknowledge a webhook delivered again without crediting",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().
+					ConfirmDeposit(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(&database.DepositResult{Deposit: deposit, Duplicate: true}, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"duplicate":true`)
+			},
+		},
+		{
+			name: "should report a deposit that ended another way",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().
+					ConfirmDeposit(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrDepositFinished)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:   "should reject a webhook not signed by the provider",
+			tamper: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().ConfirmDeposit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			provider := payments.NewFakeProvider("secret")
+			server.payments = provider
+			recorder := httptest.NewRecorder()
+
+			payment, err := provider.InitiateDeposit(context.Background(), &payments.DepositRequest{
+				Reference: "deposit-1",
+				Amount:    decimal.RequireFromString("25.5"),
+				Currency:  models.DefaultCurrency,
+			})
+			require.NoError(t, err)
+			header, body, err := provider.Webhook(payment.ProviderReference, payments.StatusSucceeded, "")
+			require.NoError(t, err)
+			if tt.tamper {
+				body = bytes.Replace(body, []byte("25.5"), []byte("2550"), 1)
+			}
+
+			request, err := http.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+			require.NoError(t, err)
+			request.Header = header
+
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDepositLimit(t *testing.T) {
+	repo := database.NewInMemory()
+	player := randomUser()
+	admin := randomUser()
+	admin.ID = 2
+	admin.IsAdmin = true
+	require.NoError(t, repo.CreateUser(player))
+	require.NoError(t, repo.CreateUser(admin))
+	walletID, err := repo.CreateWallet(&models.Wallet{
+		UUID:     uuid.New(),
+		UserID:   player.ID,
+		Currency: models.DefaultCurrency,
+	})
+	require.NoError(t, err)
+	_, err = repo.SetGamingLimit(context.Background(), &database.LimitChange{
+		WalletID: walletID,
+		Kind:     models.LimitKindDeposit,
+		Period:   models.LimitPeriodDaily,
+		Amount:   models.NewMoneyFromInt(50),
+	})
+	require.NoError(t, err)
+
+	cache := mockcache.NewMockCacher(gomock.NewController(t))
+	cache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	server, err := NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	provider := payments.NewFakeProvider("secret")
+	server.payments = provider
+	send := func(method, url, email string, body []byte) *httptest.ResponseRecorder {
+		request, err := http.NewRequest(method, url, bytes.NewReader(body))
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, email, time.Minute)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	deposit := func(amount string) *httptest.ResponseRecorder {
+		return send(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposits", walletID), player.Email, []byte(`{"amount":"`+amount+`"}`))
+	}
+	pay := func(id int64) {
+		d, err := repo.GetDeposit(id)
+		require.NoError(t, err)
+		header, body, err := provider.Webhook(d.ProviderReference, payments.StatusSucceeded, "")
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+		require.NoError(t, err)
+		request.Header = header
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	// a deposit beyond the limit is refused before the player pays
+	recorder := deposit("60")
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	require.Contains(t, recorder.Body.String(), util.ErrDepositLimitExceeded.Error())
+	deposits, err := repo.GetDeposits("")
+	require.NoError(t, err)
+	require.Empty(t, deposits)
+
+	// two deposits within the limit can still add up to more than it once
+	// paid, the second payment waits to be refunded
+	require.Equal(t, http.StatusCreated, deposit("40").Code)
+	require.Equal(t, http.StatusCreated, deposit("40").Code)
+	pay(1)
+	pay(2)
+	wallet, err := repo.GetWallet(walletID)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(40)))
+
+	recorder = send(http.MethodGet, "/api/v1/deposits/?status=refund_due", admin.Email, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"id":2`)
+	require.NotContains(t, recorder.Body.String(), `"id":1`)
+
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/deposits/2/refund", player.Email, nil).Code)
+	recorder = send(http.MethodPost, "/api/v1/deposits/2/refund", admin.Email, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"status":"refunded"`)
+	recorder = send(http.MethodPost, "/api/v1/deposits/2/refund", admin.Email, nil)
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, recorder.Body.String(), util.ErrDepositNoRefund.Error())
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrFeeExceedsAmount = errors.New("amount does not cover the fee")
+)
+
+type feeScheduleResponse struct {
+	Operation       string    `json:"operation"`
+	Currency        string    `json:"currency"`
+	Type            string    `json:"type"`
+	Flat            string    `json:"flat,omitempty"`
+	Percent         string    `json:"percent,omitempty"`
+	Tiers           string    `json:"tiers,omitempty"`
+	MinFee          string    `json:"min_fee"`
+	MaxFee          string    `json:"max_fee"`
+	RevenueWalletID int64     `json:"revenue_wallet_id"`
+	Actor           string    `json:"actor"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func newFeeScheduleResponse(s *models.FeeSchedule) feeScheduleResponse {
+	response := feeScheduleResponse{
+		Operation:       string(s.Operation),
+		Currency:        s.Currency,
+		Type:            string(s.Type),
+		Tiers:           s.Tiers,
+		MinFee:          s.MinFee.String(),
+		MaxFee:          s.MaxFee.String(),
+		RevenueWalletID: s.RevenueWalletID,
+		Actor:           s.Actor,
+		UpdatedAt:       s.UpdatedAt,
+	}
+	switch s.Type {
+	case models.FeeTypeFlat:
+		response.Flat = s.Flat.String()
+	case models.FeeTypePercentage:
+		response.Percent = s.Percent.String()
+	}
+	return response
+}
+
+// feeResponse shows how the fee splits the gross amount of an operation,
+// net is what reaches its destination
+type feeResponse struct {
+	Gross string `json:"gross"`
+	Fee   string `json:"fee"`
+	Net   string `json:"net"`
+}
+
+func newFeeResponse(gross, fee models.Money) feeResponse {
+	return feeResponse{
+		Gross: gross.String(),
+		Fee:   fee.String(),
+		Net:   gross.Sub(fee).String(),
+	}
+}
+
+type setFeeScheduleRequest struct {
+	Operation string `json:"operation" binding:"required,oneof=withdrawal transfer conversion"`
+	Currency  string `json:"currency" binding:"required,len=3"`
+	Type      string `json:"type" binding:"required,oneof=flat percentage tiered"`
+	// Flat, Percent and Tiers set the fee of their type of schedule
+	Flat            models.Money    `json:"flat"`
+	Percent         decimal.Decimal `json:"percent"`
+	Tiers           string          `json:"tiers"`
+	MinFee          models.Money    `json:"min_fee"`
+	MaxFee          models.Money    `json:"max_fee"`
+	RevenueWalletID int64           `json:"revenue_wallet_id" binding:"required,min=1"`
+}
+
+type feeScheduleKey struct {
+	Operation string `form:"operation" binding:"required,oneof=withdrawal transfer conversion"`
+	Currency  string `form:"currency" binding:"required,len=3"`
+}
+
+// setFeeSchedule sets the fee charged on an operation in a currency, it
+// applies to operations started from then on
+func (server *Server) setFeeSchedule(ctx *gin.Context) {
+	var req setFeeScheduleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	if !currency.Fits(req.Flat) || !currency.Fits(req.MinFee) || !currency.Fits(req.MaxFee) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	schedule := &models.FeeSchedule{
+		Operation:       models.FeeOperation(req.Operation),
+		Currency:        currency.Code,
+		Type:            models.FeeType(req.Type),
+		Flat:            req.Flat,
+		Percent:         req.Percent,
+		Tiers:           req.Tiers,
+		MinFee:          req.MinFee,
+		MaxFee:          req.MaxFee,
+		RevenueWalletID: req.RevenueWalletID,
+		Actor:           actor,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := schedule.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := server.validateOperatorWallets(currency.Code, req.RevenueWalletID); err != nil {
+		ctx.JSON(feeErrorStatus(err), errorResponse(err))
+		return
+	}
+	if err := server.repo.SetFeeSchedule(schedule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.FeeScheduleSetSuccess, newFeeScheduleResponse(schedule))
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getFeeSchedules(ctx *gin.Context) {
+	schedules, err := server.repo.GetFeeSchedules()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]feeScheduleResponse, 0, len(schedules))
+	for _, s := range schedules {
+		data = append(data, newFeeScheduleResponse(s))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"schedules": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// removeFeeSchedule stops charging a fee on an operation in a currency
+func (server *Server) removeFeeSchedule(ctx *gin.Context) {
+	var req feeScheduleKey
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	err := server.repo.RemoveFeeSchedule(models.FeeOperation(req.Operation), req.Currency)
+	if err != nil {
+		ctx.JSON(feeErrorStatus(err), errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, util.FeeScheduleRemoveSuccess, nil))
+}
+
+// quoteFee works out the fee charged on amount for operation in currency,
+// rounded to the currency with the configured policy, and the wallet it is
+// credited to. There is no fee when no schedule is set. The fee must leave
+// something of the amount to reach its destination.
+func (server *Server) quoteFee(operation models.FeeOperation, currency string, amount models.Money) (models.Money, int64, error) {
+	schedule, err := server.repo.GetFeeSchedule(operation, currency)
+	if errors.Is(err, util.ErrFeeScheduleNotFound) {
+		return models.ZeroMoney, 0, nil
+	}
+	if err != nil {
+		return models.ZeroMoney, 0, err
+	}
+	fee, err := schedule.Fee(amount)
+	if err != nil {
+		return models.ZeroMoney, 0, err
+	}
+	if c, ok := models.LookupCurrency(currency); ok {
+		fee = c.Round(fee, server.rounding)
+	}
+	if !fee.LessThan(amount) {
+		return models.ZeroMoney, 0, ErrFeeExceedsAmount
+	}
+	return fee, schedule.RevenueWalletID, nil
+}
+
+func feeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrFeeScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFeeExceedsAmount),
+		errors.Is(err, ErrOperatorWallets):
+		return http.StatusBadRequest
+	default:
+		return postingErrorStatus(err)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"testing"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// stubNoFee charges no fee on operation
+func stubNoFee(mockRepo *mockdb.MockRepository, operation models.FeeOperation) {
+	mockRepo.EXPECT().
+		GetFeeSchedule(gomock.Eq(operation), gomock.Any()).
+		Times(1).
+		Return(nil, util.ErrFeeScheduleNotFound)
+}
+
+func Test_quoteFee(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schedule *models.FeeSchedule
+		amount   string
+		fee      string
+		err      error
+	}{
+		{
+			name:     "should charge a flat fee whatever the amount",
+			schedule: &models.FeeSchedule{Type: models.FeeTypeFlat, Flat: models.RequireMoney("1.5")},
+			amount:   "1000",
+			fee:      "1.5",
+		},
+		{
+			name:     "should round a percentage fee to the currency",
+			schedule: &models.FeeSchedule{Type: models.FeeTypePercentage, Percent: decimal.RequireFromString("1.25")},
+			amount:   "10.99",
+			fee:      "0.13",
+		},
+		{
+			name:     "should raise a fee to the minimum",
+			schedule: &models.FeeSchedule{Type: models.FeeTypePercentage, Percent: decimal.NewFromInt(1), MinFee: models.RequireMoney("0.5")},
+			amount:   "10",
+			fee:      "0.5",
+		},
+		{
+			name:     "should cap a fee at the maximum",
+			schedule: &models.FeeSchedule{Type: models.FeeTypePercentage, Percent: decimal.NewFromInt(1), MaxFee: models.NewMoneyFromInt(5)},
+			amount:   "1000",
+			fee:      "5",
+		},
+		{
+			name:     "should charge the percent of the highest tier the amount reaches",
+			schedule: &models.FeeSchedule{Type: models.FeeTypeTiered, Tiers: "0:3,100:2,1000:1"},
+			amount:   "500",
+			fee:      "10",
+		},
+		{
+			name:     "should charge the lowest tier below the next one",
+			schedule: &models.FeeSchedule{Type: models.FeeTypeTiered, Tiers: "100:2,0:3"},
+			amount:   "99.99",
+			fee:      "2.99",
+		},
+		{
+			name:   "should charge nothing without a schedule",
+			amount: "10",
+			fee:    "0",
+		},
+		{
+			name:     "should reject an amount the fee takes all of",
+			schedule: &models.FeeSchedule{Type: models.FeeTypeFlat, Flat: models.NewMoneyFromInt(10)},
+			amount:   "10",
+			err:      ErrFeeExceedsAmount,
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			if tt.schedule == nil {
+				stubNoFee(repo, models.FeeOperationTransfer)
+			} else {
+				tt.schedule.RevenueWalletID = 9
+				repo.EXPECT().
+					GetFeeSchedule(gomock.Eq(models.FeeOperationTransfer), gomock.Eq(models.DefaultCurrency)).
+					Times(1).
+					Return(tt.schedule, nil)
+			}
+
+			server, err := NewServer(repo, mockcache.NewMockCacher(ctrl), util.RandomString(32))
+			require.NoError(t, err)
+
+			fee, revenueWalletID, err := server.quoteFee(models.FeeOperationTransfer, models.DefaultCurrency, models.RequireMoney(tt.amount))
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, fee.Equal(models.RequireMoney(tt.fee)), "fee %s", fee)
+			if tt.schedule != nil {
+				require.Equal(t, int64(9), revenueWalletID)
+			}
+		})
+	}
+}
@@ -0,0 +1,573 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/aggregator"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/fraud"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// how long the token a request is replayed with on approval lives
+const fraudReviewReplayTokenDuration = time.Minute
+
+type fraudReviewIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type fraudReviewResponse struct {
+	ID         int64      `json:"id"`
+	UUID       uuid.UUID  `json:"uuid"`
+	WalletID   int64      `json:"wallet_id"`
+	UserID     int64      `json:"user_id"`
+	Operation  string     `json:"operation"`
+	Currency   string     `json:"currency"`
+	Amount     string     `json:"amount"`
+	Reference  string     `json:"reference,omitempty"`
+	IP         string     `json:"ip"`
+	Findings   string     `json:"findings"`
+	Actor      string     `json:"actor"`
+	Status     string     `json:"status"`
+	Reviewer   string     `json:"reviewer,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newFraudReviewResponse(r *models.FraudReview) fraudReviewResponse {
+	return fraudReviewResponse{
+		ID:         r.ID,
+		UUID:       r.UUID,
+		WalletID:   r.WalletID,
+		UserID:     r.UserID,
+		Operation:  string(r.Operation),
+		Currency:   r.Currency,
+		Amount:     r.Amount.String(),
+		Reference:  r.Reference,
+		IP:         r.IP,
+		Findings:   r.Findings,
+		Actor:      r.Actor,
+		Status:     string(r.Status),
+		Reviewer:   r.Reviewer,
+		Note:       r.Note,
+		ReviewedAt: r.ReviewedAt,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+type listFraudReviewsRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending approved denied"`
+}
+
+type approveFraudReviewRequest struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+type denyFraudReviewRequest struct {
+	Note string `json:"note" binding:"required,max=255"`
+}
+
+// AddFraudRule screens wallet operations with a custom rule on top of the
+// built-in ones, it must be called before Start
+func (server *Server) AddFraudRule(rule fraud.Rule) {
+	server.fraud.Register(rule)
+}
+
+func (server *Server) getFraudReviews(ctx *gin.Context) {
+	var req listFraudReviewsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	reviews, err := server.repo.GetFraudReviews(models.FraudReviewStatus(req.Status))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]fraudReviewResponse, 0, len(reviews))
+	for _, r := range reviews {
+		data = append(data, newFraudReviewResponse(r))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"reviews": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getFraudReview(ctx *gin.Context) {
+	var param fraudReviewIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	review, err := server.repo.GetFraudReview(param.ID)
+	if err != nil {
+		ctx.JSON(fraudErrorStatus(err), errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, "", newFraudReviewResponse(review)))
+}
+
+// approveFraudReview sends the request of a flagged operation through its
+// handler again on behalf of the player who made it. The handler checks it
+// as it would any other request, against the player and wallet as they are
+// now, so a player restricted since or over a velocity rule is refused.
+// The review is claimed before the operation is applied so that it cannot
+// be denied meanwhile. It goes back to pending when the handler refuses
+// the operation and the admin gets the answer of the handler.
+func (server *Server) approveFraudReview(ctx *gin.Context) {
+	var param fraudReviewIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req approveFraudReviewRequest
+	// the body is optional, an approval needs no note
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	reviewer, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	review, err := server.repo.MoveFraudReview(ctx, &database.FraudReviewChange{
+		ReviewID: param.ID,
+		Status:   models.FraudReviewStatusApproving,
+		Reviewer: reviewer,
+		Note:     req.Note,
+	})
+	if err != nil {
+		ctx.JSON(fraudErrorStatus(err), errorResponse(err))
+		return
+	}
+	replayed, err := server.replayFraudReview(ctx, review)
+	if err != nil || replayed.status >= http.StatusMultipleChoices {
+		// the operation did not go through, the review waits for a
+		// reviewer again
+		_, releaseErr := server.repo.MoveFraudReview(ctx, &database.FraudReviewChange{
+			ReviewID: review.ID,
+			Status:   models.FraudReviewStatusPending,
+		})
+		switch {
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		case releaseErr != nil:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(releaseErr))
+		default:
+			ctx.Data(replayed.status, replayed.header.Get("Content-Type"), replayed.body.Bytes())
+		}
+		return
+	}
+	review, err = server.repo.MoveFraudReview(ctx, &database.FraudReviewChange{
+		ReviewID: review.ID,
+		Status:   models.FraudReviewStatusApproved,
+		Reviewer: reviewer,
+		Note:     req.Note,
+	})
+	if err != nil {
+		ctx.JSON(fraudErrorStatus(err), errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, util.FraudReviewApproveSuccess, gin.H{
+		"review":    newFraudReviewResponse(review),
+		"operation": json.RawMessage(replayed.body.Bytes()),
+	}))
+}
+
+// denyFraudReview drops a flagged operation, the wallet is left as it is
+func (server *Server) denyFraudReview(ctx *gin.Context) {
+	var param fraudReviewIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req denyFraudReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	reviewer, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	review, err := server.repo.MoveFraudReview(ctx, &database.FraudReviewChange{
+		ReviewID: param.ID,
+		Status:   models.FraudReviewStatusDenied,
+		Reviewer: reviewer,
+		Note:     req.Note,
+	})
+	if err != nil {
+		ctx.JSON(fraudErrorStatus(err), errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, util.FraudReviewDenySuccess, gin.H{
+		"review": newFraudReviewResponse(review),
+	}))
+}
+
+// replayFraudReview sends the request of review through the router again,
+// as the player who made it and past the fraud rules. It carries the
+// review as its idempotency key so that it is applied once however often
+// the review is approved. The answer to a refused request is forgotten,
+// the operation may well go through once the player can afford it.
+func (server *Server) replayFraudReview(ctx *gin.Context, review *models.FraudReview) (*replayRecorder, error) {
+	request, err := http.NewRequestWithContext(
+		middleware.WithApprovedFraudReview(ctx.Request.Context()),
+		review.Method,
+		review.Path,
+		strings.NewReader(review.Body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	token, err := server.tokenMaker.CreateToken(review.Actor, fraudReviewReplayTokenDuration)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(middleware.AuthorizationHeaderKey, fmt.Sprintf("%s %s", middleware.AuthorizationTypeBearer, token))
+	request.Header.Set(middleware.IdempotencyKeyHeader, fraudReviewReplayKey(review))
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := &replayRecorder{header: http.Header{}}
+	server.router.ServeHTTP(recorder, request)
+	if recorder.status >= http.StatusMultipleChoices {
+		if err := server.forgetReplayKey(review); err != nil {
+			return nil, err
+		}
+	}
+	return recorder, nil
+}
+
+func fraudReviewReplayKey(review *models.FraudReview) string {
+	return "fraud-review:" + review.UUID.String()
+}
+
+// forgetReplayKey removes the answer stored under the idempotency key of
+// review, if any
+func (server *Server) forgetReplayKey(review *models.FraudReview) error {
+	key, err := server.repo.GetIdempotencyKey(review.Actor, fraudReviewReplayKey(review))
+	if errors.Is(err, util.ErrIdempotencyKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return server.repo.DeleteIdempotencyKey(key.ID)
+}
+
+// screen holds back the requests of operation that the fraud rules flag
+// and refuses those they block, locate reads what a request moves
+func (server *Server) screen(operation models.TransactionType, locate middleware.FraudLocator) gin.HandlerFunc {
+	return middleware.FraudMiddleware(server.fraud, server.repo, middleware.FraudScreen{
+		Operation: operation,
+		Locate:    locate,
+	})
+}
+
+// screenImmediate screens the requests of a game server, which cannot wait
+// for a review, a flagged request is refused like a blocked one
+func (server *Server) screenImmediate(operation models.TransactionType, locate middleware.FraudLocator, refuse func(ctx *gin.Context, status int, err error)) gin.HandlerFunc {
+	return middleware.FraudMiddleware(server.fraud, server.repo, middleware.FraudScreen{
+		Operation: operation,
+		Locate:    locate,
+		Immediate: true,
+		Refuse:    refuse,
+	})
+}
+
+// fraudAmountRequest is the part of a request body the fraud rules look at
+// and a review keeps
+type fraudAmountRequest struct {
+	Amount    models.Money `json:"amount"`
+	Reference string       `json:"reference"`
+	Category  string       `json:"category"`
+}
+
+// locateWalletAmount reads the amount in the body of a request for the
+// wallet in the uri
+func (server *Server) locateWalletAmount(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var req fraudAmountRequest
+	if json.Unmarshal(body, &req) != nil || !req.Amount.IsPositive() {
+		return nil, nil
+	}
+	return server.locateWallet(ctx, req.Amount, req.Reference, req.Category)
+}
+
+// locateHoldCapture reads the capture of a hold of the wallet in the uri,
+// it is the held amount when the body does not say
+func (server *Server) locateHoldCapture(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var param holdIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		return nil, nil
+	}
+	var req captureHoldRequest
+	if len(body) > 0 && json.Unmarshal(body, &req) != nil {
+		return nil, nil
+	}
+	hold, err := server.repo.GetHold(param.HoldID)
+	if errors.Is(err, util.ErrHoldNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hold.WalletID != param.WalletID {
+		return nil, nil
+	}
+	amount := hold.Amount
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	if !amount.IsPositive() {
+		return nil, nil
+	}
+	return server.locateWallet(ctx, amount, hold.Reference, "")
+}
+
+// locateVoucher reads the voucher a request redeems into the wallet in the
+// uri, the voucher code is kept as the reference of a review
+func (server *Server) locateVoucher(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var req redeemVoucherRequest
+	if json.Unmarshal(body, &req) != nil || req.Code == "" {
+		return nil, nil
+	}
+	voucher, err := server.repo.GetVoucherByCode(req.Code)
+	if errors.Is(err, util.ErrVoucherNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return server.locateWallet(ctx, voucher.Amount, voucher.Code, "")
+}
+
+// locateLoyaltyRedemption reads the points a request turns into money of
+// the wallet in the uri, at the rate of the currency of the wallet
+func (server *Server) locateLoyaltyRedemption(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var req redeemLoyaltyPointsRequest
+	if json.Unmarshal(body, &req) != nil || !req.Points.IsPositive() {
+		return nil, nil
+	}
+	target, err := server.locateWallet(ctx, models.ZeroMoney, "", "")
+	if target == nil || err != nil {
+		return nil, err
+	}
+	rate, ok := server.loyaltyRates[target.Wallet.CurrencyCode()]
+	if !ok {
+		return nil, nil
+	}
+	precision := int32(2)
+	if currency, ok := models.LookupCurrency(target.Wallet.CurrencyCode()); ok {
+		precision = currency.Precision
+	}
+	target.Amount = models.RoundDown.Round(models.NewMoney(req.Points.Mul(rate)), precision)
+	if !target.Amount.IsPositive() {
+		return nil, nil
+	}
+	return target, nil
+}
+
+// locateFXQuote reads the conversion out of the source wallet of the quote
+// in the uri
+func (server *Server) locateFXQuote(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var param quoteIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		return nil, nil
+	}
+	quote, err := server.repo.GetFXQuote(param.QuoteID)
+	if errors.Is(err, util.ErrQuoteNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := server.repo.GetWallet(quote.FromWalletID)
+	if errors.Is(err, util.ErrWalletNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.FraudTarget{Wallet: wallet, Amount: quote.Amount}, nil
+}
+
+// locateTournamentEntry reads the entry fee of the tournament in the uri
+// that the wallet in the body pays, the tournament is kept as the reference
+// of a review
+func (server *Server) locateTournamentEntry(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var param tournamentIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		return nil, nil
+	}
+	var req enterTournamentRequest
+	if json.Unmarshal(body, &req) != nil || req.WalletID <= 0 {
+		return nil, nil
+	}
+	tournament, err := server.repo.GetTournament(param.ID)
+	if errors.Is(err, util.ErrTournamentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !tournament.EntryFee.IsPositive() {
+		return nil, nil
+	}
+	wallet, err := server.repo.GetWallet(req.WalletID)
+	if errors.Is(err, util.ErrWalletNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.FraudTarget{
+		Wallet:    wallet,
+		Amount:    tournament.EntryFee,
+		Reference: strconv.FormatInt(tournament.ID, 10),
+	}, nil
+}
+
+// locateRoundBet reads a bet the game server of a provider places on the
+// wallet in the uri, on behalf of the owner of the wallet
+func (server *Server) locateRoundBet(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var req gameRoundRequest
+	if json.Unmarshal(body, &req) != nil || !req.Amount.IsPositive() {
+		return nil, nil
+	}
+	target, err := server.locateWallet(ctx, req.Amount, req.RoundID, req.Category)
+	if target == nil || err != nil {
+		return nil, err
+	}
+	return server.locatePlayer(target)
+}
+
+// locateProviderDebit reads the stake of an aggregator debit callback
+func (server *Server) locateProviderDebit(ctx *gin.Context, body []byte) (*middleware.FraudTarget, error) {
+	var req aggregator.TransactionRequest
+	if json.Unmarshal(body, &req) != nil || !req.Amount.IsPositive() {
+		return nil, nil
+	}
+	wallet, err := server.getPlayerWallet(req.PlayerID, req.Currency)
+	if errors.Is(err, util.ErrWalletNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return server.locatePlayer(&middleware.FraudTarget{
+		Wallet:    wallet,
+		Amount:    req.Amount,
+		Reference: req.RoundID,
+		Category:  req.Category,
+	})
+}
+
+// locateWallet is the target of a request moving amount in or out of the
+// wallet in the uri
+func (server *Server) locateWallet(ctx *gin.Context, amount models.Money, reference, category string) (*middleware.FraudTarget, error) {
+	var param walletIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		return nil, nil
+	}
+	wallet, err := server.repo.GetWallet(param.WalletID)
+	if errors.Is(err, util.ErrWalletNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.FraudTarget{
+		Wallet:    wallet,
+		Amount:    amount,
+		Reference: reference,
+		Category:  category,
+	}, nil
+}
+
+// locatePlayer looks up the owner of the wallet of target, the requests of
+// game servers are not made by a logged in user
+func (server *Server) locatePlayer(target *middleware.FraudTarget) (*middleware.FraudTarget, error) {
+	user, err := server.repo.GetUser(target.Wallet.UserID)
+	if errors.Is(err, util.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	target.Email = user.Email
+	return target, nil
+}
+
+// refuseProviderScreened answers a callback the fraud rules turned away in
+// the protocol of the aggregator
+func refuseProviderScreened(ctx *gin.Context, status int, err error) {
+	code := aggregator.ErrorCodeInternalError
+	switch {
+	case errors.Is(err, middleware.ErrOperationBlocked):
+		code = aggregator.ErrorCodeOperationBlocked
+	case status == http.StatusBadRequest:
+		code = aggregator.ErrorCodeInvalidRequest
+	}
+	ctx.AbortWithStatusJSON(status, aggregator.ErrorResponse(code, err))
+}
+
+// recordLogin keeps an attempt to log in as email for the fraud rules
+func (server *Server) recordLogin(ctx *gin.Context, email string, succeeded bool) error {
+	return server.repo.RecordLogin(&models.LoginEvent{
+		Email:     email,
+		IP:        ctx.ClientIP(),
+		Succeeded: succeeded,
+		CreatedAt: time.Now(),
+	})
+}
+
+// replayRecorder keeps the answer to a replayed request
+type replayRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *replayRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *replayRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *replayRecorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+func fraudErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrFraudReviewNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrFraudReviewResolved),
+		errors.Is(err, util.ErrFraudReviewTransition):
+		return http.StatusConflict
+	default:
+		return postingErrorStatus(err)
+	}
+}
@@ -0,0 +1,422 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fraudLookupMatcher matches the journal lookups of the fraud rules, which
+// unlike the velocity checks always ask for one type of entry
+type fraudLookupMatcher struct{}
+
+func fraudLookup() gomock.Matcher {
+	return fraudLookupMatcher{}
+}
+
+func (fraudLookupMatcher) Matches(x interface{}) bool {
+	filter, ok := x.(*database.TransactionFilter)
+	return ok && filter.Type != ""
+}
+
+func (fraudLookupMatcher) String() string {
+	return "is a fraud rule lookup"
+}
+
+// stubNoFraud lets a credit or debit of wallet through the fraud rules, the
+// wallet has no history and its owner no logins on record
+func stubNoFraud(mockRepo *mockdb.MockRepository, wallet *models.Wallet) {
+	mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+	mockRepo.EXPECT().GetTransactionsByWallet(fraudLookup()).AnyTimes().Return(nil, nil)
+	mockRepo.EXPECT().GetLoginEvents(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+}
+
+// stubNoPlayerFraud lets an operation a game server makes for user on
+// wallet through the fraud rules, which look the player up by the wallet
+func stubNoPlayerFraud(mockRepo *mockdb.MockRepository, user *models.User, wallet *models.Wallet) {
+	stubNoFraud(mockRepo, wallet)
+	mockRepo.EXPECT().GetUser(gomock.Eq(user.ID)).Times(1).Return(user, nil)
+}
+
+// stubMovedReview moves review to each of statuses in turn on behalf of
+// reviewer, a review going back to pending has no reviewer
+func stubMovedReview(t *testing.T, mockRepo *mockdb.MockRepository, review *models.FraudReview, reviewer string, statuses ...models.FraudReviewStatus) {
+	calls := make([]*gomock.Call, 0, len(statuses))
+	for _, status := range statuses {
+		status := status
+		calls = append(calls, mockRepo.EXPECT().
+			MoveFraudReview(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ context.Context, c *database.FraudReviewChange) (*models.FraudReview, error) {
+				require.Equal(t, review.ID, c.ReviewID)
+				require.Equal(t, status, c.Status)
+				if status != models.FraudReviewStatusPending {
+					require.Equal(t, reviewer, c.Reviewer)
+				}
+				moved := *review
+				moved.Status = status
+				return &moved, nil
+			}))
+	}
+	gomock.InOrder(calls...)
+}
+
+// stubForgottenKey removes the answer to a refused request of a review
+// sent through again so that it can be sent once more
+func stubForgottenKey(t *testing.T, mockRepo *mockdb.MockRepository, review *models.FraudReview, user *models.User) {
+	key := &models.IdempotencyKey{ID: 7, Scope: user.Email, Key: "fraud-review:" + review.UUID.String()}
+	mockRepo.EXPECT().GetIdempotencyKey(gomock.Eq(user.Email), gomock.Eq(key.Key)).Times(1).Return(key, nil)
+	mockRepo.EXPECT().DeleteIdempotencyKey(gomock.Eq(key.ID)).Times(1).Return(nil)
+}
+
+// stubReplayedKey stores the answer to the request of a review sent
+// through again under its idempotency key
+func stubReplayedKey(t *testing.T, mockRepo *mockdb.MockRepository, review *models.FraudReview, user *models.User) {
+	mockRepo.EXPECT().
+		CreateIdempotencyKey(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(key *models.IdempotencyKey) error {
+			require.Equal(t, user.Email, key.Scope)
+			require.Equal(t, "fraud-review:"+review.UUID.String(), key.Key)
+			return nil
+		})
+	mockRepo.EXPECT().UpdateIdempotencyKey(gomock.Any()).Times(1).Return(nil)
+}
+
+func Test_reviewFraudReview(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	review := &models.FraudReview{
+		ID:        1,
+		UUID:      uuid.New(),
+		WalletID:  wallet.ID,
+		UserID:    user.ID,
+		Operation: models.TransactionTypeDebit,
+		Method:    http.MethodPost,
+		Path:      fmt.Sprintf("/api/v1/wallets/%d/debit", wallet.ID),
+		Body:      `{"amount":40}`,
+		Currency:  models.DefaultCurrency,
+		Amount:    models.NewMoneyFromInt(40),
+		Actor:     user.Email,
+		Status:    models.FraudReviewStatusPending,
+	}
+
+	testCases := []struct {
+		name          string
+		action        string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "should make an approved operation on behalf of the player",
+			action: "approve",
+			body:   gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				stubMovedReview(t, mockRepo, review, admin.Email, models.FraudReviewStatusApproving, models.FraudReviewStatusApproved)
+				stubReplayedKey(t, mockRepo, review, user)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
+				debited := *wallet
+				debited.Balance = wallet.Balance.Sub(review.Amount)
+				mockRepo.EXPECT().
+					AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), eqMoney(review.Amount.Neg()), gomock.Eq(database.NonNegativeBalance), eqTransaction(&models.Transaction{
+						Type:  models.TransactionTypeDebit,
+						Actor: user.Email,
+					})).
+					Times(1).
+					Return(&debited, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"approved"`)
+				require.Contains(t, recorder.Body.String(), `"balance":"60"`)
+			},
+		},
+		{
+			name:   "should keep the review pending when the player was restricted since",
+			action: "approve",
+			body:   gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				stubMovedReview(t, mockRepo, review, admin.Email, models.FraudReviewStatusApproving, models.FraudReviewStatusPending)
+				stubReplayedKey(t, mockRepo, review, user)
+				stubForgottenKey(t, mockRepo, review, user)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+				restricted := *user
+				restricted.Restriction = models.RestrictionSelfExclusion
+				mockRepo.EXPECT().GetUser(gomock.Eq(user.ID)).Times(1).Return(&restricted, nil)
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrAccountRestricted.Error())
+			},
+		},
+		{
+			name:   "should keep the review pending when the operation breaks a velocity rule",
+			action: "approve",
+			body:   gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				stubMovedReview(t, mockRepo, review, admin.Email, models.FraudReviewStatusApproving, models.FraudReviewStatusPending)
+				stubReplayedKey(t, mockRepo, review, user)
+				stubForgottenKey(t, mockRepo, review, user)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+				stubPlayer(mockRepo, user)
+				stubVelocityRule(mockRepo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxDebit: models.NewMoneyFromInt(25)})
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder, ErrorCodeMaxDebitExceeded)
+			},
+		},
+		{
+			name:   "should deny an operation without touching the wallet",
+			action: "deny",
+			body:   gin.H{"note": "stolen card"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					MoveFraudReview(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, c *database.FraudReviewChange) (*models.FraudReview, error) {
+						require.Equal(t, models.FraudReviewStatusDenied, c.Status)
+						require.Equal(t, "stolen card", c.Note)
+						denied := *review
+						denied.Status = models.FraudReviewStatusDenied
+						return &denied, nil
+					})
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"denied"`)
+			},
+		},
+		{
+			name:   "should reject a review that was already resolved",
+			action: "approve",
+			body:   gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					MoveFraudReview(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrFraudReviewResolved)
+				mockRepo.EXPECT().CreateIdempotencyKey(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:   "should not deny a review while it is being approved",
+			action: "deny",
+			body:   gin.H{"note": "stolen card"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					MoveFraudReview(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrFraudReviewTransition)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrFraudReviewTransition.Error())
+			},
+		},
+		{
+			name:   "should require a note to deny",
+			action: "deny",
+			body:   gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().MoveFraudReview(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/fraud/reviews/%d/%s", review.ID, tt.action)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, admin.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestApproveFraudReviewAgain(t *testing.T) {
+	repo := database.NewInMemory()
+	player := randomUser()
+	admin := randomUser()
+	admin.ID = 2
+	admin.IsAdmin = true
+	require.NoError(t, repo.CreateUser(player))
+	require.NoError(t, repo.CreateUser(admin))
+	walletID, err := repo.CreateWallet(&models.Wallet{
+		UUID:     uuid.New(),
+		UserID:   player.ID,
+		Currency: models.DefaultCurrency,
+		Balance:  models.NewMoneyFromInt(10),
+	})
+	require.NoError(t, err)
+	review := &models.FraudReview{
+		UUID:      uuid.New(),
+		WalletID:  walletID,
+		UserID:    player.ID,
+		Operation: models.TransactionTypeDebit,
+		Method:    http.MethodPost,
+		Path:      fmt.Sprintf("/api/v1/wallets/%d/debit", walletID),
+		Body:      `{"amount":40}`,
+		Currency:  models.DefaultCurrency,
+		Amount:    models.NewMoneyFromInt(40),
+		Actor:     player.Email,
+		Status:    models.FraudReviewStatusPending,
+	}
+	require.NoError(t, repo.CreateFraudReview(review))
+
+	cache := mockcache.NewMockCacher(gomock.NewController(t))
+	cache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	server, err := NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	approve := func() *httptest.ResponseRecorder {
+		request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/fraud/reviews/%d/approve", review.ID), http.NoBody)
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, admin.Email, time.Minute)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// the player cannot afford the debit yet
+	recorder := approve()
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Contains(t, recorder.Body.String(), ErrInsufficientBalance.Error())
+	pending, err := repo.GetFraudReview(review.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.FraudReviewStatusPending, pending.Status)
+
+	_, err = repo.AdjustBalance(context.Background(), walletID, models.NewMoneyFromInt(50), database.NonNegativeBalance, &models.Transaction{
+		UUID: uuid.New(),
+		Type: models.TransactionTypeCredit,
+	})
+	require.NoError(t, err)
+
+	// the refusal was not kept, so the debit goes through once affordable
+	recorder = approve()
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"status":"approved"`)
+	wallet, err := repo.GetWallet(walletID)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(20)))
+
+	recorder = approve()
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	wallet, err = repo.GetWallet(walletID)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(20)))
+}
+
+func TestScreenEntryAndDeposit(t *testing.T) {
+	repo := database.NewInMemory()
+	player := randomUser()
+	require.NoError(t, repo.CreateUser(player))
+	walletID, err := repo.CreateWallet(&models.Wallet{
+		UUID:     uuid.New(),
+		UserID:   player.ID,
+		Currency: models.DefaultCurrency,
+		Balance:  models.NewMoneyFromInt(100),
+	})
+	require.NoError(t, err)
+	tournament := randomTournament()
+	require.NoError(t, repo.CreateTournament(tournament))
+	// failed logins flag whatever the player does next
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.RecordLogin(&models.LoginEvent{Email: player.Email, CreatedAt: time.Now()}))
+	}
+
+	server, err := NewServer(repo, mockcache.NewMockCacher(gomock.NewController(t)), util.RandomString(32))
+	require.NoError(t, err)
+	send := func(url string, body gin.H) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, player.Email, time.Minute)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := send(fmt.Sprintf("/api/v1/tournaments/%d/enter", tournament.ID), gin.H{"wallet_id": walletID})
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	recorder = send(fmt.Sprintf("/api/v1/wallets/%d/deposits", walletID), gin.H{"amount": "50"})
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	// both wait for a review and neither went ahead
+	reviews, err := repo.GetFraudReviews(models.FraudReviewStatusPending)
+	require.NoError(t, err)
+	require.Len(t, reviews, 2)
+	require.Equal(t, models.TransactionTypeTournamentEntry, reviews[0].Operation)
+	require.True(t, reviews[0].Amount.Equal(tournament.EntryFee))
+	require.Equal(t, fmt.Sprintf("%d", tournament.ID), reviews[0].Reference)
+	require.Equal(t, models.TransactionTypeCredit, reviews[1].Operation)
+	require.True(t, reviews[1].Amount.Equal(models.NewMoneyFromInt(50)))
+
+	entered, err := repo.GetTournament(tournament.ID)
+	require.NoError(t, err)
+	require.Zero(t, entered.Entrants)
+	deposits, err := repo.GetDeposits("")
+	require.NoError(t, err)
+	require.Empty(t, deposits)
+	wallet, err := repo.GetWallet(walletID)
+	require.NoError(t, err)
+	require.True(t, wallet.Balance.Equal(models.NewMoneyFromInt(100)))
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/fx"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrConversionSameCurrency = errors.New("wallets are in the same currency, use a transfer instead")
+	ErrConversionTooSmall     = errors.New("amount is too small to convert")
+	ErrQuoteNotFound          = errors.New("quote not found")
+)
+
+type quoteIDUriBinding struct {
+	QuoteID int64 `uri:"quote_id" binding:"required,min=1"`
+}
+
+type fxQuoteResponse struct {
+	ID              int64       `json:"id"`
+	UUID            uuid.UUID   `json:"uuid"`
+	FromWalletID    int64       `json:"from_wallet_id"`
+	ToWalletID      int64       `json:"to_wallet_id"`
+	FromCurrency    string      `json:"from_currency"`
+	ToCurrency      string      `json:"to_currency"`
+	Rate            string      `json:"rate"`
+	Amount          string      `json:"amount"`
+	ConvertedAmount string      `json:"converted_amount"`
+	Fee             feeResponse `json:"fee"`
+	ExpiresAt       time.Time   `json:"expires_at"`
+	ExecutedAt      *time.Time  `json:"executed_at"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+func newFXQuoteResponse(quote *models.FXQuote) fxQuoteResponse {
+	return fxQuoteResponse{
+		ID:              quote.ID,
+		UUID:            quote.UUID,
+		FromWalletID:    quote.FromWalletID,
+		ToWalletID:      quote.ToWalletID,
+		FromCurrency:    quote.FromCurrency,
+		ToCurrency:      quote.ToCurrency,
+		Rate:            quote.Rate.String(),
+		Amount:          quote.Amount.String(),
+		ConvertedAmount: quote.ConvertedAmount.String(),
+		Fee:             newFeeResponse(quote.Amount, quote.Fee),
+		ExpiresAt:       quote.ExpiresAt,
+		ExecutedAt:      quote.ExecutedAt,
+		CreatedAt:       quote.CreatedAt,
+	}
+}
+
+type createFXQuoteRequest struct {
+	FromWalletID int64        `json:"from_wallet_id" binding:"required,min=1"`
+	ToWalletID   int64        `json:"to_wallet_id" binding:"required,min=1"`
+	Amount       models.Money `json:"amount"`
+}
+
+func (server *Server) createFXQuote(ctx *gin.Context) {
+	var req createFXQuoteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	// conversions are only allowed between wallets of the same user
+	from, err := server.getUserWallet(user, req.FromWalletID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	to, err := server.getUserWallet(user, req.ToWalletID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if from.CurrencyCode() == to.CurrencyCode() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrConversionSameCurrency))
+		return
+	}
+	fromCurrency, ok := models.LookupCurrency(from.CurrencyCode())
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	toCurrency, ok := models.LookupCurrency(to.CurrencyCode())
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	amount := req.Amount
+	if !fromCurrency.Fits(amount) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	rate, err := server.rates.Rate(ctx, fromCurrency.Code, toCurrency.Code)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	// the fee is charged in the source currency and only the rest is
+	// converted, the quote locks the fee along with the rate
+	fee, feeWalletID, err := server.quoteFee(models.FeeOperationConversion, fromCurrency.Code, amount)
+	if err != nil {
+		ctx.JSON(feeErrorStatus(err), errorResponse(err))
+		return
+	}
+	// any fraction below the smallest unit of the target currency is
+	// rounded with the configured policy
+	converted := toCurrency.Round(amount.Sub(fee).Mul(rate), server.rounding)
+	if !converted.IsPositive() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrConversionTooSmall))
+		return
+	}
+	now := time.Now()
+	quote := &models.FXQuote{
+		UUID:            uuid.New(),
+		UserID:          user.ID,
+		FromWalletID:    from.ID,
+		ToWalletID:      to.ID,
+		FromCurrency:    fromCurrency.Code,
+		ToCurrency:      toCurrency.Code,
+		Rate:            rate,
+		Amount:          amount,
+		ConvertedAmount: converted,
+		Fee:             fee,
+		FeeWalletID:     feeWalletID,
+		ExpiresAt:       now.Add(fxQuoteTTL()),
+		CreatedAt:       now,
+	}
+	if err := server.repo.CreateFXQuote(quote); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, "", newFXQuoteResponse(quote))
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (server *Server) getFXQuote(ctx *gin.Context) {
+	quote, ok := server.getUserFXQuote(ctx)
+	if !ok {
+		return
+	}
+	response := util.BuildResponseEntity(true, "", newFXQuoteResponse(quote))
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) executeFXQuote(ctx *gin.Context) {
+	quote, ok := server.getUserFXQuote(ctx)
+	if !ok {
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	from, err := server.repo.GetWallet(quote.FromWalletID)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), errorResponse(err))
+		return
+	}
+	velocity, err := server.checkVelocity(ctx, from, quote.Amount)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	// both legs are linked to the quote, which holds the rate they used
+	conversionID := quote.UUID.String()
+	executed, wallets, err := server.repo.ExecuteFXQuote(ctx, quote.ID,
+		newTransaction(models.TransactionTypeConversionOut, "", conversionID, actor),
+		newTransaction(models.TransactionTypeConversionIn, "", conversionID, actor),
+	)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	server.recordVelocity(velocity)
+	for _, wallet := range wallets {
+		if err := server.cacheWalletBalance(ctx, wallet); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	response := util.BuildResponseEntity(true, util.ConversionSuccess, gin.H{
+		"quote":        newFXQuoteResponse(executed),
+		"from_balance": newWalletBalanceResponse(wallets[0]),
+		"to_balance":   newWalletBalanceResponse(wallets[1]),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getUserFXQuote loads the quote in the uri if it belongs to the logged in
+// user. It writes the error response itself and reports whether the
+// handler can go on.
+func (server *Server) getUserFXQuote(ctx *gin.Context) (*models.FXQuote, bool) {
+	var param quoteIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, false
+	}
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return nil, false
+	}
+	quote, err := server.repo.GetFXQuote(param.QuoteID)
+	if errors.Is(err, util.ErrQuoteNotFound) || (err == nil && quote.UserID != user.ID) {
+		ctx.JSON(http.StatusNotFound, errorResponse(ErrQuoteNotFound))
+		return nil, false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	return quote, true
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/fx"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedRates is a rate provider with rates keyed by "FROM/TO"
+type fixedRates map[string]decimal.Decimal
+
+func (r fixedRates) Rate(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	rate, ok := r[from+"/"+to]
+	if !ok {
+		return decimal.Decimal{}, fx.ErrRateNotFound
+	}
+	return rate, nil
+}
+
+func Test_createFXQuote(t *testing.T) {
+	user := randomUser()
+	usd := randomWallet(user.ID)
+	jpy := randomWallet(user.ID)
+	jpy.ID = usd.ID + 1
+	jpy.Currency = "JPY"
+	eur := randomWallet(user.ID)
+	eur.ID = usd.ID + 2
+	eur.Currency = "EUR"
+
+	rates := fixedRates{"USD/JPY": decimal.RequireFromString("151.257")}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should lock the rate and round the converted amount down",
+			body: gin.H{"from_wallet_id": usd.ID, "to_wallet_id": jpy.ID, "amount": "10.25"},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(usd.ID)).Times(1).Return(usd, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(jpy.ID)).Times(1).Return(jpy, nil)
+				stubNoFee(mockRepo, models.FeeOperationConversion)
+				mockRepo.EXPECT().
+					CreateFXQuote(gomock.Any()).
+					Times(1).
+					DoAndReturn(func(quote *models.FXQuote) error {
+						require.Equal(t, user.ID, quote.UserID)
+						require.True(t, quote.Rate.Equal(rates["USD/JPY"]))
+						// 10.25 * 151.257 = 1550.38425, yen have no minor unit
+						require.True(t, quote.ConvertedAmount.Equal(models.NewMoneyFromInt(1550)))
+						require.True(t, quote.ExpiresAt.After(time.Now()))
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"converted_amount":"1550"`)
+			},
+		},
+		{
+			name: "should reject a pair without a rate",
+			body: gin.H{"from_wallet_id": usd.ID, "to_wallet_id": eur.ID, "amount": 10},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(usd.ID)).Times(1).Return(usd, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(eur.ID)).Times(1).Return(eur, nil)
+				mockRepo.EXPECT().CreateFXQuote(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), fx.ErrRateNotFound.Error())
+			},
+		},
+		{
+			name: "should reject converting into a wallet of another user",
+			body: gin.H{"from_wallet_id": usd.ID, "to_wallet_id": jpy.ID, "amount": 10},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				other := *jpy
+				other.UserID = user.ID + 1
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(usd.ID)).Times(1).Return(usd, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(jpy.ID)).Times(1).Return(&other, nil)
+				mockRepo.EXPECT().CreateFXQuote(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrWalletNotBelongsToUser.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tt.buildStubs(repo)
+
+			server, err := NewServer(repo, mockcache.NewMockCacher(ctrl), util.RandomString(32))
+			require.NoError(t, err)
+			server.rates = rates
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/api/v1/fx/quotes", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func Test_executeFXQuote(t *testing.T) {
+	user := randomUser()
+	usd := randomWallet(user.ID)
+	jpy := randomWallet(user.ID)
+	jpy.ID = usd.ID + 1
+	jpy.Currency = "JPY"
+	quote := &models.FXQuote{
+		ID:              1,
+		UUID:            uuid.New(),
+		UserID:          user.ID,
+		FromWalletID:    usd.ID,
+		ToWalletID:      jpy.ID,
+		FromCurrency:    "USD",
+		ToCurrency:      "JPY",
+		Rate:            decimal.NewFromInt(150),
+		Amount:          models.NewMoneyFromInt(10),
+		ConvertedAmount: models.NewMoneyFromInt(1500),
+		ExpiresAt:       time.Now().Add(time.Minute),
+	}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should record the conversion as a linked pair",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetFXQuote(gomock.Eq(quote.ID)).Times(2).Return(quote, nil)
+				stubNoFraud(mockRepo, usd)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(usd.ID)).Times(1).Return(usd, nil)
+				stubNoVelocity(mockRepo)
+				debit := &models.Transaction{Type: models.TransactionTypeConversionOut, CorrelationID: quote.UUID.String(), Actor: user.Email}
+				credit := &models.Transaction{Type: models.TransactionTypeConversionIn, CorrelationID: quote.UUID.String(), Actor: user.Email}
+				mockRepo.EXPECT().
+					ExecuteFXQuote(gomock.Any(), gomock.Eq(quote.ID), eqTransaction(debit), eqTransaction(credit)).
+					Times(1).
+					Return(quote, []*models.Wallet{usd, jpy}, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "should reject an expired quote",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetFXQuote(gomock.Eq(quote.ID)).Times(2).Return(quote, nil)
+				stubNoFraud(mockRepo, usd)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(usd.ID)).Times(1).Return(usd, nil)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ExecuteFXQuote(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrQuoteExpired)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrQuoteExpired.Error())
+			},
+		},
+		{
+			name: "should not execute the quote of another user",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				other := *quote
+				other.UserID = user.ID + 1
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetFXQuote(gomock.Eq(quote.ID)).Times(2).Return(&other, nil)
+				stubNoFraud(mockRepo, usd)
+				mockRepo.EXPECT().ExecuteFXQuote(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/api/v1/fx/quotes/%d/execute", quote.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
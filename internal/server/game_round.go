@@ -0,0 +1,294 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGameRoundNotFound = errors.New("game round not found")
+)
+
+type gameRoundResponse struct {
+	ID        int64     `json:"id"`
+	UUID      uuid.UUID `json:"uuid"`
+	WalletID  int64     `json:"wallet_id"`
+	Provider  string    `json:"provider"`
+	RoundID   string    `json:"round_id"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	TotalBet  string    `json:"total_bet"`
+	TotalWin  string    `json:"total_win"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newGameRoundResponse(round *models.GameRound) gameRoundResponse {
+	return gameRoundResponse{
+		ID:        round.ID,
+		UUID:      round.UUID,
+		WalletID:  round.WalletID,
+		Provider:  round.Provider,
+		RoundID:   round.RoundID,
+		Currency:  round.Currency,
+		Status:    string(round.Status),
+		TotalBet:  round.TotalBet.String(),
+		TotalWin:  round.TotalWin.String(),
+		CreatedAt: round.CreatedAt,
+		UpdatedAt: round.UpdatedAt,
+	}
+}
+
+type gameRoundActionResponse struct {
+	ID            int64     `json:"id"`
+	TransactionID string    `json:"transaction_id"`
+	Type          string    `json:"type"`
+	Amount        string    `json:"amount"`
+	RollbackOf    string    `json:"rollback_of,omitempty"`
+	RolledBack    bool      `json:"rolled_back"`
+	JournalID     int64     `json:"journal_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newGameRoundActionResponse(action *models.GameRoundAction) gameRoundActionResponse {
+	return gameRoundActionResponse{
+		ID:            action.ID,
+		TransactionID: action.ProviderTransactionID,
+		Type:          string(action.Type),
+		Amount:        action.Amount.String(),
+		RollbackOf:    action.RollbackOf,
+		RolledBack:    action.RolledBack,
+		JournalID:     action.TransactionID,
+		CreatedAt:     action.CreatedAt,
+	}
+}
+
+// gameRoundRequest is sent for bets and wins. TransactionID is the id the
+// provider gave the action, sending it again does not move funds twice.
+type gameRoundRequest struct {
+	RoundID       string       `json:"round_id" binding:"required,max=128"`
+	TransactionID string       `json:"transaction_id" binding:"required,max=128"`
+	Amount        models.Money `json:"amount"`
+	// Currency is optional, when it is sent it must be the wallet currency
+	Currency string `json:"currency"`
+	// Category is the game category of a bet, it decides the loyalty points
+	// the bet earns
+	Category string `json:"category" binding:"max=32"`
+}
+
+type rollbackBetRequest struct {
+	RoundID       string `json:"round_id" binding:"required,max=128"`
+	TransactionID string `json:"transaction_id" binding:"required,max=128"`
+	// BetTransactionID is the provider transaction id of the bet to cancel
+	BetTransactionID string `json:"bet_transaction_id" binding:"required,max=128"`
+}
+
+type getGameRoundRequest struct {
+	Provider string `form:"provider" binding:"required"`
+	RoundID  string `form:"round_id" binding:"required"`
+}
+
+// The round routes are called by the game server of a provider, which signs
+// its requests the way the aggregator callbacks are signed. The provider of
+// the round is the one that signed the request.
+
+func (server *Server) placeBet(ctx *gin.Context) {
+	wallet, ok := server.getRoundWallet(ctx)
+	if !ok {
+		return
+	}
+	var req gameRoundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// a restricted player cannot stake
+	if err := server.checkPlayAllowed(wallet); err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	velocity, err := server.checkVelocity(ctx, wallet, req.Amount)
+	if err != nil {
+		ctx.JSON(gameRoundErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	action := &models.GameRoundAction{
+		ProviderTransactionID: req.TransactionID,
+		Type:                  models.GameRoundActionBet,
+		Amount:                req.Amount,
+	}
+	server.playGameRound(ctx, wallet, req.RoundID, action, models.TransactionTypeBet, req.Category, velocity, util.GameBetSuccess)
+}
+
+func (server *Server) payWin(ctx *gin.Context) {
+	wallet, ok := server.getRoundWallet(ctx)
+	if !ok {
+		return
+	}
+	var req gameRoundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// a win of zero settles a round that was lost
+	if req.Amount.IsNegative() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidAmount))
+		return
+	}
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	action := &models.GameRoundAction{
+		ProviderTransactionID: req.TransactionID,
+		Type:                  models.GameRoundActionWin,
+		Amount:                req.Amount,
+	}
+	server.playGameRound(ctx, wallet, req.RoundID, action, models.TransactionTypeWin, "", nil, util.GameWinSuccess)
+}
+
+func (server *Server) rollbackBet(ctx *gin.Context) {
+	wallet, ok := server.getRoundWallet(ctx)
+	if !ok {
+		return
+	}
+	var req rollbackBetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	action := &models.GameRoundAction{
+		ProviderTransactionID: req.TransactionID,
+		Type:                  models.GameRoundActionRollback,
+		RollbackOf:            req.BetTransactionID,
+	}
+	server.playGameRound(ctx, wallet, req.RoundID, action, models.TransactionTypeRollback, "", nil, util.GameRollbackSuccess)
+}
+
+// playGameRound applies the action to the round and writes the response, a
+// repeated action is answered with the outcome of the first one. category
+// is the game category of a bet and velocity the check it passed.
+func (server *Server) playGameRound(
+	ctx *gin.Context,
+	wallet *models.Wallet,
+	roundID string,
+	action *models.GameRoundAction,
+	txnType models.TransactionType,
+	category string,
+	velocity *velocityCheck,
+	message string,
+) {
+	provider := ctx.GetString(middleware.ProviderPayloadKey)
+	action.Provider = provider
+	// the journal entry carries the provider round id as its reference and
+	// the provider as its actor
+	txn := newTransaction(txnType, roundID, "", "provider:"+provider)
+	txn.Category = category
+	result, err := server.repo.ApplyGameRoundAction(ctx, wallet.ID, roundID, action, txn)
+	if err != nil {
+		ctx.JSON(gameRoundErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if !result.Duplicate {
+		server.recordVelocity(velocity)
+		if err := server.cacheWalletBalance(ctx, result.Wallet); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	response := util.BuildResponseEntity(true, message, gin.H{
+		"round":     newGameRoundResponse(result.Round),
+		"action":    newGameRoundActionResponse(result.Action),
+		"balance":   newWalletBalanceResponse(result.Wallet),
+		"duplicate": result.Duplicate,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getRoundWallet returns the wallet in the uri of a round route, it writes
+// the error response when there is none
+func (server *Server) getRoundWallet(ctx *gin.Context) (*models.Wallet, bool) {
+	var walletID walletIDUriBinding
+	if err := ctx.ShouldBindUri(&walletID); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, false
+	}
+	wallet, err := server.repo.GetWallet(walletID.WalletID)
+	if err != nil {
+		ctx.JSON(gameRoundErrorStatus(err), errorResponse(err))
+		return nil, false
+	}
+	return wallet, true
+}
+
+func (server *Server) getGameRound(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req getGameRoundRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	round, err := server.repo.GetGameRound(req.Provider, req.RoundID)
+	// a round on another wallet is reported as missing rather than forbidden
+	if errors.Is(err, util.ErrGameRoundNotFound) || (err == nil && round.WalletID != wallet.ID) {
+		ctx.JSON(http.StatusNotFound, errorResponse(ErrGameRoundNotFound))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	actions, err := server.repo.GetGameRoundActions(round.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]gameRoundActionResponse, 0, len(actions))
+	for _, action := range actions {
+		data = append(data, newGameRoundActionResponse(action))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"round":   newGameRoundResponse(round),
+		"actions": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func gameRoundErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrGameRoundNotFound),
+		errors.Is(err, util.ErrGameRoundActionNotFound),
+		errors.Is(err, util.ErrJackpotNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrGameRoundClosed),
+		errors.Is(err, util.ErrGameRoundBetAlreadyRolled),
+		errors.Is(err, util.ErrGameRoundActionConflict),
+		errors.Is(err, util.ErrGameRoundWalletMismatch),
+		errors.Is(err, util.ErrJackpotHitConflict):
+		return http.StatusConflict
+	default:
+		return postingErrorStatus(err)
+	}
+}
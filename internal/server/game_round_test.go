@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/aggregator"
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func randomGameRound(walletID int64) *models.GameRound {
+	return &models.GameRound{
+		ID:       1,
+		UUID:     uuid.New(),
+		WalletID: walletID,
+		Provider: "acme",
+		RoundID:  util.RandomString(8),
+		Currency: models.DefaultCurrency,
+		Status:   models.GameRoundStatusOpen,
+		TotalBet: models.NewMoneyFromInt(30),
+	}
+}
+
+// stubRoundWallet lets a provider play rounds on wallet
+func stubRoundWallet(mockRepo *mockdb.MockRepository, wallet *models.Wallet) {
+	mockRepo.EXPECT().
+		GetWallet(gomock.Eq(wallet.ID)).
+		Times(1).
+		Return(wallet, nil)
+}
+
+// signProviderRequest signs request the way the game server of provider
+// acme does, the server must be set up with setProviderSecrets
+func signProviderRequest(request *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(aggregator.ProviderHeader, "acme")
+	request.Header.Set(aggregator.TimestampHeader, timestamp)
+	request.Header.Set(aggregator.SignatureHeader, aggregator.Sign("s3cret", timestamp, body))
+}
+
+func setProviderSecrets(t *testing.T) {
+	t.Setenv("PROVIDER_SECRETS", "acme:s3cret")
+}
+
+func Test_placeBet(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	round := randomGameRound(wallet.ID)
+	body := gin.H{
+		"round_id":       round.RoundID,
+		"transaction_id": "tx-1",
+		"amount":         "30",
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		signed        bool
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "should place the bet on the round",
+			body:   body,
+			signed: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubRoundWallet(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
+				txn := &models.Transaction{
+					Type:      models.TransactionTypeBet,
+					Reference: round.RoundID,
+					Actor:     "provider:acme",
+				}
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Eq(wallet.ID), gomock.Eq(round.RoundID), gomock.Any(), eqTransaction(txn)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, _ int64, _ string, action *models.GameRoundAction, _ *models.Transaction) (*database.RoundResult, error) {
+						require.Equal(t, models.GameRoundActionBet, action.Type)
+						require.Equal(t, "acme", action.Provider)
+						require.Equal(t, "tx-1", action.ProviderTransactionID)
+						require.True(t, action.Amount.Equal(models.NewMoneyFromInt(30)))
+						return &database.RoundResult{Round: round, Action: action, Wallet: wallet}, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), fmt.Sprintf("%d", wallet.ID), cachedBalance(t, wallet), 100*time.Second).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"duplicate":false`)
+				require.Contains(t, recorder.Body.String(), `"status":"open"`)
+			},
+		},
+		{
+			name:   "should answer a retried bet without touching the cache",
+			body:   body,
+			signed: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubRoundWallet(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
+				action := &models.GameRoundAction{ID: 1, Type: models.GameRoundActionBet, ProviderTransactionID: "tx-1"}
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(&database.RoundResult{Round: round, Action: action, Wallet: wallet, Duplicate: true}, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"duplicate":true`)
+			},
+		},
+		{
+			name:   "should reject a provider transaction id reused for another action",
+			body:   body,
+			signed: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubRoundWallet(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrGameRoundActionConflict)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:   "should reject a bet the wallet cannot cover",
+			body:   body,
+			signed: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubRoundWallet(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrInsufficientFunds)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrInsufficientBalance.Error())
+			},
+		},
+		{
+			name:   "should reject a bet of a restricted player",
+			body:   body,
+			signed: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubRoundWallet(mockRepo, wallet)
+				until := time.Now().Add(time.Hour)
+				restricted := *user
+				restricted.Restriction = models.RestrictionTimeOut
+				restricted.RestrictedUntil = &until
+				mockRepo.EXPECT().
+					GetUser(gomock.Eq(user.ID)).
+					Times(1).
+					Return(&restricted, nil)
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrAccountRestricted.Error())
+			},
+		},
+		{
+			name: "should reject a bet that no provider signed",
+			body: body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetWallet(gomock.Any()).Times(0)
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "should reject a bet without a provider transaction id",
+			body:   gin.H{"round_id": round.RoundID, "amount": "30"},
+			signed: true,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubRoundWallet(mockRepo, wallet)
+				mockRepo.EXPECT().
+					ApplyGameRoundAction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			// the fraud rules screen the bet before the handler sees it
+			if tt.signed {
+				stubNoPlayerFraud(repo, user, wallet)
+			}
+			tt.buildStubs(repo, cache)
+
+			setProviderSecrets(t)
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/provider/wallets/%d/rounds/bet", wallet.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			require.NoError(t, err)
+
+			if tt.signed {
+				signProviderRequest(request, body)
+			}
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func Test_rollbackBet(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	round := randomGameRound(wallet.ID)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	cache := mockcache.NewMockCacher(ctrl)
+
+	stubRoundWallet(repo, wallet)
+	repo.EXPECT().
+		ApplyGameRoundAction(gomock.Any(), gomock.Eq(wallet.ID), gomock.Eq(round.RoundID), gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, _ int64, _ string, action *models.GameRoundAction, _ *models.Transaction) (*database.RoundResult, error) {
+			require.Equal(t, models.GameRoundActionRollback, action.Type)
+			require.Equal(t, "tx-1", action.RollbackOf)
+			return nil, util.ErrGameRoundClosed
+		})
+
+	setProviderSecrets(t)
+	server, err := NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+
+	body, err := json.Marshal(gin.H{
+		"round_id":           round.RoundID,
+		"transaction_id":     "tx-2",
+		"bet_transaction_id": "tx-1",
+	})
+	require.NoError(t, err)
+
+	url := fmt.Sprintf("/provider/wallets/%d/rounds/rollback", wallet.ID)
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+
+	signProviderRequest(request, body)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, recorder.Body.String(), util.ErrGameRoundClosed.Error())
+}
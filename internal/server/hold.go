@@ -0,0 +1,257 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrHoldNotFound = errors.New("hold not found")
+)
+
+type holdIDUriBinding struct {
+	WalletID int64 `uri:"wallet_id" binding:"required,min=1"`
+	HoldID   int64 `uri:"hold_id" binding:"required,min=1"`
+}
+
+type holdResponse struct {
+	ID             int64     `json:"id"`
+	UUID           uuid.UUID `json:"uuid"`
+	WalletID       int64     `json:"wallet_id"`
+	Amount         string    `json:"amount"`
+	CapturedAmount string    `json:"captured_amount"`
+	Status         string    `json:"status"`
+	Reference      string    `json:"reference"`
+	Actor          string    `json:"actor"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func newHoldResponse(hold *models.Hold) holdResponse {
+	return holdResponse{
+		ID:             hold.ID,
+		UUID:           hold.UUID,
+		WalletID:       hold.WalletID,
+		Amount:         hold.Amount.String(),
+		CapturedAmount: hold.CapturedAmount.String(),
+		Status:         string(hold.Status),
+		Reference:      hold.Reference,
+		Actor:          hold.Actor,
+		ExpiresAt:      hold.ExpiresAt,
+		CreatedAt:      hold.CreatedAt,
+		UpdatedAt:      hold.UpdatedAt,
+	}
+}
+
+type createHoldRequest struct {
+	Amount    models.Money `json:"amount"`
+	Reference string       `json:"reference"`
+	// Currency is optional, when it is sent it must be the wallet currency
+	Currency string `json:"currency"`
+	// ExpiresIn is the lifetime of the hold in seconds, holds that are
+	// neither captured nor released by then are released automatically
+	ExpiresIn int64 `json:"expires_in" binding:"min=0"`
+}
+
+type captureHoldRequest struct {
+	// Amount defaults to the full held amount when it is left out
+	Amount *models.Money `json:"amount"`
+}
+
+type listHoldsRequest struct {
+	Status string `form:"status"`
+}
+
+func (server *Server) createHold(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req createHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// a restricted player cannot stake
+	if err := server.checkPlayAllowed(wallet); err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	ttl := holdDefaultTTL()
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	now := time.Now()
+	hold := &models.Hold{
+		UUID:      uuid.New(),
+		WalletID:  wallet.ID,
+		Amount:    req.Amount,
+		Status:    models.HoldStatusActive,
+		Reference: req.Reference,
+		Actor:     actor,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	w, err := server.repo.CreateHold(ctx, hold)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, w); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.HoldCreateSuccess, gin.H{
+		"hold":    newHoldResponse(hold),
+		"balance": newWalletBalanceResponse(w),
+	})
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (server *Server) captureHold(ctx *gin.Context) {
+	wallet, hold, ok := server.getWalletHold(ctx)
+	if !ok {
+		return
+	}
+	var req captureHoldRequest
+	// the body is optional, an empty one captures the full amount
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	amount := hold.Amount
+	if req.Amount != nil {
+		if err := validateRequestAmount(*req.Amount); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		amount = *req.Amount
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	velocity, err := server.checkVelocity(ctx, wallet, amount)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	// the journal entry is linked to the hold through its correlation id
+	txn := newTransaction(models.TransactionTypeHoldCapture, hold.Reference, hold.UUID.String(), actor)
+	captured, w, err := server.repo.CaptureHold(ctx, hold.ID, amount, txn)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	server.recordVelocity(velocity)
+	if err := server.cacheWalletBalance(ctx, w); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.HoldCaptureSuccess, gin.H{
+		"hold":    newHoldResponse(captured),
+		"balance": newWalletBalanceResponse(w),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) releaseHold(ctx *gin.Context) {
+	_, hold, ok := server.getWalletHold(ctx)
+	if !ok {
+		return
+	}
+	released, w, err := server.repo.ReleaseHold(ctx, hold.ID)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, w); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.HoldReleaseSuccess, gin.H{
+		"hold":    newHoldResponse(released),
+		"balance": newWalletBalanceResponse(w),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getWalletHolds(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req listHoldsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	holds, err := server.repo.GetHoldsByWallet(wallet.ID, models.HoldStatus(req.Status))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]holdResponse, 0, len(holds))
+	for _, hold := range holds {
+		data = append(data, newHoldResponse(hold))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"holds": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getWalletHold loads the wallet and the hold in the uri after checking that
+// both belong to the logged in user. It writes the error
+// response itself and reports whether the handler can go on.
+func (server *Server) getWalletHold(ctx *gin.Context) (*models.Wallet, *models.Hold, bool) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, nil, false
+	}
+	var param holdIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, nil, false
+	}
+	hold, err := server.repo.GetHold(param.HoldID)
+	// a hold on another wallet is reported as missing rather than forbidden
+	if errors.Is(err, util.ErrHoldNotFound) || (err == nil && hold.WalletID != wallet.ID) {
+		ctx.JSON(http.StatusNotFound, errorResponse(ErrHoldNotFound))
+		return nil, nil, false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, nil, false
+	}
+	return wallet, hold, true
+}
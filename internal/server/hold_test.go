@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func randomHold(walletID int64) *models.Hold {
+	return &models.Hold{
+		ID:        1,
+		UUID:      uuid.New(),
+		WalletID:  walletID,
+		Amount:    models.NewMoneyFromInt(60),
+		Status:    models.HoldStatusActive,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func Test_captureHold(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	hold := randomHold(wallet.ID)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should capture the full hold when no amount is sent",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFraud(mockRepo, wallet)
+				mockRepo.EXPECT().GetHold(gomock.Eq(hold.ID)).Times(2).Return(hold, nil)
+				stubNoVelocity(mockRepo)
+				txn := &models.Transaction{
+					Type:          models.TransactionTypeHoldCapture,
+					CorrelationID: hold.UUID.String(),
+					Actor:         user.Email,
+				}
+				mockRepo.EXPECT().
+					CaptureHold(gomock.Any(), gomock.Eq(hold.ID), eqMoney(hold.Amount), eqTransaction(txn)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, _ int64, amount models.Money, _ *models.Transaction) (*models.Hold, *models.Wallet, error) {
+						captured := *hold
+						captured.Status = models.HoldStatusCaptured
+						captured.CapturedAmount = amount
+						return &captured, wallet, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), fmt.Sprintf("%d", wallet.ID), cachedBalance(t, wallet), 100*time.Second).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"captured"`)
+			},
+		},
+		{
+			name: "should capture part of the hold",
+			body: gin.H{"amount": "25"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFraud(mockRepo, wallet)
+				mockRepo.EXPECT().GetHold(gomock.Eq(hold.ID)).Times(2).Return(hold, nil)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					CaptureHold(gomock.Any(), gomock.Eq(hold.ID), eqMoney(models.NewMoneyFromInt(25)), gomock.Any()).
+					Times(1).
+					Return(hold, wallet, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "should not capture a hold on another wallet",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				other := randomHold(wallet.ID + 1)
+				// the fraud rules leave the hold of another wallet to the handler
+				mockRepo.EXPECT().GetHold(gomock.Eq(hold.ID)).Times(2).Return(other, nil)
+				mockRepo.EXPECT().CaptureHold(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrHoldNotFound.Error())
+			},
+		},
+		{
+			name: "should reject capturing a hold that is no longer active",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFraud(mockRepo, wallet)
+				mockRepo.EXPECT().GetHold(gomock.Eq(hold.ID)).Times(2).Return(hold, nil)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					CaptureHold(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrHoldNotActive)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			var body []byte
+			if tt.body != nil {
+				body, err = json.Marshal(tt.body)
+				require.NoError(t, err)
+			}
+
+			url := fmt.Sprintf("/api/v1/wallets/%d/holds/%d/capture", wallet.ID, hold.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
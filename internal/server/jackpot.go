@@ -0,0 +1,216 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidContributionPercent = errors.New("contribution percent must be above 0 and at most 100")
+)
+
+type jackpotIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type jackpotPoolResponse struct {
+	ID                  int64     `json:"id"`
+	UUID                uuid.UUID `json:"uuid"`
+	Name                string    `json:"name"`
+	Provider            string    `json:"provider"`
+	Currency            string    `json:"currency"`
+	ContributionPercent string    `json:"contribution_percent"`
+	SeedAmount          string    `json:"seed_amount"`
+	// Amount is the exact value of the pool, Payable what a hit pays now
+	Amount    string     `json:"amount"`
+	Payable   string     `json:"payable"`
+	Hits      int64      `json:"hits"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func newJackpotPoolResponse(pool *models.JackpotPool) jackpotPoolResponse {
+	payable := pool.Amount
+	if currency, ok := models.LookupCurrency(pool.Currency); ok {
+		payable = currency.Round(pool.Amount, models.RoundDown)
+	}
+	return jackpotPoolResponse{
+		ID:                  pool.ID,
+		UUID:                pool.UUID,
+		Name:                pool.Name,
+		Provider:            pool.Provider,
+		Currency:            pool.Currency,
+		ContributionPercent: pool.ContributionPercent.String(),
+		SeedAmount:          pool.SeedAmount.String(),
+		Amount:              pool.Amount.String(),
+		Payable:             payable.String(),
+		Hits:                pool.Hits,
+		LastHitAt:           pool.LastHitAt,
+		CreatedAt:           pool.CreatedAt,
+	}
+}
+
+type jackpotEventResponse struct {
+	ID                    int64     `json:"id"`
+	Type                  string    `json:"type"`
+	WalletID              int64     `json:"wallet_id"`
+	RoundID               string    `json:"round_id"`
+	ProviderTransactionID string    `json:"provider_transaction_id"`
+	Amount                string    `json:"amount"`
+	PoolAmount            string    `json:"pool_amount"`
+	TransactionID         int64     `json:"transaction_id"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+type createJackpotRequest struct {
+	Name     string `json:"name" binding:"required,max=128"`
+	Provider string `json:"provider" binding:"required,max=64"`
+	Currency string `json:"currency" binding:"required,len=3"`
+	// ContributionPercent of every bet with the provider in the currency
+	// goes into the pool
+	ContributionPercent decimal.Decimal `json:"contribution_percent"`
+	SeedAmount          models.Money    `json:"seed_amount"`
+	WalletID            int64           `json:"wallet_id" binding:"required,min=1"`
+	SeedWalletID        int64           `json:"seed_wallet_id" binding:"required,min=1"`
+}
+
+type jackpotHistoryRequest struct {
+	PageSize int `form:"page_size" binding:"min=0"`
+}
+
+func (server *Server) createJackpot(ctx *gin.Context) {
+	var req createJackpotRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	if !req.ContributionPercent.IsPositive() || req.ContributionPercent.GreaterThan(decimal.NewFromInt(100)) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidContributionPercent))
+		return
+	}
+	// a pool may start from nothing
+	if req.SeedAmount.IsNegative() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidAmount))
+		return
+	}
+	if !currency.Fits(req.SeedAmount) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	if err := server.validateOperatorWallets(currency.Code, req.WalletID, req.SeedWalletID); err != nil {
+		ctx.JSON(jackpotErrorStatus(err), errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	pool := &models.JackpotPool{
+		UUID:                uuid.New(),
+		Name:                req.Name,
+		Provider:            req.Provider,
+		Currency:            currency.Code,
+		ContributionPercent: req.ContributionPercent,
+		SeedAmount:          req.SeedAmount,
+		WalletID:            req.WalletID,
+		SeedWalletID:        req.SeedWalletID,
+		Actor:               actor,
+	}
+	if err := server.repo.CreateJackpotPool(ctx, pool, actor); err != nil {
+		ctx.JSON(jackpotErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.JackpotCreateSuccess, newJackpotPoolResponse(pool))
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (server *Server) getJackpot(ctx *gin.Context) {
+	var param jackpotIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	pool, err := server.repo.GetJackpotPool(param.ID)
+	if err != nil {
+		ctx.JSON(jackpotErrorStatus(err), errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, "", newJackpotPoolResponse(pool))
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getJackpotHistory lists the seed, contributions and hits of a pool,
+// newest first
+func (server *Server) getJackpotHistory(ctx *gin.Context) {
+	var param jackpotIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req jackpotHistoryRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = util.DefaultPageSize
+	}
+	if pageSize > util.MaxPageSize {
+		pageSize = util.MaxPageSize
+	}
+	pool, err := server.repo.GetJackpotPool(param.ID)
+	if err != nil {
+		ctx.JSON(jackpotErrorStatus(err), errorResponse(err))
+		return
+	}
+	events, err := server.repo.GetJackpotEvents(pool.ID, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]jackpotEventResponse, 0, len(events))
+	for _, event := range events {
+		data = append(data, jackpotEventResponse{
+			ID:                    event.ID,
+			Type:                  string(event.Type),
+			WalletID:              event.WalletID,
+			RoundID:               event.RoundID,
+			ProviderTransactionID: event.ProviderTransactionID,
+			Amount:                event.Amount.String(),
+			PoolAmount:            event.PoolAmount.String(),
+			TransactionID:         event.TransactionID,
+			CreatedAt:             event.CreatedAt,
+		})
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"jackpot": newJackpotPoolResponse(pool),
+		"events":  data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func jackpotErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrJackpotNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrOperatorWallets):
+		return http.StatusBadRequest
+	default:
+		return postingErrorStatus(err)
+	}
+}
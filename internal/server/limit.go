@@ -0,0 +1,185 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codes sent along with the error of a request rejected by a gaming limit
+const (
+	ErrorCodeDepositLimitExceeded = "DEPOSIT_LIMIT_EXCEEDED"
+	ErrorCodeLossLimitExceeded    = "LOSS_LIMIT_EXCEEDED"
+	ErrorCodeWagerLimitExceeded   = "WAGER_LIMIT_EXCEEDED"
+)
+
+// gamingLimitResponse shows the limit in force and the change to it that
+// is waiting for the end of its cooling-off period, if any
+type gamingLimitResponse struct {
+	ID             int64      `json:"id"`
+	WalletID       int64      `json:"wallet_id"`
+	Kind           string     `json:"kind"`
+	Period         string     `json:"period"`
+	Amount         string     `json:"amount"`
+	PendingAmount  string     `json:"pending_amount,omitempty"`
+	PendingRemoval bool       `json:"pending_removal,omitempty"`
+	PendingFrom    *time.Time `json:"pending_from,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// newGamingLimitResponse reports false for a limit whose removal took
+// effect
+func newGamingLimitResponse(limit *models.GamingLimit, now time.Time) (gamingLimitResponse, bool) {
+	amount, ok := limit.Effective(now)
+	if !ok {
+		return gamingLimitResponse{}, false
+	}
+	response := gamingLimitResponse{
+		ID:        limit.ID,
+		WalletID:  limit.WalletID,
+		Kind:      string(limit.Kind),
+		Period:    string(limit.Period),
+		Amount:    amount.String(),
+		UpdatedAt: limit.UpdatedAt,
+	}
+	if limit.PendingFrom != nil && now.Before(*limit.PendingFrom) {
+		if !limit.PendingRemoval {
+			response.PendingAmount = limit.PendingAmount.String()
+		}
+		response.PendingRemoval = limit.PendingRemoval
+		response.PendingFrom = limit.PendingFrom
+	}
+	return response, true
+}
+
+type gamingLimitKey struct {
+	Kind   string `json:"kind" form:"kind" binding:"required,oneof=deposit loss wager"`
+	Period string `json:"period" form:"period" binding:"required,oneof=daily weekly monthly"`
+}
+
+type setGamingLimitRequest struct {
+	gamingLimitKey
+	Amount models.Money `json:"amount"`
+}
+
+func (server *Server) getGamingLimits(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	limits, err := server.repo.GetGamingLimits(wallet.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	data := make([]gamingLimitResponse, 0, len(limits))
+	for _, limit := range limits {
+		if response, ok := newGamingLimitResponse(limit, now); ok {
+			data = append(data, response)
+		}
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"limits": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// setGamingLimit lowers a limit at once and raises it after the cooling-off
+// period
+func (server *Server) setGamingLimit(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req setGamingLimitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	server.changeGamingLimit(ctx, &database.LimitChange{
+		WalletID: wallet.ID,
+		Kind:     models.LimitKind(req.Kind),
+		Period:   models.LimitPeriod(req.Period),
+		Amount:   req.Amount,
+	}, util.GamingLimitSetSuccess)
+}
+
+// removeGamingLimit lifts a limit after the cooling-off period
+func (server *Server) removeGamingLimit(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req gamingLimitKey
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	server.changeGamingLimit(ctx, &database.LimitChange{
+		WalletID: wallet.ID,
+		Kind:     models.LimitKind(req.Kind),
+		Period:   models.LimitPeriod(req.Period),
+		Remove:   true,
+	}, util.GamingLimitRemoveSuccess)
+}
+
+func (server *Server) changeGamingLimit(ctx *gin.Context, change *database.LimitChange, message string) {
+	change.CoolingOff = limitCoolingOff()
+	limit, err := server.repo.SetGamingLimit(ctx, change)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, util.ErrGamingLimitNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, errorResponse(err))
+		return
+	}
+	// a limit whose removal takes effect at once is not shown
+	data, _ := newGamingLimitResponse(limit, time.Now())
+	response := util.BuildResponseEntity(true, message, data)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// postingErrorResponse is the error response to a failed posting, it
+// carries a code when a gaming limit or a velocity rule rejected it
+func postingErrorResponse(err error) gin.H {
+	response := errorResponse(postingError(err))
+	if code := limitErrorCode(err); code != "" {
+		response["code"] = code
+	}
+	return response
+}
+
+func limitErrorCode(err error) string {
+	switch {
+	case errors.Is(err, util.ErrDepositLimitExceeded):
+		return ErrorCodeDepositLimitExceeded
+	case errors.Is(err, util.ErrLossLimitExceeded):
+		return ErrorCodeLossLimitExceeded
+	case errors.Is(err, util.ErrWagerLimitExceeded):
+		return ErrorCodeWagerLimitExceeded
+	case errors.Is(err, util.ErrMaxDebitExceeded):
+		return ErrorCodeMaxDebitExceeded
+	case errors.Is(err, util.ErrDailyDebitExceeded):
+		return ErrorCodeDailyDebitExceeded
+	case errors.Is(err, util.ErrOperationRateExceeded):
+		return ErrorCodeOperationRateExceeded
+	default:
+		return ""
+	}
+}
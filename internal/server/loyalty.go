@@ -0,0 +1,266 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidPointsPerUnit         = errors.New("points per unit cannot be less than zero")
+	ErrInvalidLoyaltyPoints         = errors.New("points must be above zero with at most 2 decimal places")
+	ErrLoyaltyRedemptionUnavailable = errors.New("loyalty points cannot be redeemed into this currency")
+)
+
+type loyaltyRuleResponse struct {
+	Category      string    `json:"category"`
+	Currency      string    `json:"currency"`
+	PointsPerUnit string    `json:"points_per_unit"`
+	Actor         string    `json:"actor"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func newLoyaltyRuleResponse(rule *models.LoyaltyRule) loyaltyRuleResponse {
+	return loyaltyRuleResponse{
+		Category:      rule.Category,
+		Currency:      rule.Currency,
+		PointsPerUnit: rule.PointsPerUnit.String(),
+		Actor:         rule.Actor,
+		UpdatedAt:     rule.UpdatedAt,
+	}
+}
+
+// loyaltyAccountResponse shows the points of a player, the tier they reached
+// and how far the next tier is. The points expire at ExpiresAt unless the
+// player wagers or redeems before then.
+type loyaltyAccountResponse struct {
+	Points           string     `json:"points"`
+	TierPoints       string     `json:"tier_points"`
+	Tier             string     `json:"tier"`
+	NextTier         string     `json:"next_tier,omitempty"`
+	PointsToNextTier string     `json:"points_to_next_tier,omitempty"`
+	LastActivityAt   *time.Time `json:"last_activity_at"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+}
+
+func (server *Server) newLoyaltyAccountResponse(account *models.LoyaltyAccount) loyaltyAccountResponse {
+	tier, next := server.loyaltyTiers.Tier(account.TierPoints)
+	response := loyaltyAccountResponse{
+		Points:     account.Points.String(),
+		TierPoints: account.TierPoints.String(),
+		Tier:       tier.Name,
+	}
+	if next != nil {
+		response.NextTier = next.Name
+		response.PointsToNextTier = next.Threshold.Sub(account.TierPoints).String()
+	}
+	// an account is only saved once it earned points
+	if account.ID != 0 {
+		expiresAt := account.LastActivityAt.Add(loyaltyPointsExpiry())
+		response.LastActivityAt = &account.LastActivityAt
+		response.ExpiresAt = &expiresAt
+	}
+	return response
+}
+
+type loyaltyEntryResponse struct {
+	Type          string    `json:"type"`
+	Points        string    `json:"points"`
+	Balance       string    `json:"balance"`
+	Category      string    `json:"category,omitempty"`
+	TransactionID int64     `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type setLoyaltyRuleRequest struct {
+	// Category is matched exactly against the category of wagers, the
+	// models.LoyaltyDefaultCategory rule applies to every other wager
+	Category string `json:"category" binding:"required,max=32"`
+	Currency string `json:"currency" binding:"required,len=3"`
+	// PointsPerUnit is earned for each unit of real money wagered
+	PointsPerUnit decimal.Decimal `json:"points_per_unit"`
+}
+
+type loyaltyAccountRequest struct {
+	PageSize int `form:"page_size" binding:"min=0"`
+}
+
+type redeemLoyaltyPointsRequest struct {
+	Points decimal.Decimal `json:"points"`
+}
+
+func (server *Server) setLoyaltyRule(ctx *gin.Context) {
+	var req setLoyaltyRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	if req.PointsPerUnit.IsNegative() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidPointsPerUnit))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	rule := &models.LoyaltyRule{
+		Category:      req.Category,
+		Currency:      currency.Code,
+		PointsPerUnit: req.PointsPerUnit,
+		Actor:         actor,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := server.repo.SetLoyaltyRule(rule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.LoyaltyRuleSetSuccess, newLoyaltyRuleResponse(rule))
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getLoyaltyRules(ctx *gin.Context) {
+	rules, err := server.repo.GetLoyaltyRules()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]loyaltyRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		data = append(data, newLoyaltyRuleResponse(rule))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"rules": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getLoyaltyAccount returns the points of the logged in user with the
+// latest entries of their loyalty ledger, newest first. Points show up a
+// little after the wagers that earned them, they are accrued in the
+// background.
+func (server *Server) getLoyaltyAccount(ctx *gin.Context) {
+	var req loyaltyAccountRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = util.DefaultPageSize
+	}
+	if pageSize > util.MaxPageSize {
+		pageSize = util.MaxPageSize
+	}
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	account, err := server.repo.GetLoyaltyAccount(user.ID)
+	if errors.Is(err, util.ErrLoyaltyAccountNotFound) {
+		account = &models.LoyaltyAccount{UserID: user.ID}
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	entries, err := server.repo.GetLoyaltyEntries(user.ID, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]loyaltyEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		data = append(data, loyaltyEntryResponse{
+			Type:          string(entry.Type),
+			Points:        entry.Points.String(),
+			Balance:       entry.Balance.String(),
+			Category:      entry.Category,
+			TransactionID: entry.TransactionID,
+			CreatedAt:     entry.CreatedAt,
+		})
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"account": server.newLoyaltyAccountResponse(account),
+		"entries": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// redeemLoyaltyPoints turns points of the logged in user into real money in
+// one of their wallets. The points were earned by the player, so unlike
+// promotions they can be cashed in while the player is restricted.
+func (server *Server) redeemLoyaltyPoints(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req redeemLoyaltyPointsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !req.Points.IsPositive() || !req.Points.Equal(req.Points.Truncate(models.LoyaltyPointsPrecision)) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidLoyaltyPoints))
+		return
+	}
+	rate, ok := server.loyaltyRates[wallet.CurrencyCode()]
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrLoyaltyRedemptionUnavailable))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	redemption := &database.LoyaltyRedemption{
+		UserID:   wallet.UserID,
+		WalletID: wallet.ID,
+		Currency: wallet.CurrencyCode(),
+		Points:   req.Points,
+		Rate:     rate,
+	}
+	txn := newTransaction(models.TransactionTypeLoyaltyRedemption, "", "", actor)
+	account, w, err := server.repo.RedeemLoyaltyPoints(ctx, redemption, txn)
+	if err != nil {
+		ctx.JSON(loyaltyErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, w); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.LoyaltyRedeemSuccess, gin.H{
+		"points":  req.Points.String(),
+		"amount":  redemption.Amount.String(),
+		"account": server.newLoyaltyAccountResponse(account),
+		"balance": newWalletBalanceResponse(w),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func loyaltyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrInsufficientLoyaltyPoints),
+		errors.Is(err, util.ErrLoyaltyRedemptionTooSmall):
+		return http.StatusBadRequest
+	default:
+		return postingErrorStatus(err)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_redeemLoyaltyPoints(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		rates         models.LoyaltyRates
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "should credit the worth of the points to the wallet",
+			body:  gin.H{"points": "250"},
+			rates: models.LoyaltyRates{models.DefaultCurrency: decimal.RequireFromString("0.01")},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().
+					RedeemLoyaltyPoints(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, redemption *database.LoyaltyRedemption, txn *models.Transaction) (*models.LoyaltyAccount, *models.Wallet, error) {
+						require.Equal(t, user.ID, redemption.UserID)
+						require.Equal(t, wallet.ID, redemption.WalletID)
+						require.Equal(t, "0.01", redemption.Rate.String())
+						require.Equal(t, models.TransactionTypeLoyaltyRedemption, txn.Type)
+						redemption.Amount = models.RequireMoney("2.5")
+						return &models.LoyaltyAccount{ID: 1, UserID: user.ID, TierPoints: decimal.NewFromInt(1200)}, wallet, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"amount":"2.5"`)
+				require.Contains(t, recorder.Body.String(), `"tier":"silver"`)
+			},
+		},
+		{
+			name:  "should reject more points than the account holds",
+			body:  gin.H{"points": "250"},
+			rates: models.LoyaltyRates{models.DefaultCurrency: decimal.RequireFromString("0.01")},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().
+					RedeemLoyaltyPoints(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrInsufficientLoyaltyPoints)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrInsufficientLoyaltyPoints.Error())
+			},
+		},
+		{
+			name: "should reject a currency without a rate",
+			body: gin.H{"points": "250"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().RedeemLoyaltyPoints(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrLoyaltyRedemptionUnavailable.Error())
+			},
+		},
+		{
+			name:  "should reject fractions of a hundredth of a point",
+			body:  gin.H{"points": "1.005"},
+			rates: models.LoyaltyRates{models.DefaultCurrency: decimal.RequireFromString("0.01")},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().RedeemLoyaltyPoints(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrInvalidLoyaltyPoints.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+			stubNoFraud(repo, wallet)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			server.loyaltyRates = tt.rates
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/wallets/%d/loyalty/redeem", wallet.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
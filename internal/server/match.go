@@ -0,0 +1,286 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrMatchPlayers = errors.New("a match is played between wallets of two different players in the match currency")
+
+type matchIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type matchResponse struct {
+	ID              int64      `json:"id"`
+	UUID            uuid.UUID  `json:"uuid"`
+	Reference       string     `json:"reference"`
+	Currency        string     `json:"currency"`
+	Stake           string     `json:"stake"`
+	RakePercent     string     `json:"rake_percent"`
+	PlayerWalletIDs []int64    `json:"player_wallet_ids"`
+	EscrowWalletID  int64      `json:"escrow_wallet_id"`
+	RakeWalletID    int64      `json:"rake_wallet_id"`
+	Status          string     `json:"status"`
+	Pot             string     `json:"pot"`
+	WinnerWalletID  int64      `json:"winner_wallet_id,omitempty"`
+	Prize           string     `json:"prize"`
+	Rake            string     `json:"rake"`
+	Actor           string     `json:"actor"`
+	FinishedBy      string     `json:"finished_by,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func newMatchResponse(m *models.Match) matchResponse {
+	return matchResponse{
+		ID:              m.ID,
+		UUID:            m.UUID,
+		Reference:       m.Reference,
+		Currency:        m.Currency,
+		Stake:           m.Stake.String(),
+		RakePercent:     m.RakePercent.String(),
+		PlayerWalletIDs: m.PlayerWalletIDs(),
+		EscrowWalletID:  m.EscrowWalletID,
+		RakeWalletID:    m.RakeWalletID,
+		Status:          string(m.Status),
+		Pot:             m.Pot.String(),
+		WinnerWalletID:  m.WinnerWalletID,
+		Prize:           m.Prize.String(),
+		Rake:            m.Rake.String(),
+		Actor:           m.Actor,
+		FinishedBy:      m.FinishedBy,
+		FinishedAt:      m.FinishedAt,
+		CreatedAt:       m.CreatedAt,
+	}
+}
+
+type startMatchRequest struct {
+	// Reference is the id of the match on the game side, starting the same
+	// match again with it is answered with the match already started
+	Reference       string          `json:"reference" binding:"required,max=128"`
+	Currency        string          `json:"currency" binding:"required,len=3"`
+	Stake           models.Money    `json:"stake"`
+	RakePercent     decimal.Decimal `json:"rake_percent"`
+	PlayerWalletIDs []int64         `json:"player_wallet_ids" binding:"required,len=2,dive,min=1"`
+	EscrowWalletID  int64           `json:"escrow_wallet_id" binding:"required,min=1"`
+	RakeWalletID    int64           `json:"rake_wallet_id" binding:"required,min=1"`
+}
+
+type settleMatchRequest struct {
+	WinnerWalletID int64 `json:"winner_wallet_id" binding:"required,min=1"`
+}
+
+// startMatch moves the stake of both players into the escrow wallet
+func (server *Server) startMatch(ctx *gin.Context) {
+	var req startMatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Stake); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !currency.Fits(req.Stake) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	if req.RakePercent.IsNegative() || req.RakePercent.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRakePercent))
+		return
+	}
+	if err := server.validateOperatorWallets(currency.Code, req.EscrowWalletID, req.RakeWalletID); err != nil {
+		ctx.JSON(matchErrorStatus(err), errorResponse(err))
+		return
+	}
+	players, err := server.validateMatchPlayers(currency.Code, req.PlayerWalletIDs, req.EscrowWalletID, req.RakeWalletID)
+	if err != nil {
+		ctx.JSON(matchErrorStatus(err), errorResponse(err))
+		return
+	}
+	// both stakes count towards the velocity rule of their wallet
+	velocities := make([]*velocityCheck, 0, len(players))
+	for _, wallet := range players {
+		velocity, err := server.checkVelocity(ctx, wallet, req.Stake)
+		if err != nil {
+			ctx.JSON(matchErrorStatus(err), postingErrorResponse(err))
+			return
+		}
+		defer server.releaseVelocity(ctx, velocity)
+		velocities = append(velocities, velocity)
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	match := &models.Match{
+		UUID:              uuid.New(),
+		Reference:         req.Reference,
+		Currency:          currency.Code,
+		Stake:             req.Stake,
+		RakePercent:       req.RakePercent,
+		PlayerOneWalletID: req.PlayerWalletIDs[0],
+		PlayerTwoWalletID: req.PlayerWalletIDs[1],
+		EscrowWalletID:    req.EscrowWalletID,
+		RakeWalletID:      req.RakeWalletID,
+		Actor:             actor,
+	}
+	result, err := server.repo.StartMatch(ctx, match)
+	if err != nil {
+		ctx.JSON(matchErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if !result.Duplicate {
+		for _, velocity := range velocities {
+			server.recordVelocity(velocity)
+		}
+	}
+	server.respondMatch(ctx, http.StatusCreated, util.MatchStartSuccess, result)
+}
+
+// validateMatchPlayers checks that the wallets of a match belong to two
+// different players who may stake, in currency and apart from the operator
+// wallets of the match, and returns them
+func (server *Server) validateMatchPlayers(currency string, walletIDs []int64, operatorWalletIDs ...int64) ([]*models.Wallet, error) {
+	users := make(map[int64]bool, len(walletIDs))
+	wallets := make([]*models.Wallet, 0, len(walletIDs))
+	for _, id := range walletIDs {
+		for _, operatorID := range operatorWalletIDs {
+			if id == operatorID {
+				return nil, ErrMatchPlayers
+			}
+		}
+		wallet, err := server.repo.GetWallet(id)
+		if err != nil {
+			return nil, err
+		}
+		if wallet.CurrencyCode() != currency || users[wallet.UserID] {
+			return nil, ErrMatchPlayers
+		}
+		users[wallet.UserID] = true
+		// a restricted player cannot stake
+		if err := server.checkPlayAllowed(wallet); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, wallet)
+	}
+	return wallets, nil
+}
+
+func (server *Server) getMatch(ctx *gin.Context) {
+	var param matchIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	match, err := server.repo.GetMatch(param.ID)
+	if err != nil {
+		ctx.JSON(matchErrorStatus(err), errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, "", newMatchResponse(match))
+	ctx.JSON(http.StatusOK, response)
+}
+
+// settleMatch pays the pot less the rake to the winner
+func (server *Server) settleMatch(ctx *gin.Context) {
+	var req settleMatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	server.finishMatch(ctx, models.MatchStatusSettled, req.WinnerWalletID, util.MatchSettleSuccess)
+}
+
+// drawMatch refunds both stakes of a match that ended level
+func (server *Server) drawMatch(ctx *gin.Context) {
+	server.finishMatch(ctx, models.MatchStatusDrawn, 0, util.MatchDrawSuccess)
+}
+
+// abortMatch refunds both stakes of a match that was called off
+func (server *Server) abortMatch(ctx *gin.Context) {
+	server.finishMatch(ctx, models.MatchStatusAborted, 0, util.MatchAbortSuccess)
+}
+
+// finishMatch ends the match in the uri with status. Finishing a match again
+// the same way is answered with the match as it ended.
+func (server *Server) finishMatch(ctx *gin.Context, status models.MatchStatus, winnerWalletID int64, message string) {
+	var param matchIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	result, err := server.repo.FinishMatch(ctx, &database.MatchOutcome{
+		MatchID:        param.ID,
+		Status:         status,
+		WinnerWalletID: winnerWalletID,
+		Actor:          actor,
+	})
+	if err != nil {
+		ctx.JSON(matchErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	server.respondMatch(ctx, http.StatusOK, message, result)
+}
+
+// respondMatch caches the balances the match moved and answers with the
+// match and those balances by wallet id
+func (server *Server) respondMatch(ctx *gin.Context, status int, message string, result *database.MatchResult) {
+	balances := make(map[int64]WalletBalanceResponse, len(result.Wallets))
+	for _, w := range result.Wallets {
+		if err := server.cacheWalletBalance(ctx, w); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		balances[w.ID] = newWalletBalanceResponse(w)
+	}
+	if result.Duplicate {
+		status = http.StatusOK
+	}
+	response := util.BuildResponseEntity(true, message, gin.H{
+		"match":     newMatchResponse(result.Match),
+		"balances":  balances,
+		"duplicate": result.Duplicate,
+	})
+	ctx.JSON(status, response)
+}
+
+func matchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrMatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrMatchConflict),
+		errors.Is(err, util.ErrMatchFinished):
+		return http.StatusConflict
+	case errors.Is(err, util.ErrMatchWinner),
+		errors.Is(err, ErrMatchPlayers),
+		errors.Is(err, ErrOperatorWallets):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAccountRestricted):
+		return http.StatusForbidden
+	default:
+		return postingErrorStatus(err)
+	}
+}
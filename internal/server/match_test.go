@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_settleMatch(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	match := &models.Match{
+		ID:                1,
+		Reference:         "match-1",
+		Currency:          models.DefaultCurrency,
+		Stake:             models.NewMoneyFromInt(5),
+		PlayerOneWalletID: 2,
+		PlayerTwoWalletID: 3,
+		EscrowWalletID:    4,
+		RakeWalletID:      5,
+		Status:            models.MatchStatusSettled,
+		Pot:               models.NewMoneyFromInt(10),
+		WinnerWalletID:    3,
+		Prize:             models.RequireMoney("9.5"),
+		Rake:              models.RequireMoney("0.5"),
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should pay the winner and cache every balance moved",
+			body: gin.H{"winner_wallet_id": 3},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					FinishMatch(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, outcome *database.MatchOutcome) (*database.MatchResult, error) {
+						require.Equal(t, match.ID, outcome.MatchID)
+						require.Equal(t, models.MatchStatusSettled, outcome.Status)
+						require.Equal(t, int64(3), outcome.WinnerWalletID)
+						require.Equal(t, admin.Email, outcome.Actor)
+						return &database.MatchResult{
+							Match: match,
+							Wallets: []*models.Wallet{
+								{ID: 4, Currency: models.DefaultCurrency},
+								{ID: 3, Currency: models.DefaultCurrency, Balance: models.RequireMoney("9.5")},
+								{ID: 5, Currency: models.DefaultCurrency, Balance: models.RequireMoney("0.5")},
+							},
+						}, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(3).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"prize":"9.5"`)
+				require.Contains(t, recorder.Body.String(), `"duplicate":false`)
+			},
+		},
+		{
+			name: "should answer a repeated settlement without moving money",
+			body: gin.H{"winner_wallet_id": 3},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					FinishMatch(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(&database.MatchResult{Match: match, Duplicate: true}, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"duplicate":true`)
+			},
+		},
+		{
+			name: "should reject settling a match that ended another way",
+			body: gin.H{"winner_wallet_id": 2},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					FinishMatch(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrMatchFinished)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrMatchFinished.Error())
+			},
+		},
+		{
+			name: "should require a winner",
+			body: gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().FinishMatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/matches/%d/settle", match.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, admin.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
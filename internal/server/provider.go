@@ -0,0 +1,241 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Oloruntobi1/qgdc/internal/aggregator"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The provider routes adapt the seamless wallet protocol of game
+// aggregators onto game rounds. A debit is a bet, a credit a win and a
+// refund the rollback of a bet, all keyed on the ids the aggregator gives
+// its calls so retries are safe.
+
+func (server *Server) providerBalance(ctx *gin.Context) {
+	var req aggregator.BalanceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+		return
+	}
+	wallet, err := server.getPlayerWallet(req.PlayerID, req.Currency)
+	if err != nil {
+		respondProviderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, aggregator.Response{
+		Status:   aggregator.StatusOK,
+		Balance:  wallet.AvailableBalance().String(),
+		Currency: wallet.CurrencyCode(),
+	})
+}
+
+func (server *Server) providerDebit(ctx *gin.Context) {
+	var req aggregator.TransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+		return
+	}
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+		return
+	}
+	action := &models.GameRoundAction{
+		ProviderTransactionID: req.TransactionID,
+		Type:                  models.GameRoundActionBet,
+		Amount:                req.Amount,
+	}
+	server.providerRoundAction(ctx, req.PlayerID, req.Currency, req.RoundID, action, models.TransactionTypeBet, req.Category)
+}
+
+func (server *Server) providerCredit(ctx *gin.Context) {
+	var req aggregator.TransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+		return
+	}
+	// a credit of zero settles a round that was lost
+	if req.Amount.IsNegative() {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, ErrInvalidAmount))
+		return
+	}
+	action := &models.GameRoundAction{
+		ProviderTransactionID: req.TransactionID,
+		Type:                  models.GameRoundActionWin,
+		Amount:                req.Amount,
+	}
+	server.providerRoundAction(ctx, req.PlayerID, req.Currency, req.RoundID, action, models.TransactionTypeWin, "")
+}
+
+func (server *Server) providerRefund(ctx *gin.Context) {
+	var req aggregator.RefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+		return
+	}
+	action := &models.GameRoundAction{
+		ProviderTransactionID: req.TransactionID,
+		Type:                  models.GameRoundActionRollback,
+		RollbackOf:            req.RefTransactionID,
+	}
+	server.providerRoundAction(ctx, req.PlayerID, req.Currency, req.RoundID, action, models.TransactionTypeRollback, "")
+}
+
+// providerJackpotHit pays a jackpot of the calling provider to the player
+func (server *Server) providerJackpotHit(ctx *gin.Context) {
+	var req aggregator.JackpotHitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, aggregator.ErrorResponse(aggregator.ErrorCodeInvalidRequest, err))
+		return
+	}
+	wallet, err := server.getPlayerWallet(req.PlayerID, req.Currency)
+	if err != nil {
+		respondProviderError(ctx, err)
+		return
+	}
+	provider := ctx.GetString(middleware.ProviderPayloadKey)
+	result, err := server.repo.HitJackpot(ctx, &database.JackpotHit{
+		PoolID:                req.JackpotID,
+		Provider:              provider,
+		ProviderTransactionID: req.TransactionID,
+		WalletID:              wallet.ID,
+		RoundID:               req.RoundID,
+		Actor:                 "provider:" + provider,
+	})
+	if err != nil {
+		respondProviderError(ctx, err)
+		return
+	}
+	if !result.Duplicate {
+		if err := server.cacheWalletBalance(ctx, result.Wallet); err != nil {
+			respondProviderError(ctx, err)
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, aggregator.Response{
+		Status:        aggregator.StatusOK,
+		Balance:       result.Wallet.AvailableBalance().String(),
+		Currency:      result.Wallet.CurrencyCode(),
+		TransactionID: strconv.FormatInt(result.Event.ID, 10),
+		Amount:        result.Event.Amount.String(),
+		Duplicate:     result.Duplicate,
+	})
+}
+
+// providerRoundAction applies the action of the calling provider to the
+// round and writes the protocol response
+func (server *Server) providerRoundAction(
+	ctx *gin.Context,
+	playerID int64,
+	currency string,
+	roundID string,
+	action *models.GameRoundAction,
+	txnType models.TransactionType,
+	category string,
+) {
+	wallet, err := server.getPlayerWallet(playerID, currency)
+	if err != nil {
+		respondProviderError(ctx, err)
+		return
+	}
+	// a restricted player cannot stake, wins and refunds are still paid
+	var velocity *velocityCheck
+	if action.Type == models.GameRoundActionBet {
+		if err := server.checkPlayAllowed(wallet); err != nil {
+			respondProviderError(ctx, err)
+			return
+		}
+		if velocity, err = server.checkVelocity(ctx, wallet, action.Amount); err != nil {
+			respondProviderError(ctx, err)
+			return
+		}
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	provider := ctx.GetString(middleware.ProviderPayloadKey)
+	action.Provider = provider
+	// the journal entry carries the provider round id as its reference and
+	// the provider as its actor
+	txn := newTransaction(txnType, roundID, "", "provider:"+provider)
+	txn.Category = category
+	result, err := server.repo.ApplyGameRoundAction(ctx, wallet.ID, roundID, action, txn)
+	if err != nil {
+		respondProviderError(ctx, err)
+		return
+	}
+	if !result.Duplicate {
+		server.recordVelocity(velocity)
+		if err := server.cacheWalletBalance(ctx, result.Wallet); err != nil {
+			respondProviderError(ctx, err)
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, aggregator.Response{
+		Status:        aggregator.StatusOK,
+		Balance:       result.Wallet.AvailableBalance().String(),
+		Currency:      result.Wallet.CurrencyCode(),
+		TransactionID: strconv.FormatInt(result.Action.ID, 10),
+		Duplicate:     result.Duplicate,
+	})
+}
+
+// getPlayerWallet returns the wallet of the player in currency
+func (server *Server) getPlayerWallet(playerID int64, currency string) (*models.Wallet, error) {
+	wallets, err := server.repo.GetWalletsByUserID(playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		if strings.EqualFold(wallet.CurrencyCode(), currency) {
+			return wallet, nil
+		}
+	}
+	return nil, util.ErrWalletNotFound
+}
+
+func respondProviderError(ctx *gin.Context, err error) {
+	ctx.JSON(gameRoundErrorStatus(err), aggregator.ErrorResponse(providerErrorCode(err), postingError(err)))
+}
+
+func providerErrorCode(err error) string {
+	switch {
+	case errors.Is(err, util.ErrWalletNotFound):
+		return aggregator.ErrorCodePlayerNotFound
+	case errors.Is(err, util.ErrInsufficientFunds):
+		return aggregator.ErrorCodeInsufficientFunds
+	case errors.Is(err, util.ErrDepositLimitExceeded),
+		errors.Is(err, util.ErrLossLimitExceeded),
+		errors.Is(err, util.ErrWagerLimitExceeded),
+		errors.Is(err, util.ErrMaxDebitExceeded),
+		errors.Is(err, util.ErrDailyDebitExceeded),
+		errors.Is(err, util.ErrOperationRateExceeded):
+		return aggregator.ErrorCodeLimitExceeded
+	case errors.Is(err, ErrAccountRestricted):
+		return aggregator.ErrorCodePlayerRestricted
+	case errors.Is(err, util.ErrGameRoundNotFound):
+		return aggregator.ErrorCodeRoundNotFound
+	case errors.Is(err, util.ErrGameRoundActionNotFound):
+		return aggregator.ErrorCodeTransactionNotFound
+	case errors.Is(err, util.ErrGameRoundClosed),
+		errors.Is(err, util.ErrGameRoundBetAlreadyRolled):
+		return aggregator.ErrorCodeRoundClosed
+	case errors.Is(err, util.ErrJackpotNotFound):
+		return aggregator.ErrorCodeJackpotNotFound
+	case errors.Is(err, util.ErrGameRoundActionConflict),
+		errors.Is(err, util.ErrGameRoundWalletMismatch),
+		errors.Is(err, util.ErrJackpotHitConflict):
+		return aggregator.ErrorCodeTransactionConflict
+	case errors.Is(err, util.ErrCurrencyMismatch),
+		errors.Is(err, util.ErrInvalidAmountPrecision):
+		return aggregator.ErrorCodeInvalidRequest
+	default:
+		return aggregator.ErrorCodeInternalError
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/aggregator"
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/fraud"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// newProviderTestServer serves a wallet backed by the in memory repository
+// with one player holding 100 USD, and returns the simulator of an
+// aggregator calling it for the provider acme together with the repository,
+// operations are screened with rules on top of the default ones
+func newProviderTestServer(t *testing.T, rules ...fraud.Rule) (*aggregator.Simulator, *models.User, *database.InMemory) {
+	t.Setenv("PROVIDER_SECRETS", "acme:s3cret")
+
+	ctrl := gomock.NewController(t)
+	cache := mockcache.NewMockCacher(ctrl)
+	cache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+
+	repo := database.NewInMemory()
+	user := randomUser()
+	require.NoError(t, repo.CreateUser(user))
+	_, err := repo.CreateWallet(&models.Wallet{
+		UserID:   user.ID,
+		Currency: models.DefaultCurrency,
+		Balance:  models.NewMoneyFromInt(100),
+	})
+	require.NoError(t, err)
+
+	server, err := NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	for _, rule := range rules {
+		server.AddFraudRule(rule)
+	}
+	httpServer := httptest.NewServer(server.router)
+	t.Cleanup(httpServer.Close)
+
+	return aggregator.NewSimulator(httpServer.URL, "acme", "s3cret"), user, repo
+}
+
+func TestProviderRoundTrip(t *testing.T) {
+	simulator, user, _ := newProviderTestServer(t)
+	ctx := context.Background()
+	debit := aggregator.TransactionRequest{
+		PlayerID:      user.ID,
+		Currency:      models.DefaultCurrency,
+		RoundID:       "round-1",
+		TransactionID: "tx-1",
+		Amount:        models.RequireMoney("30.50"),
+	}
+
+	result, err := simulator.Balance(ctx, aggregator.BalanceRequest{PlayerID: user.ID, Currency: models.DefaultCurrency})
+	require.NoError(t, err)
+	require.Equal(t, aggregator.StatusOK, result.Status)
+	require.Equal(t, "100", result.Balance)
+
+	result, err = simulator.Debit(ctx, debit)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "69.5", result.Balance)
+	require.False(t, result.Duplicate)
+
+	// the aggregator retries a debit it got no answer for
+	retried, err := simulator.Debit(ctx, debit)
+	require.NoError(t, err)
+	require.Equal(t, aggregator.StatusOK, retried.Status)
+	require.True(t, retried.Duplicate)
+	require.Equal(t, result.TransactionID, retried.TransactionID)
+	require.Equal(t, "69.5", retried.Balance)
+
+	credit := debit
+	credit.TransactionID = "tx-2"
+	credit.Amount = models.NewMoneyFromInt(50)
+	result, err = simulator.Credit(ctx, credit)
+	require.NoError(t, err)
+	require.Equal(t, "119.5", result.Balance)
+
+	// the round paid out, its stake cannot be refunded any more
+	refund := aggregator.RefundRequest{
+		PlayerID:         user.ID,
+		Currency:         models.DefaultCurrency,
+		RoundID:          "round-1",
+		TransactionID:    "tx-3",
+		RefTransactionID: "tx-1",
+	}
+	result, err = simulator.Refund(ctx, refund)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, result.StatusCode)
+	require.Equal(t, aggregator.ErrorCodeRoundClosed, result.ErrorCode)
+
+	debit.RoundID = "round-2"
+	debit.TransactionID = "tx-4"
+	_, err = simulator.Debit(ctx, debit)
+	require.NoError(t, err)
+	refund.RoundID = "round-2"
+	refund.TransactionID = "tx-5"
+	refund.RefTransactionID = "tx-4"
+	result, err = simulator.Refund(ctx, refund)
+	require.NoError(t, err)
+	require.Equal(t, aggregator.StatusOK, result.Status)
+	require.Equal(t, "119.5", result.Balance)
+
+	debit.RoundID = "round-3"
+	debit.TransactionID = "tx-6"
+	debit.Amount = models.NewMoneyFromInt(1000)
+	result, err = simulator.Debit(ctx, debit)
+	require.NoError(t, err)
+	require.Equal(t, aggregator.ErrorCodeInsufficientFunds, result.ErrorCode)
+
+	result, err = simulator.Balance(ctx, aggregator.BalanceRequest{PlayerID: user.ID, Currency: "EUR"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, result.StatusCode)
+	require.Equal(t, aggregator.ErrorCodePlayerNotFound, result.ErrorCode)
+}
+
+func TestProviderJackpotHit(t *testing.T) {
+	simulator, user, repo := newProviderTestServer(t)
+	ctx := context.Background()
+	poolWalletID, err := repo.CreateWallet(&models.Wallet{UserID: user.ID + 1, Currency: models.DefaultCurrency})
+	require.NoError(t, err)
+	seedWalletID, err := repo.CreateWallet(&models.Wallet{UserID: user.ID + 2, Currency: models.DefaultCurrency, Balance: models.NewMoneyFromInt(500)})
+	require.NoError(t, err)
+	pool := &models.JackpotPool{
+		Provider:            "acme",
+		Currency:            models.DefaultCurrency,
+		ContributionPercent: decimal.NewFromInt(10),
+		SeedAmount:          models.NewMoneyFromInt(50),
+		WalletID:            poolWalletID,
+		SeedWalletID:        seedWalletID,
+	}
+	require.NoError(t, repo.CreateJackpotPool(ctx, pool, "admin@email.com"))
+
+	result, err := simulator.Debit(ctx, aggregator.TransactionRequest{
+		PlayerID:      user.ID,
+		Currency:      models.DefaultCurrency,
+		RoundID:       "round-1",
+		TransactionID: "tx-1",
+		Amount:        models.NewMoneyFromInt(20),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "80", result.Balance)
+
+	hit := aggregator.JackpotHitRequest{
+		JackpotID:     pool.ID,
+		PlayerID:      user.ID,
+		Currency:      models.DefaultCurrency,
+		RoundID:       "round-1",
+		TransactionID: "jackpot-1",
+	}
+	result, err = simulator.HitJackpot(ctx, hit)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "52", result.Amount)
+	require.Equal(t, "132", result.Balance)
+
+	retried, err := simulator.HitJackpot(ctx, hit)
+	require.NoError(t, err)
+	require.True(t, retried.Duplicate)
+	require.Equal(t, "132", retried.Balance)
+
+	hit.JackpotID = pool.ID + 1
+	hit.TransactionID = "jackpot-2"
+	result, err = simulator.HitJackpot(ctx, hit)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, result.StatusCode)
+	require.Equal(t, aggregator.ErrorCodeJackpotNotFound, result.ErrorCode)
+}
+
+func TestProviderDebitVelocity(t *testing.T) {
+	simulator, user, repo := newProviderTestServer(t)
+	ctx := context.Background()
+	wallets, err := repo.GetWalletsByUserID(user.ID)
+	require.NoError(t, err)
+	require.NoError(t, repo.SetVelocityRule(&models.VelocityRule{
+		WalletID: wallets[0].ID,
+		Currency: models.DefaultCurrency,
+		MaxDebit: models.NewMoneyFromInt(25),
+	}))
+
+	// a bet is a debit like any other
+	result, err := simulator.Debit(ctx, aggregator.TransactionRequest{
+		PlayerID:      user.ID,
+		Currency:      models.DefaultCurrency,
+		RoundID:       "round-1",
+		TransactionID: "tx-1",
+		Amount:        models.NewMoneyFromInt(30),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, result.StatusCode)
+	require.Equal(t, aggregator.ErrorCodeLimitExceeded, result.ErrorCode)
+
+	result, err = simulator.Balance(ctx, aggregator.BalanceRequest{PlayerID: user.ID, Currency: models.DefaultCurrency})
+	require.NoError(t, err)
+	require.Equal(t, "100", result.Balance)
+}
+
+func TestProviderDebitFraud(t *testing.T) {
+	flag := fraud.NewRule("flag", func(ctx context.Context, op *fraud.Operation) (fraud.Verdict, error) {
+		return fraud.Verdict{Decision: fraud.DecisionFlag, Reason: "looks odd"}, nil
+	})
+	simulator, user, repo := newProviderTestServer(t, flag)
+	ctx := context.Background()
+
+	// the game server cannot wait for a review, so a flagged stake is refused
+	result, err := simulator.Debit(ctx, aggregator.TransactionRequest{
+		PlayerID:      user.ID,
+		Currency:      models.DefaultCurrency,
+		RoundID:       "round-1",
+		TransactionID: "tx-1",
+		Amount:        models.NewMoneyFromInt(30),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, result.StatusCode)
+	require.Equal(t, aggregator.ErrorCodeOperationBlocked, result.ErrorCode)
+
+	reviews, err := repo.GetFraudReviews("")
+	require.NoError(t, err)
+	require.Empty(t, reviews)
+	result, err = simulator.Balance(ctx, aggregator.BalanceRequest{PlayerID: user.ID, Currency: models.DefaultCurrency})
+	require.NoError(t, err)
+	require.Equal(t, "100", result.Balance)
+}
+
+func TestProviderSignature(t *testing.T) {
+	simulator, user, _ := newProviderTestServer(t)
+	ctx := context.Background()
+	req := aggregator.BalanceRequest{PlayerID: user.ID, Currency: models.DefaultCurrency}
+
+	testCases := []struct {
+		name  string
+		alter func(s *aggregator.Simulator)
+	}{
+		{
+			name:  "should reject callbacks signed with the wrong secret",
+			alter: func(s *aggregator.Simulator) { s.Secret = "guess" },
+		},
+		{
+			name:  "should reject callbacks of an unknown provider",
+			alter: func(s *aggregator.Simulator) { s.Provider = "unknown" },
+		},
+		{
+			name: "should reject replayed callbacks",
+			alter: func(s *aggregator.Simulator) {
+				s.Now = func() time.Time { return time.Now().Add(-time.Hour) }
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			s := *simulator
+			tt.alter(&s)
+			result, err := s.Balance(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			require.Equal(t, aggregator.ErrorCodeInvalidSignature, result.ErrorCode)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrAccountRestricted        = errors.New("account is restricted from play")
+	ErrInvalidRestrictionLength = errors.New("time outs last 1 to 1008 hours, self exclusions 6 to 60 months unless permanent")
+)
+
+// bounds of the length of a restriction
+const (
+	minTimeOutHours        = 1
+	maxTimeOutHours        = 6 * 7 * 24
+	minSelfExclusionMonths = 6
+	maxSelfExclusionMonths = 60
+)
+
+type restrictionResponse struct {
+	UserID          int64      `json:"user_id"`
+	Email           string     `json:"email"`
+	Restricted      bool       `json:"restricted"`
+	Restriction     string     `json:"restriction,omitempty"`
+	RestrictedUntil *time.Time `json:"restricted_until,omitempty"`
+}
+
+func newRestrictionResponse(user *models.User, now time.Time) restrictionResponse {
+	response := restrictionResponse{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Restricted: user.IsRestricted(now),
+	}
+	if response.Restricted {
+		response.Restriction = string(user.Restriction)
+		response.RestrictedUntil = user.RestrictedUntil
+	}
+	return response
+}
+
+type restrictionEventResponse struct {
+	ID          int64      `json:"id"`
+	Action      string     `json:"action"`
+	Restriction string     `json:"restriction"`
+	Until       *time.Time `json:"until"`
+	Reason      string     `json:"reason,omitempty"`
+	Actor       string     `json:"actor"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func newRestrictionEventResponse(event *models.RestrictionEvent) restrictionEventResponse {
+	return restrictionEventResponse{
+		ID:          event.ID,
+		Action:      string(event.Action),
+		Restriction: string(event.Restriction),
+		Until:       event.Until,
+		Reason:      event.Reason,
+		Actor:       event.Actor,
+		CreatedAt:   event.CreatedAt,
+	}
+}
+
+// restrictUserRequest asks for a time out of Hours or a self exclusion of
+// Months, or a permanent one
+type restrictUserRequest struct {
+	Type      string `json:"type" binding:"required,oneof=time_out self_exclusion"`
+	Hours     int    `json:"hours"`
+	Months    int    `json:"months"`
+	Permanent bool   `json:"permanent"`
+}
+
+// until returns the end of the restriction, nil when it is permanent
+func (req *restrictUserRequest) until(now time.Time) (*time.Time, error) {
+	var until time.Time
+	switch {
+	case req.Type == string(models.RestrictionTimeOut) &&
+		req.Hours >= minTimeOutHours && req.Hours <= maxTimeOutHours && !req.Permanent:
+		until = now.Add(time.Duration(req.Hours) * time.Hour)
+	case req.Type == string(models.RestrictionSelfExclusion) && req.Permanent && req.Months == 0:
+		return nil, nil
+	case req.Type == string(models.RestrictionSelfExclusion) &&
+		req.Months >= minSelfExclusionMonths && req.Months <= maxSelfExclusionMonths && !req.Permanent:
+		until = now.AddDate(0, req.Months, 0)
+	default:
+		return nil, ErrInvalidRestrictionLength
+	}
+	return &until, nil
+}
+
+type liftRestrictionRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Reason string `json:"reason" binding:"required,max=512"`
+}
+
+type userRestrictionsRequest struct {
+	Email string `form:"email" binding:"required,email"`
+}
+
+// restrictUser lets the logged in user take a time out or exclude
+// themselves, only an admin can lift the restriction before its end
+func (server *Server) restrictUser(ctx *gin.Context) {
+	var req restrictUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	until, err := req.until(now)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	event := &models.RestrictionEvent{
+		Restriction: models.RestrictionType(req.Type),
+		Until:       until,
+		Actor:       user.Email,
+	}
+	restricted, err := server.repo.RestrictUser(ctx, user.ID, event)
+	if err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.RestrictionSuccess, newRestrictionResponse(restricted, now))
+	ctx.JSON(http.StatusOK, response)
+}
+
+// liftRestriction is the admin override ending a restriction early, the
+// admin and the reason go into the audit record
+func (server *Server) liftRestriction(ctx *gin.Context) {
+	var req liftRestrictionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	user, err := server.repo.GetUserByEmail(req.Email)
+	if err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	event := &models.RestrictionEvent{
+		Reason: req.Reason,
+		Actor:  actor,
+	}
+	lifted, err := server.repo.LiftRestriction(ctx, user.ID, event)
+	if err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.RestrictionLiftSuccess, newRestrictionResponse(lifted, time.Now()))
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getUserRestrictions shows an admin the restriction of a user and its
+// audit record
+func (server *Server) getUserRestrictions(ctx *gin.Context) {
+	var req userRestrictionsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	user, err := server.repo.GetUserByEmail(req.Email)
+	if err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	events, err := server.repo.GetRestrictionEvents(user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]restrictionEventResponse, 0, len(events))
+	for _, event := range events {
+		data = append(data, newRestrictionEventResponse(event))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"restriction": newRestrictionResponse(user, time.Now()),
+		"events":      data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// checkPlayAllowed fails with ErrAccountRestricted while the owner of the
+// wallet is timed out or excluded, it guards every request staking the
+// funds of a wallet
+func (server *Server) checkPlayAllowed(wallet *models.Wallet) error {
+	user, err := server.repo.GetUser(wallet.UserID)
+	if err != nil {
+		return err
+	}
+	if user.IsRestricted(time.Now()) {
+		return ErrAccountRestricted
+	}
+	return nil
+}
+
+func restrictionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAccountRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, util.ErrUserNotRestricted),
+		errors.Is(err, util.ErrRestrictionShortened):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
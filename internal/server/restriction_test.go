@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// newRestrictionTestServer serves the in memory repository with a player
+// and an admin
+func newRestrictionTestServer(t *testing.T) (*Server, *database.InMemory, *models.User, *models.User) {
+	repo := database.NewInMemory()
+	player := randomUser()
+	admin := randomUser()
+	admin.ID = 2
+	admin.IsAdmin = true
+	require.NoError(t, repo.CreateUser(player))
+	require.NoError(t, repo.CreateUser(admin))
+
+	server, err := NewServer(repo, mockcache.NewMockCacher(gomock.NewController(t)), util.RandomString(32))
+	require.NoError(t, err)
+	return server, repo, player, admin
+}
+
+func sendRestrictionRequest(t *testing.T, server *Server, url, email string, body gin.H) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, email, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRestrictUser(t *testing.T) {
+	server, repo, player, _ := newRestrictionTestServer(t)
+	restrict := func(body gin.H) *httptest.ResponseRecorder {
+		return sendRestrictionRequest(t, server, "/api/v1/users/restriction", player.Email, body)
+	}
+
+	recorder := restrict(gin.H{"type": "self_exclusion", "months": 3})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Contains(t, recorder.Body.String(), ErrInvalidRestrictionLength.Error())
+
+	recorder = restrict(gin.H{"type": "self_exclusion", "months": 12})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"restriction":"self_exclusion"`)
+	excluded, err := repo.GetUser(player.ID)
+	require.NoError(t, err)
+	require.True(t, excluded.IsRestricted(time.Now()))
+
+	// a self exclusion in force can only be made longer
+	recorder = restrict(gin.H{"type": "self_exclusion", "months": 6})
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, recorder.Body.String(), util.ErrRestrictionShortened.Error())
+
+	recorder = restrict(gin.H{"type": "time_out", "hours": 1008})
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, recorder.Body.String(), util.ErrRestrictionShortened.Error())
+
+	user, err := repo.GetUser(player.ID)
+	require.NoError(t, err)
+	require.Equal(t, excluded.RestrictedUntil, user.RestrictedUntil)
+
+	recorder = restrict(gin.H{"type": "self_exclusion", "permanent": true})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	user, err = repo.GetUser(player.ID)
+	require.NoError(t, err)
+	require.Nil(t, user.RestrictedUntil)
+
+	events, err := repo.GetRestrictionEvents(player.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+}
+
+func TestLiftRestriction(t *testing.T) {
+	server, repo, player, admin := newRestrictionTestServer(t)
+	body := gin.H{"email": player.Email, "reason": "excluded by mistake"}
+
+	recorder := sendRestrictionRequest(t, server, "/api/v1/users/restriction", player.Email, gin.H{"type": "self_exclusion", "months": 6})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// a player cannot lift their own restriction
+	recorder = sendRestrictionRequest(t, server, "/api/v1/restrictions/lift", player.Email, body)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	require.Contains(t, recorder.Body.String(), middleware.ErrAdminRequired.Error())
+	user, err := repo.GetUser(player.ID)
+	require.NoError(t, err)
+	require.True(t, user.IsRestricted(time.Now()))
+
+	recorder = sendRestrictionRequest(t, server, "/api/v1/restrictions/lift", admin.Email, body)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"restricted":false`)
+	user, err = repo.GetUser(player.ID)
+	require.NoError(t, err)
+	require.False(t, user.IsRestricted(time.Now()))
+
+	// the admin and the reason go into the audit record
+	events, err := repo.GetRestrictionEvents(player.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	lifted := events[1]
+	require.Equal(t, models.RestrictionActionLifted, lifted.Action)
+	require.Equal(t, models.RestrictionSelfExclusion, lifted.Restriction)
+	require.Equal(t, admin.Email, lifted.Actor)
+	require.Equal(t, "excluded by mistake", lifted.Reason)
+
+	recorder = sendRestrictionRequest(t, server, "/api/v1/restrictions/lift", admin.Email, body)
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, recorder.Body.String(), util.ErrUserNotRestricted.Error())
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type transactionIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type reverseTransactionRequest struct {
+	// Amount is refunded when it is sent, otherwise everything that is
+	// left to reverse of the transaction is
+	Amount *models.Money `json:"amount"`
+	Reason string        `json:"reason" binding:"required"`
+}
+
+func (server *Server) reverseTransaction(ctx *gin.Context) {
+	var param transactionIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req reverseTransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Amount != nil {
+		// check if amount sent in request is negative
+		if err := validateRequestAmount(*req.Amount); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	txn := newTransaction(models.TransactionTypeReversal, "", "", actor)
+	txn.Reason = req.Reason
+	wallet, err := server.repo.ReverseTransaction(ctx, param.ID, req.Amount, txn)
+	if err != nil {
+		ctx.JSON(reversalErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, wallet); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.TransactionReverseSuccess, gin.H{
+		"reversal": newTransactionResponse(txn),
+		"balance":  newWalletBalanceResponse(wallet),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func reversalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrTransactionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrTransactionNotReversible),
+		errors.Is(err, util.ErrReversalExceedsTransaction):
+		return http.StatusBadRequest
+	case errors.Is(err, util.ErrTransactionAlreadyReversed):
+		return http.StatusConflict
+	default:
+		return postingErrorStatus(err)
+	}
+}
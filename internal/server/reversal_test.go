@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reverseTransaction(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	var transactionID int64 = 7
+
+	testCases := []struct {
+		name          string
+		caller        *models.User
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "should refund part of a transaction",
+			caller: admin,
+			body:   gin.H{"amount": "15", "reason": "duplicate charge"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				txn := &models.Transaction{Type: models.TransactionTypeReversal, Actor: admin.Email}
+				mockRepo.EXPECT().
+					ReverseTransaction(gomock.Any(), gomock.Eq(transactionID), eqMoneyPtr(models.NewMoneyFromInt(15)), eqTransaction(txn)).
+					Times(1).
+					DoAndReturn(func(_, _, _ interface{}, txn *models.Transaction) (*models.Wallet, error) {
+						require.Equal(t, "duplicate charge", txn.Reason)
+						return wallet, nil
+					})
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "should not reverse a transaction twice",
+			caller: admin,
+			body:   gin.H{"reason": "duplicate charge"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					ReverseTransaction(gomock.Any(), gomock.Eq(transactionID), gomock.Nil(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrTransactionAlreadyReversed)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrTransactionAlreadyReversed.Error())
+			},
+		},
+		{
+			name:   "should require a reason",
+			caller: admin,
+			body:   gin.H{"amount": 15},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().ReverseTransaction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:   "should only let admins reverse transactions",
+			caller: user,
+			body:   gin.H{"reason": "changed my mind"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().ReverseTransaction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), middleware.ErrAdminRequired.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/transactions/%d/reverse", transactionID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, tt.caller.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+// eqMoneyPtrMatcher matches a non nil amount by value
+type eqMoneyPtrMatcher struct {
+	want models.Money
+}
+
+func eqMoneyPtr(want models.Money) gomock.Matcher {
+	return eqMoneyPtrMatcher{want: want}
+}
+
+func (e eqMoneyPtrMatcher) Matches(x interface{}) bool {
+	got, ok := x.(*models.Money)
+	return ok && got != nil && got.Equal(e.want)
+}
+
+func (e eqMoneyPtrMatcher) String() string {
+	return fmt.Sprintf("points to amount %s", e.want)
+}
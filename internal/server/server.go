@@ -1,10 +1,19 @@
 package server
 
 import (
+	"os"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/aggregator"
 	"github.com/Oloruntobi1/qgdc/internal/cache"
 	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/fraud"
+	"github.com/Oloruntobi1/qgdc/internal/fx"
 	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/internal/payments"
 	"github.com/Oloruntobi1/qgdc/internal/token"
+	"github.com/Oloruntobi1/qgdc/util"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,6 +23,21 @@ type Server struct {
 	tokenMaker token.Maker
 	router     *gin.Engine
 	cache      cache.Cacher
+	rates      fx.RateProvider
+	// payments collects deposits from players
+	payments payments.Provider
+	rounding models.RoundingPolicy
+	// secrets the game providers sign their callbacks with
+	providerSecrets aggregator.Secrets
+	// debit order of bonuses granted without one
+	bonusDebitOrder models.BonusDebitOrder
+	// tiers of the loyalty program and what a point is worth in each
+	// currency it can be redeemed into
+	loyaltyTiers models.LoyaltyTiers
+	loyaltyRates models.LoyaltyRates
+	// fraud screens the operations moving money in or out of a wallet
+	// before they are made
+	fraud *fraud.Engine
 }
 
 func NewServer(repo database.Repository, cache cache.Cacher, secret string) (*Server, error) {
@@ -21,10 +45,41 @@ func NewServer(repo database.Repository, cache cache.Cacher, secret string) (*Se
 	if err != nil {
 		panic(err)
 	}
+	rounding, err := models.ParseRoundingPolicy(os.Getenv("MONEY_ROUNDING_POLICY"))
+	if err != nil {
+		return nil, err
+	}
+	providerSecrets, err := aggregator.ParseSecrets(os.Getenv("PROVIDER_SECRETS"))
+	if err != nil {
+		return nil, err
+	}
+	bonusDebitOrder, err := models.ParseBonusDebitOrder(os.Getenv("BONUS_DEBIT_ORDER"))
+	if err != nil {
+		return nil, err
+	}
+	loyaltyTiers, err := models.ParseLoyaltyTiers(os.Getenv("LOYALTY_TIERS"))
+	if err != nil {
+		return nil, err
+	}
+	loyaltyRates, err := models.ParseLoyaltyRates(os.Getenv("LOYALTY_REDEMPTION_RATES"))
+	if err != nil {
+		return nil, err
+	}
 	server := &Server{
-		repo:       repo,
-		tokenMaker: tokenMaker,
-		cache:      cache,
+		repo:            repo,
+		tokenMaker:      tokenMaker,
+		cache:           cache,
+		rates:           fx.GetCurrentRateProvider(os.Getenv("FX_RATE_PROVIDER")),
+		payments:        payments.GetCurrentProvider(os.Getenv("PAYMENT_PROVIDER")),
+		rounding:        rounding,
+		providerSecrets: providerSecrets,
+		bonusDebitOrder: bonusDebitOrder,
+		loyaltyTiers:    loyaltyTiers,
+		loyaltyRates:    loyaltyRates,
+		fraud:           fraud.NewEngine(fraud.DefaultRules(repo)...),
+	}
+	if err := server.promoteAdmin(os.Getenv("ADMIN_EMAIL")); err != nil {
+		return nil, err
 	}
 	server.setupRouter()
 	return server, nil
@@ -41,20 +96,233 @@ func (server *Server) setupRouter() {
 	userRoutes.POST("", server.createUser)
 	userRoutes.POST("/login", server.loginUser)
 	userRoutes.GET("", server.getUsers)
+	userRoutes.POST("/restriction", middleware.AuthMiddleware(server.tokenMaker), server.restrictUser)
+
+	idempotency := middleware.IdempotencyMiddleware(server.repo, idempotencyKeyRetention())
+	// every operation moving money in or out of a wallet is screened by
+	// the fraud rules before its handler runs
+	screenCredit := server.screen(models.TransactionTypeCredit, server.locateWalletAmount)
+	screenDebit := server.screen(models.TransactionTypeDebit, server.locateWalletAmount)
+	screenWithdrawal := server.screen(models.TransactionTypeWithdrawal, server.locateWalletAmount)
+	screenTransfer := server.screen(models.TransactionTypeTransferOut, server.locateWalletAmount)
+	screenHold := server.screen(models.TransactionTypeHoldCapture, server.locateWalletAmount)
+	screenCapture := server.screen(models.TransactionTypeHoldCapture, server.locateHoldCapture)
+	screenVoucher := server.screen(models.TransactionTypeVoucherRedemption, server.locateVoucher)
+	screenLoyalty := server.screen(models.TransactionTypeLoyaltyRedemption, server.locateLoyaltyRedemption)
+	screenConversion := server.screen(models.TransactionTypeConversionOut, server.locateFXQuote)
+	screenEntry := server.screen(models.TransactionTypeTournamentEntry, server.locateTournamentEntry)
+	screenRoundBet := server.screenImmediate(models.TransactionTypeBet, server.locateRoundBet, nil)
+	screenProviderDebit := server.screenImmediate(models.TransactionTypeBet, server.locateProviderDebit, refuseProviderScreened)
 
 	authRoutes := v1Routes.Group("wallets/").Use(middleware.AuthMiddleware(server.tokenMaker))
+	authRoutes.POST("", server.createWallet)
+	authRoutes.GET("", server.getUserWallets)
 	authRoutes.GET(":wallet_id/balance", middleware.CacheMiddleware(server.cache), server.getWalletBalance)
-	authRoutes.POST(":wallet_id/credit", server.creditWalletBalance)
-	authRoutes.POST(":wallet_id/debit", server.debitWalletBalance)
+	authRoutes.POST(":wallet_id/credit", idempotency, screenCredit, server.creditWalletBalance)
+	authRoutes.POST(":wallet_id/debit", idempotency, screenDebit, server.debitWalletBalance)
+	authRoutes.POST(":wallet_id/deposits", idempotency, screenCredit, server.createDeposit)
+	authRoutes.GET(":wallet_id/deposits/:deposit_id", server.getDeposit)
+	authRoutes.POST(":wallet_id/withdrawals", idempotency, screenWithdrawal, server.requestWithdrawal)
+	authRoutes.GET(":wallet_id/withdrawals/:withdrawal_id", server.getWalletWithdrawal)
+	authRoutes.POST(":wallet_id/transfer", idempotency, screenTransfer, server.transferFunds)
+	authRoutes.GET(":wallet_id/transactions", server.getWalletTransactions)
+	authRoutes.POST(":wallet_id/holds", idempotency, screenHold, server.createHold)
+	authRoutes.GET(":wallet_id/holds", server.getWalletHolds)
+	authRoutes.POST(":wallet_id/holds/:hold_id/capture", idempotency, screenCapture, server.captureHold)
+	authRoutes.POST(":wallet_id/holds/:hold_id/release", server.releaseHold)
+	authRoutes.GET(":wallet_id/bonuses", server.getWalletBonuses)
+	authRoutes.GET(":wallet_id/limits", server.getGamingLimits)
+	authRoutes.PUT(":wallet_id/limits", server.setGamingLimit)
+	authRoutes.DELETE(":wallet_id/limits", server.removeGamingLimit)
+	authRoutes.GET(":wallet_id/rounds", server.getGameRound)
+	authRoutes.POST(":wallet_id/redeem", idempotency, screenVoucher, server.redeemVoucher)
+	authRoutes.POST(":wallet_id/loyalty/redeem", idempotency, screenLoyalty, server.redeemLoyaltyPoints)
+
+	adminRoutes := v1Routes.Group("transactions/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	adminRoutes.POST(":id/reverse", idempotency, server.reverseTransaction)
+
+	restrictionRoutes := v1Routes.Group("restrictions/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	restrictionRoutes.GET("", server.getUserRestrictions)
+	restrictionRoutes.POST("lift", server.liftRestriction)
+
+	tournamentRoutes := v1Routes.Group("tournaments/").Use(middleware.AuthMiddleware(server.tokenMaker))
+	tournamentRoutes.GET(":id", server.getTournament)
+	tournamentRoutes.POST(":id/enter", idempotency, screenEntry, server.enterTournament)
+
+	tournamentAdminRoutes := v1Routes.Group("tournaments/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	tournamentAdminRoutes.POST("", idempotency, server.createTournament)
+	tournamentAdminRoutes.POST(":id/close", server.closeTournament)
+	tournamentAdminRoutes.POST(":id/cancel", server.cancelTournament)
+
+	jackpotRoutes := v1Routes.Group("jackpots/").Use(middleware.AuthMiddleware(server.tokenMaker))
+	jackpotRoutes.GET(":id", server.getJackpot)
+
+	jackpotAdminRoutes := v1Routes.Group("jackpots/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	jackpotAdminRoutes.POST("", idempotency, server.createJackpot)
+	jackpotAdminRoutes.GET(":id/history", server.getJackpotHistory)
+
+	matchRoutes := v1Routes.Group("matches/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	matchRoutes.POST("", idempotency, server.startMatch)
+	matchRoutes.GET(":id", server.getMatch)
+	matchRoutes.POST(":id/settle", idempotency, server.settleMatch)
+	matchRoutes.POST(":id/draw", idempotency, server.drawMatch)
+	matchRoutes.POST(":id/abort", idempotency, server.abortMatch)
+
+	depositRoutes := v1Routes.Group("deposits/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	depositRoutes.GET("", server.getDeposits)
+	depositRoutes.POST(":id/refund", server.refundDeposit)
+
+	withdrawalRoutes := v1Routes.Group("withdrawals/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	withdrawalRoutes.GET("", server.getWithdrawals)
+	withdrawalRoutes.GET(":id", server.getWithdrawal)
+	withdrawalRoutes.POST(":id/review", server.reviewWithdrawal)
+	withdrawalRoutes.POST(":id/approve", server.approveWithdrawal)
+	withdrawalRoutes.POST(":id/reject", server.rejectWithdrawal)
+	withdrawalRoutes.POST(":id/pay", idempotency, server.payWithdrawal)
+	withdrawalRoutes.POST(":id/fail", server.failWithdrawal)
+
+	voucherRoutes := v1Routes.Group("vouchers/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	voucherRoutes.POST("", idempotency, server.createVoucher)
+	voucherRoutes.GET("", server.getVoucher)
+
+	loyaltyRoutes := v1Routes.Group("loyalty/").Use(middleware.AuthMiddleware(server.tokenMaker))
+	loyaltyRoutes.GET("", server.getLoyaltyAccount)
+
+	loyaltyAdminRoutes := v1Routes.Group("loyalty/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	loyaltyAdminRoutes.GET("rules", server.getLoyaltyRules)
+	loyaltyAdminRoutes.PUT("rules", server.setLoyaltyRule)
+
+	feeRoutes := v1Routes.Group("fees/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	feeRoutes.GET("", server.getFeeSchedules)
+	feeRoutes.PUT("", server.setFeeSchedule)
+	feeRoutes.DELETE("", server.removeFeeSchedule)
+
+	velocityRoutes := v1Routes.Group("velocity/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	velocityRoutes.GET("rules", server.getVelocityRules)
+	velocityRoutes.PUT("rules", server.setVelocityRule)
+	velocityRoutes.DELETE("rules/:id", server.removeVelocityRule)
+
+	fraudRoutes := v1Routes.Group("fraud/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	fraudRoutes.GET("reviews", server.getFraudReviews)
+	fraudRoutes.GET("reviews/:id", server.getFraudReview)
+	fraudRoutes.POST("reviews/:id/approve", idempotency, server.approveFraudReview)
+	fraudRoutes.POST("reviews/:id/deny", server.denyFraudReview)
+
+	bonusRoutes := v1Routes.Group("bonuses/").Use(
+		middleware.AuthMiddleware(server.tokenMaker),
+		middleware.AdminMiddleware(server.repo),
+	)
+	bonusRoutes.POST("", idempotency, server.createBonus)
+	bonusRoutes.POST(":id/forfeit", server.forfeitBonus)
+
+	fxRoutes := v1Routes.Group("fx/").Use(middleware.AuthMiddleware(server.tokenMaker))
+	fxRoutes.POST("quotes", server.createFXQuote)
+	fxRoutes.GET("quotes/:quote_id", server.getFXQuote)
+	fxRoutes.POST("quotes/:quote_id/execute", idempotency, screenConversion, server.executeFXQuote)
+
+	// the payment provider signs its webhooks, they are checked by the
+	// provider itself
+	paymentRoutes := router.Group("/payments/")
+	paymentRoutes.POST("webhook", server.depositWebhook)
+
+	providerRoutes := router.Group("/provider/").Use(
+		middleware.ProviderSignatureMiddleware(server.providerSecrets, providerSignatureMaxAge()),
+	)
+	providerRoutes.POST("balance", server.providerBalance)
+	providerRoutes.POST("debit", screenProviderDebit, server.providerDebit)
+	providerRoutes.POST("credit", server.providerCredit)
+	providerRoutes.POST("refund", server.providerRefund)
+	providerRoutes.POST("jackpot", server.providerJackpotHit)
+	providerRoutes.POST("wallets/:wallet_id/rounds/bet", screenRoundBet, server.placeBet)
+	providerRoutes.POST("wallets/:wallet_id/rounds/win", server.payWin)
+	providerRoutes.POST("wallets/:wallet_id/rounds/rollback", server.rollbackBet)
 
 	server.router = router
 }
 
 // Start runs the HTTP server on a specific address.
 func (server *Server) Start(address string) error {
+	server.startSweepers()
 	return server.router.Run(address)
 }
 
+// how long the response to a request with an Idempotency-Key is kept
+func idempotencyKeyRetention() time.Duration {
+	return util.GetDurationEnv("IDEMPOTENCY_KEY_RETENTION", 24*time.Hour)
+}
+
+// how long the rate of a quote is locked for
+func fxQuoteTTL() time.Duration {
+	return util.GetDurationEnv("FX_QUOTE_TTL", 30*time.Second)
+}
+
+// how long a hold lives when the request does not say
+func holdDefaultTTL() time.Duration {
+	return util.GetDurationEnv("HOLD_DEFAULT_TTL", 15*time.Minute)
+}
+
+// how long a bonus lives when the request does not say
+func bonusDefaultTTL() time.Duration {
+	return util.GetDurationEnv("BONUS_DEFAULT_TTL", 30*24*time.Hour)
+}
+
+// how long a player waits for a raised or removed gaming limit to apply
+func limitCoolingOff() time.Duration {
+	return util.GetDurationEnv("LIMIT_COOLING_OFF", 24*time.Hour)
+}
+
+// how long a player may stay inactive before their loyalty points expire
+func loyaltyPointsExpiry() time.Duration {
+	return util.GetDurationEnv("LOYALTY_POINTS_EXPIRY", 180*24*time.Hour)
+}
+
+// how old a wager must be before it earns loyalty points, the database
+// transaction that wrote it must have committed by then
+func loyaltyAccrualDelay() time.Duration {
+	return util.GetDurationEnv("LOYALTY_ACCRUAL_DELAY", time.Minute)
+}
+
+// how far the timestamp of a provider callback may be from now
+func providerSignatureMaxAge() time.Duration {
+	return util.GetDurationEnv("PROVIDER_SIGNATURE_MAX_AGE", 5*time.Minute)
+}
+
 func errorResponse(err error) gin.H {
 	return gin.H{
 		"success": false,
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/util"
+)
+
+// startSweepers launches the background jobs that clean up expired state
+func (server *Server) startSweepers() {
+	interval := util.GetDurationEnv("SWEEPER_INTERVAL", time.Minute)
+	go every(interval, server.sweepIdempotencyKeys)
+	go every(interval, server.sweepExpiredHolds)
+	go every(interval, server.sweepExpiredBonuses)
+	go every(interval, server.sweepLoyaltyAccruals)
+	go every(interval, server.sweepExpiredLoyaltyPoints)
+}
+
+// loyaltyAccrualBatch is the most wagers accrued in one database transaction
+const loyaltyAccrualBatch = 500
+
+// every runs fn on each tick of interval for the lifetime of the process
+func every(interval time.Duration, fn func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		fn(now)
+	}
+}
+
+func (server *Server) sweepIdempotencyKeys(now time.Time) {
+	if _, err := server.repo.DeleteExpiredIdempotencyKeys(now); err != nil {
+		log.Println("cannot delete expired idempotency keys:", err)
+	}
+}
+
+func (server *Server) sweepExpiredHolds(now time.Time) {
+	if _, err := server.repo.ExpireHolds(context.Background(), now); err != nil {
+		log.Println("cannot release expired holds:", err)
+	}
+}
+
+func (server *Server) sweepExpiredBonuses(now time.Time) {
+	if _, err := server.repo.ExpireBonusGrants(context.Background(), now); err != nil {
+		log.Println("cannot remove expired bonuses:", err)
+	}
+}
+
+// sweepLoyaltyAccruals credits the loyalty points of the wagers made since
+// the last sweep, stakes never wait on it
+func (server *Server) sweepLoyaltyAccruals(now time.Time) {
+	until := now.Add(-loyaltyAccrualDelay())
+	for {
+		n, err := server.repo.AccrueLoyaltyPoints(context.Background(), until, loyaltyAccrualBatch)
+		if err != nil {
+			log.Println("cannot accrue loyalty points:", err)
+			return
+		}
+		if n < loyaltyAccrualBatch {
+			return
+		}
+	}
+}
+
+func (server *Server) sweepExpiredLoyaltyPoints(now time.Time) {
+	if _, err := server.repo.ExpireLoyaltyPoints(context.Background(), now.Add(-loyaltyPointsExpiry())); err != nil {
+		log.Println("cannot expire loyalty points:", err)
+	}
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidRakePercent = errors.New("rake percent must be at least 0 and below 100")
+	ErrOperatorWallets    = errors.New("operator wallets must be distinct wallets in the currency they are used for")
+)
+
+type tournamentIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type tournamentResponse struct {
+	ID             int64      `json:"id"`
+	UUID           uuid.UUID  `json:"uuid"`
+	Name           string     `json:"name"`
+	Currency       string     `json:"currency"`
+	EntryFee       string     `json:"entry_fee"`
+	RakePercent    string     `json:"rake_percent"`
+	PayoutTable    []string   `json:"payout_table"`
+	EscrowWalletID int64      `json:"escrow_wallet_id"`
+	RakeWalletID   int64      `json:"rake_wallet_id"`
+	Status         string     `json:"status"`
+	PrizePool      string     `json:"prize_pool"`
+	Rake           string     `json:"rake"`
+	Entrants       int64      `json:"entrants"`
+	Actor          string     `json:"actor"`
+	ClosedAt       *time.Time `json:"closed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newTournamentResponse(t *models.Tournament) tournamentResponse {
+	// the table was checked when the tournament was created
+	payouts, _ := t.Payouts()
+	table := make([]string, 0, len(payouts))
+	for _, percentage := range payouts {
+		table = append(table, percentage.String())
+	}
+	return tournamentResponse{
+		ID:             t.ID,
+		UUID:           t.UUID,
+		Name:           t.Name,
+		Currency:       t.Currency,
+		EntryFee:       t.EntryFee.String(),
+		RakePercent:    t.RakePercent.String(),
+		PayoutTable:    table,
+		EscrowWalletID: t.EscrowWalletID,
+		RakeWalletID:   t.RakeWalletID,
+		Status:         string(t.Status),
+		PrizePool:      t.PrizePool.String(),
+		Rake:           t.Rake.String(),
+		Entrants:       t.Entrants,
+		Actor:          t.Actor,
+		ClosedAt:       t.ClosedAt,
+		CreatedAt:      t.CreatedAt,
+	}
+}
+
+type tournamentEntryResponse struct {
+	WalletID  int64     `json:"wallet_id"`
+	UserID    int64     `json:"user_id"`
+	Place     int       `json:"place,omitempty"`
+	Prize     string    `json:"prize"`
+	Refunded  bool      `json:"refunded"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newTournamentEntriesResponse(entries []*models.TournamentEntry) []tournamentEntryResponse {
+	data := make([]tournamentEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		data = append(data, tournamentEntryResponse{
+			WalletID:  entry.WalletID,
+			UserID:    entry.UserID,
+			Place:     entry.Place,
+			Prize:     entry.Prize.String(),
+			Refunded:  entry.Refunded,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	return data
+}
+
+type createTournamentRequest struct {
+	Name        string          `json:"name" binding:"required,max=128"`
+	Currency    string          `json:"currency" binding:"required,len=3"`
+	EntryFee    models.Money    `json:"entry_fee"`
+	RakePercent decimal.Decimal `json:"rake_percent"`
+	// PayoutTable is the percentage of the pool after rake paid to each
+	// placement, the winner first, they add up to 100
+	PayoutTable    []decimal.Decimal `json:"payout_table" binding:"required,min=1,max=100"`
+	EscrowWalletID int64             `json:"escrow_wallet_id" binding:"required,min=1"`
+	RakeWalletID   int64             `json:"rake_wallet_id" binding:"required,min=1"`
+}
+
+type enterTournamentRequest struct {
+	WalletID int64 `json:"wallet_id" binding:"required,min=1"`
+}
+
+type closeTournamentRequest struct {
+	// Placements are the wallets of the best placed entrants, the winner
+	// first
+	Placements []int64 `json:"placements"`
+}
+
+func (server *Server) createTournament(ctx *gin.Context) {
+	var req createTournamentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.EntryFee); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !currency.Fits(req.EntryFee) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	if req.RakePercent.IsNegative() || req.RakePercent.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRakePercent))
+		return
+	}
+	table, err := models.FormatPayoutTable(req.PayoutTable)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := server.validateOperatorWallets(currency.Code, req.EscrowWalletID, req.RakeWalletID); err != nil {
+		ctx.JSON(tournamentErrorStatus(err), errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	t := &models.Tournament{
+		UUID:           uuid.New(),
+		Name:           req.Name,
+		Currency:       currency.Code,
+		EntryFee:       req.EntryFee,
+		RakePercent:    req.RakePercent,
+		PayoutTable:    table,
+		EscrowWalletID: req.EscrowWalletID,
+		RakeWalletID:   req.RakeWalletID,
+		Status:         models.TournamentStatusOpen,
+		Actor:          actor,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := server.repo.CreateTournament(t); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.TournamentCreateSuccess, newTournamentResponse(t))
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// validateOperatorWallets checks that the wallets an operator sets aside for
+// a tournament or a jackpot are distinct wallets in currency
+func (server *Server) validateOperatorWallets(currency string, walletIDs ...int64) error {
+	seen := make(map[int64]bool, len(walletIDs))
+	for _, id := range walletIDs {
+		if seen[id] {
+			return ErrOperatorWallets
+		}
+		seen[id] = true
+		wallet, err := server.repo.GetWallet(id)
+		if err != nil {
+			return err
+		}
+		if wallet.CurrencyCode() != currency {
+			return ErrOperatorWallets
+		}
+	}
+	return nil
+}
+
+func (server *Server) getTournament(ctx *gin.Context) {
+	var param tournamentIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	t, err := server.repo.GetTournament(param.ID)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), errorResponse(err))
+		return
+	}
+	entries, err := server.repo.GetTournamentEntries(t.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"tournament": newTournamentResponse(t),
+		"entries":    newTournamentEntriesResponse(entries),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// enterTournament pays the entry fee out of a wallet of the logged in user
+func (server *Server) enterTournament(ctx *gin.Context) {
+	var param tournamentIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req enterTournamentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	wallet, err := server.getUserWallet(user, req.WalletID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// a restricted player cannot stake
+	if user.IsRestricted(time.Now()) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ErrAccountRestricted))
+		return
+	}
+	tournament, err := server.repo.GetTournament(param.ID)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), errorResponse(err))
+		return
+	}
+	velocity, err := server.checkVelocity(ctx, wallet, tournament.EntryFee)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	entry := &models.TournamentEntry{
+		TournamentID: param.ID,
+		WalletID:     wallet.ID,
+		UserID:       user.ID,
+	}
+	t, w, err := server.repo.EnterTournament(ctx, entry, user.Email)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	server.recordVelocity(velocity)
+	if err := server.cacheWalletBalance(ctx, w); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.TournamentEnterSuccess, gin.H{
+		"tournament": newTournamentResponse(t),
+		"balance":    newWalletBalanceResponse(w),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) closeTournament(ctx *gin.Context) {
+	var param tournamentIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req closeTournamentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	t, entries, err := server.repo.CloseTournament(ctx, param.ID, req.Placements, actor)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.TournamentCloseSuccess, gin.H{
+		"tournament": newTournamentResponse(t),
+		"entries":    newTournamentEntriesResponse(entries),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) cancelTournament(ctx *gin.Context) {
+	var param tournamentIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	t, entries, err := server.repo.CancelTournament(ctx, param.ID, actor)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.TournamentCancelSuccess, gin.H{
+		"tournament": newTournamentResponse(t),
+		"entries":    newTournamentEntriesResponse(entries),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func tournamentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrTournamentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrTournamentNotOpen),
+		errors.Is(err, util.ErrTournamentAlreadyEntered):
+		return http.StatusConflict
+	case errors.Is(err, util.ErrTournamentPlacements),
+		errors.Is(err, ErrOperatorWallets):
+		return http.StatusBadRequest
+	default:
+		return postingErrorStatus(err)
+	}
+}
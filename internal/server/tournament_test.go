@@ -0,0 +1,453 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func randomTournament() *models.Tournament {
+	return &models.Tournament{
+		ID:             1,
+		UUID:           uuid.New(),
+		Name:           "Sunday special",
+		Currency:       models.DefaultCurrency,
+		EntryFee:       models.NewMoneyFromInt(10),
+		RakePercent:    decimal.NewFromInt(10),
+		PayoutTable:    "70,30",
+		EscrowWalletID: 4,
+		RakeWalletID:   5,
+		Status:         models.TournamentStatusOpen,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+// tournamentTestCase is a request to a tournament route by the user with
+// email
+type tournamentTestCase struct {
+	name          string
+	email         string
+	body          gin.H
+	buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+	checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+}
+
+func runTournamentTestCases(t *testing.T, url string, testCases []tournamentTestCase) {
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, tt.email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func Test_createTournament(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	player := randomUser()
+	escrow := randomWallet(admin.ID)
+	escrow.ID = 4
+	rake := randomWallet(admin.ID)
+	rake.ID = 5
+	body := gin.H{
+		"name":             "Sunday special",
+		"currency":         models.DefaultCurrency,
+		"entry_fee":        10,
+		"rake_percent":     10,
+		"payout_table":     []int{70, 30},
+		"escrow_wallet_id": escrow.ID,
+		"rake_wallet_id":   rake.ID,
+	}
+
+	runTournamentTestCases(t, "/api/v1/tournaments/", []tournamentTestCase{
+		{
+			name:  "should open the tournament",
+			email: admin.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(escrow.ID)).Times(1).Return(escrow, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(rake.ID)).Times(1).Return(rake, nil)
+				mockRepo.EXPECT().
+					CreateTournament(gomock.Any()).
+					Times(1).
+					DoAndReturn(func(tournament *models.Tournament) error {
+						require.Equal(t, models.TournamentStatusOpen, tournament.Status)
+						require.Equal(t, "70,30", tournament.PayoutTable)
+						require.Equal(t, admin.Email, tournament.Actor)
+						tournament.ID = 1
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"payout_table":["70","30"]`)
+				require.Contains(t, recorder.Body.String(), `"status":"open"`)
+			},
+		},
+		{
+			name:  "should reject a payout table that does not add up to 100",
+			email: admin.Email,
+			body: gin.H{
+				"name":             "Sunday special",
+				"currency":         models.DefaultCurrency,
+				"entry_fee":        10,
+				"payout_table":     []int{70, 20},
+				"escrow_wallet_id": escrow.ID,
+				"rake_wallet_id":   rake.ID,
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().CreateTournament(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), models.ErrInvalidPayoutTable.Error())
+			},
+		},
+		{
+			name:  "should reject the escrow wallet taking the rake",
+			email: admin.Email,
+			body: gin.H{
+				"name":             "Sunday special",
+				"currency":         models.DefaultCurrency,
+				"entry_fee":        10,
+				"payout_table":     []int{100},
+				"escrow_wallet_id": escrow.ID,
+				"rake_wallet_id":   escrow.ID,
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(escrow.ID)).Times(1).Return(escrow, nil)
+				mockRepo.EXPECT().CreateTournament(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrOperatorWallets.Error())
+			},
+		},
+		{
+			name:  "should only let admins open tournaments",
+			email: player.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(player.Email)).Times(1).Return(player, nil)
+				mockRepo.EXPECT().CreateTournament(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	})
+}
+
+func Test_enterTournament(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	tournament := randomTournament()
+	restricted := *user
+	restricted.Restriction = models.RestrictionSelfExclusion
+	body := gin.H{"wallet_id": wallet.ID}
+
+	// the fraud rules look the tournament and the wallet up first
+	stubScreened := func(mockRepo *mockdb.MockRepository) {
+		mockRepo.EXPECT().GetTournament(gomock.Eq(tournament.ID)).Times(1).Return(tournament, nil)
+		stubNoFraud(mockRepo, wallet)
+	}
+	stubEntry := func(mockRepo *mockdb.MockRepository) {
+		stubScreened(mockRepo)
+		mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+		mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+		mockRepo.EXPECT().GetTournament(gomock.Eq(tournament.ID)).Times(1).Return(tournament, nil)
+		stubNoVelocity(mockRepo)
+	}
+
+	runTournamentTestCases(t, fmt.Sprintf("/api/v1/tournaments/%d/enter", tournament.ID), []tournamentTestCase{
+		{
+			name:  "should pay the entry fee out of the wallet",
+			email: user.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubEntry(mockRepo)
+				entered := *tournament
+				entered.PrizePool = tournament.EntryFee
+				entered.Entrants = 1
+				charged := *wallet
+				charged.Balance = models.NewMoneyFromInt(90)
+				mockRepo.EXPECT().
+					EnterTournament(gomock.Any(), gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, entry *models.TournamentEntry, _ string) (*models.Tournament, *models.Wallet, error) {
+						require.Equal(t, tournament.ID, entry.TournamentID)
+						require.Equal(t, wallet.ID, entry.WalletID)
+						require.Equal(t, user.ID, entry.UserID)
+						return &entered, &charged, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), gomock.Eq(fmt.Sprintf("%d", wallet.ID)), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"prize_pool":"10"`)
+				require.Contains(t, recorder.Body.String(), `"entrants":1`)
+			},
+		},
+		{
+			name:  "should not let a restricted player stake",
+			email: user.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubScreened(mockRepo)
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(&restricted, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+				mockRepo.EXPECT().EnterTournament(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrAccountRestricted.Error())
+			},
+		},
+		{
+			name:  "should reject a tournament that does not exist",
+			email: user.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				// the fraud rules leave the missing tournament to the handler
+				mockRepo.EXPECT().GetTournament(gomock.Eq(tournament.ID)).Times(2).Return(nil, util.ErrTournamentNotFound)
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				mockRepo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+				mockRepo.EXPECT().EnterTournament(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:  "should reject entering twice",
+			email: user.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubEntry(mockRepo)
+				mockRepo.EXPECT().
+					EnterTournament(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrTournamentAlreadyEntered)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrTournamentAlreadyEntered.Error())
+			},
+		},
+		{
+			name:  "should reject entering a closed tournament",
+			email: user.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubEntry(mockRepo)
+				mockRepo.EXPECT().
+					EnterTournament(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrTournamentNotOpen)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:  "should reject a wallet that cannot pay the entry fee",
+			email: user.Email,
+			body:  body,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubEntry(mockRepo)
+				mockRepo.EXPECT().
+					EnterTournament(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrInsufficientFunds)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrInsufficientBalance.Error())
+			},
+		},
+	})
+}
+
+func Test_closeTournament(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	tournament := randomTournament()
+
+	runTournamentTestCases(t, fmt.Sprintf("/api/v1/tournaments/%d/close", tournament.ID), []tournamentTestCase{
+		{
+			name:  "should pay the prizes to the placements",
+			email: admin.Email,
+			body:  gin.H{"placements": []int64{3, 2}},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				closed := *tournament
+				closed.Status = models.TournamentStatusClosed
+				closed.PrizePool = models.NewMoneyFromInt(20)
+				closed.Rake = models.NewMoneyFromInt(2)
+				closed.Entrants = 2
+				mockRepo.EXPECT().
+					CloseTournament(gomock.Any(), gomock.Eq(tournament.ID), gomock.Eq([]int64{3, 2}), gomock.Eq(admin.Email)).
+					Times(1).
+					Return(&closed, []*models.TournamentEntry{
+						{WalletID: 2, UserID: 2, Place: 2, Prize: models.RequireMoney("5.4")},
+						{WalletID: 3, UserID: 3, Place: 1, Prize: models.RequireMoney("12.6")},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"closed"`)
+				require.Contains(t, recorder.Body.String(), `"wallet_id":3,"user_id":3,"place":1,"prize":"12.6"`)
+				require.Contains(t, recorder.Body.String(), `"wallet_id":2,"user_id":2,"place":2,"prize":"5.4"`)
+			},
+		},
+		{
+			name:  "should reject placements that are not entrants",
+			email: admin.Email,
+			body:  gin.H{"placements": []int64{9}},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					CloseTournament(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrTournamentPlacements)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrTournamentPlacements.Error())
+			},
+		},
+		{
+			name:  "should reject closing a tournament twice",
+			email: admin.Email,
+			body:  gin.H{"placements": []int64{3, 2}},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					CloseTournament(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrTournamentNotOpen)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:  "should reject a tournament that does not exist",
+			email: admin.Email,
+			body:  gin.H{"placements": []int64{3, 2}},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					CloseTournament(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrTournamentNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	})
+}
+
+func Test_cancelTournament(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	player := randomUser()
+	tournament := randomTournament()
+
+	runTournamentTestCases(t, fmt.Sprintf("/api/v1/tournaments/%d/cancel", tournament.ID), []tournamentTestCase{
+		{
+			name:  "should refund every entry fee",
+			email: admin.Email,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				cancelled := *tournament
+				cancelled.Status = models.TournamentStatusCancelled
+				mockRepo.EXPECT().
+					CancelTournament(gomock.Any(), gomock.Eq(tournament.ID), gomock.Eq(admin.Email)).
+					Times(1).
+					Return(&cancelled, []*models.TournamentEntry{
+						{WalletID: 2, UserID: 2, Refunded: true},
+						{WalletID: 3, UserID: 3, Refunded: true},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"cancelled"`)
+				require.Contains(t, recorder.Body.String(), `"wallet_id":2,"user_id":2,"prize":"0","refunded":true`)
+				require.Contains(t, recorder.Body.String(), `"wallet_id":3,"user_id":3,"prize":"0","refunded":true`)
+			},
+		},
+		{
+			name:  "should reject cancelling a closed tournament",
+			email: admin.Email,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					CancelTournament(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrTournamentNotOpen)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrTournamentNotOpen.Error())
+			},
+		},
+		{
+			name:  "should only let admins cancel tournaments",
+			email: player.Email,
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(player.Email)).Times(1).Return(player, nil)
+				mockRepo.EXPECT().CancelTournament(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	})
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCursor      = errors.New("invalid pagination cursor")
+	ErrInvalidAmountRange = errors.New("invalid amount range")
+	ErrInvalidDateRange   = errors.New("invalid date range")
+)
+
+type transactionResponse struct {
+	ID            int64     `json:"id"`
+	UUID          uuid.UUID `json:"uuid"`
+	WalletID      int64     `json:"wallet_id"`
+	Type          string    `json:"type"`
+	Currency      string    `json:"currency"`
+	Amount        string    `json:"amount"`
+	BalanceBefore string    `json:"balance_before"`
+	BalanceAfter  string    `json:"balance_after"`
+	Reference     string    `json:"reference"`
+	CorrelationID string    `json:"correlation_id"`
+	OriginalID    int64     `json:"original_id"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newTransactionResponse(txn *models.Transaction) transactionResponse {
+	return transactionResponse{
+		ID:            txn.ID,
+		UUID:          txn.UUID,
+		WalletID:      txn.WalletID,
+		Type:          string(txn.Type),
+		Currency:      txn.Currency,
+		Amount:        txn.Amount.String(),
+		BalanceBefore: txn.BalanceBefore.String(),
+		BalanceAfter:  txn.BalanceAfter.String(),
+		Reference:     txn.Reference,
+		CorrelationID: txn.CorrelationID,
+		OriginalID:    txn.OriginalID,
+		Reason:        txn.Reason,
+		Actor:         txn.Actor,
+		CreatedAt:     txn.CreatedAt,
+	}
+}
+
+type listTransactionsRequest struct {
+	Cursor    string    `form:"cursor"`
+	PageSize  int       `form:"page_size" binding:"omitempty,min=1"`
+	Type      string    `form:"type"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	MinAmount string    `form:"min_amount"`
+	MaxAmount string    `form:"max_amount"`
+}
+
+// pageCursor is handed to clients as an opaque base64 string. It holds the
+// id of the last transaction on the previous page and the page number.
+type pageCursor struct {
+	Page     int   `json:"p"`
+	BeforeID int64 `json:"b"`
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageCursor(cursor string) (pageCursor, error) {
+	if cursor == "" {
+		return pageCursor{Page: util.DefaultPage}, nil
+	}
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Page < util.DefaultPage || c.BeforeID < 1 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (server *Server) getWalletTransactions(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req listTransactionsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	cursor, err := decodePageCursor(req.Cursor)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	filter, err := buildTransactionFilter(wallet.ID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	filter.BeforeID = cursor.BeforeID
+	// fetch one extra row to find out if there is a next page
+	transactions, err := server.repo.GetTransactionsByWallet(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	pageSize := filter.Limit - 1
+	nextCursor := ""
+	if len(transactions) > pageSize {
+		transactions = transactions[:pageSize]
+		nextCursor = pageCursor{
+			Page:     cursor.Page + 1,
+			BeforeID: transactions[pageSize-1].ID,
+		}.encode()
+	}
+	data := make([]transactionResponse, 0, len(transactions))
+	for _, txn := range transactions {
+		data = append(data, newTransactionResponse(txn))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"transactions": data,
+		"page":         cursor.Page,
+		"page_size":    pageSize,
+		"next_cursor":  nextCursor,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// build the repository filter from the query string, the limit is set one
+// above the page size
+func buildTransactionFilter(walletID int64, req *listTransactionsRequest) (*database.TransactionFilter, error) {
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = util.DefaultPageSize
+	}
+	if pageSize > util.MaxPageSize {
+		pageSize = util.MaxPageSize
+	}
+	filter := &database.TransactionFilter{
+		WalletID: walletID,
+		Type:     models.TransactionType(req.Type),
+		Limit:    pageSize + 1,
+	}
+	if !req.From.IsZero() {
+		filter.From = &req.From
+	}
+	if !req.To.IsZero() {
+		filter.To = &req.To
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return nil, ErrInvalidDateRange
+	}
+	if req.MinAmount != "" {
+		minAmount, err := models.ParseMoney(req.MinAmount)
+		if err != nil {
+			return nil, ErrInvalidAmountRange
+		}
+		filter.MinAmount = &minAmount
+	}
+	if req.MaxAmount != "" {
+		maxAmount, err := models.ParseMoney(req.MaxAmount)
+		if err != nil {
+			return nil, ErrInvalidAmountRange
+		}
+		filter.MaxAmount = &maxAmount
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.GreaterThan(*filter.MaxAmount) {
+		return nil, ErrInvalidAmountRange
+	}
+	return filter, nil
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/internal/token"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getWalletTransactions(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	transactions := randomTransactions(wallet.ID, 3)
+
+	testCases := []struct {
+		name          string
+		query         string
+		setUpAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(mockRepo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "should return first page with a next cursor",
+			query: "page_size=2&type=credit",
+			setUpAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().
+					GetTransactionsByWallet(gomock.Eq(&database.TransactionFilter{
+						WalletID: wallet.ID,
+						Type:     models.TransactionTypeCredit,
+						Limit:    3,
+					})).
+					Times(1).
+					Return(transactions, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				data := readTransactionsPage(t, recorder)
+				require.Len(t, data["transactions"], 2)
+				require.EqualValues(t, util.DefaultPage, data["page"])
+
+				cursor, err := decodePageCursor(data["next_cursor"].(string))
+				require.NoError(t, err)
+				require.Equal(t, util.DefaultPage+1, cursor.Page)
+				require.Equal(t, transactions[1].ID, cursor.BeforeID)
+			},
+		},
+		{
+			name:  "should return last page without a next cursor",
+			query: fmt.Sprintf("page_size=2&cursor=%s", pageCursor{Page: 2, BeforeID: transactions[1].ID}.encode()),
+			setUpAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().
+					GetTransactionsByWallet(gomock.Eq(&database.TransactionFilter{
+						WalletID: wallet.ID,
+						BeforeID: transactions[1].ID,
+						Limit:    3,
+					})).
+					Times(1).
+					Return(transactions[2:], nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				data := readTransactionsPage(t, recorder)
+				require.Len(t, data["transactions"], 1)
+				require.EqualValues(t, 2, data["page"])
+				require.Empty(t, data["next_cursor"])
+			},
+		},
+		{
+			name:  "should reject an inverted amount range",
+			query: "min_amount=50&max_amount=10",
+			setUpAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				stubWalletOwner(mockRepo, user, wallet)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "should reject a tampered cursor",
+			query: "cursor=not-a-cursor",
+			setUpAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository) {
+				stubWalletOwner(mockRepo, user, wallet)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/api/v1/wallets/%d/transactions?%s", wallet.ID, tt.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tt.setUpAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func stubWalletOwner(mockRepo *mockdb.MockRepository, user *models.User, wallet *models.Wallet) {
+	mockRepo.EXPECT().
+		GetUserByEmail(gomock.Eq(user.Email)).
+		Times(1).
+		Return(user, nil)
+
+	mockRepo.EXPECT().
+		GetWallet(gomock.Eq(wallet.ID)).
+		Times(1).
+		Return(wallet, nil)
+}
+
+// stubPlayer lets user stake, it has no restriction
+func stubPlayer(mockRepo *mockdb.MockRepository, user *models.User) {
+	mockRepo.EXPECT().
+		GetUser(gomock.Eq(user.ID)).
+		Times(1).
+		Return(user, nil)
+}
+
+// randomTransactions returns n credits ordered newest first
+func randomTransactions(walletID int64, n int) []*models.Transaction {
+	transactions := make([]*models.Transaction, 0, n)
+	for i := n; i > 0; i-- {
+		transactions = append(transactions, &models.Transaction{
+			ID:            int64(i),
+			UUID:          uuid.New(),
+			WalletID:      walletID,
+			Type:          models.TransactionTypeCredit,
+			Amount:        models.NewMoneyFromInt(util.RandomMoney()),
+			BalanceBefore: models.ZeroMoney,
+			BalanceAfter:  models.ZeroMoney,
+			CreatedAt:     time.Now(),
+		})
+	}
+	return transactions
+}
+
+func readTransactionsPage(t *testing.T, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	data, err := ioutil.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &response))
+	return response.Data
+}
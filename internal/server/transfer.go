@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTransferToSameWallet = errors.New("cannot transfer to the same wallet")
+)
+
+type transferRequest struct {
+	ToWalletID int64        `json:"to_wallet_id" binding:"required,min=1"`
+	Amount     models.Money `json:"amount"`
+	Reference  string       `json:"reference"`
+	// Currency is optional, when it is sent it must be the wallet currency
+	Currency string `json:"currency"`
+}
+
+func (server *Server) transferFunds(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req transferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.ToWalletID == wallet.ID {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrTransferToSameWallet))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	// both legs are in the source currency, so a transfer to a wallet in
+	// another currency fails atomically
+	amount := req.Amount
+	currency := wallet.CurrencyCode()
+	fee, revenueWalletID, err := server.quoteFee(models.FeeOperationTransfer, currency, amount)
+	if err != nil {
+		ctx.JSON(feeErrorStatus(err), errorResponse(err))
+		return
+	}
+	velocity, err := server.checkVelocity(ctx, wallet, amount)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	// the fee is taken out of the amount, the destination gets the rest
+	net := amount.Sub(fee)
+	transferID := uuid.New().String()
+	postings := []*database.Posting{
+		{
+			WalletID:    wallet.ID,
+			Currency:    currency,
+			Amount:      net.Neg(),
+			Transaction: newTransaction(models.TransactionTypeTransferOut, req.Reference, transferID, actor),
+		},
+		{
+			WalletID:    req.ToWalletID,
+			Currency:    currency,
+			Amount:      net,
+			Transaction: newTransaction(models.TransactionTypeTransferIn, req.Reference, transferID, actor),
+		},
+	}
+	postings = append(postings, database.FeePostings(wallet.ID, revenueWalletID, currency, fee,
+		newTransaction(models.TransactionTypeFee, req.Reference, transferID, actor),
+		newTransaction(models.TransactionTypeFee, req.Reference, transferID, actor),
+	)...)
+	wallets, err := server.repo.ApplyPostings(ctx, postings...)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	server.recordVelocity(velocity)
+	// the source comes first, then the destination and the revenue wallet
+	for _, w := range wallets {
+		if err := server.cacheWalletBalance(ctx, w); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	response := util.BuildResponseEntity(true, util.WalletTransferSuccess, gin.H{
+		"balance":     wallets[0].Balance.String(),
+		"currency":    currency,
+		"transfer_id": transferID,
+		"fee":         newFeeResponse(amount, fee),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// map repository errors from ApplyPostings to the errors returned to clients
+func postingError(err error) error {
+	if errors.Is(err, util.ErrInsufficientFunds) {
+		return ErrInsufficientBalance
+	}
+	return err
+}
+
+func postingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrInsufficientFunds),
+		errors.Is(err, util.ErrCurrencyMismatch),
+		errors.Is(err, util.ErrInvalidAmountPrecision):
+		return http.StatusBadRequest
+	case errors.Is(err, util.ErrWalletNotFound),
+		errors.Is(err, util.ErrHoldNotFound),
+		errors.Is(err, util.ErrQuoteNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrCaptureExceedsHold):
+		return http.StatusBadRequest
+	case errors.Is(err, util.ErrDepositLimitExceeded),
+		errors.Is(err, util.ErrLossLimitExceeded),
+		errors.Is(err, util.ErrWagerLimitExceeded),
+		errors.Is(err, util.ErrMaxDebitExceeded),
+		errors.Is(err, util.ErrDailyDebitExceeded),
+		errors.Is(err, ErrAccountRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, util.ErrOperationRateExceeded),
+		errors.Is(err, ErrVelocityContention):
+		return http.StatusTooManyRequests
+	case errors.Is(err, util.ErrHoldNotActive),
+		errors.Is(err, util.ErrQuoteExpired),
+		errors.Is(err, util.ErrQuoteExecuted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
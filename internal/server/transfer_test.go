@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_transferFunds(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	destination := randomWallet(user.ID + 1)
+	destination.ID = wallet.ID + 1
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should move funds between wallets in one posting",
+			body: gin.H{"to_wallet_id": destination.ID, "amount": 40},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFee(mockRepo, models.FeeOperationTransfer)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ApplyPostings(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, postings ...*database.Posting) ([]*models.Wallet, error) {
+						require.Len(t, postings, 2)
+						require.Equal(t, wallet.ID, postings[0].WalletID)
+						require.True(t, postings[0].Amount.Equal(models.NewMoneyFromInt(-40)))
+						require.Equal(t, models.TransactionTypeTransferOut, postings[0].Transaction.Type)
+						require.Equal(t, destination.ID, postings[1].WalletID)
+						require.True(t, postings[1].Amount.Equal(models.NewMoneyFromInt(40)))
+						require.Equal(t, models.TransactionTypeTransferIn, postings[1].Transaction.Type)
+						require.Equal(t, postings[0].Transaction.CorrelationID, postings[1].Transaction.CorrelationID)
+						require.Equal(t, wallet.Currency, postings[0].Currency)
+						require.Equal(t, wallet.Currency, postings[1].Currency)
+						return []*models.Wallet{wallet, destination}, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "should take the fee out of the amount and credit it to the revenue wallet",
+			body: gin.H{"to_wallet_id": destination.ID, "amount": 40},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().
+					GetFeeSchedule(gomock.Eq(models.FeeOperationTransfer), gomock.Eq(wallet.Currency)).
+					Times(1).
+					Return(&models.FeeSchedule{
+						Type:            models.FeeTypePercentage,
+						Percent:         decimal.RequireFromString("2.5"),
+						RevenueWalletID: 9,
+					}, nil)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ApplyPostings(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, postings ...*database.Posting) ([]*models.Wallet, error) {
+						require.Len(t, postings, 4)
+						require.True(t, postings[0].Amount.Equal(models.NewMoneyFromInt(-39)))
+						require.True(t, postings[1].Amount.Equal(models.NewMoneyFromInt(39)))
+						require.Equal(t, wallet.ID, postings[2].WalletID)
+						require.True(t, postings[2].Amount.Equal(models.NewMoneyFromInt(-1)))
+						require.Equal(t, models.TransactionTypeFee, postings[2].Transaction.Type)
+						require.Equal(t, int64(9), postings[3].WalletID)
+						require.True(t, postings[3].Amount.Equal(models.NewMoneyFromInt(1)))
+						require.Equal(t, postings[0].Transaction.CorrelationID, postings[3].Transaction.CorrelationID)
+						return []*models.Wallet{wallet, destination, {ID: 9, Currency: wallet.Currency}}, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(3).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"fee":{"gross":"40","fee":"1","net":"39"}`)
+			},
+		},
+		{
+			name: "should reject a transfer of an amount that does not cover the fee",
+			body: gin.H{"to_wallet_id": destination.ID, "amount": 1},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().
+					GetFeeSchedule(gomock.Eq(models.FeeOperationTransfer), gomock.Eq(wallet.Currency)).
+					Times(1).
+					Return(&models.FeeSchedule{Type: models.FeeTypeFlat, Flat: models.NewMoneyFromInt(2), RevenueWalletID: 9}, nil)
+				mockRepo.EXPECT().ApplyPostings(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrFeeExceedsAmount.Error())
+			},
+		},
+		{
+			name: "should reject a transfer that would overdraw the wallet",
+			body: gin.H{"to_wallet_id": destination.ID, "amount": 4000},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFee(mockRepo, models.FeeOperationTransfer)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ApplyPostings(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrInsufficientFunds)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrInsufficientBalance.Error())
+			},
+		},
+		{
+			name: "should reject a transfer to a wallet in another currency",
+			body: gin.H{"to_wallet_id": destination.ID, "amount": 40},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFee(mockRepo, models.FeeOperationTransfer)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					ApplyPostings(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrCurrencyMismatch)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrCurrencyMismatch.Error())
+			},
+		},
+		{
+			name: "should reject a currency other than the wallet currency",
+			body: gin.H{"to_wallet_id": destination.ID, "amount": 40, "currency": "EUR"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().ApplyPostings(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrCurrencyMismatch.Error())
+			},
+		},
+		{
+			name: "should reject a transfer to the same wallet",
+			body: gin.H{"to_wallet_id": wallet.ID, "amount": 40},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().ApplyPostings(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), ErrTransferToSameWallet.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+			stubNoFraud(repo, wallet)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/wallets/%d/transfer", wallet.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"os"
 	"time"
@@ -19,6 +20,21 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 )
 
+// promoteAdmin makes the user with email an admin, so a database seeded
+// before there were admins can be administered. A user that has not
+// registered yet is promoted once it has and the server restarts.
+func (server *Server) promoteAdmin(email string) error {
+	if email == "" {
+		return nil
+	}
+	_, err := server.repo.PromoteAdmin(email)
+	if errors.Is(err, util.ErrUserNotFound) {
+		log.Println("cannot promote admin, no user has the email", email)
+		return nil
+	}
+	return err
+}
+
 type createUserRequest struct {
 	FullName string `json:"full_name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
@@ -92,9 +108,20 @@ func (server *Server) loginUser(ctx *gin.Context) {
 	// verify password
 	err = util.CheckPassword(req.Password, user.HashedPassword)
 	if err != nil {
+		// failed logins are kept for the fraud rules
+		if err := server.recordLogin(ctx, user.Email, false); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidCredentials))
 		return
 	}
+	// a timed out or self excluded user cannot log in until the end of the
+	// restriction
+	if user.IsRestricted(time.Now()) {
+		ctx.JSON(http.StatusForbidden, errorResponse(ErrAccountRestricted))
+		return
+	}
 	// get token duration from env
 	duration := os.Getenv("ACCESS_TOKEN_DURATION")
 	// convert string to time duration
@@ -112,6 +139,11 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	// the addresses a user logged in from are known to the fraud rules
+	if err := server.recordLogin(ctx, user.Email, true); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 	response := util.BuildResponseEntity(true, "", gin.H{
 		"access_token": accessToken,
 	})
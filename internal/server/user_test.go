@@ -0,0 +1,34 @@
+package server
+
+import (
+	"testing"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerPromotesAdmin(t *testing.T) {
+	repo := database.NewInMemory()
+	user := randomUser()
+	require.NoError(t, repo.CreateUser(user))
+	cache := mockcache.NewMockCacher(gomock.NewController(t))
+
+	// a user who has not registered yet does not keep the server from starting
+	t.Setenv("ADMIN_EMAIL", util.RandomEmail())
+	_, err := NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	got, err := repo.GetUserByEmail(user.Email)
+	require.NoError(t, err)
+	require.False(t, got.IsAdmin)
+
+	t.Setenv("ADMIN_EMAIL", user.Email)
+	_, err = NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	got, err = repo.GetUserByEmail(user.Email)
+	require.NoError(t, err)
+	require.True(t, got.IsAdmin)
+}
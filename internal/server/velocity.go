@@ -0,0 +1,454 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrUnknownLoyaltyTier = errors.New("tier is not one of the loyalty tiers")
+	ErrVelocityContention = errors.New("too many operations on the wallet at once, try again")
+)
+
+// codes sent along with the error of a request rejected by a velocity rule
+const (
+	ErrorCodeMaxDebitExceeded      = "MAX_DEBIT_EXCEEDED"
+	ErrorCodeDailyDebitExceeded    = "DAILY_DEBIT_LIMIT_EXCEEDED"
+	ErrorCodeOperationRateExceeded = "OPERATION_RATE_EXCEEDED"
+)
+
+// velocityOperations are the journal entries of the operations checked
+// against velocity rules and whether they debit the wallet. The journal
+// keeps amounts unsigned, so the debits are told apart by their type. They
+// count towards the daily debit volume, together with the fees charged on
+// them.
+var velocityOperations = map[models.TransactionType]bool{
+	models.TransactionTypeCredit:          false,
+	models.TransactionTypeDebit:           true,
+	models.TransactionTypeTransferOut:     true,
+	models.TransactionTypeConversionOut:   true,
+	models.TransactionTypeBet:             true,
+	models.TransactionTypeHoldCapture:     true,
+	models.TransactionTypeWithdrawal:      true,
+	models.TransactionTypeTournamentEntry: true,
+	models.TransactionTypeMatchStake:      true,
+}
+
+const velocityDayLayout = "2006-01-02"
+
+// velocityUsage is what a wallet did that its velocity rule caps, it is
+// kept in the cache from one operation to the next
+type velocityUsage struct {
+	// Day is the UTC day Debited was debited on
+	Day     string       `json:"day"`
+	Debited models.Money `json:"debited"`
+	// Operations are the times of the operations within the window of the
+	// rule, oldest first
+	Operations []time.Time `json:"operations"`
+}
+
+// at returns the usage as of now, the debits of an earlier day and the
+// operations that left the window no longer count
+func (u velocityUsage) at(now time.Time, window time.Duration) velocityUsage {
+	if day := now.UTC().Format(velocityDayLayout); u.Day != day {
+		u.Day = day
+		u.Debited = models.ZeroMoney
+	}
+	since := now.Add(-window)
+	operations := make([]time.Time, 0, len(u.Operations))
+	for _, t := range u.Operations {
+		if !t.Before(since) {
+			operations = append(operations, t)
+		}
+	}
+	u.Operations = operations
+	return u
+}
+
+// velocityAttempts is how many times the usage of a wallet is read and
+// written back before giving up on the operations changing it meanwhile
+const velocityAttempts = 5
+
+// velocityCheck is an operation that passed the velocity rule of its
+// wallet, it is counted in the usage of the wallet until it is released
+// without having been recorded
+type velocityCheck struct {
+	walletID int64
+	rule     *models.VelocityRule
+	debit    models.Money
+	now      time.Time
+	recorded bool
+}
+
+type velocityRuleResponse struct {
+	ID            int64     `json:"id"`
+	WalletID      int64     `json:"wallet_id,omitempty"`
+	Tier          string    `json:"tier,omitempty"`
+	Currency      string    `json:"currency"`
+	MaxDebit      string    `json:"max_debit"`
+	MaxDailyDebit string    `json:"max_daily_debit"`
+	MaxOperations int       `json:"max_operations"`
+	WindowSeconds int64     `json:"window_seconds"`
+	Actor         string    `json:"actor"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func newVelocityRuleResponse(rule *models.VelocityRule) velocityRuleResponse {
+	return velocityRuleResponse{
+		ID:            rule.ID,
+		WalletID:      rule.WalletID,
+		Tier:          rule.Tier,
+		Currency:      rule.Currency,
+		MaxDebit:      rule.MaxDebit.String(),
+		MaxDailyDebit: rule.MaxDailyDebit.String(),
+		MaxOperations: rule.MaxOperations,
+		WindowSeconds: rule.WindowSeconds,
+		Actor:         rule.Actor,
+		UpdatedAt:     rule.UpdatedAt,
+	}
+}
+
+// setVelocityRuleRequest sets the rule of a wallet, or of a loyalty tier in
+// a currency. A zero cap is not checked.
+type setVelocityRuleRequest struct {
+	WalletID int64  `json:"wallet_id" binding:"min=0"`
+	Tier     string `json:"tier" binding:"max=32"`
+	// Currency is required for the rule of a tier, the rule of a wallet is
+	// in the wallet currency
+	Currency      string       `json:"currency"`
+	MaxDebit      models.Money `json:"max_debit"`
+	MaxDailyDebit models.Money `json:"max_daily_debit"`
+	MaxOperations int          `json:"max_operations" binding:"min=0"`
+	WindowSeconds int64        `json:"window_seconds" binding:"min=0"`
+}
+
+type velocityRuleIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// setVelocityRule sets the velocity rule of a wallet or of a loyalty tier,
+// it applies to the operations checked from then on
+func (server *Server) setVelocityRule(ctx *gin.Context) {
+	var req setVelocityRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	rule := &models.VelocityRule{
+		WalletID:      req.WalletID,
+		Tier:          req.Tier,
+		MaxDebit:      req.MaxDebit,
+		MaxDailyDebit: req.MaxDailyDebit,
+		MaxOperations: req.MaxOperations,
+		WindowSeconds: req.WindowSeconds,
+		Actor:         actor,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := rule.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if rule.WalletID > 0 {
+		wallet, err := server.repo.GetWallet(rule.WalletID)
+		if err != nil {
+			ctx.JSON(velocityErrorStatus(err), errorResponse(err))
+			return
+		}
+		if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		rule.Currency = wallet.CurrencyCode()
+	} else {
+		if !server.isLoyaltyTier(rule.Tier) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnknownLoyaltyTier))
+			return
+		}
+		currency, ok := models.LookupCurrency(req.Currency)
+		if !ok {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+			return
+		}
+		rule.Currency = currency.Code
+	}
+	if currency, ok := models.LookupCurrency(rule.Currency); ok &&
+		(!currency.Fits(rule.MaxDebit) || !currency.Fits(rule.MaxDailyDebit)) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	if err := server.repo.SetVelocityRule(rule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.VelocityRuleSetSuccess, newVelocityRuleResponse(rule))
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getVelocityRules(ctx *gin.Context) {
+	rules, err := server.repo.GetVelocityRules()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]velocityRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		data = append(data, newVelocityRuleResponse(rule))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"rules": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) removeVelocityRule(ctx *gin.Context) {
+	var param velocityRuleIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := server.repo.RemoveVelocityRule(param.ID); err != nil {
+		ctx.JSON(velocityErrorStatus(err), errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, util.VelocityRuleRemoveSuccess, nil))
+}
+
+// checkVelocity checks an operation on wallet that debits debit, zero for a
+// credit, against the velocity rule of the wallet before any balance
+// changes. The operation is counted in the usage of the wallet as it is
+// checked, so concurrent operations on a wallet cannot each pass a cap that
+// they exceed together. The returned check is nil when no rule applies,
+// the caller releases it once the operation is over.
+func (server *Server) checkVelocity(ctx context.Context, wallet *models.Wallet, debit models.Money) (*velocityCheck, error) {
+	rule, err := server.velocityRule(wallet)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+	if rule.MaxDebit.IsPositive() && debit.GreaterThan(rule.MaxDebit) {
+		return nil, fmt.Errorf("%w: %s at once", util.ErrMaxDebitExceeded, rule.MaxDebit)
+	}
+	check := &velocityCheck{
+		walletID: wallet.ID,
+		rule:     rule,
+		debit:    debit,
+		now:      time.Now(),
+	}
+	err = server.changeVelocityUsage(ctx, check, func(usage *velocityUsage, cached bool) error {
+		if rule.MaxDailyDebit.IsPositive() && debit.IsPositive() &&
+			usage.Debited.Add(debit).GreaterThan(rule.MaxDailyDebit) {
+			return fmt.Errorf("%w: %s a day", util.ErrDailyDebitExceeded, rule.MaxDailyDebit)
+		}
+		if rule.MaxOperations > 0 && len(usage.Operations) >= rule.MaxOperations {
+			return fmt.Errorf("%w: %d operations in %s", util.ErrOperationRateExceeded, rule.MaxOperations, rule.Window())
+		}
+		usage.Debited = usage.Debited.Add(debit)
+		usage.Operations = append(usage.Operations, check.now)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// recordVelocity keeps an operation that passed its check counted once it
+// is done
+func (server *Server) recordVelocity(check *velocityCheck) {
+	if check != nil {
+		check.recorded = true
+	}
+}
+
+// releaseVelocity takes an operation that passed its check but was not
+// recorded back out of the usage of its wallet. A usage counted from the
+// journal again since the check never had it.
+func (server *Server) releaseVelocity(ctx context.Context, check *velocityCheck) {
+	if check == nil || check.recorded {
+		return
+	}
+	err := server.changeVelocityUsage(ctx, check, func(usage *velocityUsage, cached bool) error {
+		if !cached {
+			return nil
+		}
+		if usage.Day == check.now.UTC().Format(velocityDayLayout) {
+			usage.Debited = usage.Debited.Sub(check.debit)
+		}
+		for i, t := range usage.Operations {
+			if t.Equal(check.now) {
+				usage.Operations = append(usage.Operations[:i], usage.Operations[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = server.cache.Delete(ctx, velocityCacheKey(check.walletID))
+	}
+}
+
+// changeVelocityUsage applies change to the usage of the wallet of check
+// and caches the result unless another operation changed the usage in the
+// meantime, in which case it starts over. cached tells change whether the
+// usage was read from the cache or counted from the journal. When the cache
+// fails the cached usage is dropped, so the next check counts from the
+// journal rather than from a stale count.
+func (server *Server) changeVelocityUsage(
+	ctx context.Context,
+	check *velocityCheck,
+	change func(usage *velocityUsage, cached bool) error,
+) error {
+	key := velocityCacheKey(check.walletID)
+	for attempt := 0; attempt < velocityAttempts; attempt++ {
+		now := time.Now()
+		usage, cached, err := server.velocityUsage(ctx, check.walletID, check.rule, now)
+		if err != nil {
+			return err
+		}
+		if err := change(&usage, cached != ""); err != nil {
+			return err
+		}
+		data, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		// the usage is needed until the end of the day or of the window,
+		// whichever comes last
+		ttl := models.LimitPeriodDaily.Start(now).Add(24 * time.Hour).Sub(now)
+		if window := check.rule.Window(); window > ttl {
+			ttl = window
+		}
+		swapped, err := server.cache.CompareAndSwap(ctx, key, cached, string(data), ttl)
+		if err != nil {
+			_ = server.cache.Delete(ctx, key)
+			return nil
+		}
+		if swapped {
+			return nil
+		}
+	}
+	return ErrVelocityContention
+}
+
+// velocityRule returns the rule of wallet, or else the rule of the loyalty
+// tier of its owner in the wallet currency, nil when there is neither
+func (server *Server) velocityRule(wallet *models.Wallet) (*models.VelocityRule, error) {
+	account, err := server.repo.GetLoyaltyAccount(wallet.UserID)
+	if errors.Is(err, util.ErrLoyaltyAccountNotFound) {
+		account = &models.LoyaltyAccount{UserID: wallet.UserID}
+	} else if err != nil {
+		return nil, err
+	}
+	tier, _ := server.loyaltyTiers.Tier(account.TierPoints)
+	rule, err := server.repo.GetVelocityRule(wallet.ID, tier.Name, wallet.CurrencyCode())
+	if errors.Is(err, util.ErrVelocityRuleNotFound) {
+		return nil, nil
+	}
+	return rule, err
+}
+
+func (server *Server) isLoyaltyTier(name string) bool {
+	for _, tier := range server.loyaltyTiers {
+		if tier.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// velocityUsage returns what the wallet did as of now, from the cache or,
+// when the cache does not have it, from the journal. It also returns the
+// cached value, which is empty when the usage was counted from the journal.
+func (server *Server) velocityUsage(ctx context.Context, walletID int64, rule *models.VelocityRule, now time.Time) (velocityUsage, string, error) {
+	var usage velocityUsage
+	key := velocityCacheKey(walletID)
+	cached, err := server.cache.Get(ctx, key)
+	if err == nil && cached != "" && json.Unmarshal([]byte(cached), &usage) == nil {
+		return usage.at(now, rule.Window()), cached, nil
+	}
+	// a usage that cannot be read is dropped for the one in the journal
+	if err == nil && cached != "" {
+		_ = server.cache.Delete(ctx, key)
+	}
+	if usage, err = server.journalVelocityUsage(walletID, rule, now); err != nil {
+		return velocityUsage{}, "", err
+	}
+	return usage.at(now, rule.Window()), "", nil
+}
+
+// journalVelocityUsage counts what the wallet did as of now from its
+// journal entries
+func (server *Server) journalVelocityUsage(walletID int64, rule *models.VelocityRule, now time.Time) (velocityUsage, error) {
+	day := models.LimitPeriodDaily.Start(now)
+	since := now.Add(-rule.Window())
+	from := day
+	if since.Before(from) {
+		from = since
+	}
+	transactions, err := server.repo.GetTransactionsByWallet(&database.TransactionFilter{
+		WalletID: walletID,
+		From:     &from,
+	})
+	if err != nil {
+		return velocityUsage{}, err
+	}
+	usage := velocityUsage{
+		Day:     day.Format(velocityDayLayout),
+		Debited: models.ZeroMoney,
+	}
+	// the entries come newest first, operations are kept oldest first
+	debits := make(map[string]bool)
+	for i := len(transactions) - 1; i >= 0; i-- {
+		txn := transactions[i]
+		debit, ok := velocityOperations[txn.Type]
+		if !ok {
+			continue
+		}
+		if debit && txn.CorrelationID != "" {
+			debits[txn.CorrelationID] = true
+		}
+		if !txn.CreatedAt.Before(since) {
+			usage.Operations = append(usage.Operations, txn.CreatedAt)
+		}
+	}
+	for _, txn := range transactions {
+		if txn.CreatedAt.Before(day) {
+			continue
+		}
+		// a fee is charged on the wallet of the debit it is linked to
+		charged := txn.Type == models.TransactionTypeFee && txn.CorrelationID != "" && debits[txn.CorrelationID]
+		if velocityOperations[txn.Type] || charged {
+			usage.Debited = usage.Debited.Add(txn.Amount.Abs())
+		}
+	}
+	return usage, nil
+}
+
+func velocityCacheKey(walletID int64) string {
+	return fmt.Sprintf("velocity:%d", walletID)
+}
+
+func velocityErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrVelocityRuleNotFound):
+		return http.StatusNotFound
+	default:
+		return postingErrorStatus(err)
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// stubNoVelocity sets no velocity rule on any wallet
+func stubNoVelocity(mockRepo *mockdb.MockRepository) {
+	stubVelocityRule(mockRepo, nil)
+}
+
+// stubVelocityRule sets rule on any wallet of a player who never earned
+// loyalty points, no rule when it is nil
+func stubVelocityRule(mockRepo *mockdb.MockRepository, rule *models.VelocityRule) {
+	mockRepo.EXPECT().GetLoyaltyAccount(gomock.Any()).Times(1).Return(nil, util.ErrLoyaltyAccountNotFound)
+	if rule == nil {
+		mockRepo.EXPECT().GetVelocityRule(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, util.ErrVelocityRuleNotFound)
+		return
+	}
+	mockRepo.EXPECT().GetVelocityRule(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(rule, nil)
+}
+
+func Test_checkVelocity(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	wallet.Balance = models.NewMoneyFromInt(1000)
+	amount := models.NewMoneyFromInt(200)
+	velocityKey := fmt.Sprintf("velocity:%d", wallet.ID)
+
+	cachedUsage := func(t *testing.T, debited int64) string {
+		data, err := json.Marshal(velocityUsage{
+			Day:     time.Now().UTC().Format(velocityDayLayout),
+			Debited: models.NewMoneyFromInt(debited),
+		})
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should reject a debit over the single debit cap",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubVelocityRule(mockRepo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxDebit: models.NewMoneyFromInt(100)})
+				mockCache.EXPECT().Get(gomock.Any(), gomock.Any()).Times(0)
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder, ErrorCodeMaxDebitExceeded)
+			},
+		},
+		{
+			name: "should reject a debit over the daily volume counted in the cache",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubVelocityRule(mockRepo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxDailyDebit: models.NewMoneyFromInt(250)})
+				mockCache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(1).Return(cachedUsage(t, 100), nil)
+				mockRepo.EXPECT().GetTransactionsByWallet(gomock.Any()).Times(0)
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder, ErrorCodeDailyDebitExceeded)
+			},
+		},
+		{
+			name: "should count the operations from the journal when the cache misses",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubVelocityRule(mockRepo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxOperations: 2, WindowSeconds: 60})
+				mockCache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(1).Return("", nil)
+				recent := time.Now().Add(-10 * time.Second)
+				mockRepo.EXPECT().GetTransactionsByWallet(gomock.Any()).Times(1).Return([]*models.Transaction{
+					{WalletID: wallet.ID, Type: models.TransactionTypeDebit, Amount: models.NewMoneyFromInt(5), CreatedAt: recent},
+					{WalletID: wallet.ID, Type: models.TransactionTypeCredit, Amount: models.NewMoneyFromInt(5), CreatedAt: recent},
+					// a win is not an operation the rule counts
+					{WalletID: wallet.ID, Type: models.TransactionTypeWin, Amount: models.NewMoneyFromInt(5), CreatedAt: recent},
+				}, nil)
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				requireErrorCode(t, recorder, ErrorCodeOperationRateExceeded)
+			},
+		},
+		{
+			name: "should count the debits and their fees from the journal when the cache misses",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubVelocityRule(mockRepo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxDailyDebit: models.NewMoneyFromInt(250)})
+				mockCache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(1).Return("", nil)
+				recent := time.Now().Add(-10 * time.Second)
+				// the journal keeps amounts unsigned
+				mockRepo.EXPECT().GetTransactionsByWallet(gomock.Any()).Times(1).Return([]*models.Transaction{
+					{WalletID: wallet.ID, Type: models.TransactionTypeFee, Amount: models.NewMoneyFromInt(20), CorrelationID: "transfer-1", CreatedAt: recent},
+					{WalletID: wallet.ID, Type: models.TransactionTypeTransferOut, Amount: models.NewMoneyFromInt(40), CorrelationID: "transfer-1", CreatedAt: recent},
+					{WalletID: wallet.ID, Type: models.TransactionTypeCredit, Amount: models.NewMoneyFromInt(500), CreatedAt: recent},
+				}, nil)
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder, ErrorCodeDailyDebitExceeded)
+			},
+		},
+		{
+			name: "should count the debit again when another operation changed the usage",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubVelocityRule(mockRepo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxDailyDebit: models.NewMoneyFromInt(400)})
+				gomock.InOrder(
+					mockCache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(1).Return(cachedUsage(t, 100), nil),
+					mockCache.EXPECT().CompareAndSwap(gomock.Any(), gomock.Eq(velocityKey), gomock.Eq(cachedUsage(t, 100)), gomock.Any(), gomock.Any()).
+						Times(1).
+						Return(false, nil),
+					mockCache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(1).Return(cachedUsage(t, 150), nil),
+					mockCache.EXPECT().CompareAndSwap(gomock.Any(), gomock.Eq(velocityKey), gomock.Eq(cachedUsage(t, 150)), gomock.Any(), gomock.Any()).
+						Times(1).
+						DoAndReturn(func(_ interface{}, _, _, value string, _ time.Duration) (bool, error) {
+							var usage velocityUsage
+							require.NoError(t, json.Unmarshal([]byte(value), &usage))
+							require.True(t, usage.Debited.Equal(models.NewMoneyFromInt(350)))
+							return true, nil
+						}),
+				)
+				mockRepo.EXPECT().AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(wallet, nil)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Eq(fmt.Sprintf("%d", wallet.ID)), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "should apply the rule of the tier of the player and count the debit",
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				// a player who never earned points is in the first tier
+				mockRepo.EXPECT().GetLoyaltyAccount(gomock.Eq(user.ID)).Times(1).Return(nil, util.ErrLoyaltyAccountNotFound)
+				mockRepo.EXPECT().GetVelocityRule(gomock.Eq(wallet.ID), gomock.Eq("bronze"), gomock.Eq(wallet.CurrencyCode())).
+					Times(1).
+					Return(&models.VelocityRule{Tier: "bronze", Currency: wallet.Currency, MaxDailyDebit: models.NewMoneyFromInt(300)}, nil)
+				mockCache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(1).Return(cachedUsage(t, 100), nil)
+				mockRepo.EXPECT().
+					AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), eqMoney(amount.Neg()), gomock.Eq(database.NonNegativeBalance), gomock.Any()).
+					Times(1).
+					Return(wallet, nil)
+				mockCache.EXPECT().CompareAndSwap(gomock.Any(), gomock.Eq(velocityKey), gomock.Eq(cachedUsage(t, 100)), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, _, _, value string, _ time.Duration) (bool, error) {
+						var usage velocityUsage
+						require.NoError(t, json.Unmarshal([]byte(value), &usage))
+						require.True(t, usage.Debited.Equal(models.NewMoneyFromInt(300)))
+						require.Len(t, usage.Operations, 1)
+						return true, nil
+					})
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Eq(fmt.Sprintf("%d", wallet.ID)), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+			repo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+			stubNoFraud(repo, wallet)
+			stubPlayer(repo, user)
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"amount": amount})
+			require.NoError(t, err)
+			url := fmt.Sprintf("/api/v1/wallets/%d/debit", wallet.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func requireErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, code string) {
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	require.Equal(t, code, body["code"])
+}
+
+func TestReleaseVelocity(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	wallet.Balance = models.NewMoneyFromInt(1000)
+	amount := models.NewMoneyFromInt(200)
+	velocityKey := fmt.Sprintf("velocity:%d", wallet.ID)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	cache := mockcache.NewMockCacher(ctrl)
+	repo.EXPECT().GetWallet(gomock.Eq(wallet.ID)).Times(1).Return(wallet, nil)
+	stubNoFraud(repo, wallet)
+	stubPlayer(repo, user)
+	stubVelocityRule(repo, &models.VelocityRule{WalletID: wallet.ID, Currency: wallet.Currency, MaxDailyDebit: models.NewMoneyFromInt(1000)})
+
+	// the cache keeps what was swapped in
+	data, err := json.Marshal(velocityUsage{
+		Day:     time.Now().UTC().Format(velocityDayLayout),
+		Debited: models.NewMoneyFromInt(100),
+	})
+	require.NoError(t, err)
+	stored := string(data)
+	cache.EXPECT().Get(gomock.Any(), gomock.Eq(velocityKey)).Times(2).DoAndReturn(func(_ interface{}, _ string) (string, error) {
+		return stored, nil
+	})
+	cache.EXPECT().CompareAndSwap(gomock.Any(), gomock.Eq(velocityKey), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		DoAndReturn(func(_ interface{}, _, old, value string, _ time.Duration) (bool, error) {
+			require.Equal(t, stored, old)
+			stored = value
+			return true, nil
+		})
+	// the debit fails after it passed its check
+	repo.EXPECT().AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil, util.ErrInsufficientFunds)
+
+	server, err := NewServer(repo, cache, util.RandomString(32))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+
+	data, err = json.Marshal(gin.H{"amount": amount})
+	require.NoError(t, err)
+	url := fmt.Sprintf("/api/v1/wallets/%d/debit", wallet.ID)
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	var usage velocityUsage
+	require.NoError(t, json.Unmarshal([]byte(stored), &usage))
+	require.True(t, usage.Debited.Equal(models.NewMoneyFromInt(100)))
+	require.Empty(t, usage.Operations)
+}
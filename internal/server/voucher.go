@@ -0,0 +1,276 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidVoucherKind   = errors.New("voucher kind must be single_use, multi_use or per_user")
+	ErrVoucherUser          = errors.New("a per_user voucher needs the email of its player, other vouchers take none")
+	ErrInvalidVoucherBudget = errors.New("voucher budget must cover at least one redemption")
+	ErrVoucherExpiry        = errors.New("voucher expiry must be in the future")
+)
+
+// generated codes are this long and use letters and digits that cannot be
+// mistaken for one another
+const (
+	voucherCodeLength   = 10
+	voucherCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+type voucherResponse struct {
+	ID          int64     `json:"id"`
+	UUID        uuid.UUID `json:"uuid"`
+	Code        string    `json:"code"`
+	Kind        string    `json:"kind"`
+	UserID      int64     `json:"user_id,omitempty"`
+	Currency    string    `json:"currency"`
+	Amount      string    `json:"amount"`
+	Budget      string    `json:"budget"`
+	Redeemed    string    `json:"redeemed"`
+	Redemptions int64     `json:"redemptions"`
+	Actor       string    `json:"actor"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newVoucherResponse(v *models.Voucher) voucherResponse {
+	return voucherResponse{
+		ID:          v.ID,
+		UUID:        v.UUID,
+		Code:        v.Code,
+		Kind:        string(v.Kind),
+		UserID:      v.UserID,
+		Currency:    v.Currency,
+		Amount:      v.Amount.String(),
+		Budget:      v.Budget.String(),
+		Redeemed:    v.Redeemed.String(),
+		Redemptions: v.Redemptions,
+		Actor:       v.Actor,
+		ExpiresAt:   v.ExpiresAt,
+		CreatedAt:   v.CreatedAt,
+	}
+}
+
+type voucherRedemptionResponse struct {
+	UserID        int64     `json:"user_id"`
+	WalletID      int64     `json:"wallet_id"`
+	Amount        string    `json:"amount"`
+	TransactionID int64     `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type createVoucherRequest struct {
+	// Code is generated when it is left out
+	Code string `json:"code" binding:"omitempty,alphanum,min=4,max=64"`
+	Kind string `json:"kind" binding:"required"`
+	// Email is the player a per_user voucher is issued to
+	Email     string       `json:"email" binding:"omitempty,email"`
+	Currency  string       `json:"currency" binding:"required,len=3"`
+	Amount    models.Money `json:"amount"`
+	Budget    models.Money `json:"budget"`
+	ExpiresAt time.Time    `json:"expires_at" binding:"required"`
+}
+
+type getVoucherRequest struct {
+	Code string `form:"code" binding:"required"`
+}
+
+type redeemVoucherRequest struct {
+	Code string `json:"code" binding:"required,max=64"`
+}
+
+func (server *Server) createVoucher(ctx *gin.Context) {
+	var req createVoucherRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	kind := models.VoucherKind(req.Kind)
+	switch kind {
+	case models.VoucherSingleUse, models.VoucherMultiUse, models.VoucherPerUser:
+	default:
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidVoucherKind))
+		return
+	}
+	if (kind == models.VoucherPerUser) != (req.Email != "") {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrVoucherUser))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !currency.Fits(req.Amount) || !currency.Fits(req.Budget) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(util.ErrInvalidAmountPrecision))
+		return
+	}
+	if req.Budget.LessThan(req.Amount) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidVoucherBudget))
+		return
+	}
+	now := time.Now()
+	if !req.ExpiresAt.After(now) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrVoucherExpiry))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	v := &models.Voucher{
+		UUID:      uuid.New(),
+		Code:      req.Code,
+		Kind:      kind,
+		Currency:  currency.Code,
+		Amount:    req.Amount,
+		Budget:    req.Budget,
+		ExpiresAt: req.ExpiresAt,
+		Actor:     actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if v.Code == "" {
+		if v.Code, err = newVoucherCode(); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	if kind == models.VoucherPerUser {
+		user, err := server.repo.GetUserByEmail(req.Email)
+		if err != nil {
+			ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+			return
+		}
+		v.UserID = user.ID
+	}
+	if err := server.repo.CreateVoucher(v); err != nil {
+		ctx.JSON(voucherErrorStatus(err), errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.VoucherCreateSuccess, newVoucherResponse(v))
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// getVoucher returns a voucher with its redemptions, oldest first
+func (server *Server) getVoucher(ctx *gin.Context) {
+	var req getVoucherRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	v, err := server.repo.GetVoucherByCode(req.Code)
+	if err != nil {
+		ctx.JSON(voucherErrorStatus(err), errorResponse(err))
+		return
+	}
+	redemptions, err := server.repo.GetVoucherRedemptions(v.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]voucherRedemptionResponse, 0, len(redemptions))
+	for _, r := range redemptions {
+		data = append(data, voucherRedemptionResponse{
+			UserID:        r.UserID,
+			WalletID:      r.WalletID,
+			Amount:        r.Amount.String(),
+			TransactionID: r.TransactionID,
+			CreatedAt:     r.CreatedAt,
+		})
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"voucher":     newVoucherResponse(v),
+		"redemptions": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// redeemVoucher credits a voucher to a wallet of the logged in user
+func (server *Server) redeemVoucher(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req redeemVoucherRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// promotions are not offered to restricted players
+	if err := server.checkPlayAllowed(wallet); err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	redemption := &models.VoucherRedemption{
+		UserID:   wallet.UserID,
+		WalletID: wallet.ID,
+	}
+	txn := newTransaction(models.TransactionTypeVoucherRedemption, "", "", actor)
+	v, w, err := server.repo.RedeemVoucher(ctx, req.Code, redemption, txn)
+	if err != nil {
+		ctx.JSON(voucherErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	if err := server.cacheWalletBalance(ctx, w); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.VoucherRedeemSuccess, gin.H{
+		"code":     v.Code,
+		"amount":   redemption.Amount.String(),
+		"balance":  w.Balance.String(),
+		"currency": w.CurrencyCode(),
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// newVoucherCode draws a code from a cryptographic source, codes are money
+// to whoever guesses them
+func newVoucherCode() (string, error) {
+	buf := make([]byte, voucherCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		// the alphabet has 32 letters so every byte maps evenly
+		buf[i] = voucherCodeAlphabet[int(b)%len(voucherCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+func voucherErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrVoucherNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrVoucherCodeExists),
+		errors.Is(err, util.ErrVoucherExpired),
+		errors.Is(err, util.ErrVoucherRedeemed),
+		errors.Is(err, util.ErrVoucherBudgetExhausted):
+		return http.StatusConflict
+	default:
+		return postingErrorStatus(err)
+	}
+}
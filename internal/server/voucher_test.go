@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_redeemVoucher(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+	voucher := &models.Voucher{Code: "WELCOME10", Amount: models.NewMoneyFromInt(10)}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should credit the voucher to the wallet",
+			body: gin.H{"code": "welcome10"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().GetVoucherByCode(gomock.Eq("welcome10")).Times(1).Return(voucher, nil)
+				stubNoFraud(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				mockRepo.EXPECT().
+					RedeemVoucher(gomock.Any(), gomock.Eq("welcome10"), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, _ string, redemption *models.VoucherRedemption, txn *models.Transaction) (*models.Voucher, *models.Wallet, error) {
+						require.Equal(t, user.ID, redemption.UserID)
+						require.Equal(t, wallet.ID, redemption.WalletID)
+						require.Equal(t, models.TransactionTypeVoucherRedemption, txn.Type)
+						redemption.Amount = voucher.Amount
+						return voucher, wallet, nil
+					})
+				mockCache.EXPECT().
+					Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"amount":"10"`)
+			},
+		},
+		{
+			name: "should reject a voucher redeemed before",
+			body: gin.H{"code": "WELCOME10"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().GetVoucherByCode(gomock.Eq("WELCOME10")).Times(1).Return(voucher, nil)
+				stubNoFraud(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				mockRepo.EXPECT().
+					RedeemVoucher(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrVoucherRedeemed)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrVoucherRedeemed.Error())
+			},
+		},
+		{
+			name: "should report an unknown code as missing",
+			body: gin.H{"code": "NOPE"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().GetVoucherByCode(gomock.Eq("NOPE")).Times(1).Return(nil, util.ErrVoucherNotFound)
+				stubPlayer(mockRepo, user)
+				mockRepo.EXPECT().
+					RedeemVoucher(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil, util.ErrVoucherNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "should require a code",
+			body: gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().RedeemVoucher(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/wallets/%d/redeem", wallet.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
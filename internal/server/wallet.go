@@ -1,17 +1,21 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Oloruntobi1/qgdc/internal/database"
 	"github.com/Oloruntobi1/qgdc/internal/models"
 	"github.com/Oloruntobi1/qgdc/internal/token"
 	"github.com/Oloruntobi1/qgdc/util"
 
 	"github.com/gin-gonic/gin"
-	"github.com/shopspring/decimal"
+	"github.com/google/uuid"
 )
 
 var (
@@ -20,14 +24,114 @@ var (
 	ErrWalletNotBelongsToUser       = errors.New("unauthenticated user cannot access wallet") // only an admin can access wallet of other users
 	ErrAuthorizationPayloadNotFound = errors.New("authorization payload not found")
 	ErrAuthorizationPayloadInvalid  = errors.New("authorization payload invalid")
+	ErrUnsupportedCurrency          = errors.New("unsupported currency")
 )
 
 type walletIDUriBinding struct {
 	WalletID int64 `uri:"wallet_id" binding:"required,min=1"`
 }
 
+// WalletBalanceResponse reports both balances of a wallet. The ledger
+// balance includes funds on hold, the available balance is what can still
+// be spent. Balance is the ledger balance, kept for existing clients. The
+// ledger balance splits into real money and bonus funds.
 type WalletBalanceResponse struct {
-	Balance string `json:"balance"`
+	Currency         string `json:"currency"`
+	Balance          string `json:"balance"`
+	LedgerBalance    string `json:"ledger_balance"`
+	AvailableBalance string `json:"available_balance"`
+	RealBalance      string `json:"real_balance"`
+	BonusBalance     string `json:"bonus_balance"`
+}
+
+func newWalletBalanceResponse(wallet *models.Wallet) WalletBalanceResponse {
+	return WalletBalanceResponse{
+		Currency:         wallet.CurrencyCode(),
+		Balance:          wallet.Balance.String(),
+		LedgerBalance:    wallet.Balance.String(),
+		AvailableBalance: wallet.AvailableBalance().String(),
+		RealBalance:      wallet.RealBalance().String(),
+		BonusBalance:     wallet.BonusBalance.String(),
+	}
+}
+
+type walletResponse struct {
+	ID        int64     `json:"id"`
+	UUID      uuid.UUID `json:"uuid"`
+	Currency  string    `json:"currency"`
+	Balance   string    `json:"balance"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWalletResponse(wallet *models.Wallet) walletResponse {
+	return walletResponse{
+		ID:        wallet.ID,
+		UUID:      wallet.UUID,
+		Currency:  wallet.CurrencyCode(),
+		Balance:   wallet.Balance.String(),
+		CreatedAt: wallet.CreatedAt,
+	}
+}
+
+type createWalletRequest struct {
+	Currency string `json:"currency" binding:"required,len=3"`
+}
+
+func (server *Server) createWallet(ctx *gin.Context) {
+	var req createWalletRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, ok := models.LookupCurrency(req.Currency)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrUnsupportedCurrency))
+		return
+	}
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	now := time.Now()
+	wallet := &models.Wallet{
+		UUID:      uuid.New(),
+		UserID:    user.ID,
+		Currency:  currency.Code,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := server.repo.CreateWallet(wallet); err != nil {
+		if errors.Is(err, util.ErrWalletExists) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.WalletCreateSuccess, newWalletResponse(wallet))
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (server *Server) getUserWallets(ctx *gin.Context) {
+	user, err := server.getUserFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	wallets, err := server.repo.GetWalletsByUserID(user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]walletResponse, 0, len(wallets))
+	for _, wallet := range wallets {
+		data = append(data, newWalletResponse(wallet))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"wallets": data,
+	})
+	ctx.JSON(http.StatusOK, response)
 }
 
 func (server *Server) getWalletBalance(ctx *gin.Context) {
@@ -36,14 +140,12 @@ func (server *Server) getWalletBalance(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-	cacheErr := server.cache.Set(ctx, fmt.Sprintf("%d", wallet.ID), wallet.Balance.String(), 100*time.Second)
+	cacheErr := server.cacheWalletBalance(ctx, wallet)
 	if cacheErr != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(cacheErr))
 		return
 	}
-	response := util.BuildResponseEntity(true, "", gin.H{
-		"balance": wallet.Balance.String(),
-	})
+	response := util.BuildResponseEntity(true, "", newWalletBalanceResponse(wallet))
 	ctx.JSON(http.StatusOK, response)
 }
 
@@ -52,7 +154,14 @@ type updateWalletRequest struct {
 	// NOTE: I decided not to use Gin binding for this request because
 	// I want to use my function to validate the amount sent in the request
 	// so as to fulfill the reuquirement of the assignment.
-	Amount float64 `json:"amount"`
+	// Amount is sent as a decimal string, e.g. "10.25"
+	Amount    models.Money `json:"amount"`
+	Reference string       `json:"reference"`
+	// Currency is optional, when it is sent it must be the wallet currency
+	Currency string `json:"currency"`
+	// Category is the game category of a debit, it decides the loyalty
+	// points the debit earns
+	Category string `json:"category" binding:"max=32"`
 }
 
 func (server *Server) creditWalletBalance(ctx *gin.Context) {
@@ -71,27 +180,43 @@ func (server *Server) creditWalletBalance(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
 	// get wallet balance
 	wallet, err := server.repo.GetWallet(param.WalletID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	wallet.Balance = wallet.Balance.Add(decimal.NewFromFloat(req.Amount))
-	wallet.UpdatedAt = time.Now()
-	// update wallet balance
-	w, err := server.repo.UpdateWallet(wallet)
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	velocity, err := server.checkVelocity(ctx, wallet, models.ZeroMoney)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	// update wallet balance and record the transaction
+	txn := newTransaction(models.TransactionTypeCredit, req.Reference, "", actor)
+	w, err := server.repo.AdjustBalance(ctx, wallet.ID, req.Amount, database.NonNegativeBalance, txn)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
 		return
 	}
-	cacheErr := server.cache.Set(ctx, fmt.Sprintf("%d", wallet.ID), wallet.Balance.String(), 100*time.Second)
+	server.recordVelocity(velocity)
+	cacheErr := server.cacheWalletBalance(ctx, w)
 	if cacheErr != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(cacheErr))
 		return
 	}
 	response := util.BuildResponseEntity(true, util.WalletCreditSuccess, gin.H{
-		"balance": w.Balance.String(),
+		"balance":  w.Balance.String(),
+		"currency": w.CurrencyCode(),
 	})
 	ctx.JSON(http.StatusOK, response)
 }
@@ -112,83 +237,149 @@ func (server *Server) debitWalletBalance(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
 	// get wallet balance
 	wallet, err := server.repo.GetWallet(param.WalletID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	// check if the debit operation will cause the balance to be negative
-	err = isWalletBalanceGoingBelowZero(wallet.Balance, req.Amount)
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// a restricted player cannot stake
+	if err := server.checkPlayAllowed(wallet); err != nil {
+		ctx.JSON(restrictionErrorStatus(err), errorResponse(err))
+		return
+	}
+	// check if the debit operation will cause the available balance to be
+	// negative, this turns away obvious overdrafts before any row gets locked
+	// while AdjustBalance enforces the same rule atomically
+	err = isWalletBalanceGoingBelowZero(wallet.AvailableBalance(), req.Amount)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	velocity, err := server.checkVelocity(ctx, wallet, req.Amount)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
 
-	// update wallet balance
-	wallet.Balance = wallet.Balance.Sub(decimal.NewFromFloat(req.Amount))
-	wallet.UpdatedAt = time.Now()
-
-	w, err := server.repo.UpdateWallet(wallet)
+	// update wallet balance and record the transaction
+	txn := newTransaction(models.TransactionTypeDebit, req.Reference, "", actor)
+	txn.Category = req.Category
+	w, err := server.repo.AdjustBalance(ctx, wallet.ID, req.Amount.Neg(), database.NonNegativeBalance, txn)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
 		return
 	}
-	cacheErr := server.cache.Set(ctx, fmt.Sprintf("%d", wallet.ID), wallet.Balance.String(), 100*time.Second)
+	server.recordVelocity(velocity)
+	cacheErr := server.cacheWalletBalance(ctx, w)
 	if cacheErr != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(cacheErr))
 		return
 	}
 	response := util.BuildResponseEntity(true, util.WalletDebitSuccess, gin.H{
-		"balance": w.Balance.String(),
+		"balance":  w.Balance.String(),
+		"currency": w.CurrencyCode(),
 	})
 	ctx.JSON(http.StatusOK, response)
 }
 
+// build the journal entry for a balance change, the repository fills in
+// the wallet, amount and balances when it applies the change
+func newTransaction(txnType models.TransactionType, reference, correlationID, actor string) *models.Transaction {
+	return &models.Transaction{
+		UUID:          uuid.New(),
+		Type:          txnType,
+		Reference:     reference,
+		CorrelationID: correlationID,
+		Actor:         actor,
+	}
+}
+
 // utility function to check if amount sent in request is negative
-func validateRequestAmount(amount float64) error {
-	if amount <= 0 {
+func validateRequestAmount(amount models.Money) error {
+	if !amount.IsPositive() {
 		return ErrInvalidAmount
 	}
 	return nil
 }
 
+// utility function to check that the currency sent in a request, if any,
+// is the currency of the wallet. The repository rejects amounts with more
+// decimal places than the wallet currency allows.
+func validateRequestCurrency(code string, wallet *models.Wallet) error {
+	if code != "" && !strings.EqualFold(code, wallet.CurrencyCode()) {
+		return util.ErrCurrencyMismatch
+	}
+	return nil
+}
+
 // utility function to check if the debit operation on any given
 // wallet balance will cause the balance to be negative
-func isWalletBalanceGoingBelowZero(walletBalance decimal.Decimal, debitAmount float64) error {
-	if walletBalance.Sub(decimal.NewFromFloat(debitAmount)).Cmp(decimal.Zero) < 0 {
+func isWalletBalanceGoingBelowZero(walletBalance models.Money, debitAmount models.Money) error {
+	if walletBalance.Sub(debitAmount).IsNegative() {
 		return ErrInsufficientBalance
 	}
 	return nil
 }
 
+// store the wallet balances in the cache read by middleware.CacheMiddleware
+func (server *Server) cacheWalletBalance(ctx context.Context, wallet *models.Wallet) error {
+	data, err := json.Marshal(newWalletBalanceResponse(wallet))
+	if err != nil {
+		return err
+	}
+	return server.cache.Set(ctx, fmt.Sprintf("%d", wallet.ID), string(data), 100*time.Second)
+}
+
 // verify the wallet id belongs to logged in user
 func (server *Server) verifyWalletBelongsToUser(ctx *gin.Context) (*models.Wallet, error) {
-	userID, err := server.getUserIDFromContext(ctx)
+	user, err := server.getUserFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	// get user by email
-	user, err := server.repo.GetUserByEmail(userID)
-	if err != nil {
+	var walletID walletIDUriBinding
+	if err := ctx.ShouldBindUri(&walletID); err != nil {
 		return nil, err
 	}
-	// get wallet by user id
-	wallet, err := server.repo.GetWalletByUserID(user.ID)
-	if err != nil {
-		return nil, err
+	// a user may own one wallet per currency, so look the wallet up by
+	// its id and check who owns it
+	return server.getUserWallet(user, walletID.WalletID)
+}
+
+// get a wallet by id if it belongs to user
+func (server *Server) getUserWallet(user *models.User, walletID int64) (*models.Wallet, error) {
+	wallet, err := server.repo.GetWallet(walletID)
+	if errors.Is(err, util.ErrWalletNotFound) {
+		return nil, ErrWalletNotBelongsToUser
 	}
-	var walletID walletIDUriBinding
-	if err := ctx.ShouldBindUri(&walletID); err != nil {
+	if err != nil {
 		return nil, err
 	}
-	// check if wallet id belongs to user
-	if wallet.ID != walletID.WalletID {
+	if wallet.UserID != user.ID {
 		return nil, ErrWalletNotBelongsToUser
 	}
 	return wallet, nil
 }
 
+// get the logged in user
+func (server *Server) getUserFromContext(ctx *gin.Context) (*models.User, error) {
+	email, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return server.repo.GetUserByEmail(email)
+}
+
 // get user id from context
 func (server *Server) getUserIDFromContext(ctx *gin.Context) (string, error) {
 	payload, ok := ctx.Get("authorization_payload")
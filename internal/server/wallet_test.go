@@ -12,6 +12,7 @@ import (
 
 	"github.com/Oloruntobi1/qgdc/internal/cache"
 	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
 	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
 	"github.com/Oloruntobi1/qgdc/internal/middleware"
 	"github.com/Oloruntobi1/qgdc/internal/models"
@@ -22,13 +23,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_validateRequestAmount(t *testing.T) {
 	type args struct {
-		amount float64
+		amount models.Money
 	}
 	tests := []struct {
 		name    string
@@ -38,21 +38,21 @@ func Test_validateRequestAmount(t *testing.T) {
 		{
 			name: "should return error if amount is less than zero",
 			args: args{
-				amount: -1,
+				amount: models.NewMoneyFromInt(-1),
 			},
 			wantErr: true,
 		},
 		{
 			name: "should return error if amount is zero",
 			args: args{
-				amount: 0,
+				amount: models.ZeroMoney,
 			},
 			wantErr: true,
 		},
 		{
 			name: "should not return error if amount is greater than zero",
 			args: args{
-				amount: 1,
+				amount: models.RequireMoney("0.01"),
 			},
 			wantErr: false,
 		},
@@ -72,8 +72,8 @@ func Test_validateRequestAmount(t *testing.T) {
 
 func Test_isWalletBalanceGoingBelowZero(t *testing.T) {
 	type args struct {
-		walletBalance decimal.Decimal
-		debitAmount   float64
+		walletBalance models.Money
+		debitAmount   models.Money
 	}
 	tests := []struct {
 		name    string
@@ -83,24 +83,24 @@ func Test_isWalletBalanceGoingBelowZero(t *testing.T) {
 		{
 			name: "should return error if debit amount is greater than wallet balance",
 			args: args{
-				walletBalance: decimal.NewFromFloat(100),
-				debitAmount:   200,
+				walletBalance: models.NewMoneyFromInt(100),
+				debitAmount:   models.NewMoneyFromInt(200),
 			},
 			wantErr: true,
 		},
 		{
 			name: "should not return error if debit amount is equal to wallet balance",
 			args: args{
-				walletBalance: decimal.NewFromFloat(100),
-				debitAmount:   100,
+				walletBalance: models.NewMoneyFromInt(100),
+				debitAmount:   models.NewMoneyFromInt(100),
 			},
 			wantErr: false,
 		},
 		{
 			name: "should not return error if debit amount is lesser than wallet balance",
 			args: args{
-				walletBalance: decimal.NewFromFloat(100),
-				debitAmount:   70,
+				walletBalance: models.NewMoneyFromInt(100),
+				debitAmount:   models.NewMoneyFromInt(70),
 			},
 			wantErr: false,
 		},
@@ -142,7 +142,7 @@ func Test_getWalletBalance(t *testing.T) {
 					Return(user, nil)
 
 				mockRepo.EXPECT().
-					GetWalletByUserID(gomock.Eq(user.ID)).
+					GetWallet(gomock.Eq(wallet.ID)).
 					Times(1).
 					Return(wallet, nil)
 
@@ -155,7 +155,7 @@ func Test_getWalletBalance(t *testing.T) {
 				mockCache.EXPECT().
 					Set(gomock.Any(),
 						fmt.Sprintf("%d", wallet.ID),
-						wallet.Balance.String(), 100*time.Second).
+						cachedBalance(t, wallet), 100*time.Second).
 					Return(nil)
 
 			},
@@ -202,7 +202,8 @@ func randomWallet(userID int64) *models.Wallet {
 		ID:        1,
 		UUID:      uuid.New(),
 		UserID:    userID,
-		Balance:   decimal.NewFromFloat(100),
+		Currency:  models.DefaultCurrency,
+		Balance:   models.NewMoneyFromInt(100),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -235,11 +236,66 @@ func buildResponse(wallet *models.Wallet) *util.ResponseEntity {
 		Success: true,
 		Message: "",
 		Data: map[string]interface{}{
-			"balance": wallet.Balance.String(),
+			"currency":          wallet.CurrencyCode(),
+			"balance":           wallet.Balance.String(),
+			"ledger_balance":    wallet.Balance.String(),
+			"available_balance": wallet.AvailableBalance().String(),
+			"real_balance":      wallet.RealBalance().String(),
+			"bonus_balance":     wallet.BonusBalance.String(),
 		},
 	}
 }
 
+// cachedBalance is the value cacheWalletBalance stores for wallet
+func cachedBalance(t *testing.T, wallet *models.Wallet) string {
+	data, err := json.Marshal(newWalletBalanceResponse(wallet))
+	require.NoError(t, err)
+	return string(data)
+}
+
+// eqTransactionMatcher matches a journal entry on the fields set by the
+// handler, the repository fills in the rest when it applies the change
+type eqTransactionMatcher struct {
+	want *models.Transaction
+}
+
+func eqTransaction(want *models.Transaction) gomock.Matcher {
+	return eqTransactionMatcher{want: want}
+}
+
+func (e eqTransactionMatcher) Matches(x interface{}) bool {
+	got, ok := x.(*models.Transaction)
+	if !ok {
+		return false
+	}
+	return got.Type == e.want.Type &&
+		got.Reference == e.want.Reference &&
+		got.CorrelationID == e.want.CorrelationID &&
+		got.Actor == e.want.Actor
+}
+
+func (e eqTransactionMatcher) String() string {
+	return fmt.Sprintf("is transaction %v", e.want)
+}
+
+// eqMoneyMatcher matches amounts by value rather than representation
+type eqMoneyMatcher struct {
+	want models.Money
+}
+
+func eqMoney(want models.Money) gomock.Matcher {
+	return eqMoneyMatcher{want: want}
+}
+
+func (e eqMoneyMatcher) Matches(x interface{}) bool {
+	got, ok := x.(models.Money)
+	return ok && got.Equal(e.want)
+}
+
+func (e eqMoneyMatcher) String() string {
+	return fmt.Sprintf("is amount %s", e.want)
+}
+
 func addAuthorization(
 	t *testing.T,
 	request *http.Request,
@@ -259,7 +315,8 @@ func Test_creditWalletBalance(t *testing.T) {
 	user := randomUser()
 	wallet := randomWallet(user.ID)
 
-	var amount float64 = 200
+	// 0.1 and 0.2 cannot be represented exactly by a float
+	amount := models.RequireMoney("200.30")
 
 	testCases := []struct {
 		name          string
@@ -283,6 +340,8 @@ func Test_creditWalletBalance(t *testing.T) {
 					GetWallet(gomock.Eq(wallet.ID)).
 					Times(1).
 					Return(wallet, nil)
+				stubNoFraud(mockRepo, wallet)
+				stubNoVelocity(mockRepo)
 
 				monkey.Patch(time.Now, func() time.Time {
 					return time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC)
@@ -292,25 +351,32 @@ func Test_creditWalletBalance(t *testing.T) {
 					ID:        wallet.ID,
 					UUID:      wallet.UUID,
 					UserID:    wallet.UserID,
-					Balance:   wallet.Balance.Add(decimal.NewFromFloat(amount)),
+					Balance:   wallet.Balance.Add(amount),
 					CreatedAt: wallet.CreatedAt,
 					UpdatedAt: time.Now(),
 				}
 
+				txn := &models.Transaction{
+					Type:  models.TransactionTypeCredit,
+					Actor: user.Email,
+				}
+
 				mockRepo.EXPECT().
-					UpdateWallet(gomock.Eq(arg)).
+					AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), eqMoney(amount), gomock.Eq(database.NonNegativeBalance), eqTransaction(txn)).
 					Times(1).
-					Return(wallet, nil)
+					Return(arg, nil)
 
 				mockCache.EXPECT().
 					Set(gomock.Any(),
 						fmt.Sprintf("%d", wallet.ID),
-						wallet.Balance.Add(decimal.NewFromFloat(amount)).String(), 100*time.Second).
+						cachedBalance(t, arg), 100*time.Second).
 					Return(nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				response := buildCreditResponse(wallet)
+				response := buildCreditResponse(&models.Wallet{
+					Balance: wallet.Balance.Add(amount),
+				})
 				requireBodyMatchResponse(t, recorder.Body, response)
 			},
 		},
@@ -353,7 +419,8 @@ func buildCreditResponse(wallet *models.Wallet) *util.ResponseEntity {
 		Success: true,
 		Message: util.WalletCreditSuccess,
 		Data: map[string]interface{}{
-			"balance": wallet.Balance.String(),
+			"balance":  wallet.Balance.String(),
+			"currency": wallet.CurrencyCode(),
 		},
 	}
 }
@@ -362,12 +429,12 @@ func Test_debitWalletBalance(t *testing.T) {
 	user := randomUser()
 	wallet := randomWallet(user.ID)
 
-	var amount float64 = 200
+	amount := models.RequireMoney("200.10")
 
 	testCases := []struct {
 		name          string
 		walletID      int64
-		addToBalance  float64
+		addToBalance  models.Money
 		body          gin.H
 		setUpAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
@@ -376,7 +443,7 @@ func Test_debitWalletBalance(t *testing.T) {
 		{
 			name:         "should debit wallet balance",
 			walletID:     wallet.ID,
-			addToBalance: 500,
+			addToBalance: models.NewMoneyFromInt(500),
 			body: gin.H{
 				"amount": amount,
 			},
@@ -388,6 +455,9 @@ func Test_debitWalletBalance(t *testing.T) {
 					GetWallet(gomock.Eq(wallet.ID)).
 					Times(1).
 					Return(wallet, nil)
+				stubNoFraud(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
 
 				monkey.Patch(time.Now, func() time.Time {
 					return time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC)
@@ -397,35 +467,72 @@ func Test_debitWalletBalance(t *testing.T) {
 					ID:        wallet.ID,
 					UUID:      wallet.UUID,
 					UserID:    wallet.UserID,
-					Balance:   wallet.Balance.Sub(decimal.NewFromFloat(amount)),
+					Balance:   wallet.Balance.Sub(amount),
 					CreatedAt: wallet.CreatedAt,
 					UpdatedAt: time.Now(),
 				}
 
+				txn := &models.Transaction{
+					Type:  models.TransactionTypeDebit,
+					Actor: user.Email,
+				}
+
 				mockRepo.EXPECT().
-					UpdateWallet(gomock.Eq(arg)).
+					AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), eqMoney(amount.Neg()), gomock.Eq(database.NonNegativeBalance), eqTransaction(txn)).
 					Times(1).
-					Return(wallet, nil)
+					Return(arg, nil)
 
 				mockCache.EXPECT().
 					Set(gomock.Any(),
 						fmt.Sprintf("%d", wallet.ID),
-						wallet.Balance.Sub(decimal.NewFromFloat(amount)).String(), 100*time.Second).
+						cachedBalance(t, arg), 100*time.Second).
 					Return(nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				response := buildDebitResponse(wallet)
+				response := buildDebitResponse(&models.Wallet{
+					Balance: wallet.Balance.Sub(amount),
+				})
 				requireBodyMatchResponse(t, recorder.Body, response)
 			},
 		},
+		{
+			name:     "should reject a debit over a wager limit",
+			walletID: wallet.ID,
+			body: gin.H{
+				"amount": amount,
+			},
+			setUpAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().
+					GetWallet(gomock.Eq(wallet.ID)).
+					Times(1).
+					Return(wallet, nil)
+				stubNoFraud(mockRepo, wallet)
+				stubPlayer(mockRepo, user)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					AdjustBalance(gomock.Any(), gomock.Eq(wallet.ID), eqMoney(amount.Neg()), gomock.Eq(database.NonNegativeBalance), gomock.Any()).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: daily limit of 100", util.ErrWagerLimitExceeded))
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+				require.Equal(t, ErrorCodeWagerLimitExceeded, body["code"])
+			},
+		},
 	}
 
 	for i := range testCases {
 		tt := testCases[i]
 		t.Run(tt.name, func(t *testing.T) {
 
-			wallet.Balance = wallet.Balance.Add(decimal.NewFromFloat(tt.addToBalance))
+			wallet.Balance = wallet.Balance.Add(tt.addToBalance)
 
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
@@ -460,7 +567,8 @@ func buildDebitResponse(wallet *models.Wallet) *util.ResponseEntity {
 		Success: true,
 		Message: util.WalletDebitSuccess,
 		Data: map[string]interface{}{
-			"balance": wallet.Balance.String(),
+			"balance":  wallet.Balance.String(),
+			"currency": wallet.CurrencyCode(),
 		},
 	}
 }
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type withdrawalIDUriBinding struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type walletWithdrawalIDUriBinding struct {
+	WalletID     int64 `uri:"wallet_id" binding:"required,min=1"`
+	WithdrawalID int64 `uri:"withdrawal_id" binding:"required,min=1"`
+}
+
+type withdrawalResponse struct {
+	ID            int64       `json:"id"`
+	UUID          uuid.UUID   `json:"uuid"`
+	Reference     string      `json:"reference"`
+	WalletID      int64       `json:"wallet_id"`
+	Currency      string      `json:"currency"`
+	Amount        string      `json:"amount"`
+	Fee           feeResponse `json:"fee"`
+	Destination   string      `json:"destination"`
+	Status        string      `json:"status"`
+	Reason        string      `json:"reason,omitempty"`
+	TransactionID int64       `json:"transaction_id,omitempty"`
+	Actor         string      `json:"actor"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+func newWithdrawalResponse(w *models.Withdrawal) withdrawalResponse {
+	return withdrawalResponse{
+		ID:            w.ID,
+		UUID:          w.UUID,
+		Reference:     w.Reference,
+		WalletID:      w.WalletID,
+		Currency:      w.Currency,
+		Amount:        w.Amount.String(),
+		Fee:           newFeeResponse(w.Amount, w.Fee),
+		Destination:   w.Destination,
+		Status:        string(w.Status),
+		Reason:        w.Reason,
+		TransactionID: w.TransactionID,
+		Actor:         w.Actor,
+		CreatedAt:     w.CreatedAt,
+		UpdatedAt:     w.UpdatedAt,
+	}
+}
+
+type withdrawalTransitionResponse struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWithdrawalTransitionResponse(t *models.WithdrawalTransition) withdrawalTransitionResponse {
+	return withdrawalTransitionResponse{
+		From:      string(t.From),
+		To:        string(t.To),
+		Actor:     t.Actor,
+		Reason:    t.Reason,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+type requestWithdrawalRequest struct {
+	Amount models.Money `json:"amount"`
+	// Currency is optional, when it is sent it must be the wallet currency
+	Currency    string `json:"currency"`
+	Destination string `json:"destination" binding:"required,max=255"`
+}
+
+type listWithdrawalsRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=requested under_review approved rejected paid failed"`
+}
+
+type withdrawalReasonRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// requestWithdrawal asks to withdraw from a wallet of the logged in user.
+// The amount is held at once and only leaves the wallet once an admin
+// reviewed and approved the withdrawal and it was paid out. A restricted
+// player can still take their money out.
+func (server *Server) requestWithdrawal(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req requestWithdrawalRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// check if amount sent in request is negative
+	if err := validateRequestAmount(req.Amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := validateRequestCurrency(req.Currency, wallet); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	// the fee is worked out now so the player knows what will be paid out,
+	// it is only charged with the payout
+	fee, feeWalletID, err := server.quoteFee(models.FeeOperationWithdrawal, wallet.CurrencyCode(), req.Amount)
+	if err != nil {
+		ctx.JSON(feeErrorStatus(err), errorResponse(err))
+		return
+	}
+	// the amount counts towards the velocity rule when it is held, it is
+	// the player who asks for it
+	velocity, err := server.checkVelocity(ctx, wallet, req.Amount)
+	if err != nil {
+		ctx.JSON(postingErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	defer server.releaseVelocity(ctx, velocity)
+	now := time.Now()
+	id := uuid.New()
+	withdrawal := &models.Withdrawal{
+		UUID:        id,
+		Reference:   id.String(),
+		WalletID:    wallet.ID,
+		UserID:      wallet.UserID,
+		Currency:    wallet.CurrencyCode(),
+		Amount:      req.Amount,
+		Fee:         fee,
+		FeeWalletID: feeWalletID,
+		Destination: req.Destination,
+		Actor:       actor,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	held, err := server.repo.RequestWithdrawal(ctx, withdrawal)
+	if err != nil {
+		ctx.JSON(withdrawalErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	server.recordVelocity(velocity)
+	if err := server.cacheWalletBalance(ctx, held); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	response := util.BuildResponseEntity(true, util.WithdrawalRequestSuccess, gin.H{
+		"withdrawal": newWithdrawalResponse(withdrawal),
+		"balance":    newWalletBalanceResponse(held),
+	})
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// getWalletWithdrawal returns a withdrawal from a wallet of the logged in
+// user with its history
+func (server *Server) getWalletWithdrawal(ctx *gin.Context) {
+	wallet, err := server.verifyWalletBelongsToUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var param walletWithdrawalIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	withdrawal, err := server.repo.GetWithdrawal(param.WithdrawalID)
+	// a withdrawal from another wallet is reported as missing rather than
+	// forbidden
+	if errors.Is(err, util.ErrWithdrawalNotFound) || (err == nil && withdrawal.WalletID != wallet.ID) {
+		ctx.JSON(http.StatusNotFound, errorResponse(util.ErrWithdrawalNotFound))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.respondWithdrawal(ctx, withdrawal)
+}
+
+// getWithdrawals lists the withdrawals with the status in the query, oldest
+// first, e.g. the ones waiting for a review
+func (server *Server) getWithdrawals(ctx *gin.Context) {
+	var req listWithdrawalsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	withdrawals, err := server.repo.GetWithdrawals(models.WithdrawalStatus(req.Status))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	data := make([]withdrawalResponse, 0, len(withdrawals))
+	for _, w := range withdrawals {
+		data = append(data, newWithdrawalResponse(w))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"withdrawals": data,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) getWithdrawal(ctx *gin.Context) {
+	var param withdrawalIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	withdrawal, err := server.repo.GetWithdrawal(param.ID)
+	if err != nil {
+		ctx.JSON(withdrawalErrorStatus(err), errorResponse(err))
+		return
+	}
+	server.respondWithdrawal(ctx, withdrawal)
+}
+
+// respondWithdrawal answers with the withdrawal and its history
+func (server *Server) respondWithdrawal(ctx *gin.Context, withdrawal *models.Withdrawal) {
+	transitions, err := server.repo.GetWithdrawalTransitions(withdrawal.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	history := make([]withdrawalTransitionResponse, 0, len(transitions))
+	for _, t := range transitions {
+		history = append(history, newWithdrawalTransitionResponse(t))
+	}
+	response := util.BuildResponseEntity(true, "", gin.H{
+		"withdrawal":  newWithdrawalResponse(withdrawal),
+		"transitions": history,
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// reviewWithdrawal takes a requested withdrawal up for review
+func (server *Server) reviewWithdrawal(ctx *gin.Context) {
+	server.moveWithdrawal(ctx, models.WithdrawalStatusUnderReview, "")
+}
+
+// approveWithdrawal lets a reviewed withdrawal be paid out
+func (server *Server) approveWithdrawal(ctx *gin.Context) {
+	server.moveWithdrawal(ctx, models.WithdrawalStatusApproved, "")
+}
+
+// rejectWithdrawal refuses a reviewed withdrawal and gives the held amount
+// back
+func (server *Server) rejectWithdrawal(ctx *gin.Context) {
+	var req withdrawalReasonRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	server.moveWithdrawal(ctx, models.WithdrawalStatusRejected, req.Reason)
+}
+
+// payWithdrawal records that an approved withdrawal was paid out and
+// debits the held amount
+func (server *Server) payWithdrawal(ctx *gin.Context) {
+	server.moveWithdrawal(ctx, models.WithdrawalStatusPaid, "")
+}
+
+// failWithdrawal records that the payout of an approved withdrawal did not
+// go through and gives the held amount back
+func (server *Server) failWithdrawal(ctx *gin.Context) {
+	var req withdrawalReasonRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	server.moveWithdrawal(ctx, models.WithdrawalStatusFailed, req.Reason)
+}
+
+// moveWithdrawal moves the withdrawal in the uri to status on behalf of the
+// logged in admin
+func (server *Server) moveWithdrawal(ctx *gin.Context, status models.WithdrawalStatus, reason string) {
+	var param withdrawalIDUriBinding
+	if err := ctx.ShouldBindUri(&param); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, err := server.getUserIDFromContext(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	result, err := server.repo.MoveWithdrawal(ctx, &database.WithdrawalChange{
+		WithdrawalID: param.ID,
+		Status:       status,
+		Actor:        actor,
+		Reason:       reason,
+	})
+	if err != nil {
+		ctx.JSON(withdrawalErrorStatus(err), postingErrorResponse(err))
+		return
+	}
+	data := gin.H{
+		"withdrawal": newWithdrawalResponse(result.Withdrawal),
+		"transition": newWithdrawalTransitionResponse(result.Transition),
+	}
+	if result.Wallet != nil {
+		if err := server.cacheWalletBalance(ctx, result.Wallet); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		data["balance"] = newWalletBalanceResponse(result.Wallet)
+	}
+	if result.FeeWallet != nil {
+		if err := server.cacheWalletBalance(ctx, result.FeeWallet); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, util.BuildResponseEntity(true, util.WithdrawalUpdateSuccess, data))
+}
+
+func withdrawalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrWithdrawalNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrWithdrawalTransition):
+		return http.StatusConflict
+	default:
+		return postingErrorStatus(err)
+	}
+}
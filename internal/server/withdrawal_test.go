@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockcache "github.com/Oloruntobi1/qgdc/internal/cache/mock"
+	"github.com/Oloruntobi1/qgdc/internal/database"
+	mockdb "github.com/Oloruntobi1/qgdc/internal/database/mock"
+	"github.com/Oloruntobi1/qgdc/internal/middleware"
+	"github.com/Oloruntobi1/qgdc/internal/models"
+	"github.com/Oloruntobi1/qgdc/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_requestWithdrawal(t *testing.T) {
+	user := randomUser()
+	wallet := randomWallet(user.ID)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should hold the amount of the withdrawal",
+			body: gin.H{"amount": "40", "destination": "GB33BUKB20201555555555"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFee(mockRepo, models.FeeOperationWithdrawal)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					RequestWithdrawal(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, w *models.Withdrawal) (*models.Wallet, error) {
+						require.Equal(t, wallet.ID, w.WalletID)
+						require.True(t, w.Amount.Equal(models.NewMoneyFromInt(40)))
+						require.Equal(t, user.Email, w.Actor)
+						w.Status = models.WithdrawalStatusRequested
+						held := *wallet
+						held.HeldBalance = models.NewMoneyFromInt(40)
+						return &held, nil
+					})
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"requested"`)
+			},
+		},
+		{
+			name: "should reject a withdrawal beyond the real money not held",
+			body: gin.H{"amount": "400", "destination": "GB33BUKB20201555555555"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				stubNoFee(mockRepo, models.FeeOperationWithdrawal)
+				stubNoVelocity(mockRepo)
+				mockRepo.EXPECT().
+					RequestWithdrawal(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrInsufficientFunds)
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "should require a destination",
+			body: gin.H{"amount": "40"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				stubWalletOwner(mockRepo, user, wallet)
+				mockRepo.EXPECT().RequestWithdrawal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+			stubNoFraud(repo, wallet)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/wallets/%d/withdrawals", wallet.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, user.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func Test_failWithdrawal(t *testing.T) {
+	admin := randomUser()
+	admin.IsAdmin = true
+	withdrawal := &models.Withdrawal{
+		ID:       1,
+		WalletID: 1,
+		Currency: models.DefaultCurrency,
+		Amount:   models.NewMoneyFromInt(40),
+		Status:   models.WithdrawalStatusFailed,
+		Reason:   "account closed",
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "should give the held amount back",
+			body: gin.H{"reason": "account closed"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					MoveWithdrawal(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, c *database.WithdrawalChange) (*database.WithdrawalResult, error) {
+						require.Equal(t, withdrawal.ID, c.WithdrawalID)
+						require.Equal(t, models.WithdrawalStatusFailed, c.Status)
+						require.Equal(t, admin.Email, c.Actor)
+						require.Equal(t, "account closed", c.Reason)
+						return &database.WithdrawalResult{
+							Withdrawal: withdrawal,
+							Transition: &models.WithdrawalTransition{
+								From:  models.WithdrawalStatusApproved,
+								To:    models.WithdrawalStatusFailed,
+								Actor: admin.Email,
+							},
+							Wallet: randomWallet(2),
+						}, nil
+					})
+				mockCache.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"to":"failed"`)
+			},
+		},
+		{
+			name: "should reject a withdrawal that was not approved",
+			body: gin.H{"reason": "account closed"},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().
+					MoveWithdrawal(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, util.ErrWithdrawalTransition)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "should require a reason",
+			body: gin.H{},
+			buildStubs: func(mockRepo *mockdb.MockRepository, mockCache *mockcache.MockCacher) {
+				mockRepo.EXPECT().GetUserByEmail(gomock.Eq(admin.Email)).Times(1).Return(admin, nil)
+				mockRepo.EXPECT().MoveWithdrawal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tt := testCases[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			cache := mockcache.NewMockCacher(ctrl)
+
+			tt.buildStubs(repo, cache)
+
+			server, err := NewServer(repo, cache, util.RandomString(32))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/api/v1/withdrawals/%d/fail", withdrawal.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, middleware.AuthorizationTypeBearer, admin.Email, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 var requiredRuntimeEnv = []string{
@@ -95,3 +96,13 @@ func SetEnvFromFile(file string) {
 		os.Setenv(parts[0], parts[1])
 	}
 }
+
+// GetDurationEnv returns the duration set in the env var key, falling back
+// to fallback when it is unset or not a valid duration
+func GetDurationEnv(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
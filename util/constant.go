@@ -1,12 +1,97 @@
 package util
 
 const (
+	// wallet successfully created
+	WalletCreateSuccess = "Wallet successfully created"
 	// wallet successfully credited
 	WalletCreditSuccess = "Wallet successfully credited"
 	// wallet successfully debited
 	WalletDebitSuccess = "Wallet successfully debited"
+	// funds successfully transferred between wallets
+	WalletTransferSuccess = "Funds successfully transferred"
+	// transaction successfully reversed or refunded
+	TransactionReverseSuccess = "Transaction successfully reversed"
+	// funds successfully converted between currencies
+	ConversionSuccess = "Funds successfully converted"
+	// funds successfully put on hold
+	HoldCreateSuccess = "Funds successfully held"
+	// held funds successfully captured
+	HoldCaptureSuccess = "Hold successfully captured"
+	// held funds successfully released
+	HoldReleaseSuccess = "Hold successfully released"
+	// stake of a game round successfully placed
+	GameBetSuccess = "Bet successfully placed"
+	// payout of a game round successfully credited
+	GameWinSuccess = "Win successfully paid"
+	// stake of a game round successfully given back
+	GameRollbackSuccess = "Bet successfully rolled back"
+	// bonus funds successfully granted
+	BonusGrantSuccess = "Bonus successfully granted"
+	// bonus funds successfully forfeited
+	BonusForfeitSuccess = "Bonus successfully forfeited"
+	// gaming limit set, raises only apply after the cooling-off period
+	GamingLimitSetSuccess = "Gaming limit successfully set"
+	// gaming limit removal scheduled for the end of the cooling-off period
+	GamingLimitRemoveSuccess = "Gaming limit will be lifted after the cooling-off period"
+	// time out or self exclusion successfully started
+	RestrictionSuccess = "Account successfully restricted"
+	// restriction successfully lifted by an admin
+	RestrictionLiftSuccess = "Restriction successfully lifted"
+	// tournament successfully opened for entries
+	TournamentCreateSuccess = "Tournament successfully created"
+	// entry fee successfully paid into the tournament escrow
+	TournamentEnterSuccess = "Tournament successfully entered"
+	// prize pool successfully paid out
+	TournamentCloseSuccess = "Tournament successfully closed"
+	// entry fees successfully refunded
+	TournamentCancelSuccess = "Tournament successfully cancelled"
+	// jackpot pool successfully created and seeded
+	JackpotCreateSuccess = "Jackpot successfully created"
+	// stakes of both players successfully moved into escrow
+	MatchStartSuccess = "Match successfully started"
+	// pot successfully paid to the winner less the rake
+	MatchSettleSuccess = "Match successfully settled"
+	// stakes successfully refunded after a draw
+	MatchDrawSuccess = "Match successfully drawn"
+	// stakes successfully refunded after an abort
+	MatchAbortSuccess = "Match successfully aborted"
+	// deposit successfully started with the payment provider
+	DepositCreateSuccess = "Deposit successfully created"
+	// webhook of the payment provider successfully applied to the deposit
+	DepositConfirmSuccess = "Deposit successfully updated"
+	// payment of a deposit refused by a deposit limit successfully
+	// recorded as refunded
+	DepositRefundSuccess = "Deposit successfully refunded"
+	// withdrawal successfully requested and its amount held
+	WithdrawalRequestSuccess = "Withdrawal successfully requested"
+	// withdrawal successfully moved to another status by an admin
+	WithdrawalUpdateSuccess = "Withdrawal successfully updated"
+	// voucher code successfully issued
+	VoucherCreateSuccess = "Voucher successfully created"
+	// voucher amount successfully credited to the wallet
+	VoucherRedeemSuccess = "Voucher successfully redeemed"
+	// fee schedule of an operation successfully set
+	FeeScheduleSetSuccess = "Fee schedule successfully set"
+	// fee schedule of an operation successfully removed
+	FeeScheduleRemoveSuccess = "Fee schedule successfully removed"
+	// velocity rule of a wallet or tier successfully set
+	VelocityRuleSetSuccess = "Velocity rule successfully set"
+	// velocity rule successfully removed
+	VelocityRuleRemoveSuccess = "Velocity rule successfully removed"
+	// wallet operation held back for review by the fraud rules
+	FraudReviewPending = "Operation is under review"
+	// flagged operation successfully approved and applied
+	FraudReviewApproveSuccess = "Operation successfully approved"
+	// flagged operation successfully denied
+	FraudReviewDenySuccess = "Operation successfully denied"
+	// loyalty earning rule of a game category successfully set
+	LoyaltyRuleSetSuccess = "Loyalty rule successfully set"
+	// loyalty points successfully turned into real money
+	LoyaltyRedeemSuccess = "Loyalty points successfully redeemed"
 	// DefaultPage is the default page number for pagination
 	DefaultPage = 1
 	// DefaultPageSize is the default page size for pagination
 	DefaultPageSize = 10
+	// MaxPageSize is the largest page size a client may request
+	MaxPageSize = 100
 )
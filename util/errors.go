@@ -7,6 +7,88 @@ import (
 var (
 	ErrUserNotFound   = fmt.Errorf("user not found")
 	ErrWalletNotFound = fmt.Errorf("wallet not found")
+	ErrWalletExists   = fmt.Errorf("user already has a wallet in this currency")
+
+	ErrUserNotRestricted    = fmt.Errorf("user has no restriction in force")
+	ErrRestrictionShortened = fmt.Errorf("a restriction in force can only be extended")
+
+	ErrCurrencyMismatch       = fmt.Errorf("currency does not match the wallet currency")
+	ErrInvalidAmountPrecision = fmt.Errorf("amount has more decimal places than the currency allows")
+
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+	ErrInsufficientFunds   = fmt.Errorf("insufficient funds")
+
+	ErrTransactionNotReversible   = fmt.Errorf("only credits and debits can be reversed")
+	ErrTransactionAlreadyReversed = fmt.Errorf("transaction has already been reversed")
+	ErrReversalExceedsTransaction = fmt.Errorf("reversal amount exceeds the amount left to reverse")
+
+	ErrHoldNotFound       = fmt.Errorf("hold not found")
+	ErrHoldNotActive      = fmt.Errorf("hold is no longer active")
+	ErrCaptureExceedsHold = fmt.Errorf("capture amount exceeds the held amount")
+
+	ErrQuoteNotFound = fmt.Errorf("quote not found")
+	ErrQuoteExpired  = fmt.Errorf("quote has expired")
+	ErrQuoteExecuted = fmt.Errorf("quote has already been executed")
+
+	ErrGameRoundNotFound         = fmt.Errorf("game round not found")
+	ErrGameRoundClosed           = fmt.Errorf("game round is no longer open")
+	ErrGameRoundWalletMismatch   = fmt.Errorf("game round belongs to another wallet")
+	ErrGameRoundActionNotFound   = fmt.Errorf("bet to roll back not found")
+	ErrGameRoundActionConflict   = fmt.Errorf("provider transaction id was already used for a different action")
+	ErrGameRoundBetAlreadyRolled = fmt.Errorf("bet has already been rolled back")
+
+	ErrBonusNotFound  = fmt.Errorf("bonus not found")
+	ErrBonusNotActive = fmt.Errorf("bonus is no longer active")
+
+	ErrTournamentNotFound       = fmt.Errorf("tournament not found")
+	ErrTournamentNotOpen        = fmt.Errorf("tournament is no longer open")
+	ErrTournamentAlreadyEntered = fmt.Errorf("wallet has already entered the tournament")
+	ErrTournamentPlacements     = fmt.Errorf("placements must list distinct entrants, as many as the payout table or the entrants allow")
+
+	ErrJackpotNotFound    = fmt.Errorf("jackpot pool not found")
+	ErrJackpotHitConflict = fmt.Errorf("jackpot hit transaction id was already used for something else")
+
+	ErrVoucherNotFound        = fmt.Errorf("voucher not found")
+	ErrVoucherCodeExists      = fmt.Errorf("a voucher with this code already exists")
+	ErrVoucherExpired         = fmt.Errorf("voucher has expired")
+	ErrVoucherRedeemed        = fmt.Errorf("voucher has already been redeemed")
+	ErrVoucherBudgetExhausted = fmt.Errorf("voucher budget is exhausted")
+
+	ErrMatchNotFound = fmt.Errorf("match not found")
+	ErrMatchConflict = fmt.Errorf("match reference was already used for a different match")
+	ErrMatchFinished = fmt.Errorf("match has already finished with another outcome")
+	ErrMatchWinner   = fmt.Errorf("winner must be one of the players of the match")
+
+	ErrDepositNotFound = fmt.Errorf("deposit not found")
+	ErrDepositFinished = fmt.Errorf("deposit has already completed with another status")
+	ErrDepositMismatch = fmt.Errorf("payment does not match the amount and currency of the deposit")
+	ErrDepositNoRefund = fmt.Errorf("deposit is not waiting for its payment to be refunded")
+
+	ErrWithdrawalNotFound   = fmt.Errorf("withdrawal not found")
+	ErrWithdrawalTransition = fmt.Errorf("withdrawal cannot move to this status from its current status")
+
+	ErrFeeScheduleNotFound = fmt.Errorf("fee schedule not found")
+
+	ErrVelocityRuleNotFound  = fmt.Errorf("velocity rule not found")
+	ErrMaxDebitExceeded      = fmt.Errorf("single debit limit exceeded")
+	ErrDailyDebitExceeded    = fmt.Errorf("daily debit limit exceeded")
+	ErrOperationRateExceeded = fmt.Errorf("operation rate limit exceeded")
+
+	ErrFraudReviewNotFound   = fmt.Errorf("fraud review not found")
+	ErrFraudReviewResolved   = fmt.Errorf("fraud review has already been approved or denied")
+	ErrFraudReviewTransition = fmt.Errorf("fraud review cannot move to this status from its current status")
+
+	ErrLoyaltyAccountNotFound    = fmt.Errorf("loyalty account not found")
+	ErrInsufficientLoyaltyPoints = fmt.Errorf("not enough loyalty points")
+	ErrLoyaltyRedemptionTooSmall = fmt.Errorf("points are worth less than the smallest unit of the currency")
+
+	ErrGamingLimitNotFound  = fmt.Errorf("gaming limit not found")
+	ErrDepositLimitExceeded = fmt.Errorf("deposit limit exceeded")
+	ErrLossLimitExceeded    = fmt.Errorf("loss limit exceeded")
+	ErrWagerLimitExceeded   = fmt.Errorf("wager limit exceeded")
+
+	ErrIdempotencyKeyNotFound = fmt.Errorf("idempotency key not found")
+	ErrIdempotencyKeyExists   = fmt.Errorf("idempotency key already exists")
 )
 
 type DBError struct {